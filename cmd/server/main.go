@@ -1,12 +1,14 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"keyvalue/internal/server"
+	"keyvalue/internal/usecase/storage"
 )
 
 const (
@@ -15,9 +17,16 @@ const (
 )
 
 func main() {
+	databases := flag.Int("databases", storage.DefaultDatabases, "число логических баз")
+	flag.Parse()
+	if *databases < 1 {
+		log.Fatalf("Invalid number of databases: %d", *databases)
+	}
+
 	srv := server.NewServer(server.Config{
 		Port:        defaultPort,
 		AofFilename: aofPath,
+		Databases:   *databases,
 	})
 
 	go func() {
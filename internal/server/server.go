@@ -10,36 +10,55 @@ import (
 	"log"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 )
 
+// Config Databases — число логических баз, 0 означает storage.DefaultDatabases
 type Config struct {
 	Port        int
 	AofFilename string
+	Databases   int
 }
 
 type Server struct {
-	config      Config
-	listener    net.Listener
-	storage     *storage.Storage
-	commandExec *command.CommandExecutor
-	logger      *log.Logger
-	aof         *aof.Aof
-	shutdown    chan struct{}
-	wg          sync.WaitGroup
-	conns       sync.Map
+	config   Config
+	listener net.Listener
+	dbs      storage.Databases
+	// executors исполнители команд по номеру базы
+	executors []*command.CommandExecutor
+	logger    *log.Logger
+	aof       *aof.Aof
+	shutdown  chan struct{}
+	wg        sync.WaitGroup
+	conns     sync.Map
+
+	// writeMu упорядочивает модифицирующие команды: выполнение, перевод в
+	// детерминированный вид и запись в AOF идут под ней, поэтому AOF хранит
+	// команды в порядке применения. aofDB — база, выбранная последней
+	// командой SELECT в AOF.
+	writeMu sync.Mutex
+	aofDB   int
 }
 
 func NewServer(cfg Config) *Server {
-	stor := storage.NewStorage()
-	return &Server{
-		config:      cfg,
-		storage:     stor,
-		commandExec: command.NewCommandExecutor(stor),
-		logger:      log.New(os.Stdout, "[kv-server] ", log.Ldate|log.Ltime|log.Lshortfile),
-		shutdown:    make(chan struct{}),
+	dbs := storage.NewDatabases(cfg.Databases)
+	executors := make([]*command.CommandExecutor, len(dbs))
+	for i := range dbs {
+		executors[i] = command.NewCommandExecutor(dbs, i)
+	}
+	s := &Server{
+		config:    cfg,
+		dbs:       dbs,
+		executors: executors,
+		logger:    log.New(os.Stdout, "[kv-server] ", log.Ldate|log.Ltime|log.Lshortfile),
+		shutdown:  make(chan struct{}),
+	}
+	for _, exec := range executors {
+		exec.SetWriteLock(&s.writeMu)
 	}
+	return s
 }
 
 func (s *Server) Start() error {
@@ -54,11 +73,48 @@ func (s *Server) Start() error {
 		return fmt.Errorf("failed to create AOF: %w", err)
 	}
 
+	// Запись, оборванная сбоем в конце файла, отбрасывается, как при
+	// aof-load-truncated в Redis, чтобы сервер мог запуститься
+	dropped, err := s.aof.TruncatePartial()
+	if err != nil {
+		return fmt.Errorf("failed to read AOF: %w", err)
+	}
+	if dropped > 0 {
+		s.logger.Printf("WARNING: AOF ends with a partial record, truncated %d bytes", dropped)
+	}
+
+	upgraded, err := s.aof.Upgrade()
+	if err != nil {
+		return fmt.Errorf("failed to upgrade AOF: %w", err)
+	}
+	if upgraded {
+		s.logger.Printf("AOF converted from legacy JSON to RESP, backup saved to %s.json.bak", s.config.AofFilename)
+	}
+
+	// Команды из AOF выполняются в базе, выбранной последним SELECT. SELECT
+	// несуществующей базы прерывает загрузку: иначе следующие записи попали
+	// бы в чужую базу.
+	var replayErr error
 	if err := s.aof.Read(func(value resp.Value) {
-		s.commandExec.Execute(value)
+		if replayErr != nil {
+			return
+		}
+		if len(value.Array) > 0 && strings.EqualFold(value.Array[0].Bulk, "SELECT") {
+			db, ok := s.selectedDB(value)
+			if !ok {
+				replayErr = fmt.Errorf("invalid %q with %d databases", commandToString(value), len(s.executors))
+				return
+			}
+			s.aofDB = db
+			return
+		}
+		s.executors[s.aofDB].Execute(value)
 	}); err != nil {
 		return fmt.Errorf("failed to read AOF: %w", err)
 	}
+	if replayErr != nil {
+		return fmt.Errorf("failed to read AOF: %w", replayErr)
+	}
 
 	s.logger.Printf("Server started on port %d", s.config.Port)
 	s.wg.Add(1)
@@ -80,6 +136,7 @@ func (s *Server) Stop() {
 	if s.aof != nil {
 		s.aof.Close()
 	}
+	s.dbs.Stop()
 	s.wg.Wait()
 	s.logger.Println("Server stopped gracefully")
 }
@@ -135,6 +192,9 @@ func (s *Server) handleConnection(conn net.Conn) {
 	reader := resp.NewReader(conn)
 	writer := resp.NewWriter(conn)
 
+	// db — база, выбранная клиентом командой SELECT
+	db := 0
+
 	for {
 		select {
 		case <-s.shutdown:
@@ -160,7 +220,10 @@ func (s *Server) handleConnection(conn net.Conn) {
 			s.logger.Printf("Command from %s: %s", remoteAddr, cmdStr)
 
 			// Обработка команды
-			result := s.processCommand(cmd)
+			result := s.processCommand(db, cmd)
+			if selected, ok := s.selectedDB(cmd); ok && result.Typ != "error" {
+				db = selected
+			}
 
 			if err := writer.Write(result); err != nil {
 				s.logger.Printf("Failed to write response to %s: %v", remoteAddr, err)
@@ -170,23 +233,94 @@ func (s *Server) handleConnection(conn net.Conn) {
 	}
 }
 
-func (s *Server) processCommand(cmd resp.Value) resp.Value {
+func (s *Server) processCommand(db int, cmd resp.Value) resp.Value {
 	command := strings.ToUpper(cmd.Array[0].Bulk)
+	exec := s.executors[db]
+	if !isWriteCommand(command) {
+		return exec.Execute(cmd)
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	result := exec.Execute(cmd)
 
-	// Записываем в AOF только модифицирующие команды
-	if isWriteCommand(command) {
-		if err := s.aof.Write(cmd); err != nil {
+	// Записываем в AOF только успешно выполненные модифицирующие команды.
+	// Запись идёт после выполнения, чтобы команды со случайным результатом
+	// (например, SPOP) попали в AOF в детерминированном виде.
+	if result.Typ != "error" {
+		if err := s.appendAof(db, exec.Propagate(cmd, result)); err != nil {
 			s.logger.Printf("AOF write error: %v", err)
 			return resp.Value{Typ: "error", Str: "ERR internal error"}
 		}
 	}
 
-	return s.commandExec.Execute(cmd)
+	return result
+}
+
+// appendAof записывает команды базы db в AOF, предваряя их SELECT,
+// если предыдущая запись относилась к другой базе. Вызывается под writeMu.
+func (s *Server) appendAof(db int, entries []resp.Value) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if db != s.aofDB {
+		selectCmd := resp.Value{Typ: "array", Array: []resp.Value{
+			{Typ: "bulk", Bulk: "SELECT"},
+			{Typ: "bulk", Bulk: strconv.Itoa(db)},
+		}}
+		if err := s.aof.Write(selectCmd); err != nil {
+			return err
+		}
+		s.aofDB = db
+	}
+	for _, entry := range entries {
+		if err := s.aof.Write(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectedDB возвращает номер базы, если cmd — команда SELECT с корректным номером
+func (s *Server) selectedDB(cmd resp.Value) (int, bool) {
+	if len(cmd.Array) != 2 || !strings.EqualFold(cmd.Array[0].Bulk, "SELECT") {
+		return 0, false
+	}
+	db, err := strconv.Atoi(cmd.Array[1].Bulk)
+	if err != nil || db < 0 || db >= len(s.executors) {
+		return 0, false
+	}
+	return db, true
 }
 
 func isWriteCommand(cmd string) bool {
 	switch cmd {
-	case "SET", "HSET", "DEL", "HDEL", "HDELALL", "EXPIRE":
+	case "SET", "HSET", "DEL", "HDEL", "HDELALL",
+		"EXPIRE", "PEXPIRE", "EXPIREAT", "PEXPIREAT", "PERSIST",
+		"SETNX", "SETEX", "PSETEX", "GETSET", "GETDEL", "GETEX",
+		"MSET", "MSETNX", "APPEND", "SETRANGE",
+		"INCR", "DECR", "INCRBY", "DECRBY", "INCRBYFLOAT",
+		"SETBIT", "BITOP", "BITFIELD",
+		"PFADD", "PFMERGE",
+		"HSETNX", "HINCRBY", "HINCRBYFLOAT",
+		"HEXPIRE", "HPEXPIRE", "HEXPIREAT", "HPEXPIREAT", "HPERSIST",
+		"LPUSH", "RPUSH", "LPUSHX", "RPUSHX", "LPOP", "RPOP",
+		"LSET", "LINSERT", "LREM", "LTRIM", "LMOVE",
+		"SADD", "SREM", "SPOP", "SMOVE", "SINTERSTORE", "SUNIONSTORE", "SDIFFSTORE",
+		"ZADD", "ZINCRBY", "ZREM", "ZREMRANGEBYSCORE", "ZPOPMIN", "ZPOPMAX",
+		"ZUNIONSTORE", "ZINTERSTORE",
+		"GEOADD", "GEOSEARCHSTORE",
+		"JSON.SET", "JSON.DEL", "JSON.FORGET", "JSON.NUMINCRBY", "JSON.STRAPPEND",
+		"JSON.ARRAPPEND", "JSON.ARRPOP",
+		"BF.RESERVE", "BF.ADD", "BF.MADD", "CF.ADD", "CF.DEL",
+		"CMS.INITBYDIM", "CMS.INITBYPROB", "CMS.INCRBY", "CMS.MERGE",
+		"TOPK.RESERVE", "TOPK.ADD", "TOPK.INCRBY",
+		"TS.CREATE", "TS.ADD", "TS.MADD", "TS.CREATERULE",
+		"VADD", "VREM",
+		"UNLINK", "RENAME", "RENAMENX", "COPY", "MOVE", "SWAPDB", "FLUSHDB", "FLUSHALL",
+		"IDX.CREATE", "IDX.DROP",
+		"XADD", "XDEL", "XTRIM", "XGROUP", "XREADGROUP", "XACK", "XCLAIM", "XAUTOCLAIM":
 		return true
 	default:
 		return false
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAof записывает команды в файл AOF в формате RESP
+func writeAof(t *testing.T, path string, commands ...[]string) {
+	t.Helper()
+	var b strings.Builder
+	for _, args := range commands {
+		cmd := resp.Value{Typ: "array"}
+		for _, arg := range args {
+			cmd.Array = append(cmd.Array, resp.Value{Typ: "bulk", Bulk: arg})
+		}
+		b.Write(cmd.Marshal())
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startServer запускает сервер на свободном порту с AOF path
+func startServer(t *testing.T, path string) (*Server, error) {
+	t.Helper()
+	s := NewServer(Config{AofFilename: path, Databases: 2})
+	t.Cleanup(s.Stop)
+	return s, s.Start()
+}
+
+func TestReplaySelect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.aof")
+	writeAof(t, path,
+		[]string{"SET", "a", "0"},
+		[]string{"SELECT", "1"},
+		[]string{"SET", "b", "1"},
+		[]string{"SELECT", "0"},
+		[]string{"SET", "c", "0"},
+	)
+
+	s, err := startServer(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.dbs[0].Exists("a") || !s.dbs[0].Exists("c") || s.dbs[0].Exists("b") {
+		t.Error("db 0 has wrong keys after replay")
+	}
+	if !s.dbs[1].Exists("b") || s.dbs[1].DBSize() != 1 {
+		t.Error("db 1 has wrong keys after replay")
+	}
+	// Следующая запись в AOF продолжит с базы последнего SELECT
+	if s.aofDB != 0 {
+		t.Errorf("aofDB = %d after replay, want 0", s.aofDB)
+	}
+}
+
+func TestReplayInvalidSelect(t *testing.T) {
+	for _, db := range []string{"2", "-1", "x"} {
+		path := filepath.Join(t.TempDir(), "test.aof")
+		writeAof(t, path,
+			[]string{"SELECT", db},
+			[]string{"SET", "a", "0"},
+		)
+
+		s, err := startServer(t, path)
+		if err == nil {
+			t.Errorf("SELECT %s: replay succeeded", db)
+		}
+		if s.dbs[0].Exists("a") {
+			t.Errorf("SELECT %s: command after it was applied to db 0", db)
+		}
+	}
+}
+
+// TestReplaySkipsUnappliedWrites проверяет, что невыполненные условные
+// записи не возвращают после перезапуска ключ, истёкший до него
+func TestReplaySkipsUnappliedWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.aof")
+	s := NewServer(Config{AofFilename: path, Databases: 2})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"SET", "k", "v1", "PX", "50"},
+		{"SET", "k", "v2", "NX"},
+		{"SET", "k", "v2", "NX", "GET"},
+		{"SET", "missing", "v", "XX"},
+		{"SETNX", "k", "v3"},
+		{"MSETNX", "k", "v4", "other", "v4"},
+	} {
+		cmd := resp.Value{Typ: "array"}
+		for _, arg := range args {
+			cmd.Array = append(cmd.Array, resp.Value{Typ: "bulk", Bulk: arg})
+		}
+		s.processCommand(0, cmd)
+	}
+	s.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	s, err := startServer(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"k", "missing", "other"} {
+		if s.dbs[0].Exists(key) {
+			t.Errorf("%s exists after replay", key)
+		}
+	}
+}
+
+func TestReplayTruncatedAof(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.aof")
+	writeAof(t, path, []string{"SET", "a", "1"})
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("*3\r\n$3\r\nSET\r\n$1\r\nb")
+	f.Close()
+
+	s := NewServer(Config{AofFilename: path, Databases: 2})
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start with a partial last record: %v", err)
+	}
+	if !s.dbs[0].Exists("a") || s.dbs[0].Exists("b") {
+		t.Error("wrong keys after replay of truncated AOF")
+	}
+	s.processCommand(0, resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "bulk", Bulk: "SET"}, {Typ: "bulk", Bulk: "c"}, {Typ: "bulk", Bulk: "3"},
+	}})
+	s.Stop()
+
+	// Запись после обрезки читается при следующем запуске
+	s, err = startServer(t, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.dbs[0].Exists("a") || !s.dbs[0].Exists("c") {
+		t.Error("write after truncation was lost")
+	}
+}
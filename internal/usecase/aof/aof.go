@@ -2,18 +2,24 @@ package aof
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"keyvalue/internal/usecase/resp"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 var (
-	ErrAofClosed = errors.New("AOF file is closed")
-	SyncInterval = 1 * time.Second
+	ErrAofClosed    = errors.New("AOF file is closed")
+	ErrAofCorrupted = errors.New("AOF file is corrupted")
+	ErrAofTruncated = errors.New("AOF file ends with a partial record")
+	SyncInterval    = 1 * time.Second
 )
 
 type Aof struct {
@@ -70,12 +76,7 @@ func (a *Aof) Write(value resp.Value) error {
 		return ErrAofClosed
 	}
 
-	data, err := json.Marshal(value)
-	if err != nil {
-		return err
-	}
-
-	if _, err := a.writer.Write(append(data, '\n')); err != nil {
+	if _, err := a.writer.Write(value.Marshal()); err != nil {
 		return err
 	}
 
@@ -97,6 +98,8 @@ func (a *Aof) Flush() error {
 	return a.file.Sync()
 }
 
+// Read воспроизводит команды из файла. Поддерживаются записи в формате RESP
+// и в устаревшем формате JSON-строк, в том числе вперемешку.
 func (a *Aof) Read(callback func(value resp.Value)) error {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
@@ -109,20 +112,213 @@ func (a *Aof) Read(callback func(value resp.Value)) error {
 		return err
 	}
 
-	decoder := json.NewDecoder(a.file)
+	_, _, err := readEntries(a.file, callback)
+	return err
+}
+
+// TruncatePartial обрезает запись, оборванную в конце файла, например при
+// сбое во время записи, и возвращает число отброшенных байт. Повреждение в
+// середине файла не исправляется: Read и Upgrade вернут ErrAofCorrupted.
+func (a *Aof) TruncatePartial() (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, ErrAofClosed
+	}
+
+	if _, err := a.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	_, complete, err := readEntries(a.file, func(resp.Value) {})
+	if !errors.Is(err, ErrAofTruncated) {
+		return 0, err
+	}
+
+	stat, err := a.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := a.file.Truncate(complete); err != nil {
+		return 0, err
+	}
+	return stat.Size() - complete, a.file.Sync()
+}
+
+// countingReader считает прочитанные байты
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readEntries читает записи AOF и сообщает, встретились ли среди них записи
+// в устаревшем формате JSON, и длину файла до конца последней целой записи.
+// Запись, оборванная концом файла, даёт ErrAofTruncated.
+func readEntries(r io.Reader, callback func(value resp.Value)) (bool, int64, error) {
+	cr := &countingReader{r: r}
+	br := bufio.NewReader(cr)
+	// resp.NewReader использует тот же буфер, поэтому форматы можно чередовать
+	rr := resp.NewReader(br)
+	legacy := false
+	now := time.Now()
+	var complete int64
+	truncated := func() (bool, int64, error) {
+		return legacy, complete, fmt.Errorf("%w after %d bytes", ErrAofTruncated, complete)
+	}
+
 	for {
-		var value resp.Value
-		if err := decoder.Decode(&value); err != nil {
-			if err == io.EOF {
-				break
+		complete = cr.n - int64(br.Buffered())
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return legacy, complete, nil
+		}
+		if err != nil {
+			return legacy, complete, err
+		}
+
+		switch b {
+		case '\r', '\n', ' ':
+			continue
+		case '{':
+			br.UnreadByte()
+			line, readErr := br.ReadBytes('\n')
+			if readErr != nil && readErr != io.EOF {
+				return legacy, complete, readErr
+			}
+			var value resp.Value
+			if err := json.Unmarshal(line, &value); err != nil {
+				if readErr == io.EOF {
+					return truncated()
+				}
+				return legacy, complete, err
 			}
-			return err
+			legacy = true
+			for _, cmd := range legacyCommands(value, now) {
+				callback(cmd)
+			}
+		case resp.ARRAY:
+			br.UnreadByte()
+			value, err := rr.Read()
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return truncated()
+			}
+			if err != nil {
+				return legacy, complete, err
+			}
+			callback(value)
+		default:
+			return legacy, complete, fmt.Errorf("%w: unexpected byte %q", ErrAofCorrupted, b)
 		}
+	}
+}
 
-		callback(value)
+// legacyCommands переводит запись устаревшего формата в команды RESP.
+// Прежний HSET принимал последним аргументом TTL поля в секундах:
+// HSET key field value ttl. Сейчас у HSET нечётное число аргументов, поэтому
+// такая запись однозначна и становится HSET и HPEXPIREAT. Срок, как и при
+// прежней загрузке AOF, отсчитывается от момента чтения now.
+func legacyCommands(value resp.Value, now time.Time) []resp.Value {
+	args := value.Array
+	if len(args) != 5 || !strings.EqualFold(args[0].Bulk, "HSET") {
+		return []resp.Value{value}
+	}
+	ttl, err := strconv.Atoi(args[4].Bulk)
+	if err != nil {
+		return []resp.Value{value}
 	}
 
-	return nil
+	hset := resp.Value{Typ: "array", Array: args[:4]}
+	if ttl <= 0 {
+		return []resp.Value{hset}
+	}
+	at := now.Add(time.Duration(ttl) * time.Second).UnixMilli()
+	expire := resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "bulk", Bulk: "HPEXPIREAT"},
+		args[1],
+		{Typ: "bulk", Bulk: strconv.FormatInt(at, 10)},
+		{Typ: "bulk", Bulk: "FIELDS"},
+		{Typ: "bulk", Bulk: "1"},
+		args[2],
+	}}
+	return []resp.Value{hset, expire}
+}
+
+// Upgrade однократно переводит файл из устаревшего формата JSON в RESP.
+// Исходный файл сохраняется рядом с суффиксом .json.bak жёсткой ссылкой или
+// копией, а новый ставится на его место одним переименованием, так что при
+// сбое по исходному пути всегда лежит целый AOF. Возвращает true, если
+// преобразование выполнено.
+func (a *Aof) Upgrade() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return false, ErrAofClosed
+	}
+
+	if _, err := a.file.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	var converted bytes.Buffer
+	legacy, _, err := readEntries(a.file, func(value resp.Value) {
+		converted.Write(value.Marshal())
+	})
+	if err != nil || !legacy {
+		return false, err
+	}
+
+	tmpPath := a.filePath + ".tmp"
+	if err := writeFileSync(tmpPath, converted.Bytes()); err != nil {
+		return false, err
+	}
+	if err := backupFile(a.filePath, a.filePath+".json.bak"); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpPath, a.filePath); err != nil {
+		return false, err
+	}
+
+	return true, a.reopenFile()
+}
+
+// backupFile сохраняет копию path в backup, не трогая path: жёсткой
+// ссылкой, а если файловая система их не поддерживает, — копированием
+func backupFile(path, backup string) error {
+	if err := os.Remove(backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(path, backup); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return writeFileSync(backup, data)
+}
+
+// writeFileSync записывает файл и дожидается его сброса на диск
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 func (a *Aof) Rewrite() error {
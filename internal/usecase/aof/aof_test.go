@@ -0,0 +1,224 @@
+package aof
+
+import (
+	"encoding/json"
+	"errors"
+	"keyvalue/internal/usecase/resp"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// command собирает команду RESP из строковых аргументов
+func command(args ...string) resp.Value {
+	v := resp.Value{Typ: "array"}
+	for _, arg := range args {
+		v.Array = append(v.Array, resp.Value{Typ: "bulk", Bulk: arg})
+	}
+	return v
+}
+
+// newTestAof открывает AOF path, который закрывается по завершении теста
+func newTestAof(t *testing.T, path string) *Aof {
+	t.Helper()
+	a, err := NewAof(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+// readAll возвращает все команды файла
+func readAll(t *testing.T, a *Aof) []resp.Value {
+	t.Helper()
+	var values []resp.Value
+	if err := a.Read(func(v resp.Value) { values = append(values, v) }); err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.aof")
+	// Значения с переводами строк, нулевыми байтами и не-UTF-8 хранятся как есть
+	want := []resp.Value{
+		command("SET", "key", "value"),
+		command("SET", "multi\r\nline", "with\x00zero"),
+		command("SET", "empty", ""),
+		command("SET", "binary", "\xff\xfe{\"Typ\":"),
+	}
+
+	a := newTestAof(t, path)
+	for _, v := range want {
+		if err := a.Write(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Write(command("PING")); !errors.Is(err, ErrAofClosed) {
+		t.Errorf("Write after Close err = %v, want ErrAofClosed", err)
+	}
+
+	if got := readAll(t, newTestAof(t, path)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Read = %+v, want %+v", got, want)
+	}
+}
+
+func TestUpgradeLegacyJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.aof")
+	want := []resp.Value{
+		command("SET", "a", "1"),
+		command("SET", "b", "line\nbreak"),
+		command("DEL", "a"),
+	}
+
+	// Файл старой версии: JSON-строки, после которых уже дописаны записи RESP
+	var legacy []byte
+	for _, v := range want[:2] {
+		data, _ := json.Marshal(v)
+		legacy = append(append(legacy, data...), '\n')
+	}
+	legacy = append(legacy, want[2].Marshal()...)
+	if err := os.WriteFile(path, legacy, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAof(t, path)
+	if got := readAll(t, a); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Read of mixed file = %+v, want %+v", got, want)
+	}
+	if upgraded, err := a.Upgrade(); !upgraded || err != nil {
+		t.Fatalf("Upgrade = %v, %v", upgraded, err)
+	}
+
+	if backup, err := os.ReadFile(path + ".json.bak"); err != nil || string(backup) != string(legacy) {
+		t.Errorf("backup differs from the original file: %v", err)
+	}
+	var converted []byte
+	for _, v := range want {
+		converted = append(converted, v.Marshal()...)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(converted) {
+		t.Errorf("upgraded file = %q, want %q", data, converted)
+	}
+
+	// После преобразования запись идёт в новый файл, повторно он не переводится
+	a.Write(command("SET", "c", "3"))
+	a.Flush()
+	if got := readAll(t, a); len(got) != 4 {
+		t.Errorf("Read after Upgrade returned %d commands, want 4", len(got))
+	}
+	if upgraded, err := a.Upgrade(); upgraded || err != nil {
+		t.Errorf("second Upgrade = %v, %v", upgraded, err)
+	}
+}
+
+func TestReadCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.aof")
+	data := append(command("SET", "a", "1").Marshal(), "garbage"...)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAof(t, path)
+	n := 0
+	err := a.Read(func(resp.Value) { n++ })
+	if !errors.Is(err, ErrAofCorrupted) || n != 1 {
+		t.Errorf("Read = %d commands, %v; want 1 and ErrAofCorrupted", n, err)
+	}
+	if upgraded, err := a.Upgrade(); upgraded || err == nil {
+		t.Errorf("Upgrade of corrupted file = %v, %v", upgraded, err)
+	}
+}
+
+// TestUpgradeLegacyHSetTTL проверяет перевод прежнего HSET key field value
+// ttl в HSET и HPEXPIREAT со сроком от момента чтения
+func TestUpgradeLegacyHSetTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.aof")
+	var legacy []byte
+	for _, v := range []resp.Value{
+		command("HSET", "h", "f", "v", "60"),
+		command("HSET", "h", "g", "w", "0"),
+		command("HSET", "h", "a", "1", "b", "2"),
+	} {
+		data, _ := json.Marshal(v)
+		legacy = append(append(legacy, data...), '\n')
+	}
+	if err := os.WriteFile(path, legacy, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newTestAof(t, path)
+	before := time.Now()
+	if upgraded, err := a.Upgrade(); !upgraded || err != nil {
+		t.Fatalf("Upgrade = %v, %v", upgraded, err)
+	}
+	got := readAll(t, a)
+	if len(got) != 4 {
+		t.Fatalf("upgraded file has %d commands, want 4: %+v", len(got), got)
+	}
+
+	want := []resp.Value{
+		command("HSET", "h", "f", "v"),
+		command("HPEXPIREAT", "h", got[1].Array[2].Bulk, "FIELDS", "1", "f"),
+		command("HSET", "h", "g", "w"),
+		command("HSET", "h", "a", "1", "b", "2"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("upgraded commands = %+v, want %+v", got, want)
+	}
+	at, _ := strconv.ParseInt(got[1].Array[2].Bulk, 10, 64)
+	if ttl := time.UnixMilli(at).Sub(before); ttl < 59*time.Second || ttl > 61*time.Second {
+		t.Errorf("field expires in %v, want 60s", ttl)
+	}
+}
+
+func TestTruncatePartial(t *testing.T) {
+	set := command("SET", "a", "1").Marshal()
+	legacySet, _ := json.Marshal(command("SET", "b", "2"))
+	tests := []struct {
+		name    string
+		tail    string
+		dropped bool
+		records int
+		wantErr error
+	}{
+		{"complete", "", false, 1, nil},
+		{"partial header", "*3\r\n$3\r", true, 1, nil},
+		{"partial bulk", "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$5\r\nval", true, 1, nil},
+		{"partial JSON", string(legacySet[:10]), true, 1, nil},
+		{"JSON without newline", string(legacySet), false, 2, nil},
+		{"corrupted", "garbage", false, 0, ErrAofCorrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.aof")
+			if err := os.WriteFile(path, append(slices.Clone(set), tt.tail...), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			a := newTestAof(t, path)
+			want := 0
+			if tt.dropped {
+				want = len(tt.tail)
+			}
+			dropped, err := a.TruncatePartial()
+			if int(dropped) != want || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TruncatePartial = %d, %v; want %d, %v", dropped, err, want, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got := readAll(t, a); len(got) != tt.records {
+				t.Errorf("Read after truncation = %+v, want %d commands", got, tt.records)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"strconv"
+	"strings"
+)
+
+// maxBitOffset предельное смещение бита: строка не длиннее 512 МБ
+const maxBitOffset = storage.MaxStringLength * 8
+
+var (
+	errBitOffset    = resp.Value{Typ: "error", Str: "ERR bit offset is not an integer or out of range"}
+	errBitValue     = resp.Value{Typ: "error", Str: "ERR bit is not an integer or out of range"}
+	errBitfieldType = resp.Value{Typ: "error", Str: "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is."}
+)
+
+// parseBitOffset разбирает смещение бита; при hashAllowed форма "#N"
+// означает N-е поле шириной width бит
+func parseBitOffset(arg string, hashAllowed bool, width int) (int, bool) {
+	multiplier := 1
+	if hashAllowed && strings.HasPrefix(arg, "#") {
+		arg = arg[1:]
+		multiplier = width
+	}
+
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 || n >= maxBitOffset/int64(multiplier) {
+		return 0, false
+	}
+	return int(n) * multiplier, true
+}
+
+// parseBitUnit разбирает необязательную единицу диапазона BYTE|BIT
+func parseBitUnit(arg string) (bool, bool) {
+	switch strings.ToUpper(arg) {
+	case "BYTE":
+		return false, true
+	case "BIT":
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func (e *CommandExecutor) setbit(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("SETBIT")
+	}
+
+	offset, ok := parseBitOffset(args[1].Bulk, false, 0)
+	if !ok {
+		return errBitOffset
+	}
+	bit := args[2].Bulk
+	if bit != "0" && bit != "1" {
+		return errBitValue
+	}
+
+	old, err := e.store.SetBit(args[0].Bulk, offset, int(bit[0]-'0'))
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: old}
+}
+
+func (e *CommandExecutor) getbit(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("GETBIT")
+	}
+
+	offset, ok := parseBitOffset(args[1].Bulk, false, 0)
+	if !ok {
+		return errBitOffset
+	}
+
+	bit, err := e.store.GetBit(args[0].Bulk, offset)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: bit}
+}
+
+func (e *CommandExecutor) bitcount(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("BITCOUNT")
+	}
+	if len(args) != 1 && len(args) != 3 && len(args) != 4 {
+		return errSyntax
+	}
+
+	var r *storage.BitRange
+	if len(args) > 1 {
+		start, ok1 := parseInt(args[1])
+		end, ok2 := parseInt(args[2])
+		if !ok1 || !ok2 {
+			return errNotInteger
+		}
+		r = &storage.BitRange{Start: start, End: end, HasEnd: true}
+		if len(args) == 4 {
+			bitMode, ok := parseBitUnit(args[3].Bulk)
+			if !ok {
+				return errSyntax
+			}
+			r.BitMode = bitMode
+		}
+	}
+
+	count, err := e.store.BitCount(args[0].Bulk, r)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: count}
+}
+
+func (e *CommandExecutor) bitpos(args []resp.Value) resp.Value {
+	if len(args) < 2 || len(args) > 5 {
+		return wrongArgs("BITPOS")
+	}
+
+	bit, ok := parseInt(args[1])
+	if !ok {
+		return errNotInteger
+	}
+	if bit != 0 && bit != 1 {
+		return resp.Value{Typ: "error", Str: "ERR The bit argument must be 1 or 0."}
+	}
+
+	var r *storage.BitRange
+	if len(args) > 2 {
+		start, ok := parseInt(args[2])
+		if !ok {
+			return errNotInteger
+		}
+		r = &storage.BitRange{Start: start}
+		if len(args) > 3 {
+			end, ok := parseInt(args[3])
+			if !ok {
+				return errNotInteger
+			}
+			r.End, r.HasEnd = end, true
+		}
+		if len(args) > 4 {
+			bitMode, ok := parseBitUnit(args[4].Bulk)
+			if !ok {
+				return errSyntax
+			}
+			r.BitMode = bitMode
+		}
+	}
+
+	pos, err := e.store.BitPos(args[0].Bulk, bit, r)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: pos}
+}
+
+func (e *CommandExecutor) bitop(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("BITOP")
+	}
+
+	var op storage.BitOp
+	switch strings.ToUpper(args[0].Bulk) {
+	case "AND":
+		op = storage.BitAnd
+	case "OR":
+		op = storage.BitOr
+	case "XOR":
+		op = storage.BitXor
+	case "NOT":
+		op = storage.BitNot
+		if len(args) != 3 {
+			return resp.Value{Typ: "error", Str: "ERR BITOP NOT must be called with a single source key."}
+		}
+	default:
+		return errSyntax
+	}
+
+	length, err := e.store.BitOp(op, args[1].Bulk, bulkStrings(args[2:]))
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: length}
+}
+
+// parseBitfieldType разбирает тип поля BITFIELD: i1..i64 или u1..u63
+func parseBitfieldType(arg string) (bool, int, bool) {
+	if len(arg) < 2 {
+		return false, 0, false
+	}
+
+	signed := arg[0] == 'i' || arg[0] == 'I'
+	if !signed && arg[0] != 'u' && arg[0] != 'U' {
+		return false, 0, false
+	}
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil || n < 1 || (signed && n > 64) || (!signed && n > 63) {
+		return false, 0, false
+	}
+	return signed, n, true
+}
+
+// parseBitfield разбирает подкоманды BITFIELD: GET, SET, INCRBY и OVERFLOW
+func parseBitfield(args []resp.Value, readOnly bool) ([]storage.BitFieldOp, resp.Value, bool) {
+	var ops []storage.BitFieldOp
+	overflow := storage.OverflowWrap
+
+	for i := 0; i < len(args); {
+		sub := strings.ToUpper(args[i].Bulk)
+
+		if sub == "OVERFLOW" && i+1 < len(args) {
+			switch strings.ToUpper(args[i+1].Bulk) {
+			case "WRAP":
+				overflow = storage.OverflowWrap
+			case "SAT":
+				overflow = storage.OverflowSat
+			case "FAIL":
+				overflow = storage.OverflowFail
+			default:
+				return nil, resp.Value{Typ: "error", Str: "ERR Invalid OVERFLOW type specified"}, false
+			}
+			i += 2
+			continue
+		}
+
+		var op storage.BitFieldOp
+		argc := 3
+		switch sub {
+		case "GET":
+			op.Kind, argc = storage.BitFieldGet, 2
+		case "SET":
+			op.Kind = storage.BitFieldSet
+		case "INCRBY":
+			op.Kind = storage.BitFieldIncrBy
+		default:
+			return nil, errSyntax, false
+		}
+		if i+argc >= len(args) {
+			return nil, errSyntax, false
+		}
+		if readOnly && op.Kind != storage.BitFieldGet {
+			return nil, resp.Value{Typ: "error", Str: "ERR BITFIELD_RO only supports the GET subcommand"}, false
+		}
+
+		signed, width, ok := parseBitfieldType(args[i+1].Bulk)
+		if !ok {
+			return nil, errBitfieldType, false
+		}
+		offset, ok := parseBitOffset(args[i+2].Bulk, true, width)
+		if !ok {
+			return nil, errBitOffset, false
+		}
+		op.Signed, op.Bits, op.Offset, op.Overflow = signed, width, offset, overflow
+
+		if op.Kind != storage.BitFieldGet {
+			value, err := strconv.ParseInt(args[i+3].Bulk, 10, 64)
+			if err != nil {
+				return nil, errNotInteger, false
+			}
+			op.Value = value
+		}
+
+		ops = append(ops, op)
+		i += argc + 1
+	}
+	return ops, resp.Value{}, true
+}
+
+func (e *CommandExecutor) bitfield(name string, readOnly bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 1 {
+			return wrongArgs(name)
+		}
+
+		ops, reply, ok := parseBitfield(args[1:], readOnly)
+		if !ok {
+			return reply
+		}
+
+		values, applied, err := e.store.BitField(args[0].Bulk, ops)
+		if err != nil {
+			return errorValue(err)
+		}
+
+		result := make([]resp.Value, len(values))
+		for i, value := range values {
+			if applied[i] {
+				result[i] = resp.Value{Typ: "integer", Num: int(value)}
+			} else {
+				result[i] = resp.Value{Typ: "null"}
+			}
+		}
+		return resp.Value{Typ: "array", Array: result}
+	}
+}
@@ -0,0 +1,212 @@
+package command
+
+import (
+	"errors"
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"strconv"
+	"strings"
+)
+
+// boolsValue формирует массив ответов 0/1
+func boolsValue(values []bool) resp.Value {
+	result := make([]resp.Value, len(values))
+	for i, v := range values {
+		result[i] = boolValue(v)
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) bfReserve(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("BF.RESERVE")
+	}
+
+	opts := storage.DefaultBloomOptions
+	errRate, err := strconv.ParseFloat(args[1].Bulk, 64)
+	if err != nil {
+		return resp.Value{Typ: "error", Str: "ERR bad error rate"}
+	}
+	if !(errRate > 0 && errRate < 1) {
+		return resp.Value{Typ: "error", Str: "ERR (0 < error rate range < 1)"}
+	}
+	capacity, ok := parseInt(args[2])
+	if !ok {
+		return resp.Value{Typ: "error", Str: "ERR bad capacity"}
+	}
+	if capacity <= 0 {
+		return resp.Value{Typ: "error", Str: "ERR (capacity should be larger than 0)"}
+	}
+	opts.ErrorRate, opts.Capacity = errRate, capacity
+
+	hasExpansion := false
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "NONSCALING":
+			opts.NonScaling = true
+		case "EXPANSION":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			expansion, ok := parseInt(args[i+1])
+			if !ok || expansion < 1 {
+				return resp.Value{Typ: "error", Str: "ERR expansion should be greater or equal to 1"}
+			}
+			opts.Expansion, hasExpansion = expansion, true
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if opts.NonScaling && hasExpansion {
+		return resp.Value{Typ: "error", Str: "ERR Nonscaling filters cannot expand"}
+	}
+
+	if err := e.store.BFReserve(args[0].Bulk, opts); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) bfAdd(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("BF.ADD")
+	}
+
+	added, err := e.store.BFAdd(args[0].Bulk, []string{args[1].Bulk})
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(added[0])
+}
+
+func (e *CommandExecutor) bfMAdd(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("BF.MADD")
+	}
+
+	// Элементы после заполнения фильтра получают ошибку в своей позиции ответа,
+	// уже добавленные остаются в фильтре
+	items := bulkStrings(args[1:])
+	added, err := e.store.BFAdd(args[0].Bulk, items)
+	if err != nil && !errors.Is(err, storage.ErrBloomFull) && !errors.Is(err, storage.ErrBloomTooLarge) {
+		return errorValue(err)
+	}
+	reply := boolsValue(added)
+	for range items[len(added):] {
+		reply.Array = append(reply.Array, errorValue(err))
+	}
+	return reply
+}
+
+func (e *CommandExecutor) bfExists(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("BF.EXISTS")
+	}
+
+	found, err := e.store.BFExists(args[0].Bulk, []string{args[1].Bulk})
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(found[0])
+}
+
+func (e *CommandExecutor) bfMExists(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("BF.MEXISTS")
+	}
+
+	found, err := e.store.BFExists(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolsValue(found)
+}
+
+func (e *CommandExecutor) bfInfo(args []resp.Value) resp.Value {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgs("BF.INFO")
+	}
+
+	info, err := e.store.BFInfo(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	fields := []struct {
+		option string
+		name   string
+		value  int
+	}{
+		{"CAPACITY", "Capacity", info.Capacity},
+		{"SIZE", "Size", info.Size},
+		{"FILTERS", "Number of filters", info.Filters},
+		{"ITEMS", "Number of items inserted", info.Items},
+		{"EXPANSION", "Expansion rate", info.Expansion},
+	}
+
+	var result []resp.Value
+	for _, f := range fields {
+		if len(args) == 2 {
+			if strings.ToUpper(args[1].Bulk) == f.option {
+				return resp.Value{Typ: "array", Array: []resp.Value{{Typ: "integer", Num: f.value}}}
+			}
+			continue
+		}
+		result = append(result,
+			resp.Value{Typ: "bulk", Bulk: f.name},
+			resp.Value{Typ: "integer", Num: f.value},
+		)
+	}
+	if len(args) == 2 {
+		return resp.Value{Typ: "error", Str: "ERR Invalid information value"}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) cfAdd(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("CF.ADD")
+	}
+
+	if err := e.store.CFAdd(args[0].Bulk, args[1].Bulk); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: 1}
+}
+
+func (e *CommandExecutor) cfDel(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("CF.DEL")
+	}
+
+	deleted, err := e.store.CFDel(args[0].Bulk, args[1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(deleted)
+}
+
+func (e *CommandExecutor) cfExists(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("CF.EXISTS")
+	}
+
+	count, err := e.store.CFCount(args[0].Bulk, args[1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(count > 0)
+}
+
+func (e *CommandExecutor) cfCount(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("CF.COUNT")
+	}
+
+	count, err := e.store.CFCount(args[0].Bulk, args[1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: count}
+}
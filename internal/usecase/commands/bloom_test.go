@@ -0,0 +1,39 @@
+package command
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBFReserveArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{"ok", []string{"0.01", "100"}, false},
+		{"scaling options", []string{"0.01", "100", "EXPANSION", "4"}, false},
+		{"nonscaling", []string{"0.01", "100", "NONSCALING"}, false},
+		{"nan", []string{"nan", "100"}, true},
+		{"zero rate", []string{"0", "100"}, true},
+		{"rate one", []string{"1", "100"}, true},
+		{"infinite rate", []string{"inf", "100"}, true},
+		{"zero capacity", []string{"0.01", "0"}, true},
+		{"huge capacity", []string{"0.01", strconv.Itoa(1 << 62)}, true},
+		{"zero expansion", []string{"0.01", "100", "EXPANSION", "0"}, true},
+		{"missing expansion", []string{"0.01", "100", "EXPANSION"}, true},
+		{"unknown option", []string{"0.01", "100", "FAST"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExecutor(t)
+			got := run(e, "BF.RESERVE", append([]string{"bf"}, tt.args...)...)
+			if (got.Typ == "error") != tt.wantErr {
+				t.Errorf("BF.RESERVE bf %q = %+v", tt.args, got)
+			}
+			if exists := run(e, "EXISTS", "bf").Num == 1; exists == tt.wantErr {
+				t.Errorf("key exists = %v after BF.RESERVE", exists)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// int64sValue формирует массив целых из int64
+func int64sValue(items []int64) resp.Value {
+	result := make([]resp.Value, len(items))
+	for i, item := range items {
+		result[i] = resp.Value{Typ: "integer", Num: int(item)}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+// parseItemIncrements разбирает пары элемент, неотрицательное приращение
+func parseItemIncrements(args []resp.Value, maxIncr int64) ([]string, []int64, bool) {
+	items := make([]string, 0, len(args)/2)
+	increments := make([]int64, 0, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		incr, err := strconv.ParseInt(args[i+1].Bulk, 10, 64)
+		if err != nil || incr < 0 || incr > maxIncr {
+			return nil, nil, false
+		}
+		items = append(items, args[i].Bulk)
+		increments = append(increments, incr)
+	}
+	return items, increments, true
+}
+
+func (e *CommandExecutor) cmsInitByDim(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("CMS.INITBYDIM")
+	}
+
+	width, ok := parseInt(args[1])
+	if !ok || width < 1 {
+		return resp.Value{Typ: "error", Str: "ERR CMS: invalid width"}
+	}
+	depth, ok := parseInt(args[2])
+	if !ok || depth < 1 {
+		return resp.Value{Typ: "error", Str: "ERR CMS: invalid depth"}
+	}
+
+	if err := e.store.CMSInit(args[0].Bulk, width, depth); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) cmsInitByProb(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("CMS.INITBYPROB")
+	}
+
+	errRate, err := strconv.ParseFloat(args[1].Bulk, 64)
+	if err != nil || !(errRate > 0 && errRate < 1) {
+		return resp.Value{Typ: "error", Str: "ERR CMS: invalid overestimation value"}
+	}
+	probability, err := strconv.ParseFloat(args[2].Bulk, 64)
+	if err != nil || !(probability > 0 && probability < 1) {
+		return resp.Value{Typ: "error", Str: "ERR CMS: invalid prob value"}
+	}
+
+	width, depth := storage.CMSDimensions(errRate, probability)
+	if err := e.store.CMSInit(args[0].Bulk, width, depth); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) cmsIncrBy(args []resp.Value) resp.Value {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return wrongArgs("CMS.INCRBY")
+	}
+
+	items, increments, ok := parseItemIncrements(args[1:], math.MaxInt64)
+	if !ok {
+		return resp.Value{Typ: "error", Str: "ERR CMS: Cannot parse number"}
+	}
+
+	counts, err := e.store.CMSIncrBy(args[0].Bulk, items, increments)
+	if err != nil {
+		return errorValue(err)
+	}
+	return int64sValue(counts)
+}
+
+func (e *CommandExecutor) cmsQuery(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("CMS.QUERY")
+	}
+
+	counts, err := e.store.CMSQuery(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+	return int64sValue(counts)
+}
+
+func (e *CommandExecutor) cmsMerge(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("CMS.MERGE")
+	}
+
+	numKeys, ok := parseInt(args[1])
+	if !ok || numKeys < 1 || numKeys > len(args)-2 {
+		return resp.Value{Typ: "error", Str: "ERR CMS: invalid numkeys"}
+	}
+	sources := bulkStrings(args[2 : 2+numKeys])
+
+	weights := make([]int64, numKeys)
+	rest := args[2+numKeys:]
+	switch {
+	case len(rest) == 0:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case strings.ToUpper(rest[0].Bulk) == "WEIGHTS" && len(rest)-1 == numKeys:
+		for i, arg := range rest[1:] {
+			w, err := strconv.ParseInt(arg.Bulk, 10, 64)
+			if err != nil {
+				return resp.Value{Typ: "error", Str: "ERR CMS: invalid weight value"}
+			}
+			weights[i] = w
+		}
+	default:
+		return errSyntax
+	}
+
+	if err := e.store.CMSMerge(args[0].Bulk, sources, weights); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
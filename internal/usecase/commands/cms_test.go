@@ -0,0 +1,73 @@
+package command
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestCMSInitArgs(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		wantErr bool
+	}{
+		{"CMS.INITBYDIM", []string{"100", "5"}, false},
+		{"CMS.INITBYDIM", []string{"0", "5"}, true},
+		{"CMS.INITBYDIM", []string{"100", "-1"}, true},
+		{"CMS.INITBYDIM", []string{strconv.Itoa(math.MaxInt), "2"}, true},
+		{"CMS.INITBYDIM", []string{strconv.Itoa(1 << 40), strconv.Itoa(1 << 40)}, true},
+		{"CMS.INITBYPROB", []string{"0.01", "0.01"}, false},
+		{"CMS.INITBYPROB", []string{"nan", "0.01"}, true},
+		{"CMS.INITBYPROB", []string{"0.01", "1"}, true},
+		{"CMS.INITBYPROB", []string{"1e-300", "0.01"}, true},
+	}
+	for _, tt := range tests {
+		e := newTestExecutor(t)
+		got := run(e, tt.command, append([]string{"cms"}, tt.args...)...)
+		if (got.Typ == "error") != tt.wantErr {
+			t.Errorf("%s cms %q = %+v", tt.command, tt.args, got)
+		}
+	}
+}
+
+func TestCMSMergeArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"2", "a", "b"}, false},
+		{[]string{"2", "a", "b", "WEIGHTS", "2", "-1"}, false},
+		{[]string{"1", "a"}, false},
+		{[]string{"0", "a"}, true},
+		{[]string{"-1", "a"}, true},
+		{[]string{"3", "a", "b"}, true},
+		{[]string{strconv.Itoa(math.MaxInt), "a", "b"}, true},
+		{[]string{"2", "a", "b", "WEIGHTS", "1"}, true},
+		{[]string{"2", "a", "b", "WEIGHTS", "1", "x"}, true},
+		{[]string{"1", "a", "b"}, true},
+		{[]string{"1", "missing"}, true},
+	}
+	for _, tt := range tests {
+		e := newTestExecutor(t)
+		run(e, "CMS.INITBYDIM", "dst", "10", "2")
+		run(e, "CMS.INITBYDIM", "a", "10", "2")
+		run(e, "CMS.INITBYDIM", "b", "10", "2")
+		run(e, "CMS.INCRBY", "a", "x", "3")
+		run(e, "CMS.INCRBY", "b", "x", "1")
+
+		got := run(e, "CMS.MERGE", append([]string{"dst"}, tt.args...)...)
+		if (got.Typ == "error") != tt.wantErr {
+			t.Errorf("CMS.MERGE dst %q = %+v", tt.args, got)
+		}
+	}
+
+	e := newTestExecutor(t)
+	run(e, "CMS.INITBYDIM", "dst", "10", "2")
+	run(e, "CMS.INITBYDIM", "a", "10", "2")
+	run(e, "CMS.INCRBY", "a", "x", "3")
+	run(e, "CMS.MERGE", "dst", "2", "a", "a", "WEIGHTS", "2", "1")
+	if got := run(e, "CMS.QUERY", "dst", "x"); len(got.Array) != 1 || got.Array[0].Num != 9 {
+		t.Errorf("CMS.QUERY after weighted merge = %+v, want 9", got)
+	}
+}
@@ -3,22 +3,35 @@ package command
 import (
 	"keyvalue/internal/usecase/resp"
 	"keyvalue/internal/usecase/storage"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// CommandExecutor выполняет команды в базе db. Команды, работающие
+// с несколькими базами, обращаются к ним через dbs.
 type CommandExecutor struct {
 	store     *storage.Storage
+	dbs       storage.Databases
+	db        int
 	commands  map[string]CommandHandler
+	rewriters map[string]Rewriter
 	startTime time.Time
+	// writeLock блокировка, под которой сервер выполняет модифицирующие
+	// команды; блокирующий XREADGROUP отпускает её на время ожидания
+	writeLock sync.Locker
 }
 
 type CommandHandler func(args []resp.Value) resp.Value
 
-func NewCommandExecutor(store *storage.Storage) *CommandExecutor {
+func NewCommandExecutor(dbs storage.Databases, db int) *CommandExecutor {
 	executor := &CommandExecutor{
-		store:     store,
+		store:     dbs[db],
+		dbs:       dbs,
+		db:        db,
 		startTime: time.Now(),
 	}
 
@@ -34,11 +47,240 @@ func NewCommandExecutor(store *storage.Storage) *CommandExecutor {
 		"HDEL":    executor.hdel,
 		"FLUSHDB": executor.flushdb,
 		"INFO":    executor.info,
-		"EXPIRE":  executor.expire,
-		"TTL":     executor.ttl,
+		"EXPIRE":  executor.expire("EXPIRE", time.Second, false),
+		"TTL":     executor.ttl("TTL", time.Second, false),
 		"COMMAND": executor.command,
 		"PERSIST": executor.persist,
 		"HLEN":    executor.hlen,
+		"TYPE":    executor.typ,
+		"EXISTS":  executor.exists,
+		"SCAN":    executor.scan,
+
+		"PEXPIRE":     executor.expire("PEXPIRE", time.Millisecond, false),
+		"EXPIREAT":    executor.expire("EXPIREAT", time.Second, true),
+		"PEXPIREAT":   executor.expire("PEXPIREAT", time.Millisecond, true),
+		"PTTL":        executor.ttl("PTTL", time.Millisecond, false),
+		"EXPIRETIME":  executor.ttl("EXPIRETIME", time.Second, true),
+		"PEXPIRETIME": executor.ttl("PEXPIRETIME", time.Millisecond, true),
+
+		"KEYS":      executor.keys,
+		"DBSIZE":    executor.dbsize,
+		"RANDOMKEY": executor.randomkey,
+		"TOUCH":     executor.touch,
+		"UNLINK":    executor.unlink,
+		"RENAME":    executor.rename("RENAME", false),
+		"RENAMENX":  executor.rename("RENAMENX", true),
+		"COPY":      executor.copyKey,
+
+		"SELECT":   executor.selectDB,
+		"MOVE":     executor.move,
+		"SWAPDB":   executor.swapdb,
+		"FLUSHALL": executor.flushall,
+
+		"SETNX":    executor.setnx,
+		"SETEX":    executor.setex("SETEX", "EX"),
+		"PSETEX":   executor.setex("PSETEX", "PX"),
+		"GETSET":   executor.getset,
+		"GETDEL":   executor.getdel,
+		"GETEX":    executor.getex,
+		"MGET":     executor.mget,
+		"MSET":     executor.mset("MSET", false),
+		"MSETNX":   executor.mset("MSETNX", true),
+		"APPEND":   executor.appendValue,
+		"STRLEN":   executor.strlen,
+		"GETRANGE": executor.getrange,
+		"SETRANGE": executor.setrange,
+		"LCS":      executor.lcs,
+
+		"HMGET":        executor.hmget,
+		"HKEYS":        executor.hkeys,
+		"HVALS":        executor.hvals,
+		"HSETNX":       executor.hsetnx,
+		"HINCRBY":      executor.hincrby,
+		"HINCRBYFLOAT": executor.hincrbyfloat,
+		"HSTRLEN":      executor.hstrlen,
+		"HRANDFIELD":   executor.hrandfield,
+		"HSCAN":        executor.hscan,
+
+		"HEXPIRE":      executor.hexpire("HEXPIRE", time.Second, false),
+		"HPEXPIRE":     executor.hexpire("HPEXPIRE", time.Millisecond, false),
+		"HEXPIREAT":    executor.hexpire("HEXPIREAT", time.Second, true),
+		"HPEXPIREAT":   executor.hexpire("HPEXPIREAT", time.Millisecond, true),
+		"HTTL":         executor.httl("HTTL", time.Second, false),
+		"HPTTL":        executor.httl("HPTTL", time.Millisecond, false),
+		"HEXPIRETIME":  executor.httl("HEXPIRETIME", time.Second, true),
+		"HPEXPIRETIME": executor.httl("HPEXPIRETIME", time.Millisecond, true),
+		"HPERSIST":     executor.hpersist,
+
+		"INCR":        executor.incr("INCR", 1, false),
+		"DECR":        executor.incr("DECR", -1, false),
+		"INCRBY":      executor.incr("INCRBY", 1, true),
+		"DECRBY":      executor.incr("DECRBY", -1, true),
+		"INCRBYFLOAT": executor.incrbyfloat,
+
+		"SETBIT":      executor.setbit,
+		"GETBIT":      executor.getbit,
+		"BITCOUNT":    executor.bitcount,
+		"BITPOS":      executor.bitpos,
+		"BITOP":       executor.bitop,
+		"BITFIELD":    executor.bitfield("BITFIELD", false),
+		"BITFIELD_RO": executor.bitfield("BITFIELD_RO", true),
+
+		"PFADD":   executor.pfadd,
+		"PFCOUNT": executor.pfcount,
+		"PFMERGE": executor.pfmerge,
+
+		"LPUSH":   executor.push("LPUSH", storage.ListLeft, false),
+		"RPUSH":   executor.push("RPUSH", storage.ListRight, false),
+		"LPUSHX":  executor.push("LPUSHX", storage.ListLeft, true),
+		"RPUSHX":  executor.push("RPUSHX", storage.ListRight, true),
+		"LPOP":    executor.pop("LPOP", storage.ListLeft),
+		"RPOP":    executor.pop("RPOP", storage.ListRight),
+		"LLEN":    executor.llen,
+		"LRANGE":  executor.lrange,
+		"LINDEX":  executor.lindex,
+		"LSET":    executor.lset,
+		"LINSERT": executor.linsert,
+		"LREM":    executor.lrem,
+		"LTRIM":   executor.ltrim,
+		"LPOS":    executor.lpos,
+		"LMOVE":   executor.lmove,
+
+		"SADD":        executor.sadd,
+		"SREM":        executor.srem,
+		"SMEMBERS":    executor.smembers,
+		"SSCAN":       executor.sscan,
+		"SISMEMBER":   executor.sismember,
+		"SMISMEMBER":  executor.smismember,
+		"SCARD":       executor.scard,
+		"SPOP":        executor.spop,
+		"SRANDMEMBER": executor.srandmember,
+		"SMOVE":       executor.smove,
+		"SINTER":      executor.scombine("SINTER", storage.SetInter),
+		"SUNION":      executor.scombine("SUNION", storage.SetUnion),
+		"SDIFF":       executor.scombine("SDIFF", storage.SetDiff),
+		"SINTERSTORE": executor.scombineStore("SINTERSTORE", storage.SetInter),
+		"SUNIONSTORE": executor.scombineStore("SUNIONSTORE", storage.SetUnion),
+		"SDIFFSTORE":  executor.scombineStore("SDIFFSTORE", storage.SetDiff),
+		"SINTERCARD":  executor.sintercard,
+
+		"ZADD":             executor.zadd,
+		"ZINCRBY":          executor.zincrby,
+		"ZSCORE":           executor.zscore,
+		"ZCARD":            executor.zcard,
+		"ZSCAN":            executor.zscan,
+		"ZRANK":            executor.zrank("ZRANK", false),
+		"ZREVRANK":         executor.zrank("ZREVRANK", true),
+		"ZRANGE":           executor.zrange,
+		"ZCOUNT":           executor.zcount,
+		"ZREM":             executor.zrem,
+		"ZREMRANGEBYSCORE": executor.zremrangebyscore,
+		"ZPOPMIN":          executor.zpop("ZPOPMIN", false),
+		"ZPOPMAX":          executor.zpop("ZPOPMAX", true),
+		"ZUNIONSTORE":      executor.zcombineStore("ZUNIONSTORE", storage.SetUnion),
+		"ZINTERSTORE":      executor.zcombineStore("ZINTERSTORE", storage.SetInter),
+
+		"GEOADD":         executor.geoadd,
+		"GEOPOS":         executor.geopos,
+		"GEODIST":        executor.geodist,
+		"GEOHASH":        executor.geohash,
+		"GEOSEARCH":      executor.geosearch,
+		"GEOSEARCHSTORE": executor.geosearchstore,
+
+		"JSON.SET":       executor.jsonSet,
+		"JSON.GET":       executor.jsonGet,
+		"JSON.MGET":      executor.jsonMGet,
+		"JSON.DEL":       executor.jsonDel("JSON.DEL"),
+		"JSON.FORGET":    executor.jsonDel("JSON.FORGET"),
+		"JSON.TYPE":      executor.jsonType,
+		"JSON.NUMINCRBY": executor.jsonNumIncrBy,
+		"JSON.STRAPPEND": executor.jsonStrAppend,
+		"JSON.ARRAPPEND": executor.jsonArrAppend,
+		"JSON.ARRPOP":    executor.jsonArrPop,
+		"JSON.ARRLEN":    executor.jsonArrLen,
+		"JSON.OBJKEYS":   executor.jsonObjKeys,
+
+		"BF.RESERVE": executor.bfReserve,
+		"BF.ADD":     executor.bfAdd,
+		"BF.MADD":    executor.bfMAdd,
+		"BF.EXISTS":  executor.bfExists,
+		"BF.MEXISTS": executor.bfMExists,
+		"BF.INFO":    executor.bfInfo,
+		"CF.ADD":     executor.cfAdd,
+		"CF.DEL":     executor.cfDel,
+		"CF.EXISTS":  executor.cfExists,
+		"CF.COUNT":   executor.cfCount,
+
+		"CMS.INITBYDIM":  executor.cmsInitByDim,
+		"CMS.INITBYPROB": executor.cmsInitByProb,
+		"CMS.INCRBY":     executor.cmsIncrBy,
+		"CMS.QUERY":      executor.cmsQuery,
+		"CMS.MERGE":      executor.cmsMerge,
+		"TOPK.RESERVE":   executor.topkReserve,
+		"TOPK.ADD":       executor.topkAdd,
+		"TOPK.INCRBY":    executor.topkIncrBy,
+		"TOPK.QUERY":     executor.topkQuery,
+		"TOPK.LIST":      executor.topkList,
+
+		"TS.CREATE":     executor.tsCreate,
+		"TS.ADD":        executor.tsAdd,
+		"TS.MADD":       executor.tsMAdd,
+		"TS.RANGE":      executor.tsRange("TS.RANGE", false),
+		"TS.REVRANGE":   executor.tsRange("TS.REVRANGE", true),
+		"TS.MRANGE":     executor.tsMRange,
+		"TS.CREATERULE": executor.tsCreateRule,
+
+		"VADD":  executor.vadd,
+		"VREM":  executor.vrem,
+		"VSIM":  executor.vsim,
+		"VCARD": executor.vcard,
+		"VDIM":  executor.vdim,
+		"VINFO": executor.vinfo,
+
+		"IDX.CREATE": executor.idxCreate,
+		"IDX.DROP":   executor.idxDrop,
+		"IDX.SEARCH": executor.idxSearch("IDX.SEARCH"),
+		"FT.SEARCH":  executor.idxSearch("FT.SEARCH"),
+
+		"XADD":       executor.xadd,
+		"XRANGE":     executor.xrange("XRANGE", false),
+		"XREVRANGE":  executor.xrange("XREVRANGE", true),
+		"XLEN":       executor.xlen,
+		"XDEL":       executor.xdel,
+		"XTRIM":      executor.xtrim,
+		"XREAD":      executor.xread,
+		"XGROUP":     executor.xgroup,
+		"XREADGROUP": executor.xreadgroup,
+		"XACK":       executor.xack,
+		"XPENDING":   executor.xpending,
+		"XCLAIM":     executor.xclaim,
+		"XAUTOCLAIM": executor.xautoclaim,
+		"XINFO":      executor.xinfo,
+	}
+
+	executor.rewriters = map[string]Rewriter{
+		"SET":         rewriteSet,
+		"SETNX":       rewriteIfApplied("SETNX"),
+		"MSETNX":      rewriteIfApplied("MSETNX"),
+		"HSETNX":      rewriteIfApplied("HSETNX"),
+		"SETEX":       rewriteSetEx("EX"),
+		"PSETEX":      rewriteSetEx("PX"),
+		"GETEX":       rewriteGetEx,
+		"INCRBYFLOAT": rewriteIncrByFloat,
+		"EXPIRE":      rewriteExpire("EXPIRE", time.Second, false),
+		"PEXPIRE":     rewriteExpire("PEXPIRE", time.Millisecond, false),
+		"EXPIREAT":    rewriteExpire("EXPIREAT", time.Second, true),
+		"PEXPIREAT":   rewriteExpire("PEXPIREAT", time.Millisecond, true),
+		"HEXPIRE":     rewriteHExpire(time.Second, false),
+		"HPEXPIRE":    rewriteHExpire(time.Millisecond, false),
+		"HEXPIREAT":   rewriteHExpire(time.Second, true),
+		"SPOP":        rewriteSpop,
+		"XADD":        rewriteXAdd,
+		"XREADGROUP":  executor.rewriteXReadGroup,
+		"XCLAIM":      executor.rewriteXClaim,
+		"XAUTOCLAIM":  executor.rewriteXAutoClaim,
+		"TS.ADD":      rewriteTSAdd,
+		"TS.MADD":     rewriteTSMAdd,
 	}
 
 	return executor
@@ -59,6 +301,12 @@ func (e *CommandExecutor) Execute(cmd resp.Value) resp.Value {
 	return resp.Value{Typ: "error", Str: "Unknown command '" + command + "'"}
 }
 
+// SetWriteLock задаёт блокировку, под которой вызывающий выполняет
+// модифицирующие команды
+func (e *CommandExecutor) SetWriteLock(l sync.Locker) {
+	e.writeLock = l
+}
+
 func (e *CommandExecutor) RegisterCommand(name string, handler CommandHandler) {
 	e.commands[strings.ToUpper(name)] = handler
 }
@@ -76,7 +324,10 @@ func (e *CommandExecutor) get(args []resp.Value) resp.Value {
 		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'GET' command"}
 	}
 
-	value, found := e.store.Get(args[0].Bulk)
+	value, found, err := e.store.Get(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
 	if !found {
 		return resp.Value{Typ: "null"}
 	}
@@ -84,56 +335,6 @@ func (e *CommandExecutor) get(args []resp.Value) resp.Value {
 	return resp.Value{Typ: "bulk", Bulk: value}
 }
 
-func (e *CommandExecutor) set(args []resp.Value) resp.Value {
-	if len(args) < 2 {
-		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'SET' command"}
-	}
-
-	key := args[0].Bulk
-	value := args[1].Bulk
-	var ttl time.Duration
-
-	// опции (NX, XX, EX, PX)
-	if len(args) > 2 {
-		for i := 2; i < len(args); i++ {
-			arg := strings.ToUpper(args[i].Bulk)
-			switch arg {
-			case "NX":
-				if _, exists := e.store.Get(key); exists {
-					return resp.Value{Typ: "null"}
-				}
-			case "XX":
-				if _, exists := e.store.Get(key); !exists {
-					return resp.Value{Typ: "null"}
-				}
-			case "EX":
-				if i+1 >= len(args) {
-					return resp.Value{Typ: "error", Str: "ERR syntax error"}
-				}
-				seconds, err := strconv.Atoi(args[i+1].Bulk)
-				if err != nil {
-					return resp.Value{Typ: "error", Str: "ERR invalid expire time"}
-				}
-				ttl = time.Duration(seconds) * time.Second
-				i++
-			case "PX":
-				if i+1 >= len(args) {
-					return resp.Value{Typ: "error", Str: "ERR syntax error"}
-				}
-				millis, err := strconv.Atoi(args[i+1].Bulk)
-				if err != nil {
-					return resp.Value{Typ: "error", Str: "ERR invalid expire time"}
-				}
-				ttl = time.Duration(millis) * time.Millisecond
-				i++
-			}
-		}
-	}
-
-	e.store.Set(key, value, ttl)
-	return resp.Value{Typ: "string", Str: "OK"}
-}
-
 func (e *CommandExecutor) del(args []resp.Value) resp.Value {
 	if len(args) < 1 {
 		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'DEL' command"}
@@ -149,19 +350,27 @@ func (e *CommandExecutor) del(args []resp.Value) resp.Value {
 	return resp.Value{Typ: "integer", Num: deleted}
 }
 
-func (e *CommandExecutor) persist(args []resp.Value) resp.Value {
-	if len(args) != 1 {
-		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'PERSIST' command"}
+func (e *CommandExecutor) exists(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'EXISTS' command"}
 	}
 
-	key := args[0].Bulk
+	count := 0
+	for _, arg := range args {
+		if e.store.Exists(arg.Bulk) {
+			count++
+		}
+	}
 
-	err := e.store.SetTTL(key, 0)
-	if err != nil {
-		return resp.Value{Typ: "integer", Num: 0} // ключ не найден
+	return resp.Value{Typ: "integer", Num: count}
+}
+
+func (e *CommandExecutor) persist(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'PERSIST' command"}
 	}
 
-	return resp.Value{Typ: "integer", Num: 1}
+	return boolValue(e.store.Persist(args[0].Bulk))
 }
 
 func (e *CommandExecutor) hset(args []resp.Value) resp.Value {
@@ -169,29 +378,19 @@ func (e *CommandExecutor) hset(args []resp.Value) resp.Value {
 		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'HSET' command"}
 	}
 
-	hash := args[0].Bulk
-	fields := 0
-	var ttl time.Duration = 0
-
-	// Проверяем, есть ли TTL (последний аргумент должен быть числом)
-	if len(args) >= 4 {
-		if ttlSec, err := strconv.Atoi(args[len(args)-1].Bulk); err == nil {
-			ttl = time.Duration(ttlSec) * time.Second
-			args = args[:len(args)-1] // удаляем TTL из аргументов
-		}
+	fields := make([]string, 0, len(args)/2)
+	values := make([]string, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		fields = append(fields, args[i].Bulk)
+		values = append(values, args[i+1].Bulk)
 	}
 
-	for i := 1; i < len(args); i += 2 {
-		if i+1 >= len(args) {
-			return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for field-value pairs"}
-		}
-		field := args[i].Bulk
-		value := args[i+1].Bulk
-		e.store.HSet(hash, field, value, ttl)
-		fields++
+	created, err := e.store.HSet(args[0].Bulk, fields, values)
+	if err != nil {
+		return errorValue(err)
 	}
 
-	return resp.Value{Typ: "integer", Num: fields}
+	return resp.Value{Typ: "integer", Num: created}
 }
 
 func (e *CommandExecutor) hget(args []resp.Value) resp.Value {
@@ -199,7 +398,10 @@ func (e *CommandExecutor) hget(args []resp.Value) resp.Value {
 		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'HGET' command"}
 	}
 
-	value, found := e.store.HGet(args[0].Bulk, args[1].Bulk)
+	value, found, err := e.store.HGet(args[0].Bulk, args[1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
 	if !found {
 		return resp.Value{Typ: "null"}
 	}
@@ -216,7 +418,11 @@ func (e *CommandExecutor) hdel(args []resp.Value) resp.Value {
 	deleted := 0
 
 	for i := 1; i < len(args); i++ {
-		if err := e.store.HDelete(hash, args[i].Bulk); err == nil {
+		err := e.store.HDelete(hash, args[i].Bulk)
+		if err == storage.ErrWrongType {
+			return errorValue(err)
+		}
+		if err == nil {
 			deleted++
 		}
 	}
@@ -230,7 +436,10 @@ func (e *CommandExecutor) hgetall(args []resp.Value) resp.Value {
 	}
 
 	collection := args[0].Bulk
-	fields := e.store.HGetAll(collection)
+	fields, err := e.store.HGetAll(collection)
+	if err != nil {
+		return errorValue(err)
+	}
 
 	if fields == nil {
 		// Коллекции нет → пустой массив
@@ -254,7 +463,12 @@ func (e *CommandExecutor) hexists(args []resp.Value) resp.Value {
 	collection := args[0].Bulk
 	field := args[1].Bulk
 
-	if e.store.HExists(collection, field) {
+	exists, err := e.store.HExists(collection, field)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	if exists {
 		return resp.Value{Typ: "integer", Num: 1}
 	}
 
@@ -262,9 +476,12 @@ func (e *CommandExecutor) hexists(args []resp.Value) resp.Value {
 }
 
 func (e *CommandExecutor) flushdb(args []resp.Value) resp.Value {
-	if len(args) > 0 {
+	if len(args) > 1 {
 		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'FLUSHDB' command"}
 	}
+	if !parseFlushMode(args) {
+		return errSyntax
+	}
 
 	e.store.FlushDB()
 	return resp.Value{Typ: "string", Str: "OK"}
@@ -276,52 +493,94 @@ func (e *CommandExecutor) hlen(args []resp.Value) resp.Value {
 	}
 
 	collection := args[0].Bulk
-	return resp.Value{Typ: "integer", Num: e.store.HLen(collection)}
+	length, err := e.store.HLen(collection)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	return resp.Value{Typ: "integer", Num: length}
 }
 
-func (e *CommandExecutor) expire(args []resp.Value) resp.Value {
-	if len(args) != 2 {
-		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'EXPIRE' command"}
-	}
+// expireRequest разобранные аргументы EXPIRE и родственных команд
+type expireRequest struct {
+	at   time.Time
+	cond storage.ExpireCondition
+}
+
+// parseExpire разбирает key time [NX|XX|GT|LT]; время задано в единицах
+// unit, абсолютное или относительно текущего момента
+func parseExpire(name string, args []resp.Value, unit time.Duration, absolute bool) (expireRequest, resp.Value, bool) {
+	var req expireRequest
 
-	seconds, err := strconv.Atoi(args[1].Bulk)
+	n, err := strconv.ParseInt(args[1].Bulk, 10, 64)
 	if err != nil {
-		return resp.Value{Typ: "error", Str: "ERR invalid expire time"}
+		return req, errNotInteger, false
+	}
+	limit := int64(math.MaxInt64) / unit.Nanoseconds()
+	if n > limit || n < -limit {
+		return req, resp.Value{Typ: "error", Str: "ERR invalid expire time in '" + strings.ToLower(name) + "' command"}, false
 	}
 
-	key := args[0].Bulk
-	ttl := time.Duration(seconds) * time.Second
-
-	err = e.store.SetTTL(key, ttl)
-	if err != nil {
-		return resp.Value{Typ: "integer", Num: 0}
+	if absolute {
+		req.at = time.UnixMilli(n * unit.Milliseconds())
+	} else {
+		req.at = time.Now().Add(time.Duration(n) * unit)
 	}
 
-	return resp.Value{Typ: "integer", Num: 1}
+	if len(args) == 3 {
+		cond, ok := parseExpireCondition(args[2].Bulk)
+		if !ok {
+			return req, resp.Value{Typ: "error", Str: "ERR Unsupported option " + args[2].Bulk}, false
+		}
+		req.cond = cond
+	}
+	return req, resp.Value{}, true
 }
 
-func (e *CommandExecutor) ttl(args []resp.Value) resp.Value {
-	if len(args) != 1 {
-		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'TTL' command"}
+// expire обработчик EXPIRE, PEXPIRE, EXPIREAT и PEXPIREAT
+func (e *CommandExecutor) expire(name string, unit time.Duration, absolute bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 2 || len(args) > 3 {
+			return wrongArgs(name)
+		}
+
+		req, reply, ok := parseExpire(name, args, unit, absolute)
+		if !ok {
+			return reply
+		}
+		return boolValue(e.store.Expire(args[0].Bulk, req.at, req.cond))
 	}
+}
 
-	key := args[0].Bulk
+// ttl обработчик TTL, PTTL, EXPIRETIME и PEXPIRETIME: оставшееся время
+// или момент истечения ключа в единицах unit, -1 без TTL, -2 без ключа
+func (e *CommandExecutor) ttl(name string, unit time.Duration, absolute bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) != 1 {
+			return wrongArgs(name)
+		}
 
-	_, found := e.store.Get(key)
-	if !found {
-		return resp.Value{Typ: "integer", Num: -2}
+		at, found := e.store.ExpireTime(args[0].Bulk)
+		switch {
+		case !found:
+			return resp.Value{Typ: "integer", Num: -2}
+		case at.IsZero():
+			return resp.Value{Typ: "integer", Num: -1}
+		case absolute:
+			return resp.Value{Typ: "integer", Num: int(at.UnixMilli() / unit.Milliseconds())}
+		}
+		// Как в Redis, оставшиеся секунды округляются до ближайшего целого
+		ms := max(time.Until(at).Milliseconds(), 0)
+		return resp.Value{Typ: "integer", Num: int((ms + unit.Milliseconds()/2) / unit.Milliseconds())}
 	}
+}
 
-	remaining, err := e.store.TTL(key)
-	if err != nil {
-		if err.Error() == "key has no TTL" {
-			return resp.Value{Typ: "integer", Num: -1} // нет TTL
-		}
-		// Если ключ просрочен, но ещё не удалён
-		return resp.Value{Typ: "integer", Num: -2}
+func (e *CommandExecutor) typ(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for 'TYPE' command"}
 	}
 
-	return resp.Value{Typ: "integer", Num: int(remaining.Seconds())}
+	return resp.Value{Typ: "string", Str: e.store.Type(args[0].Bulk).String()}
 }
 
 func (e *CommandExecutor) info(args []resp.Value) resp.Value {
@@ -333,6 +592,12 @@ func (e *CommandExecutor) info(args []resp.Value) resp.Value {
 		"uptime_secs": strconv.Itoa(int(uptime.Seconds())),
 	}
 
+	expires := e.dbs.ExpireStats()
+	info["expired_keys"] = strconv.FormatInt(expires.ExpiredKeys, 10)
+	info["expired_stale_perc"] = strconv.FormatFloat(expires.StalePerc, 'f', 2, 64)
+	info["expire_cycle_last_us"] = strconv.FormatInt(expires.LastCycle.Microseconds(), 10)
+	info["expire_cycle_cpu_milliseconds"] = strconv.FormatInt(expires.CycleTime.Milliseconds(), 10)
+
 	var sections []string
 	if len(args) > 0 {
 		sections = strings.Split(strings.ToLower(args[0].Bulk), ",")
@@ -352,12 +617,68 @@ func (e *CommandExecutor) info(args []resp.Value) resp.Value {
 }
 
 func (e *CommandExecutor) command(args []resp.Value) resp.Value {
-	commands := []string{"PING", "GET", "SET", "DEL",
-		"HSET", "HGET", "HGETALL", "HEXISTS", "HDEL",
-		"FLUSHDB", "INFO", "EXPIRE", "TTL", "COMMAND", "PERSIST", "HLEN"}
+	commands := make([]string, 0, len(e.commands))
+	for name := range e.commands {
+		commands = append(commands, name)
+	}
+	sort.Strings(commands)
 	return resp.Value{Typ: "array", Array: toRespArray(commands)}
 }
 
+var (
+	errSyntax     = resp.Value{Typ: "error", Str: "ERR syntax error"}
+	errNotInteger = resp.Value{Typ: "error", Str: "ERR value is not an integer or out of range"}
+)
+
+// wrongArgs ответ на вызов команды с неверным числом аргументов
+func wrongArgs(command string) resp.Value {
+	return resp.Value{Typ: "error", Str: "ERR wrong number of arguments for '" + command + "' command"}
+}
+
+// bulkStrings извлекает строковые значения аргументов
+func bulkStrings(args []resp.Value) []string {
+	result := make([]string, len(args))
+	for i, arg := range args {
+		result[i] = arg.Bulk
+	}
+	return result
+}
+
+// boolValue ответ 1/0
+func boolValue(ok bool) resp.Value {
+	if ok {
+		return resp.Value{Typ: "integer", Num: 1}
+	}
+	return resp.Value{Typ: "integer", Num: 0}
+}
+
+// parseInt разбирает целочисленный аргумент
+func parseInt(arg resp.Value) (int, bool) {
+	n, err := strconv.Atoi(arg.Bulk)
+	return n, err == nil
+}
+
+// formatFloat форматирует число так же, как Redis: без лишних нулей,
+// с экспонентой для очень больших и очень маленьких значений
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	abs := math.Abs(f)
+	if abs != 0 && (abs >= 1e17 || abs < 1e-5) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// errorValue превращает ошибку хранилища в ответ RESP
+func errorValue(err error) resp.Value {
+	return resp.Value{Typ: "error", Str: err.Error()}
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
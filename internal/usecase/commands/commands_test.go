@@ -0,0 +1,21 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"testing"
+)
+
+// newTestExecutor создаёт исполнитель над одной базой, которая
+// останавливается по завершении теста
+func newTestExecutor(t *testing.T) *CommandExecutor {
+	t.Helper()
+	dbs := storage.NewDatabases(1)
+	t.Cleanup(dbs.Stop)
+	return NewCommandExecutor(dbs, 0)
+}
+
+// run выполняет команду из строковых аргументов
+func run(e *CommandExecutor, name string, args ...string) resp.Value {
+	return e.Execute(newCommand(name, args...))
+}
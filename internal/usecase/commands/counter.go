@@ -0,0 +1,62 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// incr обработчик INCR, DECR, INCRBY и DECRBY. Для INCR/DECR шаг равен sign,
+// для INCRBY/DECRBY он читается из аргумента и умножается на sign.
+func (e *CommandExecutor) incr(name string, sign int64, withDelta bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if (withDelta && len(args) != 2) || (!withDelta && len(args) != 1) {
+			return wrongArgs(name)
+		}
+
+		delta := sign
+		if withDelta {
+			n, err := strconv.ParseInt(args[1].Bulk, 10, 64)
+			if err != nil {
+				return errNotInteger
+			}
+			if sign < 0 && n == math.MinInt64 {
+				return resp.Value{Typ: "error", Str: "ERR decrement would overflow"}
+			}
+			delta = n * sign
+		}
+
+		value, err := e.store.IncrBy(args[0].Bulk, delta)
+		if err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "integer", Num: int(value)}
+	}
+}
+
+func (e *CommandExecutor) incrbyfloat(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("INCRBYFLOAT")
+	}
+
+	delta, err := strconv.ParseFloat(strings.TrimSpace(args[1].Bulk), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return errNotFloat
+	}
+
+	value, err := e.store.IncrByFloat(args[0].Bulk, delta)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "bulk", Bulk: value}
+}
+
+// rewriteIncrByFloat записывает INCRBYFLOAT как SET итогового значения,
+// чтобы повторное применение не зависело от округления
+func rewriteIncrByFloat(args []resp.Value, reply resp.Value) []resp.Value {
+	if reply.Typ != "bulk" {
+		return nil
+	}
+	return []resp.Value{newCommand("SET", args[0].Bulk, reply.Bulk, "KEEPTTL")}
+}
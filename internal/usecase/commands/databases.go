@@ -0,0 +1,90 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"strings"
+)
+
+// parseDB разбирает номер базы
+func (e *CommandExecutor) parseDB(arg resp.Value) (int, *resp.Value) {
+	db, ok := parseInt(arg)
+	if !ok {
+		return 0, &errNotInteger
+	}
+	if db < 0 || db >= len(e.dbs) {
+		reply := errorValue(storage.ErrDBIndex)
+		return 0, &reply
+	}
+	return db, nil
+}
+
+// parseFlushMode проверяет необязательный аргумент ASYNC или SYNC.
+// Оба режима очищают базу сразу: память старых значений освобождает
+// сборщик мусора.
+func parseFlushMode(args []resp.Value) bool {
+	if len(args) == 0 {
+		return true
+	}
+	mode := strings.ToUpper(args[0].Bulk)
+	return mode == "ASYNC" || mode == "SYNC"
+}
+
+// selectDB проверяет номер базы. Выбранная база — состояние соединения,
+// его переключает сервер после успешного ответа.
+func (e *CommandExecutor) selectDB(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("SELECT")
+	}
+	if _, errReply := e.parseDB(args[0]); errReply != nil {
+		return *errReply
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) move(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("MOVE")
+	}
+	db, errReply := e.parseDB(args[1])
+	if errReply != nil {
+		return *errReply
+	}
+
+	moved, err := e.dbs.Move(args[0].Bulk, e.db, db)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(moved)
+}
+
+func (e *CommandExecutor) swapdb(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("SWAPDB")
+	}
+	a, errReply := e.parseDB(args[0])
+	if errReply != nil {
+		return *errReply
+	}
+	b, errReply := e.parseDB(args[1])
+	if errReply != nil {
+		return *errReply
+	}
+
+	if err := e.dbs.Swap(a, b); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) flushall(args []resp.Value) resp.Value {
+	if len(args) > 1 {
+		return wrongArgs("FLUSHALL")
+	}
+	if !parseFlushMode(args) {
+		return errSyntax
+	}
+
+	e.dbs.FlushAll()
+	return resp.Value{Typ: "string", Str: "OK"}
+}
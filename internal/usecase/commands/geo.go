@@ -0,0 +1,331 @@
+package command
+
+import (
+	"fmt"
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errGeoUnit = resp.Value{Typ: "error", Str: "ERR unsupported unit provided. please use M, KM, FT, MI"}
+
+// parseGeoUnit возвращает число метров в единице расстояния
+func parseGeoUnit(arg string) (float64, bool) {
+	switch strings.ToLower(arg) {
+	case "m":
+		return 1, true
+	case "km":
+		return 1000, true
+	case "ft":
+		return 0.3048, true
+	case "mi":
+		return 1609.34, true
+	default:
+		return 0, false
+	}
+}
+
+// parseGeoPoint разбирает пару долгота, широта
+func parseGeoPoint(lon, lat resp.Value) (storage.GeoPoint, resp.Value, bool) {
+	var p storage.GeoPoint
+	var err1, err2 error
+	p.Lon, err1 = strconv.ParseFloat(lon.Bulk, 64)
+	p.Lat, err2 = strconv.ParseFloat(lat.Bulk, 64)
+	if err1 != nil || err2 != nil || math.IsNaN(p.Lon) || math.IsNaN(p.Lat) {
+		return p, errNotFloat, false
+	}
+	if !storage.ValidGeoPoint(p) {
+		return p, resp.Value{Typ: "error", Str: fmt.Sprintf("ERR invalid longitude,latitude pair %f,%f", p.Lon, p.Lat)}, false
+	}
+	return p, resp.Value{}, true
+}
+
+// coordValue формирует пару координат ответа GEOPOS и WITHCOORD
+func coordValue(p storage.GeoPoint) resp.Value {
+	return resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "bulk", Bulk: strconv.FormatFloat(p.Lon, 'g', 17, 64)},
+		{Typ: "bulk", Bulk: strconv.FormatFloat(p.Lat, 'g', 17, 64)},
+	}}
+}
+
+// distanceValue форматирует расстояние в единицах unit, как Redis: 4 знака после точки
+func distanceValue(meters, unit float64) resp.Value {
+	return resp.Value{Typ: "bulk", Bulk: strconv.FormatFloat(meters/unit, 'f', 4, 64)}
+}
+
+func (e *CommandExecutor) geoadd(args []resp.Value) resp.Value {
+	if len(args) < 4 {
+		return wrongArgs("GEOADD")
+	}
+
+	var opts storage.ZAddOptions
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "CH":
+			opts.CH = true
+		default:
+			break options
+		}
+	}
+
+	triples := args[i:]
+	if len(triples) == 0 || len(triples)%3 != 0 {
+		return resp.Value{Typ: "error", Str: "ERR syntax error. Try GEOADD key [x1] [y1] [name1] [x2] [y2] [name2] ... "}
+	}
+	if opts.NX && opts.XX {
+		return resp.Value{Typ: "error", Str: "ERR XX and NX options at the same time are not compatible"}
+	}
+
+	members := make([]storage.ZMember, 0, len(triples)/3)
+	for j := 0; j < len(triples); j += 3 {
+		p, reply, ok := parseGeoPoint(triples[j], triples[j+1])
+		if !ok {
+			return reply
+		}
+		members = append(members, storage.ZMember{Member: triples[j+2].Bulk, Score: storage.GeoScore(p)})
+	}
+
+	count, _, _, err := e.store.ZAdd(args[0].Bulk, opts, members)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: count}
+}
+
+func (e *CommandExecutor) geopos(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("GEOPOS")
+	}
+
+	points, found, err := e.store.GeoPos(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+
+	result := make([]resp.Value, len(points))
+	for i, p := range points {
+		if found[i] {
+			result[i] = coordValue(p)
+		} else {
+			result[i] = resp.Value{Typ: "null"}
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) geodist(args []resp.Value) resp.Value {
+	if len(args) != 3 && len(args) != 4 {
+		return wrongArgs("GEODIST")
+	}
+
+	unit := 1.0
+	if len(args) == 4 {
+		var ok bool
+		if unit, ok = parseGeoUnit(args[3].Bulk); !ok {
+			return errGeoUnit
+		}
+	}
+
+	points, found, err := e.store.GeoPos(args[0].Bulk, []string{args[1].Bulk, args[2].Bulk})
+	if err != nil {
+		return errorValue(err)
+	}
+	if !found[0] || !found[1] {
+		return resp.Value{Typ: "null"}
+	}
+	return distanceValue(storage.GeoDistance(points[0], points[1]), unit)
+}
+
+func (e *CommandExecutor) geohash(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("GEOHASH")
+	}
+
+	points, found, err := e.store.GeoPos(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+
+	result := make([]resp.Value, len(points))
+	for i, p := range points {
+		if found[i] {
+			result[i] = resp.Value{Typ: "bulk", Bulk: storage.GeoHashString(p)}
+		} else {
+			result[i] = resp.Value{Typ: "null"}
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+// geoSearchRequest разобранные аргументы GEOSEARCH и GEOSEARCHSTORE
+type geoSearchRequest struct {
+	query     storage.GeoQuery
+	unit      float64
+	withDist  bool
+	withHash  bool
+	withCoord bool
+	storeDist bool
+}
+
+// parseGeoSearch разбирает аргументы после ключа источника
+func parseGeoSearch(name string, args []resp.Value, store bool) (geoSearchRequest, resp.Value, bool) {
+	req := geoSearchRequest{unit: 1}
+	q := &req.query
+	hasFrom, hasBy := 0, 0
+
+	for i := 0; i < len(args); i++ {
+		left := len(args) - i - 1
+		switch arg := strings.ToUpper(args[i].Bulk); {
+		case arg == "FROMMEMBER" && left >= 1:
+			q.FromMember, q.HasFromMember = args[i+1].Bulk, true
+			hasFrom++
+			i++
+		case arg == "FROMLONLAT" && left >= 2:
+			p, reply, ok := parseGeoPoint(args[i+1], args[i+2])
+			if !ok {
+				return req, reply, false
+			}
+			q.Center = p
+			hasFrom++
+			i += 2
+		case arg == "BYRADIUS" && left >= 2:
+			radius, err := strconv.ParseFloat(args[i+1].Bulk, 64)
+			if err != nil || math.IsNaN(radius) {
+				return req, resp.Value{Typ: "error", Str: "ERR need numeric radius"}, false
+			}
+			if radius < 0 {
+				return req, resp.Value{Typ: "error", Str: "ERR radius cannot be negative"}, false
+			}
+			unit, ok := parseGeoUnit(args[i+2].Bulk)
+			if !ok {
+				return req, errGeoUnit, false
+			}
+			q.Radius, q.Box, req.unit = radius*unit, false, unit
+			hasBy++
+			i += 2
+		case arg == "BYBOX" && left >= 3:
+			width, err1 := strconv.ParseFloat(args[i+1].Bulk, 64)
+			height, err2 := strconv.ParseFloat(args[i+2].Bulk, 64)
+			if err1 != nil || math.IsNaN(width) {
+				return req, resp.Value{Typ: "error", Str: "ERR need numeric width"}, false
+			}
+			if err2 != nil || math.IsNaN(height) {
+				return req, resp.Value{Typ: "error", Str: "ERR need numeric height"}, false
+			}
+			if width < 0 || height < 0 {
+				return req, resp.Value{Typ: "error", Str: "ERR height or width cannot be negative"}, false
+			}
+			unit, ok := parseGeoUnit(args[i+3].Bulk)
+			if !ok {
+				return req, errGeoUnit, false
+			}
+			q.Width, q.Height, q.Box, req.unit = width*unit, height*unit, true, unit
+			hasBy++
+			i += 3
+		case arg == "ASC":
+			q.Sort = storage.GeoAsc
+		case arg == "DESC":
+			q.Sort = storage.GeoDesc
+		case arg == "COUNT" && left >= 1:
+			count, ok := parseInt(args[i+1])
+			if !ok {
+				return req, errNotInteger, false
+			}
+			if count <= 0 {
+				return req, resp.Value{Typ: "error", Str: "ERR COUNT must be > 0"}, false
+			}
+			q.Count = count
+			i++
+		case arg == "ANY":
+			q.Any = true
+		case arg == "WITHDIST" && !store:
+			req.withDist = true
+		case arg == "WITHHASH" && !store:
+			req.withHash = true
+		case arg == "WITHCOORD" && !store:
+			req.withCoord = true
+		case arg == "STOREDIST" && store:
+			req.storeDist = true
+		default:
+			return req, errSyntax, false
+		}
+	}
+
+	if hasFrom != 1 {
+		return req, resp.Value{Typ: "error", Str: "ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + name}, false
+	}
+	if hasBy != 1 {
+		return req, resp.Value{Typ: "error", Str: "ERR exactly one of BYRADIUS and BYBOX can be specified for " + name}, false
+	}
+	if q.Any && q.Count == 0 {
+		return req, resp.Value{Typ: "error", Str: "ERR the ANY argument requires COUNT argument"}, false
+	}
+	// Без ANY ограничение COUNT относится к ближайшим точкам
+	if q.Count > 0 && !q.Any && q.Sort == storage.GeoUnsorted {
+		q.Sort = storage.GeoAsc
+	}
+	return req, resp.Value{}, true
+}
+
+func (e *CommandExecutor) geosearch(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("GEOSEARCH")
+	}
+
+	req, reply, ok := parseGeoSearch("GEOSEARCH", args[1:], false)
+	if !ok {
+		return reply
+	}
+
+	results, err := e.store.GeoSearch(args[0].Bulk, req.query)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	items := make([]resp.Value, len(results))
+	for i, r := range results {
+		member := resp.Value{Typ: "bulk", Bulk: r.Member}
+		if !req.withDist && !req.withHash && !req.withCoord {
+			items[i] = member
+			continue
+		}
+
+		item := []resp.Value{member}
+		if req.withDist {
+			item = append(item, distanceValue(r.Dist, req.unit))
+		}
+		if req.withHash {
+			item = append(item, resp.Value{Typ: "integer", Num: int(r.Hash)})
+		}
+		if req.withCoord {
+			item = append(item, coordValue(r.Point))
+		}
+		items[i] = resp.Value{Typ: "array", Array: item}
+	}
+	return resp.Value{Typ: "array", Array: items}
+}
+
+func (e *CommandExecutor) geosearchstore(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("GEOSEARCHSTORE")
+	}
+
+	req, reply, ok := parseGeoSearch("GEOSEARCHSTORE", args[2:], true)
+	if !ok {
+		return reply
+	}
+
+	count, err := e.store.GeoSearchStore(args[0].Bulk, args[1].Bulk, req.query, req.storeDist, req.unit)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: count}
+}
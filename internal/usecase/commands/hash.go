@@ -0,0 +1,462 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/glob"
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidCursor     = resp.Value{Typ: "error", Str: "ERR invalid cursor"}
+	errFieldsMissing     = resp.Value{Typ: "error", Str: "ERR Mandatory argument FIELDS is missing or not at the right position"}
+	errInvalidFieldsTime = resp.Value{Typ: "error", Str: "ERR invalid expire time, must be >= 0 and <= 2^48"}
+)
+
+// maxFieldExpire предельный срок жизни поля в миллисекундах
+const maxFieldExpire = 1<<48 - 1
+
+// scanOptions общие опции команд семейства SCAN
+type scanOptions struct {
+	cursor   uint64
+	pattern  string
+	count    int
+	noValues bool
+	typ      string
+}
+
+// parseScanOptions разбирает курсор и опции MATCH и COUNT; при allowNoValues
+// принимается также NOVALUES, при allowType — TYPE
+func parseScanOptions(args []resp.Value, allowNoValues, allowType bool) (scanOptions, resp.Value, bool) {
+	opts := scanOptions{count: 10}
+
+	cursor, err := strconv.ParseUint(args[0].Bulk, 10, 64)
+	if err != nil {
+		return opts, errInvalidCursor, false
+	}
+	opts.cursor = cursor
+
+	for i := 1; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i].Bulk); {
+		case option == "MATCH" && i+1 < len(args):
+			opts.pattern = args[i+1].Bulk
+			i++
+		case option == "COUNT" && i+1 < len(args):
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				return opts, errNotInteger, false
+			}
+			if n < 1 {
+				return opts, errSyntax, false
+			}
+			opts.count = n
+			i++
+		case option == "NOVALUES" && allowNoValues:
+			opts.noValues = true
+		case option == "TYPE" && allowType && i+1 < len(args):
+			opts.typ = args[i+1].Bulk
+			i++
+		default:
+			return opts, errSyntax, false
+		}
+	}
+	return opts, resp.Value{}, true
+}
+
+// matches проверяет элемент по шаблону MATCH
+func (o scanOptions) matches(item string) bool {
+	return o.pattern == "" || o.pattern == "*" || glob.Match(o.pattern, item)
+}
+
+// scanReply ответ SCAN: следующий курсор и найденные элементы
+func scanReply(next uint64, items []resp.Value) resp.Value {
+	return resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "bulk", Bulk: strconv.FormatUint(next, 10)},
+		{Typ: "array", Array: items},
+	}}
+}
+
+func (e *CommandExecutor) hmget(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("HMGET")
+	}
+
+	values, found, err := e.store.HMGet(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+
+	result := make([]resp.Value, len(values))
+	for i, value := range values {
+		if found[i] {
+			result[i] = resp.Value{Typ: "bulk", Bulk: value}
+		} else {
+			result[i] = resp.Value{Typ: "null"}
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) hkeys(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("HKEYS")
+	}
+
+	fields, err := e.store.HGetAll(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	result := make([]resp.Value, 0, len(fields))
+	for field := range fields {
+		result = append(result, resp.Value{Typ: "bulk", Bulk: field})
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) hvals(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("HVALS")
+	}
+
+	fields, err := e.store.HGetAll(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	result := make([]resp.Value, 0, len(fields))
+	for _, value := range fields {
+		result = append(result, resp.Value{Typ: "bulk", Bulk: value})
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) hsetnx(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("HSETNX")
+	}
+
+	created, err := e.store.HSetNX(args[0].Bulk, args[1].Bulk, args[2].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(created)
+}
+
+func (e *CommandExecutor) hincrby(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("HINCRBY")
+	}
+
+	delta, err := strconv.ParseInt(args[2].Bulk, 10, 64)
+	if err != nil {
+		return errNotInteger
+	}
+
+	value, err := e.store.HIncrBy(args[0].Bulk, args[1].Bulk, delta)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: int(value)}
+}
+
+func (e *CommandExecutor) hincrbyfloat(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("HINCRBYFLOAT")
+	}
+
+	delta, err := strconv.ParseFloat(strings.TrimSpace(args[2].Bulk), 64)
+	if err != nil || math.IsNaN(delta) || math.IsInf(delta, 0) {
+		return errNotFloat
+	}
+
+	value, err := e.store.HIncrByFloat(args[0].Bulk, args[1].Bulk, delta)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "bulk", Bulk: value}
+}
+
+func (e *CommandExecutor) hstrlen(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("HSTRLEN")
+	}
+
+	length, err := e.store.HStrLen(args[0].Bulk, args[1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: length}
+}
+
+func (e *CommandExecutor) hrandfield(args []resp.Value) resp.Value {
+	if len(args) < 1 || len(args) > 3 {
+		return wrongArgs("HRANDFIELD")
+	}
+
+	count := 1
+	withValues := false
+	if len(args) >= 2 {
+		n, ok := parseInt(args[1])
+		if !ok {
+			return errNotInteger
+		}
+		if n < -storage.MaxRandomCount {
+			return resp.Value{Typ: "error", Str: "ERR value is out of range"}
+		}
+		count = n
+	}
+	if len(args) == 3 {
+		if strings.ToUpper(args[2].Bulk) != "WITHVALUES" {
+			return errSyntax
+		}
+		withValues = true
+	}
+
+	fields, values, err := e.store.HRandField(args[0].Bulk, count)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	if len(args) == 1 {
+		if len(fields) == 0 {
+			return resp.Value{Typ: "null"}
+		}
+		return resp.Value{Typ: "bulk", Bulk: fields[0]}
+	}
+
+	result := make([]resp.Value, 0, len(fields)*2)
+	for i, field := range fields {
+		result = append(result, resp.Value{Typ: "bulk", Bulk: field})
+		if withValues {
+			result = append(result, resp.Value{Typ: "bulk", Bulk: values[i]})
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) hscan(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("HSCAN")
+	}
+
+	opts, reply, ok := parseScanOptions(args[1:], true, false)
+	if !ok {
+		return reply
+	}
+
+	fields, values, next, err := e.store.HScan(args[0].Bulk, opts.cursor, opts.count)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	items := make([]resp.Value, 0, len(fields)*2)
+	for i, field := range fields {
+		if !opts.matches(field) {
+			continue
+		}
+		items = append(items, resp.Value{Typ: "bulk", Bulk: field})
+		if !opts.noValues {
+			items = append(items, resp.Value{Typ: "bulk", Bulk: values[i]})
+		}
+	}
+	return scanReply(next, items)
+}
+
+// parseFields разбирает FIELDS numfields field ...
+func parseFields(args []resp.Value) ([]string, resp.Value, bool) {
+	if len(args) < 2 || strings.ToUpper(args[0].Bulk) != "FIELDS" {
+		return nil, errFieldsMissing, false
+	}
+
+	n, ok := parseInt(args[1])
+	if !ok {
+		return nil, errNotInteger, false
+	}
+	if n <= 0 {
+		return nil, resp.Value{Typ: "error", Str: "ERR Parameter `numFields` should be greater than 0"}, false
+	}
+	if n != len(args)-2 {
+		return nil, resp.Value{Typ: "error", Str: "ERR The `numfields` parameter must match the number of arguments"}, false
+	}
+	return bulkStrings(args[2:]), resp.Value{}, true
+}
+
+// parseExpireCondition разбирает необязательное условие NX|XX|GT|LT
+func parseExpireCondition(arg string) (storage.ExpireCondition, bool) {
+	switch strings.ToUpper(arg) {
+	case "NX":
+		return storage.ExpireNX, true
+	case "XX":
+		return storage.ExpireXX, true
+	case "GT":
+		return storage.ExpireGT, true
+	case "LT":
+		return storage.ExpireLT, true
+	default:
+		return storage.ExpireAlways, false
+	}
+}
+
+// hexpireRequest разобранные аргументы HEXPIRE и родственных команд
+type hexpireRequest struct {
+	at     time.Time
+	cond   storage.ExpireCondition
+	fields []string
+}
+
+// parseHExpire разбирает key time [NX|XX|GT|LT] FIELDS numfields field ...;
+// время задано в единицах unit, абсолютное или относительно текущего момента
+func parseHExpire(args []resp.Value, unit time.Duration, absolute bool) (hexpireRequest, resp.Value, bool) {
+	var req hexpireRequest
+
+	n, err := strconv.ParseInt(args[1].Bulk, 10, 64)
+	if err != nil {
+		return req, errNotInteger, false
+	}
+	if n < 0 || n > maxFieldExpire/unit.Milliseconds() {
+		return req, errInvalidFieldsTime, false
+	}
+
+	ms := n * unit.Milliseconds()
+	if absolute {
+		req.at = time.UnixMilli(ms)
+	} else {
+		req.at = time.Now().Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	i := 2
+	if i < len(args) {
+		if cond, ok := parseExpireCondition(args[i].Bulk); ok {
+			req.cond = cond
+			i++
+		}
+	}
+
+	fields, reply, ok := parseFields(args[i:])
+	if !ok {
+		return req, reply, false
+	}
+	req.fields = fields
+	return req, resp.Value{}, true
+}
+
+// integersValue ответ-массив целых чисел
+func integersValue(items []int) resp.Value {
+	result := make([]resp.Value, len(items))
+	for i, n := range items {
+		result[i] = resp.Value{Typ: "integer", Num: n}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) hexpire(name string, unit time.Duration, absolute bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 4 {
+			return wrongArgs(name)
+		}
+
+		req, reply, ok := parseHExpire(args, unit, absolute)
+		if !ok {
+			return reply
+		}
+
+		result, err := e.store.HExpire(args[0].Bulk, req.fields, req.at, req.cond)
+		if err != nil {
+			return errorValue(err)
+		}
+		return integersValue(result)
+	}
+}
+
+// httl обработчик HTTL, HPTTL, HEXPIRETIME и HPEXPIRETIME: оставшееся время
+// или момент истечения полей в единицах unit
+func (e *CommandExecutor) httl(name string, unit time.Duration, absolute bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 3 {
+			return wrongArgs(name)
+		}
+
+		fields, reply, ok := parseFields(args[1:])
+		if !ok {
+			return reply
+		}
+
+		times, found, err := e.store.HExpireTime(args[0].Bulk, fields)
+		if err != nil {
+			return errorValue(err)
+		}
+
+		now := time.Now()
+		result := make([]int, len(fields))
+		for i, at := range times {
+			switch {
+			case !found[i]:
+				result[i] = storage.FieldNotFound
+			case at.IsZero():
+				result[i] = storage.FieldNoTTL
+			default:
+				ms := at.UnixMilli()
+				if !absolute {
+					ms = max(at.Sub(now).Milliseconds(), 0)
+				}
+				result[i] = int((ms + unit.Milliseconds() - 1) / unit.Milliseconds())
+			}
+		}
+		return integersValue(result)
+	}
+}
+
+func (e *CommandExecutor) hpersist(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("HPERSIST")
+	}
+
+	fields, reply, ok := parseFields(args[1:])
+	if !ok {
+		return reply
+	}
+
+	result, err := e.store.HPersist(args[0].Bulk, fields)
+	if err != nil {
+		return errorValue(err)
+	}
+	return integersValue(result)
+}
+
+// rewriteHExpire записывает HEXPIRE и родственные команды как HPEXPIREAT с
+// абсолютным временем для полей, получивших TTL, и HDEL для удалённых полей.
+// Условие NX/XX/GT/LT уже проверено, поэтому в AOF не попадает.
+func rewriteHExpire(unit time.Duration, absolute bool) Rewriter {
+	return func(args []resp.Value, reply resp.Value) []resp.Value {
+		req, _, ok := parseHExpire(args, unit, absolute)
+		if !ok || len(reply.Array) != len(req.fields) {
+			return nil
+		}
+
+		var expired, deleted []string
+		for i, code := range reply.Array {
+			switch code.Num {
+			case storage.FieldExpireSet:
+				expired = append(expired, req.fields[i])
+			case storage.FieldExpireDelete:
+				deleted = append(deleted, req.fields[i])
+			}
+		}
+
+		key := args[0].Bulk
+		var result []resp.Value
+		if len(expired) > 0 {
+			at := strconv.FormatInt(req.at.UnixMilli(), 10)
+			result = append(result, newCommand("HPEXPIREAT",
+				append([]string{key, at, "FIELDS", strconv.Itoa(len(expired))}, expired...)...))
+		}
+		if len(deleted) > 0 {
+			result = append(result, newCommand("HDEL", append([]string{key}, deleted...)...))
+		}
+		return result
+	}
+}
@@ -0,0 +1,33 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHRandFieldCount(t *testing.T) {
+	e := newTestExecutor(t)
+	run(e, "HSET", "h", "a", "1", "b", "2", "c", "3")
+
+	tests := []struct {
+		args    []string
+		wantLen int
+		wantErr bool
+	}{
+		{[]string{"2"}, 2, false},
+		{[]string{"10"}, 3, false},
+		{[]string{"-5"}, 5, false},
+		{[]string{"-5", "WITHVALUES"}, 10, false},
+		{[]string{"2", "VALUES"}, 0, true},
+		{[]string{strconv.Itoa(-storage.MaxRandomCount - 1)}, 0, true},
+		{[]string{strconv.Itoa(math.MinInt), "WITHVALUES"}, 0, true},
+	}
+	for _, tt := range tests {
+		got := run(e, "HRANDFIELD", append([]string{"h"}, tt.args...)...)
+		if (got.Typ == "error") != tt.wantErr || len(got.Array) != tt.wantLen {
+			t.Errorf("HRANDFIELD h %q = %s with %d items %s", tt.args, got.Typ, len(got.Array), got.Str)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+)
+
+func (e *CommandExecutor) pfadd(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("PFADD")
+	}
+
+	changed, err := e.store.PFAdd(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(changed)
+}
+
+func (e *CommandExecutor) pfcount(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("PFCOUNT")
+	}
+
+	count, err := e.store.PFCount(bulkStrings(args))
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: int(count)}
+}
+
+func (e *CommandExecutor) pfmerge(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("PFMERGE")
+	}
+
+	if err := e.store.PFMerge(args[0].Bulk, bulkStrings(args[1:])); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
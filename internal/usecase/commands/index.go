@@ -0,0 +1,185 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"strconv"
+	"strings"
+)
+
+// idxDefaultLimit число документов в ответе IDX.SEARCH без LIMIT
+const idxDefaultLimit = 10
+
+// Теги подсветки по умолчанию, как в RediSearch
+const (
+	highlightOpen  = "<b>"
+	highlightClose = "</b>"
+)
+
+var indexFieldTypes = map[string]storage.IndexFieldType{
+	"NUMERIC": storage.IndexNumeric,
+	"TAG":     storage.IndexTag,
+	"TEXT":    storage.IndexText,
+}
+
+// idxCreate разбирает IDX.CREATE name ON HASH [PREFIX [n] prefix ...]
+// SCHEMA field type [WEIGHT w] ...
+func (e *CommandExecutor) idxCreate(args []resp.Value) resp.Value {
+	if len(args) < 5 {
+		return wrongArgs("IDX.CREATE")
+	}
+
+	def := storage.IndexDefinition{Name: args[0].Bulk}
+	i := 1
+	if strings.ToUpper(args[i].Bulk) == "ON" {
+		if strings.ToUpper(args[i+1].Bulk) != "HASH" {
+			return resp.Value{Typ: "error", Str: "ERR only HASH indexes are supported"}
+		}
+		i += 2
+	}
+	if i < len(args) && strings.ToUpper(args[i].Bulk) == "PREFIX" {
+		for i++; i < len(args) && strings.ToUpper(args[i].Bulk) != "SCHEMA"; i++ {
+			def.Prefixes = append(def.Prefixes, args[i].Bulk)
+		}
+		// Форма с числом префиксов, как в FT.CREATE
+		if len(def.Prefixes) > 0 {
+			if n, err := strconv.Atoi(def.Prefixes[0]); err == nil && n == len(def.Prefixes)-1 {
+				def.Prefixes = def.Prefixes[1:]
+			}
+		}
+	}
+	if i >= len(args) || strings.ToUpper(args[i].Bulk) != "SCHEMA" {
+		return errSyntax
+	}
+
+	schema := args[i+1:]
+	if len(schema) == 0 {
+		return errSyntax
+	}
+	for j := 0; j < len(schema); j += 2 {
+		if j+1 >= len(schema) {
+			return errSyntax
+		}
+		field := storage.IndexField{Name: schema[j].Bulk, Weight: 1}
+		typ, known := indexFieldTypes[strings.ToUpper(schema[j+1].Bulk)]
+		if !known {
+			return resp.Value{Typ: "error", Str: "ERR Invalid field type for field `" + field.Name + "`"}
+		}
+		field.Type = typ
+
+		if typ == storage.IndexText && j+3 < len(schema) && strings.ToUpper(schema[j+2].Bulk) == "WEIGHT" {
+			weight, err := strconv.ParseFloat(schema[j+3].Bulk, 64)
+			if err != nil || weight <= 0 {
+				return resp.Value{Typ: "error", Str: "ERR Bad weight for field `" + field.Name + "`"}
+			}
+			field.Weight = weight
+			j += 2
+		}
+		def.Schema = append(def.Schema, field)
+	}
+
+	if err := e.store.IdxCreate(def); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) idxDrop(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("IDX.DROP")
+	}
+
+	if err := e.store.IdxDrop(args[0].Bulk); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+// idxSearch отвечает числом найденных хэшей и для каждого ключом,
+// оценкой при WITHSCORES и [поле, значение, ...] без NOCONTENT
+func (e *CommandExecutor) idxSearch(name string) func([]resp.Value) resp.Value {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 2 {
+			return wrongArgs(name)
+		}
+
+		q, err := storage.ParseIndexQuery(args[1].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		opts, withScores, noContent, errValue := parseSearchOptions(args[2:])
+		if errValue != nil {
+			return *errValue
+		}
+
+		total, docs, err := e.store.IdxSearch(args[0].Bulk, q, opts)
+		if err != nil {
+			return errorValue(err)
+		}
+		result := make([]resp.Value, 0, 1+3*len(docs))
+		result = append(result, resp.Value{Typ: "integer", Num: total})
+		for _, doc := range docs {
+			result = append(result, resp.Value{Typ: "bulk", Bulk: doc.Key})
+			if withScores {
+				result = append(result, resp.Value{Typ: "bulk", Bulk: formatFloat(doc.Score)})
+			}
+			if !noContent {
+				result = append(result, resp.Value{Typ: "array", Array: toRespArray(doc.Fields)})
+			}
+		}
+		return resp.Value{Typ: "array", Array: result}
+	}
+}
+
+// parseSearchOptions разбирает SORTBY, LIMIT, WITHSCORES, NOCONTENT
+// и HIGHLIGHT [FIELDS n field ...] [TAGS open close]
+func parseSearchOptions(args []resp.Value) (storage.IndexSearchOptions, bool, bool, *resp.Value) {
+	opts := storage.IndexSearchOptions{Limit: idxDefaultLimit}
+	withScores, noContent := false, false
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i].Bulk); {
+		case option == "WITHSCORES":
+			withScores = true
+		case option == "NOCONTENT":
+			noContent = true
+		case option == "HIGHLIGHT":
+			opts.Highlight = true
+			opts.HighlightOpen, opts.HighlightClose = highlightOpen, highlightClose
+			if i+2 < len(args) && strings.ToUpper(args[i+1].Bulk) == "FIELDS" {
+				n, ok := parseInt(args[i+2])
+				if !ok || n < 1 || n > len(args)-i-3 {
+					return opts, false, false, &errSyntax
+				}
+				opts.HighlightFields = bulkStrings(args[i+3 : i+3+n])
+				i += 2 + n
+			}
+			if i+3 < len(args) && strings.ToUpper(args[i+1].Bulk) == "TAGS" {
+				opts.HighlightOpen, opts.HighlightClose = args[i+2].Bulk, args[i+3].Bulk
+				i += 3
+			}
+		case option == "SORTBY" && i+1 < len(args):
+			opts.SortBy = args[i+1].Bulk
+			i++
+			if i+1 < len(args) {
+				switch strings.ToUpper(args[i+1].Bulk) {
+				case "ASC":
+					i++
+				case "DESC":
+					opts.Descending = true
+					i++
+				}
+			}
+		case option == "LIMIT" && i+2 < len(args):
+			offset, okOffset := parseInt(args[i+1])
+			limit, okLimit := parseInt(args[i+2])
+			if !okOffset || !okLimit || offset < 0 || limit < 0 {
+				return opts, false, false, &resp.Value{Typ: "error", Str: "ERR Invalid LIMIT parameters"}
+			}
+			opts.Offset, opts.Limit = offset, limit
+			i += 2
+		default:
+			return opts, false, false, &errSyntax
+		}
+	}
+	return opts, withScores, noContent, nil
+}
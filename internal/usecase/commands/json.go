@@ -0,0 +1,351 @@
+package command
+
+import (
+	"errors"
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"strconv"
+	"strings"
+)
+
+// parseJSONPath разбирает путь JSONPath или путь старого синтаксиса
+func parseJSONPath(arg string) (*storage.JSONPath, resp.Value, bool) {
+	path, err := storage.ParseJSONPath(arg)
+	if err != nil {
+		return nil, errorValue(err), false
+	}
+	return path, resp.Value{}, true
+}
+
+// optionalJSONPath разбирает необязательный путь; по умолчанию — корень
+// в старом синтаксисе, как в RedisJSON
+func optionalJSONPath(args []resp.Value, i int) (*storage.JSONPath, resp.Value, bool) {
+	if i < len(args) {
+		return parseJSONPath(args[i].Bulk)
+	}
+	return parseJSONPath(".")
+}
+
+// jsonReply формирует ответ по результатам для значений пути: для пути
+// старого синтаксиса — один результат, для JSONPath — массив, в котором
+// nil означает значение неподходящего типа
+func jsonReply(path *storage.JSONPath, results []any, value func(any) resp.Value) resp.Value {
+	if path.Legacy {
+		if len(results) == 0 || results[0] == nil {
+			return resp.Value{Typ: "null"}
+		}
+		return value(results[0])
+	}
+
+	items := make([]resp.Value, len(results))
+	for i, r := range results {
+		if r == nil {
+			items[i] = resp.Value{Typ: "null"}
+		} else {
+			items[i] = value(r)
+		}
+	}
+	return resp.Value{Typ: "array", Array: items}
+}
+
+func jsonInteger(r any) resp.Value {
+	return resp.Value{Typ: "integer", Num: int(r.(int64))}
+}
+
+func jsonBulk(r any) resp.Value {
+	return resp.Value{Typ: "bulk", Bulk: r.(string)}
+}
+
+// jsonReadError возвращает null для отсутствующего ключа в командах чтения
+func jsonReadError(err error) resp.Value {
+	if errors.Is(err, storage.ErrJSONNoKey) {
+		return resp.Value{Typ: "null"}
+	}
+	return errorValue(err)
+}
+
+func (e *CommandExecutor) jsonSet(args []resp.Value) resp.Value {
+	if len(args) != 3 && len(args) != 4 {
+		return wrongArgs("JSON.SET")
+	}
+
+	path, reply, ok := parseJSONPath(args[1].Bulk)
+	if !ok {
+		return reply
+	}
+	value, err := storage.ParseJSON(args[2].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	cond := storage.SetAlways
+	if len(args) == 4 {
+		switch strings.ToUpper(args[3].Bulk) {
+		case "NX":
+			cond = storage.SetIfNotExists
+		case "XX":
+			cond = storage.SetIfExists
+		default:
+			return errSyntax
+		}
+	}
+
+	applied, err := e.store.JSONSet(args[0].Bulk, path, value, cond)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !applied {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) jsonGet(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("JSON.GET")
+	}
+
+	var format storage.JSONFormat
+	var paths []*storage.JSONPath
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(args[i].Bulk)
+		if (option == "INDENT" || option == "NEWLINE" || option == "SPACE") && i+1 < len(args) {
+			switch option {
+			case "INDENT":
+				format.Indent = args[i+1].Bulk
+			case "NEWLINE":
+				format.Newline = args[i+1].Bulk
+			case "SPACE":
+				format.Space = args[i+1].Bulk
+			}
+			i++
+			continue
+		}
+
+		path, reply, ok := parseJSONPath(args[i].Bulk)
+		if !ok {
+			return reply
+		}
+		paths = append(paths, path)
+	}
+
+	value, found, err := e.store.JSONGet(args[0].Bulk, paths, format)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !found {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: value}
+}
+
+func (e *CommandExecutor) jsonMGet(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("JSON.MGET")
+	}
+
+	path, reply, ok := parseJSONPath(args[len(args)-1].Bulk)
+	if !ok {
+		return reply
+	}
+
+	values, found := e.store.JSONMGet(bulkStrings(args[:len(args)-1]), path)
+	result := make([]resp.Value, len(values))
+	for i, value := range values {
+		if found[i] {
+			result[i] = resp.Value{Typ: "bulk", Bulk: value}
+		} else {
+			result[i] = resp.Value{Typ: "null"}
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+// jsonDel обработчик JSON.DEL и JSON.FORGET; путь по умолчанию — корень
+func (e *CommandExecutor) jsonDel(name string) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) != 1 && len(args) != 2 {
+			return wrongArgs(name)
+		}
+
+		path, reply, ok := optionalJSONPath(args, 1)
+		if !ok {
+			return reply
+		}
+
+		deleted, err := e.store.JSONDel(args[0].Bulk, path)
+		if err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "integer", Num: deleted}
+	}
+}
+
+func (e *CommandExecutor) jsonType(args []resp.Value) resp.Value {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgs("JSON.TYPE")
+	}
+
+	path, reply, ok := optionalJSONPath(args, 1)
+	if !ok {
+		return reply
+	}
+
+	results, err := e.store.JSONType(args[0].Bulk, path)
+	if err != nil {
+		return jsonReadError(err)
+	}
+	return jsonReply(path, results, func(r any) resp.Value {
+		if path.Legacy {
+			return resp.Value{Typ: "string", Str: r.(string)}
+		}
+		return jsonBulk(r)
+	})
+}
+
+func (e *CommandExecutor) jsonNumIncrBy(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("JSON.NUMINCRBY")
+	}
+
+	path, reply, ok := parseJSONPath(args[1].Bulk)
+	if !ok {
+		return reply
+	}
+	delta, err := storage.ParseJSON(args[2].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	switch delta.(type) {
+	case int64, float64:
+	default:
+		return errorValue(storage.ErrJSONNotNumber)
+	}
+
+	results, err := e.store.JSONNumIncrBy(args[0].Bulk, path, delta)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	// Новые значения возвращаются одной строкой JSON
+	var format storage.JSONFormat
+	if path.Legacy {
+		return resp.Value{Typ: "bulk", Bulk: format.JSONString(results[0])}
+	}
+	items := make([]string, len(results))
+	for i, r := range results {
+		items[i] = format.JSONString(r)
+	}
+	return resp.Value{Typ: "bulk", Bulk: "[" + strings.Join(items, ",") + "]"}
+}
+
+func (e *CommandExecutor) jsonStrAppend(args []resp.Value) resp.Value {
+	if len(args) != 2 && len(args) != 3 {
+		return wrongArgs("JSON.STRAPPEND")
+	}
+
+	path, reply, ok := optionalJSONPath(args[:len(args)-1], 1)
+	if !ok {
+		return reply
+	}
+	value, err := storage.ParseJSON(args[len(args)-1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	suffix, ok := value.(string)
+	if !ok {
+		return resp.Value{Typ: "error", Str: "ERR wrong type of value - expected a JSON string"}
+	}
+
+	results, err := e.store.JSONStrAppend(args[0].Bulk, path, suffix)
+	if err != nil {
+		return errorValue(err)
+	}
+	return jsonReply(path, results, jsonInteger)
+}
+
+func (e *CommandExecutor) jsonArrAppend(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("JSON.ARRAPPEND")
+	}
+
+	path, reply, ok := parseJSONPath(args[1].Bulk)
+	if !ok {
+		return reply
+	}
+	values := make([]any, len(args)-2)
+	for i, arg := range args[2:] {
+		value, err := storage.ParseJSON(arg.Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		values[i] = value
+	}
+
+	results, err := e.store.JSONArrAppend(args[0].Bulk, path, values)
+	if err != nil {
+		return errorValue(err)
+	}
+	return jsonReply(path, results, jsonInteger)
+}
+
+func (e *CommandExecutor) jsonArrPop(args []resp.Value) resp.Value {
+	if len(args) < 1 || len(args) > 3 {
+		return wrongArgs("JSON.ARRPOP")
+	}
+
+	path, reply, ok := optionalJSONPath(args, 1)
+	if !ok {
+		return reply
+	}
+	index := -1
+	if len(args) == 3 {
+		n, err := strconv.Atoi(args[2].Bulk)
+		if err != nil {
+			return errNotInteger
+		}
+		index = n
+	}
+
+	results, err := e.store.JSONArrPop(args[0].Bulk, path, index)
+	if err != nil {
+		return errorValue(err)
+	}
+	return jsonReply(path, results, jsonBulk)
+}
+
+func (e *CommandExecutor) jsonArrLen(args []resp.Value) resp.Value {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgs("JSON.ARRLEN")
+	}
+
+	path, reply, ok := optionalJSONPath(args, 1)
+	if !ok {
+		return reply
+	}
+
+	results, err := e.store.JSONArrLen(args[0].Bulk, path)
+	if err != nil {
+		return jsonReadError(err)
+	}
+	return jsonReply(path, results, jsonInteger)
+}
+
+func (e *CommandExecutor) jsonObjKeys(args []resp.Value) resp.Value {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgs("JSON.OBJKEYS")
+	}
+
+	path, reply, ok := optionalJSONPath(args, 1)
+	if !ok {
+		return reply
+	}
+
+	results, err := e.store.JSONObjKeys(args[0].Bulk, path)
+	if err != nil {
+		return jsonReadError(err)
+	}
+	return jsonReply(path, results, func(r any) resp.Value {
+		return resp.Value{Typ: "array", Array: toRespArray(r.([]string))}
+	})
+}
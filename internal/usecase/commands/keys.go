@@ -0,0 +1,123 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"strings"
+)
+
+func (e *CommandExecutor) scan(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("SCAN")
+	}
+
+	opts, reply, ok := parseScanOptions(args, false, true)
+	if !ok {
+		return reply
+	}
+
+	keys, next := e.store.Scan(opts.cursor, opts.count, opts.typ)
+	items := make([]resp.Value, 0, len(keys))
+	for _, key := range keys {
+		if opts.matches(key) {
+			items = append(items, resp.Value{Typ: "bulk", Bulk: key})
+		}
+	}
+	return scanReply(next, items)
+}
+
+func (e *CommandExecutor) keys(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("KEYS")
+	}
+	return resp.Value{Typ: "array", Array: toRespArray(e.store.Keys(args[0].Bulk))}
+}
+
+func (e *CommandExecutor) dbsize(args []resp.Value) resp.Value {
+	if len(args) != 0 {
+		return wrongArgs("DBSIZE")
+	}
+	return resp.Value{Typ: "integer", Num: e.store.DBSize()}
+}
+
+func (e *CommandExecutor) randomkey(args []resp.Value) resp.Value {
+	if len(args) != 0 {
+		return wrongArgs("RANDOMKEY")
+	}
+	key, found := e.store.RandomKey()
+	if !found {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: key}
+}
+
+// touch считает существующие ключи; время доступа к ключам не хранится
+func (e *CommandExecutor) touch(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("TOUCH")
+	}
+
+	count := 0
+	for _, arg := range args {
+		if e.store.Exists(arg.Bulk) {
+			count++
+		}
+	}
+	return resp.Value{Typ: "integer", Num: count}
+}
+
+// unlink удаляет ключи, как DEL: память освобождается сборщиком мусора
+func (e *CommandExecutor) unlink(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("UNLINK")
+	}
+	return e.del(args)
+}
+
+// rename реализует RENAME и RENAMENX (при nx)
+func (e *CommandExecutor) rename(name string, nx bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+
+		renamed, err := e.store.Rename(args[0].Bulk, args[1].Bulk, nx)
+		if err != nil {
+			return errorValue(err)
+		}
+		if nx {
+			return boolValue(renamed)
+		}
+		return resp.Value{Typ: "string", Str: "OK"}
+	}
+}
+
+func (e *CommandExecutor) copyKey(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("COPY")
+	}
+
+	db, replace := e.db, false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "REPLACE":
+			replace = true
+		case "DB":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			i++
+			var ok bool
+			if db, ok = parseInt(args[i]); !ok {
+				return errNotInteger
+			}
+		default:
+			return errSyntax
+		}
+	}
+
+	copied, err := e.dbs.Copy(args[0].Bulk, e.db, args[1].Bulk, db, replace)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(copied)
+}
@@ -0,0 +1,274 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"strings"
+)
+
+// parseListEnd разбирает аргумент LEFT/RIGHT
+func parseListEnd(arg resp.Value) (storage.ListEnd, bool) {
+	switch strings.ToUpper(arg.Bulk) {
+	case "LEFT":
+		return storage.ListLeft, true
+	case "RIGHT":
+		return storage.ListRight, true
+	default:
+		return 0, false
+	}
+}
+
+func (e *CommandExecutor) push(name string, end storage.ListEnd, onlyExisting bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 2 {
+			return wrongArgs(name)
+		}
+
+		values := make([]string, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			values = append(values, arg.Bulk)
+		}
+
+		length, err := e.store.Push(args[0].Bulk, end, values, onlyExisting)
+		if err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "integer", Num: length}
+	}
+}
+
+func (e *CommandExecutor) pop(name string, end storage.ListEnd) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 1 || len(args) > 2 {
+			return wrongArgs(name)
+		}
+
+		count := 1
+		if len(args) == 2 {
+			n, ok := parseInt(args[1])
+			if !ok || n < 0 {
+				return resp.Value{Typ: "error", Str: "ERR value is out of range, must be positive"}
+			}
+			count = n
+		}
+
+		values, err := e.store.Pop(args[0].Bulk, end, count)
+		if err != nil {
+			return errorValue(err)
+		}
+		if values == nil {
+			return resp.Value{Typ: "null"}
+		}
+
+		if len(args) == 2 {
+			return resp.Value{Typ: "array", Array: toRespArray(values)}
+		}
+		if len(values) == 0 {
+			return resp.Value{Typ: "null"}
+		}
+		return resp.Value{Typ: "bulk", Bulk: values[0]}
+	}
+}
+
+func (e *CommandExecutor) llen(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("LLEN")
+	}
+
+	length, err := e.store.LLen(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: length}
+}
+
+func (e *CommandExecutor) lrange(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("LRANGE")
+	}
+
+	start, ok1 := parseInt(args[1])
+	stop, ok2 := parseInt(args[2])
+	if !ok1 || !ok2 {
+		return errNotInteger
+	}
+
+	items, err := e.store.LRange(args[0].Bulk, start, stop)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "array", Array: toRespArray(items)}
+}
+
+func (e *CommandExecutor) lindex(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("LINDEX")
+	}
+
+	index, ok := parseInt(args[1])
+	if !ok {
+		return errNotInteger
+	}
+
+	value, found, err := e.store.LIndex(args[0].Bulk, index)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !found {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: value}
+}
+
+func (e *CommandExecutor) lset(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("LSET")
+	}
+
+	index, ok := parseInt(args[1])
+	if !ok {
+		return errNotInteger
+	}
+
+	if err := e.store.LSet(args[0].Bulk, index, args[2].Bulk); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) linsert(args []resp.Value) resp.Value {
+	if len(args) != 4 {
+		return wrongArgs("LINSERT")
+	}
+
+	var before bool
+	switch strings.ToUpper(args[1].Bulk) {
+	case "BEFORE":
+		before = true
+	case "AFTER":
+		before = false
+	default:
+		return errSyntax
+	}
+
+	length, err := e.store.LInsert(args[0].Bulk, before, args[2].Bulk, args[3].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: length}
+}
+
+func (e *CommandExecutor) lrem(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("LREM")
+	}
+
+	count, ok := parseInt(args[1])
+	if !ok {
+		return errNotInteger
+	}
+
+	removed, err := e.store.LRem(args[0].Bulk, count, args[2].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: removed}
+}
+
+func (e *CommandExecutor) ltrim(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("LTRIM")
+	}
+
+	start, ok1 := parseInt(args[1])
+	stop, ok2 := parseInt(args[2])
+	if !ok1 || !ok2 {
+		return errNotInteger
+	}
+
+	if err := e.store.LTrim(args[0].Bulk, start, stop); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) lpos(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("LPOS")
+	}
+
+	rank, count, maxlen := 1, -1, 0
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		n, ok := parseInt(args[i+1])
+		if !ok {
+			return errNotInteger
+		}
+
+		switch strings.ToUpper(args[i].Bulk) {
+		case "RANK":
+			if n == 0 {
+				return resp.Value{Typ: "error", Str: "ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list"}
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				return resp.Value{Typ: "error", Str: "ERR COUNT can't be negative"}
+			}
+			count = n
+		case "MAXLEN":
+			if n < 0 {
+				return resp.Value{Typ: "error", Str: "ERR MAXLEN can't be negative"}
+			}
+			maxlen = n
+		default:
+			return errSyntax
+		}
+	}
+
+	limit := count
+	if count < 0 {
+		limit = 1
+	}
+
+	positions, err := e.store.LPos(args[0].Bulk, args[1].Bulk, rank, limit, maxlen)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	if count < 0 {
+		if len(positions) == 0 {
+			return resp.Value{Typ: "null"}
+		}
+		return resp.Value{Typ: "integer", Num: positions[0]}
+	}
+
+	result := make([]resp.Value, len(positions))
+	for i, pos := range positions {
+		result[i] = resp.Value{Typ: "integer", Num: pos}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) lmove(args []resp.Value) resp.Value {
+	if len(args) != 4 {
+		return wrongArgs("LMOVE")
+	}
+
+	from, ok1 := parseListEnd(args[2])
+	to, ok2 := parseListEnd(args[3])
+	if !ok1 || !ok2 {
+		return errSyntax
+	}
+
+	value, found, err := e.store.LMove(args[0].Bulk, args[1].Bulk, from, to)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !found {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: value}
+}
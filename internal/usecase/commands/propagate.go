@@ -0,0 +1,200 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rewriter превращает выполненную команду и её ответ в детерминированные
+// команды для записи в AOF
+type Rewriter func(args []resp.Value, reply resp.Value) []resp.Value
+
+// Propagate возвращает команды, которые нужно записать в AOF после
+// выполнения cmd. Команды со случайным результатом переписываются по ответу,
+// чтобы повторное применение AOF приводило к тому же состоянию.
+func (e *CommandExecutor) Propagate(cmd resp.Value, reply resp.Value) []resp.Value {
+	name := strings.ToUpper(cmd.Array[0].Bulk)
+	if rewrite, exists := e.rewriters[name]; exists {
+		return rewrite(cmd.Array[1:], reply)
+	}
+	return []resp.Value{cmd}
+}
+
+// newCommand собирает команду RESP из строковых аргументов
+func newCommand(name string, args ...string) resp.Value {
+	array := make([]resp.Value, 0, len(args)+1)
+	array = append(array, resp.Value{Typ: "bulk", Bulk: name})
+	for _, arg := range args {
+		array = append(array, resp.Value{Typ: "bulk", Bulk: arg})
+	}
+	return resp.Value{Typ: "array", Array: array}
+}
+
+// replyStrings извлекает строки из ответа-строки или ответа-массива
+func replyStrings(reply resp.Value) []string {
+	switch reply.Typ {
+	case "bulk":
+		return []string{reply.Bulk}
+	case "array":
+		result := make([]string, 0, len(reply.Array))
+		for _, item := range reply.Array {
+			result = append(result, item.Bulk)
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// rewriteSpop записывает SPOP как SREM извлечённых элементов
+func rewriteSpop(args []resp.Value, reply resp.Value) []resp.Value {
+	members := replyStrings(reply)
+	if len(members) == 0 {
+		return nil
+	}
+	return []resp.Value{newCommand("SREM", append([]string{args[0].Bulk}, members...)...)}
+}
+
+// rewriteXAdd подставляет в XADD идентификатор, сгенерированный сервером
+func rewriteXAdd(args []resp.Value, reply resp.Value) []resp.Value {
+	if reply.Typ != "bulk" {
+		return nil
+	}
+	req, _, ok := parseXAdd(args)
+	if !ok {
+		return nil
+	}
+
+	rewritten := append([]resp.Value{{Typ: "bulk", Bulk: "XADD"}}, args...)
+	rewritten[req.idIndex+1] = resp.Value{Typ: "bulk", Bulk: reply.Bulk}
+	return []resp.Value{{Typ: "array", Array: rewritten}}
+}
+
+// replyEntryIDs извлекает идентификаторы из списка записей [id, fields] или списка id
+func replyEntryIDs(reply resp.Value) []string {
+	var ids []string
+	for _, item := range reply.Array {
+		if item.Typ == "array" && len(item.Array) > 0 {
+			ids = append(ids, item.Array[0].Bulk)
+		} else if item.Typ == "bulk" {
+			ids = append(ids, item.Bulk)
+		}
+	}
+	return ids
+}
+
+// propagateClaims записывает текущее состояние записей PEL группы как
+// XCLAIM ... TIME RETRYCOUNT FORCE JUSTID и фиксирует last-delivered-id
+// через XGROUP SETID. Так восстанавливаются время доставки и счётчики,
+// которые при повторном выполнении исходной команды получились бы другими.
+func (e *CommandExecutor) propagateClaims(key, group string, rawIDs []string) []resp.Value {
+	ids := make([]storage.StreamID, 0, len(rawIDs))
+	for _, raw := range rawIDs {
+		if id, err := storage.ParseStreamID(raw, 0); err == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	pending, lastID, err := e.store.XPendingEntries(key, group, ids)
+	if err != nil {
+		return nil
+	}
+
+	var result []resp.Value
+	for _, pe := range pending {
+		result = append(result, newCommand("XCLAIM", key, group, pe.Consumer, "0", pe.ID.String(),
+			"TIME", strconv.FormatInt(pe.DeliveryTime.UnixMilli(), 10),
+			"RETRYCOUNT", strconv.Itoa(pe.DeliveryCount),
+			"FORCE", "JUSTID"))
+	}
+	return append(result, newCommand("XGROUP", "SETID", key, group, lastID.String()))
+}
+
+func (e *CommandExecutor) rewriteXReadGroup(args []resp.Value, reply resp.Value) []resp.Value {
+	opts, _, ok := parseXRead("XREADGROUP", args, true)
+	if !ok || reply.Typ != "array" {
+		return nil
+	}
+
+	var result []resp.Value
+	for _, stream := range reply.Array {
+		if len(stream.Array) != 2 {
+			continue
+		}
+		key := stream.Array[0].Bulk
+		result = append(result, e.propagateClaims(key, opts.group, replyEntryIDs(stream.Array[1]))...)
+	}
+	return result
+}
+
+// rewriteXClaim записывает переданные записи через XCLAIM, а записи,
+// удалённые из потока и убранные XCLAIM из PEL, — через XACK. Такие записи
+// в ответ не попадают, поэтому это запрошенные id, которых больше нет
+// в PEL; для id, которых там не было, XACK ничего не меняет.
+func (e *CommandExecutor) rewriteXClaim(args []resp.Value, reply resp.Value) []resp.Value {
+	if reply.Typ != "array" {
+		return nil
+	}
+	key, group := args[0].Bulk, args[1].Bulk
+	result := e.propagateClaims(key, group, replyEntryIDs(reply))
+
+	var requested []storage.StreamID
+	for _, arg := range args[4:] {
+		id, err := storage.ParseStreamID(arg.Bulk, 0)
+		if err != nil {
+			break
+		}
+		requested = append(requested, id)
+	}
+	pending, _, err := e.store.XPendingEntries(key, group, requested)
+	if err != nil {
+		return result
+	}
+
+	var dropped []string
+	for _, id := range requested {
+		if !slices.ContainsFunc(pending, func(pe storage.PendingEntry) bool { return pe.ID == id }) {
+			dropped = append(dropped, id.String())
+		}
+	}
+	if len(dropped) > 0 {
+		result = append(result, newCommand("XACK", append([]string{key, group}, dropped...)...))
+	}
+	return result
+}
+
+// rewriteXAutoClaim записывает переданные записи через XCLAIM, а записи,
+// удалённые из потока и убранные из PEL, — через XACK
+func (e *CommandExecutor) rewriteXAutoClaim(args []resp.Value, reply resp.Value) []resp.Value {
+	if reply.Typ != "array" || len(reply.Array) != 3 {
+		return nil
+	}
+	key, group := args[0].Bulk, args[1].Bulk
+
+	result := e.propagateClaims(key, group, replyEntryIDs(reply.Array[1]))
+	if deleted := replyEntryIDs(reply.Array[2]); len(deleted) > 0 {
+		result = append(result, newCommand("XACK", append([]string{key, group}, deleted...)...))
+	}
+	return result
+}
+
+// rewriteExpire записывает EXPIRE и родственные команды как PEXPIREAT
+// с абсолютным временем, если срок был установлен. Момент в прошлом при
+// повторе удалит ключ, как и исходная команда. Условие NX/XX/GT/LT уже
+// проверено и в AOF не попадает.
+func rewriteExpire(name string, unit time.Duration, absolute bool) Rewriter {
+	return func(args []resp.Value, reply resp.Value) []resp.Value {
+		if reply.Num != 1 {
+			return nil
+		}
+		req, _, ok := parseExpire(name, args, unit, absolute)
+		if !ok {
+			return nil
+		}
+		return []resp.Value{newCommand("PEXPIREAT", args[0].Bulk, strconv.FormatInt(req.at.UnixMilli(), 10))}
+	}
+}
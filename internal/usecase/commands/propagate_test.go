@@ -0,0 +1,209 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/storage"
+	"slices"
+	"testing"
+	"time"
+)
+
+// replayDelay пауза между выполнением команд и загрузкой AOF. Относительные
+// сроки, записанные без перевода в абсолютные, сдвинулись бы на неё.
+const replayDelay = 20 * time.Millisecond
+
+// replay выполняет команды на основном исполнителе, а записанное через
+// Propagate применяет на втором, как при загрузке AOF
+func replay(t *testing.T, commands ...[]string) (primary, replica *CommandExecutor) {
+	t.Helper()
+	primary, replica = newTestExecutor(t), newTestExecutor(t)
+
+	var aof [][]string
+	for _, args := range commands {
+		cmd := newCommand(args[0], args[1:]...)
+		reply := primary.Execute(cmd)
+		if reply.Typ == "error" {
+			t.Fatalf("%q: %s", args, reply.Str)
+		}
+		for _, entry := range primary.Propagate(cmd, reply) {
+			aof = append(aof, bulkStrings(entry.Array))
+		}
+	}
+
+	time.Sleep(replayDelay)
+	for _, args := range aof {
+		if reply := run(replica, args[0], args[1:]...); reply.Typ == "error" {
+			t.Fatalf("replaying %q: %s", args, reply.Str)
+		}
+	}
+	return primary, replica
+}
+
+// sameExpireTime сверяет срок жизни ключа на обоих исполнителях с
+// точностью до миллисекунды, в которой он хранится в AOF
+func sameExpireTime(t *testing.T, primary, replica *CommandExecutor, key string) {
+	t.Helper()
+	want, wantFound := primary.store.ExpireTime(key)
+	got, found := replica.store.ExpireTime(key)
+	if found != wantFound || got.IsZero() != want.IsZero() {
+		t.Fatalf("%s: replica expires at %v (found %v), primary at %v (found %v)", key, got, found, want, wantFound)
+	}
+	if diff := got.Sub(want).Abs(); diff > replayDelay/4 {
+		t.Errorf("%s: replica expires %v apart from primary", key, diff)
+	}
+}
+
+func TestPropagateExpire(t *testing.T) {
+	primary, replica := replay(t,
+		[]string{"SET", "a", "1"},
+		[]string{"SET", "b", "1"},
+		[]string{"SET", "c", "1"},
+		[]string{"SET", "gone", "1"},
+		[]string{"EXPIRE", "a", "100"},
+		[]string{"PEXPIRE", "b", "100000", "NX"},
+		// Невыполненное условие не меняет TTL и не попадает в AOF
+		[]string{"EXPIRE", "b", "5", "GT"},
+		[]string{"EXPIREAT", "c", "4102444800", "XX"},
+		[]string{"EXPIRE", "gone", "-1"},
+	)
+
+	for _, key := range []string{"a", "b", "c", "gone"} {
+		sameExpireTime(t, primary, replica, key)
+	}
+	if at, _ := replica.store.ExpireTime("b"); time.Until(at) < 90*time.Second {
+		t.Errorf("failed GT condition changed the TTL to %v", time.Until(at))
+	}
+}
+
+func TestPropagateSpop(t *testing.T) {
+	primary, replica := replay(t,
+		[]string{"SADD", "s", "a", "b", "c", "d", "e"},
+		[]string{"SPOP", "s"},
+		[]string{"SPOP", "s", "2"},
+		[]string{"SPOP", "missing"},
+	)
+
+	want := replyStrings(run(primary, "SMEMBERS", "s"))
+	got := replyStrings(run(replica, "SMEMBERS", "s"))
+	slices.Sort(want)
+	slices.Sort(got)
+	if len(want) != 2 || !slices.Equal(got, want) {
+		t.Errorf("replica members = %q, primary %q", got, want)
+	}
+
+	// Извлечение всех элементов удаляет ключ и на копии
+	_, replica = replay(t,
+		[]string{"SADD", "s", "a", "b"},
+		[]string{"SPOP", "s", "5"},
+	)
+	if run(replica, "EXISTS", "s").Num != 0 {
+		t.Error("replica kept the emptied set")
+	}
+}
+
+// pendingState возвращает записи PEL группы по всем записям потока и
+// last-delivered-id; время доставки приводится к миллисекундам, как в AOF
+func pendingState(t *testing.T, e *CommandExecutor, key, group string) ([]storage.PendingEntry, storage.StreamID) {
+	t.Helper()
+	var ids []storage.StreamID
+	for _, raw := range replyEntryIDs(run(e, "XRANGE", key, "-", "+")) {
+		id, err := storage.ParseStreamID(raw, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	pending, lastID, err := e.store.XPendingEntries(key, group, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range pending {
+		pending[i].DeliveryTime = pending[i].DeliveryTime.Truncate(time.Millisecond)
+	}
+	return pending, lastID
+}
+
+func TestPropagateXReadGroup(t *testing.T) {
+	primary, replica := replay(t,
+		[]string{"XADD", "s", "*", "f", "1"},
+		[]string{"XADD", "s", "*", "f", "2"},
+		[]string{"XADD", "s", "*", "f", "3"},
+		[]string{"XGROUP", "CREATE", "s", "g", "0"},
+		[]string{"XREADGROUP", "GROUP", "g", "alice", "COUNT", "2", "STREAMS", "s", ">"},
+		[]string{"XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">"},
+		// Повторное чтение своей истории увеличивает счётчик доставок
+		[]string{"XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", "0"},
+		// NOACK не создаёт записей PEL, но сдвигает last-delivered-id
+		[]string{"XADD", "s", "*", "f", "4"},
+		[]string{"XREADGROUP", "GROUP", "g", "bob", "NOACK", "STREAMS", "s", ">"},
+	)
+
+	want, wantLast := pendingState(t, primary, "s", "g")
+	got, gotLast := pendingState(t, replica, "s", "g")
+	if len(want) != 3 || !slices.Equal(got, want) || gotLast != wantLast {
+		t.Errorf("replica PEL = %+v, last %v; primary %+v, last %v", got, gotLast, want, wantLast)
+	}
+	if last := replyEntryIDs(run(primary, "XRANGE", "s", "-", "+"))[3]; wantLast.String() != last {
+		t.Errorf("last-delivered-id = %v, want %s", wantLast, last)
+	}
+	if want[0].DeliveryCount != 2 {
+		t.Errorf("first entry delivered %d times, want 2", want[0].DeliveryCount)
+	}
+}
+
+func TestPropagateSetExpire(t *testing.T) {
+	primary, replica := replay(t,
+		[]string{"SET", "ex", "v", "EX", "100"},
+		[]string{"SET", "px", "v", "PX", "100000", "GET"},
+		[]string{"SETEX", "setex", "100", "v"},
+		[]string{"PSETEX", "psetex", "100000", "v"},
+		[]string{"SET", "keep", "v", "EX", "100"},
+		[]string{"SET", "keep", "w", "KEEPTTL"},
+		// Невыполненное NX при повторе тоже ничего не меняет
+		[]string{"SET", "ex", "other", "NX", "EX", "1"},
+		[]string{"SET", "getex", "v"},
+		[]string{"GETEX", "getex", "EX", "100"},
+	)
+
+	for _, key := range []string{"ex", "px", "setex", "psetex", "keep", "getex"} {
+		sameExpireTime(t, primary, replica, key)
+		if got, want := run(replica, "GET", key).Bulk, run(primary, "GET", key).Bulk; got != want {
+			t.Errorf("%s: replica value %q, primary %q", key, got, want)
+		}
+	}
+
+	cmd := newCommand("SET", "k", "v", "EX", "100")
+	entries := primary.Propagate(cmd, primary.Execute(cmd))
+	if args := bulkStrings(entries[0].Array); len(args) != 5 || args[3] != "PXAT" {
+		t.Errorf("SET EX propagated as %q", args)
+	}
+}
+
+func TestPropagateXClaimDeleted(t *testing.T) {
+	ids := []string{"1-0", "2-0", "3-0"}
+	primary, replica := replay(t,
+		[]string{"XADD", "s", ids[0], "f", "1"},
+		[]string{"XADD", "s", ids[1], "f", "2"},
+		[]string{"XADD", "s", ids[2], "f", "3"},
+		[]string{"XGROUP", "CREATE", "s", "g", "0"},
+		[]string{"XREADGROUP", "GROUP", "g", "alice", "STREAMS", "s", ">"},
+		[]string{"XDEL", "s", ids[1]},
+		// Удалённая из потока запись убирается из PEL, а не передаётся
+		[]string{"XCLAIM", "s", "g", "bob", "0", ids[0], ids[1], "4-0", "JUSTID"},
+	)
+
+	var streamIDs []storage.StreamID
+	for _, raw := range ids {
+		id, _ := storage.ParseStreamID(raw, 0)
+		streamIDs = append(streamIDs, id)
+	}
+	want, _, _ := primary.store.XPendingEntries("s", "g", streamIDs)
+	got, _, _ := replica.store.XPendingEntries("s", "g", streamIDs)
+	if len(want) != 2 || len(got) != len(want) {
+		t.Fatalf("replica PEL = %+v, primary %+v", got, want)
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Consumer != want[i].Consumer {
+			t.Errorf("replica PEL entry %+v, primary %+v", got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"strings"
+)
+
+func (e *CommandExecutor) sadd(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("SADD")
+	}
+
+	added, err := e.store.SAdd(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: added}
+}
+
+func (e *CommandExecutor) srem(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("SREM")
+	}
+
+	removed, err := e.store.SRem(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: removed}
+}
+
+func (e *CommandExecutor) smembers(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("SMEMBERS")
+	}
+
+	members, err := e.store.SMembers(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "array", Array: toRespArray(members)}
+}
+
+func (e *CommandExecutor) sscan(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("SSCAN")
+	}
+
+	opts, reply, ok := parseScanOptions(args[1:], false, false)
+	if !ok {
+		return reply
+	}
+
+	members, next, err := e.store.SScan(args[0].Bulk, opts.cursor, opts.count)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	items := make([]resp.Value, 0, len(members))
+	for _, member := range members {
+		if opts.matches(member) {
+			items = append(items, resp.Value{Typ: "bulk", Bulk: member})
+		}
+	}
+	return scanReply(next, items)
+}
+
+func (e *CommandExecutor) sismember(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("SISMEMBER")
+	}
+
+	found, err := e.store.SIsMember(args[0].Bulk, []string{args[1].Bulk})
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(found[0])
+}
+
+func (e *CommandExecutor) smismember(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("SMISMEMBER")
+	}
+
+	found, err := e.store.SIsMember(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+
+	result := make([]resp.Value, len(found))
+	for i, ok := range found {
+		result[i] = boolValue(ok)
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) scard(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("SCARD")
+	}
+
+	card, err := e.store.SCard(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: card}
+}
+
+func (e *CommandExecutor) spop(args []resp.Value) resp.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongArgs("SPOP")
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, ok := parseInt(args[1])
+		if !ok || n < 0 {
+			return resp.Value{Typ: "error", Str: "ERR value is out of range, must be positive"}
+		}
+		count = n
+	}
+
+	members, err := e.store.SPop(args[0].Bulk, count)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	if len(args) == 2 {
+		return resp.Value{Typ: "array", Array: toRespArray(members)}
+	}
+	if len(members) == 0 {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: members[0]}
+}
+
+func (e *CommandExecutor) srandmember(args []resp.Value) resp.Value {
+	if len(args) < 1 || len(args) > 2 {
+		return wrongArgs("SRANDMEMBER")
+	}
+
+	count := 1
+	if len(args) == 2 {
+		n, ok := parseInt(args[1])
+		if !ok {
+			return errNotInteger
+		}
+		if n < -storage.MaxRandomCount {
+			return resp.Value{Typ: "error", Str: "ERR value is out of range"}
+		}
+		count = n
+	}
+
+	members, err := e.store.SRandMember(args[0].Bulk, count)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	if len(args) == 2 {
+		return resp.Value{Typ: "array", Array: toRespArray(members)}
+	}
+	if len(members) == 0 {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: members[0]}
+}
+
+func (e *CommandExecutor) smove(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("SMOVE")
+	}
+
+	moved, err := e.store.SMove(args[0].Bulk, args[1].Bulk, args[2].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(moved)
+}
+
+func (e *CommandExecutor) scombine(name string, op storage.SetOp) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 1 {
+			return wrongArgs(name)
+		}
+
+		members, err := e.store.SCombine(op, bulkStrings(args))
+		if err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "array", Array: toRespArray(members)}
+	}
+}
+
+func (e *CommandExecutor) scombineStore(name string, op storage.SetOp) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 2 {
+			return wrongArgs(name)
+		}
+
+		card, err := e.store.SCombineStore(op, args[0].Bulk, bulkStrings(args[1:]))
+		if err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "integer", Num: card}
+	}
+}
+
+func (e *CommandExecutor) sintercard(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("SINTERCARD")
+	}
+
+	numKeys, ok := parseInt(args[0])
+	if !ok || numKeys <= 0 {
+		return resp.Value{Typ: "error", Str: "ERR numkeys should be greater than 0"}
+	}
+	if numKeys > len(args)-1 {
+		return resp.Value{Typ: "error", Str: "ERR Number of keys can't be greater than number of args"}
+	}
+
+	keys := bulkStrings(args[1 : 1+numKeys])
+	rest := args[1+numKeys:]
+	limit := 0
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(rest[0].Bulk) != "LIMIT" {
+			return errSyntax
+		}
+		n, ok := parseInt(rest[1])
+		if !ok || n < 0 {
+			return resp.Value{Typ: "error", Str: "ERR LIMIT can't be negative"}
+		}
+		limit = n
+	}
+
+	card, err := e.store.SInterCard(keys, limit)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: card}
+}
@@ -0,0 +1,59 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestSRandMemberCount(t *testing.T) {
+	e := newTestExecutor(t)
+	run(e, "SADD", "s", "a", "b", "c")
+
+	tests := []struct {
+		count   string
+		wantLen int
+		wantErr bool
+	}{
+		{"2", 2, false},
+		{"10", 3, false},
+		{"0", 0, false},
+		{"-5", 5, false},
+		{strconv.Itoa(-storage.MaxRandomCount - 1), 0, true},
+		{strconv.Itoa(math.MinInt), 0, true},
+		{"x", 0, true},
+	}
+	for _, tt := range tests {
+		got := run(e, "SRANDMEMBER", "s", tt.count)
+		if (got.Typ == "error") != tt.wantErr || len(got.Array) != tt.wantLen {
+			t.Errorf("SRANDMEMBER s %s = %s with %d items %s", tt.count, got.Typ, len(got.Array), got.Str)
+		}
+	}
+}
+
+func TestSInterCardLimit(t *testing.T) {
+	e := newTestExecutor(t)
+	run(e, "SADD", "a", "1", "2", "3")
+	run(e, "SADD", "b", "1", "2", "3", "4")
+
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{[]string{"2", "a", "b"}, 3, false},
+		{[]string{"2", "a", "b", "LIMIT", "2"}, 2, false},
+		{[]string{"2", "a", "b", "LIMIT", "0"}, 3, false},
+		{[]string{"2", "a", "b", "LIMIT", "-1"}, 0, true},
+		{[]string{"2", "a", "b", "LIMIT"}, 0, true},
+		{[]string{"3", "a", "b"}, 0, true},
+		{[]string{"0", "a"}, 0, true},
+	}
+	for _, tt := range tests {
+		got := run(e, "SINTERCARD", tt.args...)
+		if (got.Typ == "error") != tt.wantErr || got.Num != tt.want {
+			t.Errorf("SINTERCARD %q = %+v, want %d", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,883 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errStreamsSyntax = resp.Value{Typ: "error", Str: "ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified."}
+	errBlockTimeout  = resp.Value{Typ: "error", Str: "ERR timeout is not an integer or out of range"}
+)
+
+// parseRangeBound разбирает границу XRANGE: "-", "+", "ms", "ms-seq" и
+// исключающую форму "(ms-seq". Неполный идентификатор дополняется нулевым
+// порядковым номером для начала диапазона и максимальным — для конца.
+func parseRangeBound(arg string, start bool) (storage.StreamID, bool, error) {
+	switch arg {
+	case "-":
+		return storage.StreamID{}, true, nil
+	case "+":
+		return storage.MaxStreamID, true, nil
+	}
+
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+
+	defaultSeq := uint64(0)
+	if !start {
+		defaultSeq = storage.MaxStreamID.Seq
+	}
+	id, err := storage.ParseStreamID(arg, defaultSeq)
+	if err != nil || !exclusive {
+		return id, true, err
+	}
+
+	if start {
+		id, ok := id.Next()
+		return id, ok, nil
+	}
+	id, ok := id.Prev()
+	return id, ok, nil
+}
+
+// parseTrim разбирает MAXLEN|MINID [=|~] threshold [LIMIT count], начиная с args[i].
+// Возвращает индекс первого неразобранного аргумента.
+func parseTrim(args []resp.Value, i int) (storage.StreamTrim, int, resp.Value, bool) {
+	var trim storage.StreamTrim
+	switch strings.ToUpper(args[i].Bulk) {
+	case "MAXLEN":
+		trim.Strategy = storage.TrimMaxLen
+	case "MINID":
+		trim.Strategy = storage.TrimMinID
+	default:
+		return trim, i, errSyntax, false
+	}
+	i++
+
+	// Приблизительная обрезка "~" выполняется точно: это допустимо,
+	// так как она лишь разрешает удалить меньше записей
+	approx := false
+	if i < len(args) && (args[i].Bulk == "=" || args[i].Bulk == "~") {
+		approx = args[i].Bulk == "~"
+		i++
+	}
+	if i >= len(args) {
+		return trim, i, errSyntax, false
+	}
+
+	if trim.Strategy == storage.TrimMaxLen {
+		n, ok := parseInt(args[i])
+		if !ok || n < 0 {
+			return trim, i, resp.Value{Typ: "error", Str: "ERR The MAXLEN argument must be >= 0."}, false
+		}
+		trim.MaxLen = n
+	} else {
+		id, err := storage.ParseStreamID(args[i].Bulk, 0)
+		if err != nil {
+			return trim, i, errorValue(err), false
+		}
+		trim.MinID = id
+	}
+	i++
+
+	if i+1 < len(args) && strings.ToUpper(args[i].Bulk) == "LIMIT" {
+		if !approx {
+			return trim, i, resp.Value{Typ: "error", Str: "ERR syntax error, LIMIT cannot be used without the special ~ option"}, false
+		}
+		n, ok := parseInt(args[i+1])
+		if !ok || n < 0 {
+			return trim, i, errNotInteger, false
+		}
+		trim.Limit = n
+		i += 2
+	}
+	return trim, i, resp.Value{}, true
+}
+
+// xaddRequest разобранные аргументы XADD
+type xaddRequest struct {
+	key        string
+	noMkStream bool
+	trim       storage.StreamTrim
+	id         storage.XAddID
+	idIndex    int
+	fields     []string
+}
+
+func parseXAdd(args []resp.Value) (xaddRequest, resp.Value, bool) {
+	req := xaddRequest{key: args[0].Bulk}
+
+	i := 1
+	for i < len(args) {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "NOMKSTREAM":
+			req.noMkStream = true
+			i++
+			continue
+		case "MAXLEN", "MINID":
+			trim, next, reply, ok := parseTrim(args, i)
+			if !ok {
+				return req, reply, false
+			}
+			req.trim, i = trim, next
+			continue
+		}
+		break
+	}
+
+	if i >= len(args) {
+		return req, wrongArgs("XADD"), false
+	}
+	req.idIndex = i
+	switch idArg := args[i].Bulk; {
+	case idArg == "*":
+		req.id.Auto = true
+	case strings.HasSuffix(idArg, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(idArg, "-*"), 10, 64)
+		if err != nil {
+			return req, errorValue(storage.ErrInvalidStreamID), false
+		}
+		req.id = storage.XAddID{ID: storage.StreamID{Ms: ms}, AutoSeq: true}
+	default:
+		id, err := storage.ParseStreamID(idArg, 0)
+		if err != nil {
+			return req, errorValue(err), false
+		}
+		req.id.ID = id
+	}
+
+	pairs := args[i+1:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return req, wrongArgs("XADD"), false
+	}
+	req.fields = bulkStrings(pairs)
+	return req, resp.Value{}, true
+}
+
+// streamEntryValue формирует ответ [id, [field, value, ...]]; удалённая запись даёт [id, nil]
+func streamEntryValue(entry storage.StreamEntry) resp.Value {
+	fields := resp.Value{Typ: "null"}
+	if entry.Fields != nil {
+		fields = resp.Value{Typ: "array", Array: toRespArray(entry.Fields)}
+	}
+	return resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "bulk", Bulk: entry.ID.String()},
+		fields,
+	}}
+}
+
+func streamEntriesValue(entries []storage.StreamEntry) resp.Value {
+	result := make([]resp.Value, len(entries))
+	for i, entry := range entries {
+		result[i] = streamEntryValue(entry)
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func streamResultsValue(results []storage.StreamResult) resp.Value {
+	if len(results) == 0 {
+		return resp.Value{Typ: "null"}
+	}
+	array := make([]resp.Value, len(results))
+	for i, r := range results {
+		array[i] = resp.Value{Typ: "array", Array: []resp.Value{
+			{Typ: "bulk", Bulk: r.Key},
+			streamEntriesValue(r.Entries),
+		}}
+	}
+	return resp.Value{Typ: "array", Array: array}
+}
+
+func streamIDsValue(ids []storage.StreamID) resp.Value {
+	result := make([]resp.Value, len(ids))
+	for i, id := range ids {
+		result[i] = resp.Value{Typ: "bulk", Bulk: id.String()}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+// blockRead повторяет read, пока тот не вернёт данные, не истечёт timeout
+// (0 — без ограничения) или не остановится хранилище. Удерживаемая
+// вызывающим блокировка held, если она задана, отпускается на время
+// ожидания, как в sync.Cond, и снова захватывается перед возвратом.
+func (e *CommandExecutor) blockRead(timeout time.Duration, held sync.Locker, read func() ([]storage.StreamResult, error)) ([]storage.StreamResult, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		signal := e.store.StreamSignal()
+		results, err := read()
+		if err != nil || len(results) > 0 {
+			return results, err
+		}
+
+		if held != nil {
+			held.Unlock()
+		}
+		woken := false
+		select {
+		case <-signal:
+			woken = true
+		case <-deadline:
+		case <-e.store.Done():
+		}
+		if held != nil {
+			held.Lock()
+		}
+		if !woken {
+			return nil, nil
+		}
+	}
+}
+
+func (e *CommandExecutor) xadd(args []resp.Value) resp.Value {
+	if len(args) < 4 {
+		return wrongArgs("XADD")
+	}
+
+	req, reply, ok := parseXAdd(args)
+	if !ok {
+		return reply
+	}
+
+	id, added, err := e.store.XAdd(req.key, req.id, req.fields, req.noMkStream, req.trim)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !added {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: id.String()}
+}
+
+func (e *CommandExecutor) xrange(name string, rev bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) != 3 && len(args) != 5 {
+			return wrongArgs(name)
+		}
+
+		startArg, endArg := args[1].Bulk, args[2].Bulk
+		if rev {
+			startArg, endArg = endArg, startArg
+		}
+		start, ok1, err := parseRangeBound(startArg, true)
+		if err != nil {
+			return errorValue(err)
+		}
+		end, ok2, err := parseRangeBound(endArg, false)
+		if err != nil {
+			return errorValue(err)
+		}
+
+		count := 0
+		if len(args) == 5 {
+			if strings.ToUpper(args[3].Bulk) != "COUNT" {
+				return errSyntax
+			}
+			n, ok := parseInt(args[4])
+			if !ok {
+				return errNotInteger
+			}
+			if n <= 0 {
+				return resp.Value{Typ: "array", Array: []resp.Value{}}
+			}
+			count = n
+		}
+		if !ok1 || !ok2 {
+			return resp.Value{Typ: "array", Array: []resp.Value{}}
+		}
+
+		entries, err := e.store.XRange(args[0].Bulk, start, end, count, rev)
+		if err != nil {
+			return errorValue(err)
+		}
+		return streamEntriesValue(entries)
+	}
+}
+
+func (e *CommandExecutor) xlen(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("XLEN")
+	}
+
+	length, err := e.store.XLen(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: length}
+}
+
+// parseStreamIDs разбирает список полных или неполных идентификаторов
+func parseStreamIDs(args []resp.Value) ([]storage.StreamID, error) {
+	ids := make([]storage.StreamID, len(args))
+	for i, arg := range args {
+		id, err := storage.ParseStreamID(arg.Bulk, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func (e *CommandExecutor) xdel(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("XDEL")
+	}
+
+	ids, err := parseStreamIDs(args[1:])
+	if err != nil {
+		return errorValue(err)
+	}
+
+	deleted, err := e.store.XDel(args[0].Bulk, ids)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: deleted}
+}
+
+func (e *CommandExecutor) xtrim(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("XTRIM")
+	}
+
+	trim, next, reply, ok := parseTrim(args, 1)
+	if !ok {
+		return reply
+	}
+	if next != len(args) {
+		return errSyntax
+	}
+
+	trimmed, err := e.store.XTrim(args[0].Bulk, trim)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: trimmed}
+}
+
+// xreadOptions общие параметры XREAD и XREADGROUP
+type xreadOptions struct {
+	group, consumer string
+	count           int
+	block           time.Duration
+	blocking        bool
+	noAck           bool
+	keys            []string
+	ids             []string
+}
+
+func parseXRead(name string, args []resp.Value, withGroup bool) (xreadOptions, resp.Value, bool) {
+	var opts xreadOptions
+	i := 0
+	for ; i < len(args); i++ {
+		switch arg := strings.ToUpper(args[i].Bulk); {
+		case arg == "COUNT" && i+1 < len(args):
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				return opts, errNotInteger, false
+			}
+			opts.count = n
+			i++
+		case arg == "BLOCK" && i+1 < len(args):
+			n, ok := parseInt(args[i+1])
+			if !ok || n < 0 {
+				return opts, errBlockTimeout, false
+			}
+			opts.block, opts.blocking = time.Duration(n)*time.Millisecond, true
+			i++
+		case arg == "GROUP" && withGroup && i+2 < len(args):
+			opts.group, opts.consumer = args[i+1].Bulk, args[i+2].Bulk
+			i += 2
+		case arg == "NOACK" && withGroup:
+			opts.noAck = true
+		case arg == "STREAMS":
+			rest := args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				return opts, errStreamsSyntax, false
+			}
+			half := len(rest) / 2
+			opts.keys = bulkStrings(rest[:half])
+			opts.ids = bulkStrings(rest[half:])
+			if withGroup && opts.group == "" {
+				return opts, resp.Value{Typ: "error", Str: "ERR Missing GROUP option for XREADGROUP"}, false
+			}
+			return opts, resp.Value{}, true
+		default:
+			return opts, errSyntax, false
+		}
+	}
+	return opts, wrongArgs(name), false
+}
+
+func (e *CommandExecutor) xread(args []resp.Value) resp.Value {
+	opts, reply, ok := parseXRead("XREAD", args, false)
+	if !ok {
+		return reply
+	}
+
+	// "$" фиксируется в момент вызова, чтобы блокирующее чтение ждало только новые записи
+	ids := make([]storage.StreamID, len(opts.ids))
+	for i, arg := range opts.ids {
+		if arg == "$" {
+			last, err := e.store.XLastID(opts.keys[i])
+			if err != nil {
+				return errorValue(err)
+			}
+			ids[i] = last
+			continue
+		}
+		id, err := storage.ParseStreamID(arg, 0)
+		if err != nil {
+			return errorValue(err)
+		}
+		ids[i] = id
+	}
+
+	read := func() ([]storage.StreamResult, error) {
+		return e.store.XRead(opts.keys, ids, opts.count)
+	}
+
+	var results []storage.StreamResult
+	var err error
+	if opts.blocking {
+		results, err = e.blockRead(opts.block, nil, read)
+	} else {
+		results, err = read()
+	}
+	if err != nil {
+		return errorValue(err)
+	}
+	return streamResultsValue(results)
+}
+
+func (e *CommandExecutor) xreadgroup(args []resp.Value) resp.Value {
+	opts, reply, ok := parseXRead("XREADGROUP", args, true)
+	if !ok {
+		return reply
+	}
+
+	ids := make([]storage.StreamID, len(opts.ids))
+	newOnly := make([]bool, len(opts.ids))
+	history := false
+	for i, arg := range opts.ids {
+		if arg == ">" {
+			newOnly[i] = true
+			continue
+		}
+		id, err := storage.ParseStreamID(arg, 0)
+		if err != nil {
+			return errorValue(err)
+		}
+		ids[i] = id
+		history = true
+	}
+
+	read := func() ([]storage.StreamResult, error) {
+		return e.store.XReadGroup(opts.group, opts.consumer, opts.keys, ids, newOnly, opts.count, opts.noAck)
+	}
+
+	var results []storage.StreamResult
+	var err error
+	if opts.blocking && !history {
+		// XREADGROUP выполняется под блокировкой порядка записи; ожидание
+		// с ней остановило бы XADD, который должен разбудить чтение
+		results, err = e.blockRead(opts.block, e.writeLock, read)
+	} else {
+		results, err = read()
+	}
+	if err != nil {
+		return errorValue(err)
+	}
+	return streamResultsValue(results)
+}
+
+func (e *CommandExecutor) xgroup(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("XGROUP")
+	}
+
+	sub := strings.ToUpper(args[0].Bulk)
+	switch {
+	case sub == "CREATE" && len(args) >= 4:
+		mkStream := false
+		for _, arg := range args[4:] {
+			switch strings.ToUpper(arg.Bulk) {
+			case "MKSTREAM":
+				mkStream = true
+			default:
+				return errSyntax
+			}
+		}
+		id, useLast, err := parseGroupID(args[3].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		if err := e.store.XGroupCreate(args[1].Bulk, args[2].Bulk, id, useLast, mkStream); err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "string", Str: "OK"}
+
+	case sub == "SETID" && len(args) == 4:
+		id, useLast, err := parseGroupID(args[3].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		if err := e.store.XGroupSetID(args[1].Bulk, args[2].Bulk, id, useLast); err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "string", Str: "OK"}
+
+	case sub == "DESTROY" && len(args) == 3:
+		destroyed, err := e.store.XGroupDestroy(args[1].Bulk, args[2].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		return boolValue(destroyed)
+
+	case sub == "CREATECONSUMER" && len(args) == 4:
+		created, err := e.store.XGroupCreateConsumer(args[1].Bulk, args[2].Bulk, args[3].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		return boolValue(created)
+
+	case sub == "DELCONSUMER" && len(args) == 4:
+		pending, err := e.store.XGroupDelConsumer(args[1].Bulk, args[2].Bulk, args[3].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "integer", Num: pending}
+	}
+
+	return resp.Value{Typ: "error", Str: "ERR unknown subcommand or wrong number of arguments for 'XGROUP " + sub + "'"}
+}
+
+// parseGroupID разбирает идентификатор группы: "$" означает последнюю запись потока
+func parseGroupID(arg string) (storage.StreamID, bool, error) {
+	if arg == "$" {
+		return storage.StreamID{}, true, nil
+	}
+	id, err := storage.ParseStreamID(arg, 0)
+	return id, false, err
+}
+
+func (e *CommandExecutor) xack(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("XACK")
+	}
+
+	ids, err := parseStreamIDs(args[2:])
+	if err != nil {
+		return errorValue(err)
+	}
+
+	acked, err := e.store.XAck(args[0].Bulk, args[1].Bulk, ids)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: acked}
+}
+
+func (e *CommandExecutor) xpending(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("XPENDING")
+	}
+	key, group := args[0].Bulk, args[1].Bulk
+
+	if len(args) == 2 {
+		summary, err := e.store.XPendingSummary(key, group)
+		if err != nil {
+			return errorValue(err)
+		}
+		if summary.Count == 0 {
+			return resp.Value{Typ: "array", Array: []resp.Value{
+				{Typ: "integer", Num: 0}, {Typ: "null"}, {Typ: "null"}, {Typ: "null"},
+			}}
+		}
+
+		consumers := make([]string, 0, len(summary.Consumers))
+		for name := range summary.Consumers {
+			consumers = append(consumers, name)
+		}
+		sort.Strings(consumers)
+		perConsumer := make([]resp.Value, len(consumers))
+		for i, name := range consumers {
+			perConsumer[i] = resp.Value{Typ: "array", Array: toRespArray([]string{name, strconv.Itoa(summary.Consumers[name])})}
+		}
+		return resp.Value{Typ: "array", Array: []resp.Value{
+			{Typ: "integer", Num: summary.Count},
+			{Typ: "bulk", Bulk: summary.Min.String()},
+			{Typ: "bulk", Bulk: summary.Max.String()},
+			{Typ: "array", Array: perConsumer},
+		}}
+	}
+
+	rest := args[2:]
+	var minIdle time.Duration
+	if strings.ToUpper(rest[0].Bulk) == "IDLE" {
+		if len(rest) < 2 {
+			return errSyntax
+		}
+		ms, ok := parseInt(rest[1])
+		if !ok {
+			return errNotInteger
+		}
+		minIdle = time.Duration(ms) * time.Millisecond
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		return errSyntax
+	}
+
+	start, ok1, err := parseRangeBound(rest[0].Bulk, true)
+	if err != nil {
+		return errorValue(err)
+	}
+	end, ok2, err := parseRangeBound(rest[1].Bulk, false)
+	if err != nil {
+		return errorValue(err)
+	}
+	count, ok := parseInt(rest[2])
+	if !ok {
+		return errNotInteger
+	}
+	consumer := ""
+	if len(rest) == 4 {
+		consumer = rest[3].Bulk
+	}
+	if !ok1 || !ok2 || count <= 0 {
+		return resp.Value{Typ: "array", Array: []resp.Value{}}
+	}
+
+	entries, err := e.store.XPending(key, group, minIdle, start, end, count, consumer)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	now := time.Now()
+	result := make([]resp.Value, len(entries))
+	for i, pe := range entries {
+		result[i] = resp.Value{Typ: "array", Array: []resp.Value{
+			{Typ: "bulk", Bulk: pe.ID.String()},
+			{Typ: "bulk", Bulk: pe.Consumer},
+			{Typ: "integer", Num: int(now.Sub(pe.DeliveryTime).Milliseconds())},
+			{Typ: "integer", Num: pe.DeliveryCount},
+		}}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) xclaim(args []resp.Value) resp.Value {
+	if len(args) < 5 {
+		return wrongArgs("XCLAIM")
+	}
+
+	minIdleMs, ok := parseInt(args[3])
+	if !ok {
+		return resp.Value{Typ: "error", Str: "ERR Invalid min-idle-time argument for XCLAIM"}
+	}
+
+	var ids []storage.StreamID
+	var opts storage.XClaimOptions
+	i := 4
+	for ; i < len(args); i++ {
+		id, err := storage.ParseStreamID(args[i].Bulk, 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].Bulk)
+		switch opt {
+		case "FORCE":
+			opts.Force = true
+			continue
+		case "JUSTID":
+			opts.JustID = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return errSyntax
+		}
+		value := args[i+1]
+		i++
+
+		switch opt {
+		case "IDLE":
+			ms, ok := parseInt(value)
+			if !ok {
+				return resp.Value{Typ: "error", Str: "ERR Invalid IDLE option argument for XCLAIM"}
+			}
+			idle := time.Duration(ms) * time.Millisecond
+			opts.Idle = &idle
+		case "TIME":
+			ms, ok := parseInt(value)
+			if !ok {
+				return resp.Value{Typ: "error", Str: "ERR Invalid TIME option argument for XCLAIM"}
+			}
+			t := time.UnixMilli(int64(ms))
+			opts.Time = &t
+		case "RETRYCOUNT":
+			n, ok := parseInt(value)
+			if !ok {
+				return resp.Value{Typ: "error", Str: "ERR Invalid RETRYCOUNT option argument for XCLAIM"}
+			}
+			opts.RetryCount = &n
+		case "LASTID":
+			id, err := storage.ParseStreamID(value.Bulk, 0)
+			if err != nil {
+				return errorValue(err)
+			}
+			opts.LastID = &id
+		default:
+			return resp.Value{Typ: "error", Str: "ERR Unrecognized XCLAIM option '" + args[i-1].Bulk + "'"}
+		}
+	}
+
+	minIdle := time.Duration(minIdleMs) * time.Millisecond
+	entries, err := e.store.XClaim(args[0].Bulk, args[1].Bulk, args[2].Bulk, minIdle, ids, opts)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	if opts.JustID {
+		claimed := make([]storage.StreamID, len(entries))
+		for i, entry := range entries {
+			claimed[i] = entry.ID
+		}
+		return streamIDsValue(claimed)
+	}
+	return streamEntriesValue(entries)
+}
+
+func (e *CommandExecutor) xautoclaim(args []resp.Value) resp.Value {
+	if len(args) < 5 {
+		return wrongArgs("XAUTOCLAIM")
+	}
+
+	minIdleMs, ok := parseInt(args[3])
+	if !ok {
+		return resp.Value{Typ: "error", Str: "ERR Invalid min-idle-time argument for XAUTOCLAIM"}
+	}
+	start, _, err := parseRangeBound(args[4].Bulk, true)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	count, justID := 100, false
+	for i := 5; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, ok := parseInt(args[i+1])
+			if !ok || n < 1 {
+				return resp.Value{Typ: "error", Str: "ERR COUNT must be > 0"}
+			}
+			count = n
+			i++
+		case "JUSTID":
+			justID = true
+		default:
+			return errSyntax
+		}
+	}
+
+	minIdle := time.Duration(minIdleMs) * time.Millisecond
+	next, entries, deleted, err := e.store.XAutoClaim(args[0].Bulk, args[1].Bulk, args[2].Bulk, minIdle, start, count, justID)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	claimed := streamEntriesValue(entries)
+	if justID {
+		ids := make([]storage.StreamID, len(entries))
+		for i, entry := range entries {
+			ids[i] = entry.ID
+		}
+		claimed = streamIDsValue(ids)
+	}
+	return resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "bulk", Bulk: next.String()},
+		claimed,
+		streamIDsValue(deleted),
+	}}
+}
+
+func (e *CommandExecutor) xinfo(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("XINFO")
+	}
+
+	sub := strings.ToUpper(args[0].Bulk)
+	switch {
+	case sub == "STREAM" && len(args) == 2:
+		info, err := e.store.XInfoStream(args[1].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		first, last := resp.Value{Typ: "null"}, resp.Value{Typ: "null"}
+		if info.First != nil {
+			first, last = streamEntryValue(*info.First), streamEntryValue(*info.Last)
+		}
+		return resp.Value{Typ: "array", Array: []resp.Value{
+			{Typ: "bulk", Bulk: "length"}, {Typ: "integer", Num: info.Length},
+			{Typ: "bulk", Bulk: "last-generated-id"}, {Typ: "bulk", Bulk: info.LastID.String()},
+			{Typ: "bulk", Bulk: "max-deleted-entry-id"}, {Typ: "bulk", Bulk: info.MaxDeletedID.String()},
+			{Typ: "bulk", Bulk: "entries-added"}, {Typ: "integer", Num: int(info.EntriesAdded)},
+			{Typ: "bulk", Bulk: "groups"}, {Typ: "integer", Num: info.Groups},
+			{Typ: "bulk", Bulk: "first-entry"}, first,
+			{Typ: "bulk", Bulk: "last-entry"}, last,
+		}}
+
+	case sub == "GROUPS" && len(args) == 2:
+		groups, err := e.store.XInfoGroups(args[1].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		result := make([]resp.Value, len(groups))
+		for i, g := range groups {
+			result[i] = resp.Value{Typ: "array", Array: []resp.Value{
+				{Typ: "bulk", Bulk: "name"}, {Typ: "bulk", Bulk: g.Name},
+				{Typ: "bulk", Bulk: "consumers"}, {Typ: "integer", Num: g.Consumers},
+				{Typ: "bulk", Bulk: "pending"}, {Typ: "integer", Num: g.Pending},
+				{Typ: "bulk", Bulk: "last-delivered-id"}, {Typ: "bulk", Bulk: g.LastID.String()},
+			}}
+		}
+		return resp.Value{Typ: "array", Array: result}
+
+	case sub == "CONSUMERS" && len(args) == 3:
+		consumers, err := e.store.XInfoConsumers(args[1].Bulk, args[2].Bulk)
+		if err != nil {
+			return errorValue(err)
+		}
+		result := make([]resp.Value, len(consumers))
+		for i, c := range consumers {
+			result[i] = resp.Value{Typ: "array", Array: []resp.Value{
+				{Typ: "bulk", Bulk: "name"}, {Typ: "bulk", Bulk: c.Name},
+				{Typ: "bulk", Bulk: "pending"}, {Typ: "integer", Num: c.Pending},
+				{Typ: "bulk", Bulk: "idle"}, {Typ: "integer", Num: int(c.Idle.Milliseconds())},
+			}}
+		}
+		return resp.Value{Typ: "array", Array: result}
+	}
+
+	return resp.Value{Typ: "error", Str: "ERR unknown subcommand or wrong number of arguments for 'XINFO " + sub + "'"}
+}
@@ -0,0 +1,468 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// isExpireOption сообщает, задаёт ли опция срок жизни ключа
+func isExpireOption(option string) bool {
+	switch option {
+	case "EX", "PX", "EXAT", "PXAT":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseExpireTime переводит значение опции EX/PX/EXAT/PXAT в абсолютный момент истечения
+func parseExpireTime(command, option string, arg resp.Value) (time.Time, resp.Value, bool) {
+	n, err := strconv.ParseInt(arg.Bulk, 10, 64)
+	if err != nil {
+		return time.Time{}, errNotInteger, false
+	}
+
+	invalid := resp.Value{Typ: "error", Str: "ERR invalid expire time in '" + command + "' command"}
+	if n <= 0 {
+		return time.Time{}, invalid, false
+	}
+
+	switch option {
+	case "EX":
+		if n > math.MaxInt64/int64(time.Second) {
+			return time.Time{}, invalid, false
+		}
+		return time.Now().Add(time.Duration(n) * time.Second), resp.Value{}, true
+	case "PX":
+		if n > math.MaxInt64/int64(time.Millisecond) {
+			return time.Time{}, invalid, false
+		}
+		return time.Now().Add(time.Duration(n) * time.Millisecond), resp.Value{}, true
+	case "EXAT":
+		if n > math.MaxInt64/1000 {
+			return time.Time{}, invalid, false
+		}
+		return time.Unix(n, 0), resp.Value{}, true
+	default:
+		return time.UnixMilli(n), resp.Value{}, true
+	}
+}
+
+// parseSetOptions разбирает опции SET: NX|XX, GET, KEEPTTL, EX|PX|EXAT|PXAT
+func parseSetOptions(args []resp.Value) (storage.SetOptions, resp.Value, bool) {
+	var opts storage.SetOptions
+	for i := 0; i < len(args); i++ {
+		option := strings.ToUpper(args[i].Bulk)
+		switch {
+		case option == "NX" || option == "XX":
+			if opts.Condition != storage.SetAlways {
+				return opts, errSyntax, false
+			}
+			opts.Condition = storage.SetIfNotExists
+			if option == "XX" {
+				opts.Condition = storage.SetIfExists
+			}
+		case option == "GET":
+			opts.Get = true
+		case option == "KEEPTTL":
+			if !opts.ExpireAt.IsZero() {
+				return opts, errSyntax, false
+			}
+			opts.KeepTTL = true
+		case isExpireOption(option):
+			if opts.KeepTTL || !opts.ExpireAt.IsZero() || i+1 >= len(args) {
+				return opts, errSyntax, false
+			}
+			at, reply, ok := parseExpireTime("set", option, args[i+1])
+			if !ok {
+				return opts, reply, false
+			}
+			opts.ExpireAt = at
+			i++
+		default:
+			return opts, errSyntax, false
+		}
+	}
+	return opts, resp.Value{}, true
+}
+
+// setCommand собирает SET с абсолютным сроком жизни для записи в AOF
+func setCommand(key, value string, opts storage.SetOptions) resp.Value {
+	args := []string{key, value}
+	switch opts.Condition {
+	case storage.SetIfNotExists:
+		args = append(args, "NX")
+	case storage.SetIfExists:
+		args = append(args, "XX")
+	}
+	if opts.KeepTTL {
+		args = append(args, "KEEPTTL")
+	}
+	if !opts.ExpireAt.IsZero() {
+		args = append(args, "PXAT", strconv.FormatInt(opts.ExpireAt.UnixMilli(), 10))
+	}
+	return newCommand("SET", args...)
+}
+
+// rewriteSet записывает относительный срок жизни SET как PXAT,
+// чтобы после перезапуска ключ истёк в тот же момент. Не выполненный из-за
+// NX/XX SET не пишется: к моменту повтора ключ мог истечь, и запись
+// применилась бы, вернув ключ без срока жизни.
+func rewriteSet(args []resp.Value, reply resp.Value) []resp.Value {
+	opts, _, ok := parseSetOptions(args[2:])
+	if !ok || !setApplied(opts, reply) {
+		return nil
+	}
+	return []resp.Value{setCommand(args[0].Bulk, args[1].Bulk, opts)}
+}
+
+// setApplied определяет по ответу SET, была ли выполнена запись. С GET
+// ответ — старое значение, и null означает отсутствие ключа.
+func setApplied(opts storage.SetOptions, reply resp.Value) bool {
+	if !opts.Get {
+		return reply.Typ != "null"
+	}
+	switch opts.Condition {
+	case storage.SetIfNotExists:
+		return reply.Typ == "null"
+	case storage.SetIfExists:
+		return reply.Typ != "null"
+	default:
+		return true
+	}
+}
+
+// rewriteIfApplied записывает команду name как есть, только если она
+// ответила 1: SETNX и подобные при ответе 0 ничего не меняют
+func rewriteIfApplied(name string) Rewriter {
+	return func(args []resp.Value, reply resp.Value) []resp.Value {
+		if reply.Num != 1 {
+			return nil
+		}
+		command := append([]resp.Value{{Typ: "bulk", Bulk: name}}, args...)
+		return []resp.Value{{Typ: "array", Array: command}}
+	}
+}
+
+// rewriteSetEx записывает SETEX и PSETEX как SET ... PXAT
+func rewriteSetEx(option string) Rewriter {
+	return func(args []resp.Value, reply resp.Value) []resp.Value {
+		at, _, ok := parseExpireTime("", option, args[1])
+		if !ok {
+			return nil
+		}
+		return []resp.Value{setCommand(args[0].Bulk, args[2].Bulk, storage.SetOptions{ExpireAt: at})}
+	}
+}
+
+// rewriteGetEx записывает только изменение TTL; GETEX без опций не пишется
+func rewriteGetEx(args []resp.Value, reply resp.Value) []resp.Value {
+	if reply.Typ == "null" || len(args) < 2 {
+		return nil
+	}
+	option := strings.ToUpper(args[1].Bulk)
+	if option == "PERSIST" {
+		return []resp.Value{newCommand("GETEX", args[0].Bulk, "PERSIST")}
+	}
+	if len(args) < 3 {
+		return nil
+	}
+
+	at, _, ok := parseExpireTime("", option, args[2])
+	if !ok {
+		return nil
+	}
+	return []resp.Value{newCommand("GETEX", args[0].Bulk, "PXAT", strconv.FormatInt(at.UnixMilli(), 10))}
+}
+
+func (e *CommandExecutor) set(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("SET")
+	}
+
+	opts, reply, ok := parseSetOptions(args[2:])
+	if !ok {
+		return reply
+	}
+
+	result, err := e.store.SetWithOptions(args[0].Bulk, args[1].Bulk, opts)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	if opts.Get {
+		if !result.HadOld {
+			return resp.Value{Typ: "null"}
+		}
+		return resp.Value{Typ: "bulk", Bulk: result.Old}
+	}
+	if !result.Applied {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) setnx(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("SETNX")
+	}
+
+	opts := storage.SetOptions{Condition: storage.SetIfNotExists}
+	result, err := e.store.SetWithOptions(args[0].Bulk, args[1].Bulk, opts)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(result.Applied)
+}
+
+func (e *CommandExecutor) setex(name, option string) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) != 3 {
+			return wrongArgs(name)
+		}
+
+		at, reply, ok := parseExpireTime(strings.ToLower(name), option, args[1])
+		if !ok {
+			return reply
+		}
+
+		if _, err := e.store.SetWithOptions(args[0].Bulk, args[2].Bulk, storage.SetOptions{ExpireAt: at}); err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "string", Str: "OK"}
+	}
+}
+
+func (e *CommandExecutor) getset(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("GETSET")
+	}
+
+	result, err := e.store.SetWithOptions(args[0].Bulk, args[1].Bulk, storage.SetOptions{Get: true})
+	if err != nil {
+		return errorValue(err)
+	}
+	if !result.HadOld {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: result.Old}
+}
+
+func (e *CommandExecutor) getdel(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("GETDEL")
+	}
+
+	value, found, err := e.store.GetDel(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !found {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: value}
+}
+
+func (e *CommandExecutor) getex(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("GETEX")
+	}
+
+	var expireAt time.Time
+	persist := false
+	switch {
+	case len(args) == 1:
+	case len(args) == 2 && strings.ToUpper(args[1].Bulk) == "PERSIST":
+		persist = true
+	case len(args) == 3 && isExpireOption(strings.ToUpper(args[1].Bulk)):
+		at, reply, ok := parseExpireTime("getex", strings.ToUpper(args[1].Bulk), args[2])
+		if !ok {
+			return reply
+		}
+		expireAt = at
+	default:
+		return errSyntax
+	}
+
+	value, found, err := e.store.GetEx(args[0].Bulk, expireAt, persist)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !found {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: value}
+}
+
+func (e *CommandExecutor) mget(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("MGET")
+	}
+
+	values, found := e.store.MGet(bulkStrings(args))
+	result := make([]resp.Value, len(values))
+	for i, value := range values {
+		if found[i] {
+			result[i] = resp.Value{Typ: "bulk", Bulk: value}
+		} else {
+			result[i] = resp.Value{Typ: "null"}
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) mset(name string, onlyNew bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 2 || len(args)%2 != 0 {
+			return wrongArgs(name)
+		}
+
+		keys := make([]string, 0, len(args)/2)
+		values := make([]string, 0, len(args)/2)
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, args[i].Bulk)
+			values = append(values, args[i+1].Bulk)
+		}
+
+		applied := e.store.MSet(keys, values, onlyNew)
+		if onlyNew {
+			return boolValue(applied)
+		}
+		return resp.Value{Typ: "string", Str: "OK"}
+	}
+}
+
+func (e *CommandExecutor) appendValue(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("APPEND")
+	}
+
+	length, err := e.store.Append(args[0].Bulk, args[1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: length}
+}
+
+func (e *CommandExecutor) strlen(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("STRLEN")
+	}
+
+	length, err := e.store.StrLen(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: length}
+}
+
+func (e *CommandExecutor) getrange(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("GETRANGE")
+	}
+
+	start, ok1 := parseInt(args[1])
+	end, ok2 := parseInt(args[2])
+	if !ok1 || !ok2 {
+		return errNotInteger
+	}
+
+	value, err := e.store.GetRange(args[0].Bulk, start, end)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "bulk", Bulk: value}
+}
+
+func (e *CommandExecutor) setrange(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("SETRANGE")
+	}
+
+	offset, ok := parseInt(args[1])
+	if !ok {
+		return errNotInteger
+	}
+	if offset < 0 || offset > storage.MaxStringLength {
+		return resp.Value{Typ: "error", Str: "ERR offset is out of range"}
+	}
+
+	length, err := e.store.SetRange(args[0].Bulk, offset, args[2].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: length}
+}
+
+func (e *CommandExecutor) lcs(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("LCS")
+	}
+
+	getLen, getIdx, withMatchLen := false, false, false
+	minMatchLen := 0
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "LEN":
+			getLen = true
+		case "IDX":
+			getIdx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, ok := parseInt(args[i+1])
+			if !ok {
+				return errNotInteger
+			}
+			minMatchLen = max(n, 0)
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if getLen && getIdx {
+		return resp.Value{Typ: "error", Str: "ERR If you want both the length and indexes, please just use IDX."}
+	}
+
+	result, err := e.store.LCS(args[0].Bulk, args[1].Bulk, minMatchLen)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	switch {
+	case getLen:
+		return resp.Value{Typ: "integer", Num: len(result.Sequence)}
+	case !getIdx:
+		return resp.Value{Typ: "bulk", Bulk: result.Sequence}
+	}
+
+	matches := make([]resp.Value, 0, len(result.Matches))
+	for _, m := range result.Matches {
+		match := []resp.Value{rangeValue(m.A), rangeValue(m.B)}
+		if withMatchLen {
+			match = append(match, resp.Value{Typ: "integer", Num: m.Len})
+		}
+		matches = append(matches, resp.Value{Typ: "array", Array: match})
+	}
+	return resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "bulk", Bulk: "matches"},
+		{Typ: "array", Array: matches},
+		{Typ: "bulk", Bulk: "len"},
+		{Typ: "integer", Num: len(result.Sequence)},
+	}}
+}
+
+// rangeValue ответ-пара [начало, конец]
+func rangeValue(r [2]int) resp.Value {
+	return resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "integer", Num: r[0]},
+		{Typ: "integer", Num: r[1]},
+	}}
+}
@@ -0,0 +1,32 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestSetRangeOffset(t *testing.T) {
+	tests := []struct {
+		offset  string
+		want    int
+		wantErr bool
+	}{
+		{"0", 5, false},
+		{"3", 5, false},
+		{"10", 12, false},
+		{"-1", 0, true},
+		{strconv.Itoa(storage.MaxStringLength), 0, true},
+		{strconv.Itoa(math.MaxInt), 0, true},
+		{"x", 0, true},
+	}
+	for _, tt := range tests {
+		e := newTestExecutor(t)
+		run(e, "SET", "k", "hello")
+		got := run(e, "SETRANGE", "k", tt.offset, "ab")
+		if (got.Typ == "error") != tt.wantErr || got.Num != tt.want {
+			t.Errorf("SETRANGE k %s ab = %+v, want %d", tt.offset, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errTSTimestamp = resp.Value{Typ: "error", Str: "ERR TSDB: invalid timestamp"}
+	errTSValue     = resp.Value{Typ: "error", Str: "ERR TSDB: invalid value"}
+)
+
+var duplicatePolicies = map[string]storage.DuplicatePolicy{
+	"BLOCK": storage.DuplicateBlock,
+	"FIRST": storage.DuplicateFirst,
+	"LAST":  storage.DuplicateLast,
+	"MIN":   storage.DuplicateMin,
+	"MAX":   storage.DuplicateMax,
+	"SUM":   storage.DuplicateSum,
+}
+
+var aggregations = map[string]storage.Aggregation{
+	"AVG":   storage.AggAvg,
+	"SUM":   storage.AggSum,
+	"MIN":   storage.AggMin,
+	"MAX":   storage.AggMax,
+	"COUNT": storage.AggCount,
+	"FIRST": storage.AggFirst,
+	"LAST":  storage.AggLast,
+}
+
+// parseTimestamp разбирает метку времени в миллисекундах; "*" — текущее время
+func parseTimestamp(arg resp.Value) (int64, bool) {
+	if arg.Bulk == "*" {
+		return time.Now().UnixMilli(), true
+	}
+	ts, err := strconv.ParseInt(arg.Bulk, 10, 64)
+	return ts, err == nil && ts >= 0
+}
+
+// parseSampleValue разбирает значение отсчёта
+func parseSampleValue(arg resp.Value) (float64, bool) {
+	value, err := strconv.ParseFloat(arg.Bulk, 64)
+	return value, err == nil && !math.IsNaN(value)
+}
+
+// parseTSOptions разбирает RETENTION, DUPLICATE_POLICY и LABELS, а для
+// TS.ADD ещё и ON_DUPLICATE. LABELS забирает все оставшиеся аргументы.
+func parseTSOptions(args []resp.Value, add bool) (storage.TSOptions, storage.DuplicatePolicy, *resp.Value) {
+	var opts storage.TSOptions
+	var onDuplicate storage.DuplicatePolicy
+	for i := 0; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i].Bulk); {
+		case option == "RETENTION" && i+1 < len(args):
+			retention, err := strconv.ParseInt(args[i+1].Bulk, 10, 64)
+			if err != nil || retention < 0 {
+				return opts, 0, &resp.Value{Typ: "error", Str: "ERR TSDB: invalid RETENTION value"}
+			}
+			opts.Retention = retention
+			i++
+		case (option == "DUPLICATE_POLICY" || add && option == "ON_DUPLICATE") && i+1 < len(args):
+			policy, known := duplicatePolicies[strings.ToUpper(args[i+1].Bulk)]
+			if !known {
+				return opts, 0, &resp.Value{Typ: "error", Str: "ERR TSDB: Unknown DUPLICATE_POLICY"}
+			}
+			if option == "ON_DUPLICATE" {
+				onDuplicate = policy
+			} else {
+				opts.Duplicate = policy
+			}
+			i++
+		case option == "LABELS":
+			rest := args[i+1:]
+			if len(rest)%2 != 0 {
+				return opts, 0, &errSyntax
+			}
+			for j := 0; j < len(rest); j += 2 {
+				opts.Labels = append(opts.Labels, storage.TSLabel{Name: rest[j].Bulk, Value: rest[j+1].Bulk})
+			}
+			i = len(args)
+		default:
+			return opts, 0, &errSyntax
+		}
+	}
+	return opts, onDuplicate, nil
+}
+
+// parseAggregation разбирает "агрегатор длина_корзины"
+func parseAggregation(args []resp.Value) (storage.Aggregation, int64, *resp.Value) {
+	agg, known := aggregations[strings.ToUpper(args[0].Bulk)]
+	if !known {
+		return 0, 0, &resp.Value{Typ: "error", Str: "ERR TSDB: unknown aggregation type"}
+	}
+	bucket, err := strconv.ParseInt(args[1].Bulk, 10, 64)
+	if err != nil || bucket <= 0 {
+		return 0, 0, &resp.Value{Typ: "error", Str: "ERR TSDB: bucketDuration must be greater than zero"}
+	}
+	return agg, bucket, nil
+}
+
+// parseTSBound разбирает границу диапазона: "-", "+" или метку времени
+func parseTSBound(arg resp.Value) (int64, bool) {
+	switch arg.Bulk {
+	case "-":
+		return 0, true
+	case "+":
+		return math.MaxInt64, true
+	}
+	ts, err := strconv.ParseInt(arg.Bulk, 10, 64)
+	return ts, err == nil
+}
+
+// parseRangeQuery разбирает "from to [COUNT n] [AGGREGATION agg bucket]".
+// Для TS.MRANGE возвращает также условия FILTER и признак WITHLABELS.
+func parseRangeQuery(args []resp.Value, multi bool) (storage.TSRangeQuery, []storage.TSFilter, bool, *resp.Value) {
+	var q storage.TSRangeQuery
+	var filters []storage.TSFilter
+	withLabels := false
+
+	from, okFrom := parseTSBound(args[0])
+	to, okTo := parseTSBound(args[1])
+	if !okFrom || !okTo {
+		return q, nil, false, &errTSTimestamp
+	}
+	q.From, q.To = from, to
+
+	for i := 2; i < len(args); i++ {
+		switch option := strings.ToUpper(args[i].Bulk); {
+		case option == "COUNT" && i+1 < len(args):
+			count, ok := parseInt(args[i+1])
+			if !ok || count <= 0 {
+				return q, nil, false, &resp.Value{Typ: "error", Str: "ERR TSDB: Invalid COUNT value"}
+			}
+			q.Count = count
+			i++
+		case option == "AGGREGATION" && i+2 < len(args):
+			agg, bucket, errValue := parseAggregation(args[i+1 : i+3])
+			if errValue != nil {
+				return q, nil, false, errValue
+			}
+			q.Aggregation, q.Bucket = agg, bucket
+			i += 2
+		case multi && option == "WITHLABELS":
+			withLabels = true
+		case multi && option == "FILTER" && i+1 < len(args):
+			for _, arg := range args[i+1:] {
+				filter, ok := parseTSFilter(arg.Bulk)
+				if !ok {
+					return q, nil, false, &resp.Value{Typ: "error", Str: "ERR TSDB: failed parsing labels"}
+				}
+				filters = append(filters, filter)
+			}
+			i = len(args)
+		default:
+			return q, nil, false, &errSyntax
+		}
+	}
+	return q, filters, withLabels, nil
+}
+
+// parseTSFilter разбирает условие label=value, label!=value,
+// label=(a,b) или label!=(a,b); пустое значение означает отсутствие метки
+func parseTSFilter(raw string) (storage.TSFilter, bool) {
+	var filter storage.TSFilter
+	var value string
+	if i := strings.Index(raw, "!="); i >= 0 {
+		filter.Label, value = raw[:i], raw[i+2:]
+	} else if i := strings.IndexByte(raw, '='); i >= 0 {
+		filter.Label, value, filter.Equal = raw[:i], raw[i+1:], true
+	} else {
+		return filter, false
+	}
+	if filter.Label == "" {
+		return filter, false
+	}
+
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		for _, v := range strings.Split(value[1:len(value)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				filter.Values = append(filter.Values, v)
+			}
+		}
+	} else if value != "" {
+		filter.Values = []string{value}
+	}
+	return filter, true
+}
+
+func samplesValue(samples []storage.TSSample) resp.Value {
+	result := make([]resp.Value, len(samples))
+	for i, s := range samples {
+		result[i] = resp.Value{Typ: "array", Array: []resp.Value{
+			{Typ: "integer", Num: int(s.Timestamp)},
+			{Typ: "string", Str: formatFloat(s.Value)},
+		}}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) tsCreate(args []resp.Value) resp.Value {
+	if len(args) < 1 {
+		return wrongArgs("TS.CREATE")
+	}
+
+	opts, _, errValue := parseTSOptions(args[1:], false)
+	if errValue != nil {
+		return *errValue
+	}
+	if err := e.store.TSCreate(args[0].Bulk, opts); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+func (e *CommandExecutor) tsAdd(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("TS.ADD")
+	}
+
+	ts, ok := parseTimestamp(args[1])
+	if !ok {
+		return errTSTimestamp
+	}
+	value, ok := parseSampleValue(args[2])
+	if !ok {
+		return errTSValue
+	}
+	opts, onDuplicate, errValue := parseTSOptions(args[3:], true)
+	if errValue != nil {
+		return *errValue
+	}
+
+	sample := storage.TSSample{Timestamp: ts, Value: value}
+	if err := e.store.TSAdd(args[0].Bulk, sample, opts, onDuplicate); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: int(ts)}
+}
+
+// tsMAdd отвечает меткой времени или ошибкой для каждого отсчёта
+func (e *CommandExecutor) tsMAdd(args []resp.Value) resp.Value {
+	if len(args) < 3 || len(args)%3 != 0 {
+		return wrongArgs("TS.MADD")
+	}
+
+	n := len(args) / 3
+	keys := make([]string, n)
+	samples := make([]storage.TSSample, n)
+	for i := range n {
+		ts, ok := parseTimestamp(args[3*i+1])
+		if !ok {
+			return errTSTimestamp
+		}
+		value, ok := parseSampleValue(args[3*i+2])
+		if !ok {
+			return errTSValue
+		}
+		keys[i], samples[i] = args[3*i].Bulk, storage.TSSample{Timestamp: ts, Value: value}
+	}
+
+	errs := e.store.TSMAdd(keys, samples)
+	result := make([]resp.Value, n)
+	for i, err := range errs {
+		if err != nil {
+			result[i] = errorValue(err)
+		} else {
+			result[i] = resp.Value{Typ: "integer", Num: int(samples[i].Timestamp)}
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) tsRange(name string, reverse bool) func([]resp.Value) resp.Value {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 3 {
+			return wrongArgs(name)
+		}
+
+		q, _, _, errValue := parseRangeQuery(args[1:], false)
+		if errValue != nil {
+			return *errValue
+		}
+		q.Reverse = reverse
+
+		samples, err := e.store.TSRange(args[0].Bulk, q)
+		if err != nil {
+			return errorValue(err)
+		}
+		return samplesValue(samples)
+	}
+}
+
+// tsMRange отвечает списком [ключ, метки, отсчёты]; метки выводятся
+// только с WITHLABELS
+func (e *CommandExecutor) tsMRange(args []resp.Value) resp.Value {
+	if len(args) < 4 {
+		return wrongArgs("TS.MRANGE")
+	}
+
+	q, filters, withLabels, errValue := parseRangeQuery(args, true)
+	if errValue != nil {
+		return *errValue
+	}
+	matcher := false
+	for _, f := range filters {
+		matcher = matcher || f.Equal && len(f.Values) > 0
+	}
+	if !matcher {
+		return resp.Value{Typ: "error", Str: "ERR TSDB: please provide at least one matcher"}
+	}
+
+	series := e.store.TSMRange(filters, q)
+	result := make([]resp.Value, len(series))
+	for i, s := range series {
+		labels := []resp.Value{}
+		if withLabels {
+			for _, l := range s.Labels {
+				labels = append(labels, resp.Value{Typ: "array", Array: []resp.Value{
+					{Typ: "bulk", Bulk: l.Name},
+					{Typ: "bulk", Bulk: l.Value},
+				}})
+			}
+		}
+		result[i] = resp.Value{Typ: "array", Array: []resp.Value{
+			{Typ: "bulk", Bulk: s.Key},
+			{Typ: "array", Array: labels},
+			samplesValue(s.Samples),
+		}}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) tsCreateRule(args []resp.Value) resp.Value {
+	if len(args) != 5 && len(args) != 6 {
+		return wrongArgs("TS.CREATERULE")
+	}
+	if strings.ToUpper(args[2].Bulk) != "AGGREGATION" {
+		return errSyntax
+	}
+
+	agg, bucket, errValue := parseAggregation(args[3:5])
+	if errValue != nil {
+		return *errValue
+	}
+	var align int64
+	if len(args) == 6 {
+		var err error
+		if align, err = strconv.ParseInt(args[5].Bulk, 10, 64); err != nil {
+			return errTSTimestamp
+		}
+	}
+
+	if err := e.store.TSCreateRule(args[0].Bulk, args[1].Bulk, agg, bucket, align); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+// rewriteTSAdd подставляет в TS.ADD метку времени, выбранную сервером для "*"
+func rewriteTSAdd(args []resp.Value, reply resp.Value) []resp.Value {
+	if reply.Typ != "integer" {
+		return nil
+	}
+	rewritten := append([]resp.Value{{Typ: "bulk", Bulk: "TS.ADD"}}, args...)
+	rewritten[2] = resp.Value{Typ: "bulk", Bulk: strconv.Itoa(reply.Num)}
+	return []resp.Value{{Typ: "array", Array: rewritten}}
+}
+
+// rewriteTSMAdd записывает только добавленные отсчёты с фактическими метками времени
+func rewriteTSMAdd(args []resp.Value, reply resp.Value) []resp.Value {
+	if reply.Typ != "array" || len(reply.Array)*3 != len(args) {
+		return nil
+	}
+
+	var triplets []string
+	for i, item := range reply.Array {
+		if item.Typ == "integer" {
+			triplets = append(triplets, args[3*i].Bulk, strconv.Itoa(item.Num), args[3*i+2].Bulk)
+		}
+	}
+	if len(triplets) == 0 {
+		return nil
+	}
+	return []resp.Value{newCommand("TS.MADD", triplets...)}
+}
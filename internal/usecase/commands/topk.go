@@ -0,0 +1,124 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"strconv"
+	"strings"
+)
+
+// topkMaxIncrement наибольшее приращение TOPK.INCRBY, как в RedisBloom
+const topkMaxIncrement = 100000
+
+func (e *CommandExecutor) topkReserve(args []resp.Value) resp.Value {
+	if len(args) != 2 && len(args) != 5 {
+		return wrongArgs("TOPK.RESERVE")
+	}
+
+	opts := storage.DefaultTopKOptions
+	k, ok := parseInt(args[1])
+	if !ok || k < 1 {
+		return resp.Value{Typ: "error", Str: "ERR TOPK: invalid k"}
+	}
+	opts.K = k
+
+	if len(args) == 5 {
+		width, ok := parseInt(args[2])
+		if !ok || width < 1 {
+			return resp.Value{Typ: "error", Str: "ERR TOPK: invalid width"}
+		}
+		depth, ok := parseInt(args[3])
+		if !ok || depth < 1 {
+			return resp.Value{Typ: "error", Str: "ERR TOPK: invalid depth"}
+		}
+		decay, err := strconv.ParseFloat(args[4].Bulk, 64)
+		if err != nil || decay <= 0 || decay > 1 {
+			return resp.Value{Typ: "error", Str: "ERR TOPK: invalid decay value. must be '<= 1' & '> 0'"}
+		}
+		opts.Width, opts.Depth, opts.Decay = width, depth, decay
+	}
+
+	if err := e.store.TopKReserve(args[0].Bulk, opts); err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "string", Str: "OK"}
+}
+
+// topkIncr учитывает элементы и отвечает вытесненными из списка лучших
+func (e *CommandExecutor) topkIncr(key string, items []string, increments []int64) resp.Value {
+	dropped, expelled, err := e.store.TopKIncrBy(key, items, increments)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	result := make([]resp.Value, len(dropped))
+	for i, item := range dropped {
+		if expelled[i] {
+			result[i] = resp.Value{Typ: "bulk", Bulk: item}
+		} else {
+			result[i] = resp.Value{Typ: "null"}
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) topkAdd(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("TOPK.ADD")
+	}
+
+	items := bulkStrings(args[1:])
+	increments := make([]int64, len(items))
+	for i := range increments {
+		increments[i] = 1
+	}
+	return e.topkIncr(args[0].Bulk, items, increments)
+}
+
+func (e *CommandExecutor) topkIncrBy(args []resp.Value) resp.Value {
+	if len(args) < 3 || len(args)%2 != 1 {
+		return wrongArgs("TOPK.INCRBY")
+	}
+
+	items, increments, ok := parseItemIncrements(args[1:], topkMaxIncrement)
+	if !ok {
+		return resp.Value{Typ: "error", Str: "ERR TOPK: increment must be an integer greater or equal to 0 and smaller or equal to 100000"}
+	}
+	return e.topkIncr(args[0].Bulk, items, increments)
+}
+
+func (e *CommandExecutor) topkQuery(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("TOPK.QUERY")
+	}
+
+	found, err := e.store.TopKQuery(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolsValue(found)
+}
+
+func (e *CommandExecutor) topkList(args []resp.Value) resp.Value {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgs("TOPK.LIST")
+	}
+	withCount := len(args) == 2
+	if withCount && strings.ToUpper(args[1].Bulk) != "WITHCOUNT" {
+		return errSyntax
+	}
+
+	items, err := e.store.TopKList(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	result := make([]resp.Value, 0, len(items)*2)
+	for _, item := range items {
+		result = append(result, resp.Value{Typ: "bulk", Bulk: item.Item})
+		if withCount {
+			result = append(result, resp.Value{Typ: "integer", Num: int(item.Count)})
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
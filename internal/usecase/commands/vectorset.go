@@ -0,0 +1,216 @@
+package command
+
+import (
+	"encoding/binary"
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// vsimDefaultCount число результатов VSIM без COUNT
+const vsimDefaultCount = 10
+
+var vectorMetrics = map[string]storage.VectorMetric{
+	"COSINE": storage.MetricCosine,
+	"L2":     storage.MetricL2,
+	"IP":     storage.MetricIP,
+}
+
+// parseVector разбирает "FP32 blob" или "VALUES n v1 ... vn" и возвращает
+// вектор и число использованных аргументов
+func parseVector(args []resp.Value) ([]float32, int, *resp.Value) {
+	if len(args) < 2 {
+		return nil, 0, &errSyntax
+	}
+	switch strings.ToUpper(args[0].Bulk) {
+	case "FP32":
+		blob := args[1].Bulk
+		if len(blob) == 0 || len(blob)%4 != 0 {
+			return nil, 0, &resp.Value{Typ: "error", Str: "ERR invalid vector blob"}
+		}
+		vector := make([]float32, len(blob)/4)
+		for i := range vector {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32([]byte(blob[4*i : 4*i+4])))
+		}
+		return vector, 2, nil
+	case "VALUES":
+		n, ok := parseInt(args[1])
+		if !ok || n < 1 {
+			return nil, 0, &resp.Value{Typ: "error", Str: "ERR invalid vector dimension"}
+		}
+		if n > len(args)-2 {
+			return nil, 0, &errSyntax
+		}
+		vector := make([]float32, n)
+		for i := range vector {
+			x, err := strconv.ParseFloat(args[i+2].Bulk, 32)
+			if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
+				return nil, 0, &resp.Value{Typ: "error", Str: "ERR invalid vector value"}
+			}
+			vector[i] = float32(x)
+		}
+		return vector, n + 2, nil
+	}
+	return nil, 0, &errSyntax
+}
+
+func (e *CommandExecutor) vadd(args []resp.Value) resp.Value {
+	if len(args) < 4 {
+		return wrongArgs("VADD")
+	}
+
+	vector, used, errValue := parseVector(args[1:])
+	if errValue != nil {
+		return *errValue
+	}
+	rest := args[1+used:]
+	if len(rest) == 0 {
+		return errSyntax
+	}
+	element := rest[0].Bulk
+
+	opts := storage.DefaultVectorOptions
+	for i := 1; i < len(rest); i++ {
+		switch option := strings.ToUpper(rest[i].Bulk); {
+		case option == "NOQUANT":
+		case option == "METRIC" && i+1 < len(rest):
+			metric, known := vectorMetrics[strings.ToUpper(rest[i+1].Bulk)]
+			if !known {
+				return resp.Value{Typ: "error", Str: "ERR unknown metric, use COSINE, L2 or IP"}
+			}
+			opts.Metric = metric
+			i++
+		case (option == "M" || option == "EF") && i+1 < len(rest):
+			n, ok := parseInt(rest[i+1])
+			if !ok || n < 2 || n > 4096 {
+				return resp.Value{Typ: "error", Str: "ERR invalid " + option + " value"}
+			}
+			if option == "M" {
+				opts.M = n
+			} else {
+				opts.EF = n
+			}
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	added, err := e.store.VAdd(args[0].Bulk, element, vector, opts)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(added)
+}
+
+func (e *CommandExecutor) vrem(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("VREM")
+	}
+
+	removed, err := e.store.VRem(args[0].Bulk, args[1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return boolValue(removed)
+}
+
+func (e *CommandExecutor) vsim(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("VSIM")
+	}
+
+	q := storage.VectorQuery{Count: vsimDefaultCount}
+	var rest []resp.Value
+	if strings.ToUpper(args[1].Bulk) == "ELE" {
+		q.Element, rest = args[2].Bulk, args[3:]
+	} else {
+		vector, used, errValue := parseVector(args[1:])
+		if errValue != nil {
+			return *errValue
+		}
+		q.Vector, rest = vector, args[1+used:]
+	}
+
+	withScores := false
+	for i := 0; i < len(rest); i++ {
+		switch option := strings.ToUpper(rest[i].Bulk); {
+		case option == "WITHSCORES":
+			withScores = true
+		case (option == "COUNT" || option == "EF") && i+1 < len(rest):
+			n, ok := parseInt(rest[i+1])
+			if !ok || n < 1 {
+				return resp.Value{Typ: "error", Str: "ERR invalid " + option + " value"}
+			}
+			if option == "COUNT" {
+				q.Count = n
+			} else {
+				q.EF = n
+			}
+			i++
+		default:
+			return errSyntax
+		}
+	}
+
+	matches, err := e.store.VSim(args[0].Bulk, q)
+	if err != nil {
+		return errorValue(err)
+	}
+	result := make([]resp.Value, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, resp.Value{Typ: "bulk", Bulk: m.Element})
+		if withScores {
+			result = append(result, resp.Value{Typ: "bulk", Bulk: formatFloat(m.Score)})
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) vcard(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("VCARD")
+	}
+
+	n, err := e.store.VCard(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: n}
+}
+
+func (e *CommandExecutor) vdim(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("VDIM")
+	}
+
+	dim, err := e.store.VDim(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: dim}
+}
+
+func (e *CommandExecutor) vinfo(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("VINFO")
+	}
+
+	info, found, err := e.store.VInfo(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !found {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "array", Array: []resp.Value{
+		{Typ: "bulk", Bulk: "quant-type"}, {Typ: "bulk", Bulk: "f32"},
+		{Typ: "bulk", Bulk: "metric"}, {Typ: "bulk", Bulk: info.Metric.String()},
+		{Typ: "bulk", Bulk: "vector-dim"}, {Typ: "integer", Num: info.Dim},
+		{Typ: "bulk", Bulk: "size"}, {Typ: "integer", Num: info.Size},
+		{Typ: "bulk", Bulk: "max-level"}, {Typ: "integer", Num: info.MaxLevel},
+		{Typ: "bulk", Bulk: "hnsw-m"}, {Typ: "integer", Num: info.M},
+	}}
+}
@@ -0,0 +1,29 @@
+package command
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestVAddValues(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"huge dimension", []string{"VALUES", strconv.Itoa(1 << 40), "1"}, "error"},
+		{"negative dimension", []string{"VALUES", "-1", "1"}, "error"},
+		{"missing values", []string{"VALUES", "3", "1", "2"}, "error"},
+		{"nan", []string{"VALUES", "1", "nan"}, "error"},
+		{"ok", []string{"VALUES", "2", "1", "2"}, "integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestExecutor(t)
+			args := append([]string{"v"}, tt.args...)
+			if got := run(e, "VADD", append(args, "a")...); got.Typ != tt.want {
+				t.Errorf("VADD %q = %+v, want %s", tt.args, got, tt.want)
+			}
+		})
+	}
+}
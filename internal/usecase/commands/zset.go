@@ -0,0 +1,430 @@
+package command
+
+import (
+	"keyvalue/internal/usecase/resp"
+	"keyvalue/internal/usecase/storage"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	errNotFloat      = resp.Value{Typ: "error", Str: "ERR value is not a valid float"}
+	errMinMaxFloat   = resp.Value{Typ: "error", Str: "ERR min or max is not a float"}
+	errMinMaxLexItem = resp.Value{Typ: "error", Str: "ERR min or max not valid string range item"}
+)
+
+// parseScoreBound разбирает границу диапазона оценок: число, "(число", "-inf", "+inf"
+func parseScoreBound(arg string) (float64, bool, bool) {
+	exclusive := strings.HasPrefix(arg, "(")
+	if exclusive {
+		arg = arg[1:]
+	}
+	score, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, false, false
+	}
+	return score, exclusive, true
+}
+
+func parseScoreRange(min, max resp.Value) (storage.ScoreRange, bool) {
+	var r storage.ScoreRange
+	var ok1, ok2 bool
+	r.Min, r.MinEx, ok1 = parseScoreBound(min.Bulk)
+	r.Max, r.MaxEx, ok2 = parseScoreBound(max.Bulk)
+	return r, ok1 && ok2
+}
+
+// parseLexBound разбирает границу лексикографического диапазона: "-", "+", "[str", "(str"
+func parseLexBound(arg string) (storage.LexBound, bool) {
+	switch {
+	case arg == "-":
+		return storage.LexBound{Inf: -1}, true
+	case arg == "+":
+		return storage.LexBound{Inf: 1}, true
+	case strings.HasPrefix(arg, "["):
+		return storage.LexBound{Value: arg[1:]}, true
+	case strings.HasPrefix(arg, "("):
+		return storage.LexBound{Value: arg[1:], Exclusive: true}, true
+	default:
+		return storage.LexBound{}, false
+	}
+}
+
+// zmembersValue формирует ответ из элементов, при withScores чередуя их с оценками
+func zmembersValue(members []storage.ZMember, withScores bool) resp.Value {
+	result := make([]resp.Value, 0, len(members)*2)
+	for _, m := range members {
+		result = append(result, resp.Value{Typ: "bulk", Bulk: m.Member})
+		if withScores {
+			result = append(result, resp.Value{Typ: "bulk", Bulk: formatFloat(m.Score)})
+		}
+	}
+	return resp.Value{Typ: "array", Array: result}
+}
+
+func (e *CommandExecutor) zadd(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("ZADD")
+	}
+
+	var opts storage.ZAddOptions
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		case "GT":
+			opts.GT = true
+		case "LT":
+			opts.LT = true
+		case "CH":
+			opts.CH = true
+		case "INCR":
+			opts.Incr = true
+		default:
+			break options
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return errSyntax
+	}
+	if opts.NX && opts.XX {
+		return resp.Value{Typ: "error", Str: "ERR XX and NX options at the same time are not compatible"}
+	}
+	if (opts.GT && opts.LT) || (opts.NX && (opts.GT || opts.LT)) {
+		return resp.Value{Typ: "error", Str: "ERR GT, LT, and/or NX options at the same time are not compatible"}
+	}
+	if opts.Incr && len(pairs) != 2 {
+		return resp.Value{Typ: "error", Str: "ERR INCR option supports a single increment-element pair"}
+	}
+
+	members := make([]storage.ZMember, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, err := strconv.ParseFloat(pairs[j].Bulk, 64)
+		if err != nil || math.IsNaN(score) {
+			return errNotFloat
+		}
+		members = append(members, storage.ZMember{Member: pairs[j+1].Bulk, Score: score})
+	}
+
+	count, score, applied, err := e.store.ZAdd(args[0].Bulk, opts, members)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	if opts.Incr {
+		if !applied {
+			return resp.Value{Typ: "null"}
+		}
+		return resp.Value{Typ: "bulk", Bulk: formatFloat(score)}
+	}
+	return resp.Value{Typ: "integer", Num: count}
+}
+
+func (e *CommandExecutor) zincrby(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("ZINCRBY")
+	}
+
+	incr, err := strconv.ParseFloat(args[1].Bulk, 64)
+	if err != nil || math.IsNaN(incr) {
+		return errNotFloat
+	}
+
+	member := storage.ZMember{Member: args[2].Bulk, Score: incr}
+	_, score, _, err := e.store.ZAdd(args[0].Bulk, storage.ZAddOptions{Incr: true}, []storage.ZMember{member})
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "bulk", Bulk: formatFloat(score)}
+}
+
+func (e *CommandExecutor) zscore(args []resp.Value) resp.Value {
+	if len(args) != 2 {
+		return wrongArgs("ZSCORE")
+	}
+
+	score, found, err := e.store.ZScore(args[0].Bulk, args[1].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	if !found {
+		return resp.Value{Typ: "null"}
+	}
+	return resp.Value{Typ: "bulk", Bulk: formatFloat(score)}
+}
+
+func (e *CommandExecutor) zscan(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("ZSCAN")
+	}
+
+	opts, reply, ok := parseScanOptions(args[1:], false, false)
+	if !ok {
+		return reply
+	}
+
+	members, next, err := e.store.ZScan(args[0].Bulk, opts.cursor, opts.count)
+	if err != nil {
+		return errorValue(err)
+	}
+
+	items := make([]resp.Value, 0, len(members)*2)
+	for _, m := range members {
+		if opts.matches(m.Member) {
+			items = append(items,
+				resp.Value{Typ: "bulk", Bulk: m.Member},
+				resp.Value{Typ: "bulk", Bulk: formatFloat(m.Score)})
+		}
+	}
+	return scanReply(next, items)
+}
+
+func (e *CommandExecutor) zcard(args []resp.Value) resp.Value {
+	if len(args) != 1 {
+		return wrongArgs("ZCARD")
+	}
+
+	card, err := e.store.ZCard(args[0].Bulk)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: card}
+}
+
+func (e *CommandExecutor) zrank(name string, reverse bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+
+		rank, found, err := e.store.ZRank(args[0].Bulk, args[1].Bulk, reverse)
+		if err != nil {
+			return errorValue(err)
+		}
+		if !found {
+			return resp.Value{Typ: "null"}
+		}
+		return resp.Value{Typ: "integer", Num: rank}
+	}
+}
+
+func (e *CommandExecutor) zrange(args []resp.Value) resp.Value {
+	if len(args) < 3 {
+		return wrongArgs("ZRANGE")
+	}
+
+	q := storage.ZRangeQuery{By: storage.ZRangeByRank, Count: -1}
+	withScores, limit := false, false
+
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i].Bulk) {
+		case "BYSCORE":
+			q.By = storage.ZRangeByScore
+		case "BYLEX":
+			q.By = storage.ZRangeByLex
+		case "REV":
+			q.Rev = true
+		case "WITHSCORES":
+			withScores = true
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return errSyntax
+			}
+			offset, ok1 := parseInt(args[i+1])
+			count, ok2 := parseInt(args[i+2])
+			if !ok1 || !ok2 {
+				return errNotInteger
+			}
+			q.Offset, q.Count = offset, count
+			limit = true
+			i += 2
+		default:
+			return errSyntax
+		}
+	}
+
+	if limit && q.By == storage.ZRangeByRank {
+		return resp.Value{Typ: "error", Str: "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX"}
+	}
+	if withScores && q.By == storage.ZRangeByLex {
+		return resp.Value{Typ: "error", Str: "ERR syntax error, WITHSCORES not supported in combination with BYLEX"}
+	}
+	if q.Offset < 0 {
+		return resp.Value{Typ: "array", Array: []resp.Value{}}
+	}
+
+	// В обратном порядке диапазон задаётся от большего к меньшему
+	min, max := args[1], args[2]
+	if q.Rev && q.By != storage.ZRangeByRank {
+		min, max = max, min
+	}
+
+	switch q.By {
+	case storage.ZRangeByRank:
+		start, ok1 := parseInt(args[1])
+		stop, ok2 := parseInt(args[2])
+		if !ok1 || !ok2 {
+			return errNotInteger
+		}
+		q.Start, q.Stop = start, stop
+	case storage.ZRangeByScore:
+		r, ok := parseScoreRange(min, max)
+		if !ok {
+			return errMinMaxFloat
+		}
+		q.Score = r
+	case storage.ZRangeByLex:
+		lmin, ok1 := parseLexBound(min.Bulk)
+		lmax, ok2 := parseLexBound(max.Bulk)
+		if !ok1 || !ok2 {
+			return errMinMaxLexItem
+		}
+		q.Lex = storage.LexRange{Min: lmin, Max: lmax}
+	}
+
+	members, err := e.store.ZRange(args[0].Bulk, q)
+	if err != nil {
+		return errorValue(err)
+	}
+	return zmembersValue(members, withScores)
+}
+
+func (e *CommandExecutor) zcount(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("ZCOUNT")
+	}
+
+	r, ok := parseScoreRange(args[1], args[2])
+	if !ok {
+		return errMinMaxFloat
+	}
+
+	count, err := e.store.ZCount(args[0].Bulk, r)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: count}
+}
+
+func (e *CommandExecutor) zrem(args []resp.Value) resp.Value {
+	if len(args) < 2 {
+		return wrongArgs("ZREM")
+	}
+
+	removed, err := e.store.ZRem(args[0].Bulk, bulkStrings(args[1:]))
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: removed}
+}
+
+func (e *CommandExecutor) zremrangebyscore(args []resp.Value) resp.Value {
+	if len(args) != 3 {
+		return wrongArgs("ZREMRANGEBYSCORE")
+	}
+
+	r, ok := parseScoreRange(args[1], args[2])
+	if !ok {
+		return errMinMaxFloat
+	}
+
+	removed, err := e.store.ZRemRangeByScore(args[0].Bulk, r)
+	if err != nil {
+		return errorValue(err)
+	}
+	return resp.Value{Typ: "integer", Num: removed}
+}
+
+func (e *CommandExecutor) zpop(name string, max bool) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 1 || len(args) > 2 {
+			return wrongArgs(name)
+		}
+
+		count := 1
+		if len(args) == 2 {
+			n, ok := parseInt(args[1])
+			if !ok || n < 0 {
+				return resp.Value{Typ: "error", Str: "ERR value is out of range, must be positive"}
+			}
+			count = n
+		}
+
+		members, err := e.store.ZPop(args[0].Bulk, count, max)
+		if err != nil {
+			return errorValue(err)
+		}
+		return zmembersValue(members, true)
+	}
+}
+
+func (e *CommandExecutor) zcombineStore(name string, op storage.SetOp) CommandHandler {
+	return func(args []resp.Value) resp.Value {
+		if len(args) < 3 {
+			return wrongArgs(name)
+		}
+
+		numKeys, ok := parseInt(args[1])
+		if !ok {
+			return errNotInteger
+		}
+		if numKeys < 1 {
+			return resp.Value{Typ: "error", Str: "ERR at least 1 input key is needed for '" + strings.ToLower(name) + "' command"}
+		}
+		if numKeys > len(args)-2 {
+			return errSyntax
+		}
+
+		keys := bulkStrings(args[2 : 2+numKeys])
+		var weights []float64
+		agg := storage.AggregateSum
+
+		for i := 2 + numKeys; i < len(args); i++ {
+			switch strings.ToUpper(args[i].Bulk) {
+			case "WEIGHTS":
+				if i+numKeys >= len(args) {
+					return errSyntax
+				}
+				weights = make([]float64, numKeys)
+				for j := 0; j < numKeys; j++ {
+					w, err := strconv.ParseFloat(args[i+1+j].Bulk, 64)
+					if err != nil || math.IsNaN(w) {
+						return resp.Value{Typ: "error", Str: "ERR weight value is not a float"}
+					}
+					weights[j] = w
+				}
+				i += numKeys
+			case "AGGREGATE":
+				if i+1 >= len(args) {
+					return errSyntax
+				}
+				switch strings.ToUpper(args[i+1].Bulk) {
+				case "SUM":
+					agg = storage.AggregateSum
+				case "MIN":
+					agg = storage.AggregateMin
+				case "MAX":
+					agg = storage.AggregateMax
+				default:
+					return errSyntax
+				}
+				i++
+			default:
+				return errSyntax
+			}
+		}
+
+		card, err := e.store.ZCombineStore(op, args[0].Bulk, keys, weights, agg)
+		if err != nil {
+			return errorValue(err)
+		}
+		return resp.Value{Typ: "integer", Num: card}
+	}
+}
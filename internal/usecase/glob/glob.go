@@ -0,0 +1,113 @@
+// Package glob реализует сопоставление с шаблонами в стиле Redis,
+// которое используют команды KEYS, SCAN и их аналоги для коллекций
+package glob
+
+// Match сообщает, соответствует ли строка шаблону. Поддерживаются
+// "*" (любая подстрока), "?" (один символ), классы "[abc]", "[^a]", "[a-z]"
+// и экранирование "\" — так же, как в stringmatchlen из Redis.
+func Match(pattern, s string) bool {
+	exhausted := false
+	return match(pattern, s, 0, &exhausted)
+}
+
+// maxNesting ограничивает глубину рекурсии для шаблонов с множеством "*"
+const maxNesting = 1000
+
+// match выставляет exhausted, когда "*" дошла до конца строки без
+// совпадения: тогда сдвигать дальше предыдущие "*" бесполезно, и перебор
+// не уходит в экспоненту на шаблонах вроде "*a*a*a*b" (skipLongerMatches
+// в Redis)
+func match(pattern, s string, nesting int, exhausted *bool) bool {
+	if nesting > maxNesting {
+		return false
+	}
+
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := range len(s) {
+				if match(pattern[1:], s[i:], nesting+1, exhausted) {
+					return true
+				}
+				if *exhausted {
+					return false
+				}
+			}
+			*exhausted = true
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			rest, ok := matchClass(pattern[1:], s[0])
+			if !ok {
+				return false
+			}
+			pattern = rest
+			s = s[1:]
+			continue
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		pattern = pattern[1:]
+	}
+	return len(s) == 0
+}
+
+// matchClass проверяет символ c по классу, начинающемуся сразу после "[",
+// и возвращает остаток шаблона после закрывающей "]"
+func matchClass(pattern string, c byte) (string, bool) {
+	not := len(pattern) > 0 && pattern[0] == '^'
+	if not {
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for {
+		switch {
+		case len(pattern) == 0:
+			// Незакрытый класс: как в Redis, считаем конец шаблона концом класса
+			return pattern, matched != not
+		case pattern[0] == ']':
+			return pattern[1:], matched != not
+		case pattern[0] == '\\' && len(pattern) >= 2:
+			if pattern[1] == c {
+				matched = true
+			}
+			pattern = pattern[2:]
+		case len(pattern) >= 3 && pattern[1] == '-':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			pattern = pattern[3:]
+		default:
+			if pattern[0] == c {
+				matched = true
+			}
+			pattern = pattern[1:]
+		}
+	}
+}
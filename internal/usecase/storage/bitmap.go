@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"math"
+	"math/bits"
+)
+
+// BitOp логическая операция BITOP
+type BitOp int
+
+const (
+	BitAnd BitOp = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// BitRange диапазон BITCOUNT и BITPOS: индексы байт или, при BitMode, бит.
+// Отрицательные индексы отсчитываются от конца строки.
+type BitRange struct {
+	Start   int
+	End     int
+	HasEnd  bool
+	BitMode bool
+}
+
+// BitFieldOpKind подкоманда BITFIELD
+type BitFieldOpKind int
+
+const (
+	BitFieldGet BitFieldOpKind = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOverflow поведение BITFIELD при переполнении
+type BitFieldOverflow int
+
+const (
+	OverflowWrap BitFieldOverflow = iota
+	OverflowSat
+	OverflowFail
+)
+
+// BitFieldOp одна операция BITFIELD над целым из Bits бит по смещению Offset
+type BitFieldOp struct {
+	Kind     BitFieldOpKind
+	Signed   bool
+	Bits     int
+	Offset   int
+	Value    int64
+	Overflow BitFieldOverflow
+}
+
+// readBits возвращает содержимое строки только для чтения. Вызывается под блокировкой.
+func (s *Storage) readBits(key string) ([]byte, error) {
+	obj, err := s.lookupType(key, TypeString)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	if b, ok := obj.value.([]byte); ok {
+		return b, nil
+	}
+	return []byte(obj.value.(string)), nil
+}
+
+// writeBits возвращает изменяемое содержимое строки длиной не меньше size
+// байт, дополняя её нулями и создавая ключ при необходимости. Вызывается под
+// блокировкой на запись.
+func (s *Storage) writeBits(key string, size int) ([]byte, error) {
+	obj, err := s.lookupWriteType(key, TypeString)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	if obj != nil {
+		if raw, ok := obj.value.([]byte); ok {
+			b = raw
+		} else {
+			b = []byte(obj.value.(string))
+		}
+	}
+	if len(b) < size {
+		b = append(b, make([]byte, size-len(b))...)
+	}
+
+	if obj == nil {
+		s.put(key, &object{typ: TypeString, value: b})
+	} else {
+		obj.value = b
+	}
+	return b, nil
+}
+
+// bitAt возвращает бит по смещению; за концом строки биты нулевые
+func bitAt(b []byte, offset int) int {
+	if offset/8 >= len(b) {
+		return 0
+	}
+	return int(b[offset/8]>>(7-offset%8)) & 1
+}
+
+// setBitAt устанавливает бит по смещению внутри строки
+func setBitAt(b []byte, offset, bit int) {
+	mask := byte(1 << (7 - offset%8))
+	if bit == 1 {
+		b[offset/8] |= mask
+	} else {
+		b[offset/8] &^= mask
+	}
+}
+
+// SetBit устанавливает бит и возвращает его прежнее значение
+func (s *Storage) SetBit(key string, offset, bit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.writeBits(key, offset/8+1)
+	if err != nil {
+		return 0, err
+	}
+
+	old := bitAt(b, offset)
+	setBitAt(b, offset, bit)
+	return old, nil
+}
+
+// GetBit возвращает бит по смещению
+func (s *Storage) GetBit(key string, offset int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := s.readBits(key)
+	if err != nil {
+		return 0, err
+	}
+	return bitAt(b, offset), nil
+}
+
+// bitRange приводит диапазон к границам [0, length) так же, как Redis:
+// возвращает индексы первого и последнего элемента включительно
+func bitRange(start, end, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	start, end = max(start, 0), max(end, 0)
+	end = min(end, length-1)
+	if length == 0 || start > end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// bitBounds переводит диапазон в индексы первого и последнего бита
+func bitBounds(r *BitRange, size int) (int, int, bool) {
+	if r == nil {
+		return 0, size*8 - 1, size > 0
+	}
+
+	length := size
+	if r.BitMode {
+		length *= 8
+	}
+	end := length - 1
+	if r.HasEnd {
+		end = r.End
+	}
+
+	start, end, ok := bitRange(r.Start, end, length)
+	if !ok || r.BitMode {
+		return start, end, ok
+	}
+	return start * 8, end*8 + 7, true
+}
+
+// countBits считает единичные биты с first по last включительно
+func countBits(b []byte, first, last int) int {
+	count := 0
+	for i := first / 8; i <= last/8; i++ {
+		count += bits.OnesCount8(b[i])
+	}
+	count -= bits.OnesCount8(b[first/8] >> (8 - first%8))
+	count -= bits.OnesCount8(b[last/8] & (0xFF >> (last%8 + 1)))
+	return count
+}
+
+// BitCount считает единичные биты в строке или в диапазоне r
+func (s *Storage) BitCount(key string, r *BitRange) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := s.readBits(key)
+	if err != nil {
+		return 0, err
+	}
+
+	first, last, ok := bitBounds(r, len(b))
+	if !ok {
+		return 0, nil
+	}
+	return countBits(b, first, last), nil
+}
+
+// BitPos возвращает позицию первого бита, равного bit, или -1. Если ищется
+// нулевой бит, а конец диапазона не задан, строка считается дополненной нулями.
+func (s *Storage) BitPos(key string, bit int, r *BitRange) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, err := s.readBits(key)
+	if err != nil {
+		return 0, err
+	}
+	if b == nil {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+
+	first, last, ok := bitBounds(r, len(b))
+	if !ok {
+		return -1, nil
+	}
+
+	// Целые байты без искомого бита пропускаются
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xFF
+	}
+	for i := first; i <= last; {
+		if i%8 == 0 && i+7 <= last && b[i/8] == skip {
+			i += 8
+			continue
+		}
+		if bitAt(b, i) == bit {
+			return i, nil
+		}
+		i++
+	}
+
+	if bit == 0 && (r == nil || !r.HasEnd) {
+		return last + 1, nil
+	}
+	return -1, nil
+}
+
+// BitOp выполняет побитовую операцию над строками keys и сохраняет
+// результат в dest. Отсутствующие ключи считаются пустыми строками,
+// пустой результат удаляет dest. Возвращает длину результата.
+func (s *Storage) BitOp(op BitOp, dest string, keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sources := make([][]byte, len(keys))
+	size := 0
+	for i, key := range keys {
+		b, err := s.readBits(key)
+		if err != nil {
+			return 0, err
+		}
+		sources[i] = b
+		size = max(size, len(b))
+	}
+
+	if size == 0 {
+		s.remove(dest)
+		return 0, nil
+	}
+
+	result := make([]byte, size)
+	for i := range result {
+		var acc byte
+		for j, src := range sources {
+			var v byte
+			if i < len(src) {
+				v = src[i]
+			}
+			switch {
+			case op == BitNot:
+				acc = ^v
+			case j == 0:
+				acc = v
+			case op == BitAnd:
+				acc &= v
+			case op == BitOr:
+				acc |= v
+			case op == BitXor:
+				acc ^= v
+			}
+		}
+		result[i] = acc
+	}
+
+	s.remove(dest)
+	s.put(dest, &object{typ: TypeString, value: result})
+	return size, nil
+}
+
+// BitField выполняет операции BITFIELD по порядку. Для каждой операции
+// возвращается значение и признак ok; ok равен false, если операция не
+// выполнена из-за переполнения в режиме OverflowFail.
+func (s *Storage) BitField(key string, ops []BitFieldOp) ([]int64, []bool, error) {
+	size := 0
+	for _, op := range ops {
+		if op.Kind != BitFieldGet {
+			size = max(size, (op.Offset+op.Bits-1)/8+1)
+		}
+	}
+
+	var b []byte
+	var err error
+	if size > 0 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		b, err = s.writeBits(key, size)
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		b, err = s.readBits(key)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make([]int64, len(ops))
+	ok := make([]bool, len(ops))
+	for i, op := range ops {
+		current := getField(b, op)
+		switch op.Kind {
+		case BitFieldGet:
+			values[i], ok[i] = current, true
+		case BitFieldSet:
+			if next, applied := fieldOverflow(op, op.Value, 0); applied {
+				setField(b, op, next)
+				values[i], ok[i] = current, true
+			}
+		case BitFieldIncrBy:
+			if next, applied := fieldOverflow(op, current, op.Value); applied {
+				setField(b, op, next)
+				values[i], ok[i] = next, true
+			}
+		}
+	}
+	return values, ok, nil
+}
+
+// getField читает целое поле BITFIELD; знаковые значения расширяются по знаку
+func getField(b []byte, op BitFieldOp) int64 {
+	var u uint64
+	for i := 0; i < op.Bits; i++ {
+		u = u<<1 | uint64(bitAt(b, op.Offset+i))
+	}
+	if op.Signed && op.Bits < 64 && u&(1<<(op.Bits-1)) != 0 {
+		u |= math.MaxUint64 << op.Bits
+	}
+	return int64(u)
+}
+
+// setField записывает младшие Bits бит значения
+func setField(b []byte, op BitFieldOp, value int64) {
+	u := uint64(value)
+	for i := 0; i < op.Bits; i++ {
+		setBitAt(b, op.Offset+i, int(u>>(op.Bits-1-i))&1)
+	}
+}
+
+// fieldOverflow вычисляет value+incr с учётом разрядности поля и режима
+// переполнения, как checkSignedBitfieldOverflow и checkUnsignedBitfieldOverflow
+// в Redis. Возвращает false, если в режиме OverflowFail значение не помещается.
+func fieldOverflow(op BitFieldOp, value, incr int64) (int64, bool) {
+	if op.Signed {
+		return signedOverflow(value, incr, op.Bits, op.Overflow)
+	}
+	return unsignedOverflow(uint64(value), incr, op.Bits, op.Overflow)
+}
+
+func signedOverflow(value, incr int64, n int, mode BitFieldOverflow) (int64, bool) {
+	maxValue := int64(math.MaxInt64)
+	if n < 64 {
+		maxValue = 1<<(n-1) - 1
+	}
+	minValue := -maxValue - 1
+	maxIncr := maxValue - value
+	minIncr := minValue - value
+
+	var limit int64
+	switch {
+	case value > maxValue || (n != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr):
+		limit = maxValue
+	case value < minValue || (n != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr):
+		limit = minValue
+	default:
+		return value + incr, true
+	}
+
+	switch mode {
+	case OverflowSat:
+		return limit, true
+	case OverflowFail:
+		return 0, false
+	}
+
+	c := uint64(value) + uint64(incr)
+	if n < 64 {
+		mask := uint64(math.MaxUint64) << n
+		if c&(1<<(n-1)) != 0 {
+			c |= mask
+		} else {
+			c &^= mask
+		}
+	}
+	return int64(c), true
+}
+
+func unsignedOverflow(value uint64, incr int64, n int, mode BitFieldOverflow) (int64, bool) {
+	maxValue := uint64(1)<<n - 1
+
+	var limit uint64
+	switch {
+	case value > maxValue || (incr > 0 && uint64(incr) > maxValue-value):
+		limit = maxValue
+	case incr < 0 && uint64(-(incr+1))+1 > value:
+		limit = 0
+	default:
+		return int64(value + uint64(incr)), true
+	}
+
+	switch mode {
+	case OverflowSat:
+		return int64(limit), true
+	case OverflowFail:
+		return 0, false
+	}
+	return int64((value + uint64(incr)) & maxValue), true
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSetBitGrowsString(t *testing.T) {
+	s := newTestStorage(t)
+	if old, err := s.SetBit("k", 7, 1); err != nil || old != 0 {
+		t.Fatalf("SetBit = %d, %v", old, err)
+	}
+	if old, _ := s.SetBit("k", 7, 0); old != 1 {
+		t.Errorf("second SetBit returned %d, want 1", old)
+	}
+	s.SetBit("k", 100, 1)
+	if n, _ := s.StrLen("k"); n != 13 {
+		t.Errorf("StrLen = %d, want 13", n)
+	}
+	if bit, _ := s.GetBit("k", 100); bit != 1 {
+		t.Errorf("GetBit(100) = %d", bit)
+	}
+	if bit, _ := s.GetBit("k", math.MaxInt); bit != 0 {
+		t.Errorf("GetBit past end = %d", bit)
+	}
+
+	// SETBIT меняет строку, записанную через SET
+	s.Set("str", "\x00", 0)
+	s.SetBit("str", 0, 1)
+	if got, _, _ := s.Get("str"); got != "\x80" {
+		t.Errorf("string after SetBit = %q", got)
+	}
+}
+
+func TestBitCount(t *testing.T) {
+	s := newTestStorage(t)
+	s.Set("k", "foobar", 0)
+
+	tests := []struct {
+		r    *BitRange
+		want int
+	}{
+		{nil, 26},
+		{&BitRange{Start: 0, End: 0, HasEnd: true}, 4},
+		{&BitRange{Start: 1, End: 1, HasEnd: true}, 6},
+		{&BitRange{Start: 5, End: 30, HasEnd: true, BitMode: true}, 17},
+		{&BitRange{Start: -2, End: -1, HasEnd: true}, 7},
+		{&BitRange{Start: 3, End: 1, HasEnd: true}, 0},
+		{&BitRange{Start: math.MinInt, End: math.MaxInt, HasEnd: true}, 26},
+	}
+	for _, tt := range tests {
+		if got, err := s.BitCount("k", tt.r); err != nil || got != tt.want {
+			t.Errorf("BitCount(%+v) = %d, %v; want %d", tt.r, got, err, tt.want)
+		}
+	}
+	if got, _ := s.BitCount("missing", nil); got != 0 {
+		t.Errorf("BitCount(missing) = %d", got)
+	}
+}
+
+func TestBitPos(t *testing.T) {
+	s := newTestStorage(t)
+	s.Set("a", "\xff\xf0\x00", 0)
+	s.Set("b", "\x00\xff\xf0", 0)
+	s.Set("ones", "\xff\xff", 0)
+
+	tests := []struct {
+		key  string
+		bit  int
+		r    *BitRange
+		want int
+	}{
+		{"a", 0, nil, 12},
+		{"b", 1, &BitRange{Start: 0}, 8},
+		{"b", 1, &BitRange{Start: 2}, 16},
+		{"b", 1, &BitRange{Start: 2, End: -1, HasEnd: true, BitMode: true}, 8},
+		{"ones", 0, nil, 16},
+		{"ones", 0, &BitRange{Start: 0, End: -1, HasEnd: true}, -1},
+		{"missing", 0, nil, 0},
+		{"missing", 1, nil, -1},
+	}
+	for _, tt := range tests {
+		if got, err := s.BitPos(tt.key, tt.bit, tt.r); err != nil || got != tt.want {
+			t.Errorf("BitPos(%s, %d, %+v) = %d, %v; want %d", tt.key, tt.bit, tt.r, got, err, tt.want)
+		}
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	s := newTestStorage(t)
+	s.Set("a", "\xf0\x0f", 0)
+	s.Set("b", "\xff", 0)
+
+	tests := []struct {
+		op   BitOp
+		keys []string
+		want string
+	}{
+		{BitAnd, []string{"a", "b"}, "\xf0\x00"},
+		{BitOr, []string{"a", "b"}, "\xff\x0f"},
+		{BitXor, []string{"a", "b"}, "\x0f\x0f"},
+		{BitNot, []string{"a"}, "\x0f\xf0"},
+	}
+	for _, tt := range tests {
+		n, err := s.BitOp(tt.op, "dst", tt.keys)
+		got, _, _ := s.Get("dst")
+		if err != nil || n != len(tt.want) || got != tt.want {
+			t.Errorf("BitOp(%d) = %d, %q, %v; want %q", tt.op, n, got, err, tt.want)
+		}
+	}
+
+	if n, _ := s.BitOp(BitOr, "dst", []string{"missing"}); n != 0 || s.Exists("dst") {
+		t.Error("empty BITOP result kept the destination")
+	}
+	s.SAdd("set", []string{"a"})
+	if _, err := s.BitOp(BitOr, "dst", []string{"a", "set"}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("BitOp with set err = %v, want ErrWrongType", err)
+	}
+}
+
+func TestBitFieldOverflow(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     BitFieldOp
+		want   int64
+		wantOK bool
+	}{
+		{"u2 wrap", BitFieldOp{Kind: BitFieldIncrBy, Bits: 2, Value: 4}, 3, true},
+		{"u2 sat", BitFieldOp{Kind: BitFieldIncrBy, Bits: 2, Value: 4, Overflow: OverflowSat}, 3, true},
+		{"u2 fail", BitFieldOp{Kind: BitFieldIncrBy, Bits: 2, Value: 4, Overflow: OverflowFail}, 0, false},
+		{"i8 wrap", BitFieldOp{Kind: BitFieldIncrBy, Signed: true, Bits: 8, Value: 200}, -57, true},
+		{"i8 sat", BitFieldOp{Kind: BitFieldIncrBy, Signed: true, Bits: 8, Value: 200, Overflow: OverflowSat}, 127, true},
+		{"i8 sat down", BitFieldOp{Kind: BitFieldIncrBy, Signed: true, Bits: 8, Value: -200, Overflow: OverflowSat}, -128, true},
+		{"i64 max", BitFieldOp{Kind: BitFieldIncrBy, Signed: true, Bits: 64, Value: math.MaxInt64, Overflow: OverflowFail}, 0, false},
+		{"set returns old", BitFieldOp{Kind: BitFieldSet, Bits: 8, Value: 9}, 255, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			// Поле заполнено единицами: u2 = 3, i8 = -1, i64 = -1
+			s.Set("k", "\xff\xff\xff\xff\xff\xff\xff\xff", 0)
+			if tt.op.Bits == 64 {
+				s.BitField("k", []BitFieldOp{{Kind: BitFieldSet, Signed: true, Bits: 64, Value: 1}})
+			}
+
+			values, ok, err := s.BitField("k", []BitFieldOp{tt.op})
+			if err != nil || values[0] != tt.want || ok[0] != tt.wantOK {
+				t.Errorf("BitField = %d, %v, %v; want %d, %v", values[0], ok[0], err, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestBitFieldGetDoesNotCreateKey(t *testing.T) {
+	s := newTestStorage(t)
+	values, ok, err := s.BitField("k", []BitFieldOp{{Kind: BitFieldGet, Signed: true, Bits: 5, Offset: 100}})
+	if err != nil || values[0] != 0 || !ok[0] {
+		t.Errorf("BitField GET = %v, %v, %v", values, ok, err)
+	}
+	if s.Exists("k") {
+		t.Error("BITFIELD GET created the key")
+	}
+}
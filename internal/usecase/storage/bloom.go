@@ -0,0 +1,479 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+var (
+	ErrFilterExists   = errors.New("ERR item exists")
+	ErrFilterNotFound = errors.New("ERR not found")
+	ErrBloomFull      = errors.New("ERR non scaling filter is full")
+	ErrBloomTooLarge  = errors.New("ERR filter is too large")
+)
+
+// MaxBloomBits наибольший размер слоя фильтра Блума в битах (512 МиБ)
+const MaxBloomBits = 1 << 32
+
+// BloomOptions параметры фильтра Блума: допустимая доля ложных
+// срабатываний, ёмкость первого слоя и множитель ёмкости новых слоёв
+type BloomOptions struct {
+	ErrorRate  float64
+	Capacity   int
+	Expansion  int
+	NonScaling bool
+}
+
+// DefaultBloomOptions параметры фильтра, создаваемого BF.ADD
+var DefaultBloomOptions = BloomOptions{ErrorRate: 0.01, Capacity: 100, Expansion: 2}
+
+// bloomTightening во столько раз уменьшается доля ложных срабатываний
+// каждого следующего слоя, чтобы суммарная не превышала заданную
+const bloomTightening = 0.5
+
+// bloomLayer слой масштабируемого фильтра Блума фиксированной ёмкости
+type bloomLayer struct {
+	bits     []uint64
+	size     uint64
+	hashes   int
+	capacity int
+	count    int
+	errRate  float64
+}
+
+// BloomFilter масштабируемый фильтр Блума: когда слой заполняется,
+// добавляется следующий, ёмкостью в Expansion раз больше
+type BloomFilter struct {
+	layers     []*bloomLayer
+	expansion  int
+	nonScaling bool
+}
+
+// bloomLayerBits возвращает размер слоя в битах. Размер считается в
+// float64, чтобы огромная ёмкость не переполняла целые.
+func bloomLayerBits(capacity, errRate float64) float64 {
+	bitsPerEntry := -math.Log(errRate) / (math.Ln2 * math.Ln2)
+	return math.Ceil(capacity * bitsPerEntry)
+}
+
+// newBloomLayer создаёт слой; размер должен быть проверен bloomLayerBits
+func newBloomLayer(capacity int, errRate float64) *bloomLayer {
+	bitsPerEntry := -math.Log(errRate) / (math.Ln2 * math.Ln2)
+	size := uint64(bloomLayerBits(float64(capacity), errRate))
+	size = (size + 63) / 64 * 64
+	return &bloomLayer{
+		bits:     make([]uint64, size/64),
+		size:     size,
+		hashes:   int(math.Ceil(math.Ln2 * bitsPerEntry)),
+		capacity: capacity,
+		errRate:  errRate,
+	}
+}
+
+func newBloomFilter(opts BloomOptions) *BloomFilter {
+	return &BloomFilter{
+		layers:     []*bloomLayer{newBloomLayer(opts.Capacity, opts.ErrorRate)},
+		expansion:  opts.Expansion,
+		nonScaling: opts.NonScaling,
+	}
+}
+
+// bloomHash возвращает пару хэшей для двойного хэширования, как в RedisBloom
+func bloomHash(item string) (uint64, uint64) {
+	a := murmurHash64A([]byte(item), 0xc6a4a7935bd1e995)
+	return a, murmurHash64A([]byte(item), a)
+}
+
+func (l *bloomLayer) has(a, b uint64) bool {
+	for i := 0; i < l.hashes; i++ {
+		x := (a + uint64(i)*b) % l.size
+		if l.bits[x/64]&(1<<(x%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *bloomLayer) add(a, b uint64) {
+	for i := 0; i < l.hashes; i++ {
+		x := (a + uint64(i)*b) % l.size
+		l.bits[x/64] |= 1 << (x % 64)
+	}
+	l.count++
+}
+
+func (f *BloomFilter) has(item string) bool {
+	a, b := bloomHash(item)
+	for _, l := range f.layers {
+		if l.has(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// add добавляет элемент и сообщает, что его, вероятно, ещё не было
+func (f *BloomFilter) add(item string) (bool, error) {
+	a, b := bloomHash(item)
+	for _, l := range f.layers {
+		if l.has(a, b) {
+			return false, nil
+		}
+	}
+
+	last := f.layers[len(f.layers)-1]
+	if last.count >= last.capacity {
+		if f.nonScaling {
+			return false, ErrBloomFull
+		}
+		capacity, errRate := float64(last.capacity)*float64(f.expansion), last.errRate*bloomTightening
+		if bloomLayerBits(capacity, errRate) > MaxBloomBits {
+			return false, ErrBloomTooLarge
+		}
+		last = newBloomLayer(int(capacity), errRate)
+		f.layers = append(f.layers, last)
+	}
+	last.add(a, b)
+	return true, nil
+}
+
+// BloomInfo сведения BF.INFO; Size — объём битовых массивов в байтах
+type BloomInfo struct {
+	Capacity  int
+	Size      int
+	Filters   int
+	Items     int
+	Expansion int
+}
+
+// getBloom возвращает фильтр Блума или nil. Вызывается под блокировкой.
+func (s *Storage) getBloom(key string) (*BloomFilter, error) {
+	obj, err := s.lookupType(key, TypeBloom)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return obj.value.(*BloomFilter), nil
+}
+
+// BFReserve создаёт пустой фильтр Блума
+func (s *Storage) BFReserve(key string, opts BloomOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if bloomLayerBits(float64(opts.Capacity), opts.ErrorRate) > MaxBloomBits {
+		return ErrBloomTooLarge
+	}
+	if s.lookupWrite(key) != nil {
+		return ErrFilterExists
+	}
+	s.put(key, &object{typ: TypeBloom, value: newBloomFilter(opts)})
+	return nil
+}
+
+// BFAdd добавляет элементы, создавая фильтр с параметрами по умолчанию.
+// added[i] равен true, если элемента, вероятно, ещё не было. Если
+// немасштабируемый фильтр заполнен или новый слой превысил бы MaxBloomBits,
+// возвращаются результаты для уже добавленных элементов и ErrBloomFull или
+// ErrBloomTooLarge.
+func (s *Storage) BFAdd(key string, items []string) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, err := s.lookupWriteType(key, TypeBloom)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		obj = &object{typ: TypeBloom, value: newBloomFilter(DefaultBloomOptions)}
+		s.put(key, obj)
+	}
+	filter := obj.value.(*BloomFilter)
+
+	added := make([]bool, 0, len(items))
+	for _, item := range items {
+		ok, err := filter.add(item)
+		if err != nil {
+			return added, err
+		}
+		added = append(added, ok)
+	}
+	return added, nil
+}
+
+// BFExists проверяет элементы; false означает, что элемента точно нет
+func (s *Storage) BFExists(key string, items []string) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filter, err := s.getBloom(key)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]bool, len(items))
+	if filter == nil {
+		return found, nil
+	}
+	for i, item := range items {
+		found[i] = filter.has(item)
+	}
+	return found, nil
+}
+
+// BFInfo возвращает сведения о фильтре Блума
+func (s *Storage) BFInfo(key string) (BloomInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filter, err := s.getBloom(key)
+	if err != nil {
+		return BloomInfo{}, err
+	}
+	if filter == nil {
+		return BloomInfo{}, ErrFilterNotFound
+	}
+
+	info := BloomInfo{Filters: len(filter.layers), Expansion: filter.expansion}
+	for _, l := range filter.layers {
+		info.Capacity += l.capacity
+		info.Size += len(l.bits) * 8
+		info.Items += l.count
+	}
+	if filter.nonScaling {
+		info.Expansion = 0
+	}
+	return info, nil
+}
+
+// Параметры фильтров с кукушкиным хэшированием, как по умолчанию в RedisBloom
+const (
+	cuckooCapacity      = 1024
+	cuckooBucketSize    = 2
+	cuckooMaxIterations = 20
+	cuckooExpansion     = 1
+)
+
+// cuckooLayer таблица отпечатков: numBuckets корзин по cuckooBucketSize
+// однобайтовых отпечатков; ноль означает пустую ячейку
+type cuckooLayer struct {
+	buckets    []byte
+	numBuckets uint64
+}
+
+// CuckooFilter фильтр с кукушкиным хэшированием. В отличие от фильтра
+// Блума поддерживает удаление и подсчёт. Когда вставка не удаётся,
+// добавляется новый слой. Вытеснение детерминировано, чтобы повтор
+// команд из AOF воссоздавал то же содержимое.
+type CuckooFilter struct {
+	layers []*cuckooLayer
+	kicks  uint64
+}
+
+func newCuckooLayer(capacity int) *cuckooLayer {
+	numBuckets := uint64(1) << bits.Len64(uint64(max(capacity/cuckooBucketSize, 1)-1))
+	return &cuckooLayer{
+		buckets:    make([]byte, numBuckets*cuckooBucketSize),
+		numBuckets: numBuckets,
+	}
+}
+
+// cuckooHash возвращает отпечаток элемента и хэш для выбора корзины
+func cuckooHash(item string) (byte, uint64) {
+	hash := murmurHash64A([]byte(item), 0)
+	return byte(hash%255 + 1), hash
+}
+
+func (l *cuckooLayer) indexes(fp byte, hash uint64) (uint64, uint64) {
+	i1 := hash & (l.numBuckets - 1)
+	return i1, l.altIndex(i1, fp)
+}
+
+// altIndex вторая корзина отпечатка; операция симметрична, так как число корзин — степень двойки
+func (l *cuckooLayer) altIndex(i uint64, fp byte) uint64 {
+	return (i ^ uint64(fp)*0x5bd1e995) & (l.numBuckets - 1)
+}
+
+func (l *cuckooLayer) bucket(i uint64) []byte {
+	return l.buckets[i*cuckooBucketSize : (i+1)*cuckooBucketSize]
+}
+
+// place записывает отпечаток в свободную ячейку корзины
+func (l *cuckooLayer) place(i uint64, fp byte) bool {
+	b := l.bucket(i)
+	for j := range b {
+		if b[j] == 0 {
+			b[j] = fp
+			return true
+		}
+	}
+	return false
+}
+
+func (l *cuckooLayer) count(fp byte, hash uint64) int {
+	i1, i2 := l.indexes(fp, hash)
+	n := 0
+	for _, i := range []uint64{i1, i2} {
+		for _, v := range l.bucket(i) {
+			if v == fp {
+				n++
+			}
+		}
+		if i1 == i2 {
+			break
+		}
+	}
+	return n
+}
+
+func (l *cuckooLayer) remove(fp byte, hash uint64) bool {
+	i1, i2 := l.indexes(fp, hash)
+	for _, i := range []uint64{i1, i2} {
+		b := l.bucket(i)
+		for j := range b {
+			if b[j] == fp {
+				b[j] = 0
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// insert вставляет отпечаток, при необходимости вытесняя другие. Если за
+// cuckooMaxIterations вытеснений места не нашлось, изменения отменяются.
+func (f *CuckooFilter) insert(l *cuckooLayer, fp byte, hash uint64) bool {
+	i1, i2 := l.indexes(fp, hash)
+	if l.place(i1, fp) || l.place(i2, fp) {
+		return true
+	}
+
+	type swap struct {
+		slot int
+		old  byte
+	}
+	var history []swap
+
+	i := i1
+	if f.kicks%2 == 1 {
+		i = i2
+	}
+	for n := 0; n < cuckooMaxIterations; n++ {
+		f.kicks++
+		slot := int(i)*cuckooBucketSize + int(f.kicks%cuckooBucketSize)
+		history = append(history, swap{slot, l.buckets[slot]})
+		fp, l.buckets[slot] = l.buckets[slot], fp
+
+		i = l.altIndex(i, fp)
+		if l.place(i, fp) {
+			return true
+		}
+	}
+
+	for j := len(history) - 1; j >= 0; j-- {
+		l.buckets[history[j].slot] = history[j].old
+	}
+	return false
+}
+
+func (f *CuckooFilter) add(item string) {
+	fp, hash := cuckooHash(item)
+	for _, l := range f.layers {
+		if f.insert(l, fp, hash) {
+			return
+		}
+	}
+
+	last := f.layers[len(f.layers)-1]
+	l := newCuckooLayer(int(last.numBuckets) * cuckooBucketSize * cuckooExpansion)
+	f.layers = append(f.layers, l)
+	f.insert(l, fp, hash)
+}
+
+// getCuckoo возвращает фильтр или nil. Вызывается под блокировкой.
+func (s *Storage) getCuckoo(key string) (*CuckooFilter, error) {
+	obj, err := s.lookupType(key, TypeCuckoo)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return obj.value.(*CuckooFilter), nil
+}
+
+// CFAdd добавляет элемент, создавая фильтр при необходимости. Повторное
+// добавление того же элемента увеличивает его счётчик.
+func (s *Storage) CFAdd(key, item string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, err := s.lookupWriteType(key, TypeCuckoo)
+	if err != nil {
+		return err
+	}
+	if obj == nil {
+		obj = &object{typ: TypeCuckoo, value: &CuckooFilter{layers: []*cuckooLayer{newCuckooLayer(cuckooCapacity)}}}
+		s.put(key, obj)
+	}
+	obj.value.(*CuckooFilter).add(item)
+	return nil
+}
+
+// CFDel удаляет одно вхождение элемента
+func (s *Storage) CFDel(key, item string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	filter, err := s.getCuckoo(key)
+	if err != nil {
+		return false, err
+	}
+	if filter == nil {
+		return false, ErrFilterNotFound
+	}
+
+	fp, hash := cuckooHash(item)
+	for i := len(filter.layers) - 1; i >= 0; i-- {
+		if filter.layers[i].remove(fp, hash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CFCount возвращает оценку числа вхождений элемента (возможно завышенную)
+func (s *Storage) CFCount(key, item string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	filter, err := s.getCuckoo(key)
+	if err != nil || filter == nil {
+		return 0, err
+	}
+
+	fp, hash := cuckooHash(item)
+	n := 0
+	for _, l := range filter.layers {
+		n += l.count(fp, hash)
+	}
+	return n, nil
+}
+
+// clone возвращает независимую копию фильтра Блума
+func (f *BloomFilter) clone() *BloomFilter {
+	copied := &BloomFilter{expansion: f.expansion, nonScaling: f.nonScaling}
+	for _, layer := range f.layers {
+		l := *layer
+		l.bits = slices.Clone(layer.bits)
+		copied.layers = append(copied.layers, &l)
+	}
+	return copied
+}
+
+// clone возвращает независимую копию фильтра с кукушкой
+func (f *CuckooFilter) clone() *CuckooFilter {
+	copied := &CuckooFilter{kicks: f.kicks}
+	for _, layer := range f.layers {
+		copied.layers = append(copied.layers, &cuckooLayer{buckets: slices.Clone(layer.buckets), numBuckets: layer.numBuckets})
+	}
+	return copied
+}
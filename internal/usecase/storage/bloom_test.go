@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestBFReserve(t *testing.T) {
+	tests := []struct {
+		name string
+		opts BloomOptions
+		want error
+	}{
+		{"default", DefaultBloomOptions, nil},
+		{"high error rate", BloomOptions{ErrorRate: 0.5, Capacity: 1000, Expansion: 2}, nil},
+		{"too many bits", BloomOptions{ErrorRate: 0.01, Capacity: math.MaxInt, Expansion: 2}, ErrBloomTooLarge},
+		{"tiny error rate", BloomOptions{ErrorRate: 1e-300, Capacity: 10000000, Expansion: 2}, ErrBloomTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			if err := s.BFReserve("bf", tt.opts); !errors.Is(err, tt.want) {
+				t.Fatalf("BFReserve err = %v, want %v", err, tt.want)
+			}
+			if s.Exists("bf") != (tt.want == nil) {
+				t.Errorf("key exists = %v after BFReserve", s.Exists("bf"))
+			}
+		})
+	}
+
+	s := newTestStorage(t)
+	s.BFReserve("bf", DefaultBloomOptions)
+	if err := s.BFReserve("bf", DefaultBloomOptions); !errors.Is(err, ErrFilterExists) {
+		t.Errorf("second BFReserve err = %v, want ErrFilterExists", err)
+	}
+}
+
+func TestBloomFalsePositives(t *testing.T) {
+	s := newTestStorage(t)
+	s.BFReserve("bf", BloomOptions{ErrorRate: 0.01, Capacity: 1000, Expansion: 2})
+
+	items := make([]string, 5000)
+	for i := range items {
+		items[i] = "in" + strconv.Itoa(i)
+	}
+	if _, err := s.BFAdd("bf", items); err != nil {
+		t.Fatal(err)
+	}
+	found, _ := s.BFExists("bf", items)
+	for i, ok := range found {
+		if !ok {
+			t.Fatalf("false negative for %s", items[i])
+		}
+	}
+
+	probes := make([]string, 20000)
+	for i := range probes {
+		probes[i] = "out" + strconv.Itoa(i)
+	}
+	positives := 0
+	found, _ = s.BFExists("bf", probes)
+	for _, ok := range found {
+		if ok {
+			positives++
+		}
+	}
+	// Суммарная доля ложных срабатываний всех слоёв не должна заметно превышать заданную
+	if rate := float64(positives) / float64(len(probes)); rate > 0.02 {
+		t.Errorf("false positive rate = %.4f, want about 0.01", rate)
+	}
+
+	info, _ := s.BFInfo("bf")
+	if info.Filters != 3 || info.Capacity != 7000 || info.Items > 5000 {
+		t.Errorf("BFInfo = %+v, want 3 layers with capacity 7000", info)
+	}
+}
+
+func TestBloomFull(t *testing.T) {
+	tests := []struct {
+		name string
+		opts BloomOptions
+		want error
+	}{
+		{"non scaling", BloomOptions{ErrorRate: 0.01, Capacity: 10, NonScaling: true}, ErrBloomFull},
+		{"next layer too large", BloomOptions{ErrorRate: 0.01, Capacity: 10, Expansion: 1 << 30}, ErrBloomTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			s.BFReserve("bf", tt.opts)
+
+			items := make([]string, 20)
+			for i := range items {
+				items[i] = strconv.Itoa(i)
+			}
+			added, err := s.BFAdd("bf", items)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("BFAdd err = %v, want %v", err, tt.want)
+			}
+			// Элементы до ошибки добавлены и возвращены
+			if len(added) < 10 || len(added) == len(items) {
+				t.Errorf("BFAdd returned %d results", len(added))
+			}
+			if info, _ := s.BFInfo("bf"); info.Filters != 1 {
+				t.Errorf("filter has %d layers", info.Filters)
+			}
+		})
+	}
+}
+
+func TestCuckooFilter(t *testing.T) {
+	s := newTestStorage(t)
+	if _, err := s.CFDel("cf", "a"); !errors.Is(err, ErrFilterNotFound) {
+		t.Errorf("CFDel of missing filter err = %v, want ErrFilterNotFound", err)
+	}
+
+	s.CFAdd("cf", "a")
+	s.CFAdd("cf", "a")
+	if n, _ := s.CFCount("cf", "a"); n != 2 {
+		t.Errorf("CFCount = %d, want 2", n)
+	}
+	if ok, _ := s.CFDel("cf", "a"); !ok {
+		t.Error("CFDel failed")
+	}
+	if n, _ := s.CFCount("cf", "a"); n != 1 {
+		t.Errorf("CFCount after CFDel = %d, want 1", n)
+	}
+
+	// Заполнение первого слоя добавляет новые, не теряя элементов
+	const n = 5000
+	for i := range n {
+		s.CFAdd("big", strconv.Itoa(i))
+	}
+	for i := range n {
+		if count, _ := s.CFCount("big", strconv.Itoa(i)); count == 0 {
+			t.Fatalf("item %d lost", i)
+		}
+	}
+}
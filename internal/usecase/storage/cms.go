@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+var (
+	ErrCMSExists    = errors.New("ERR CMS: key already exists")
+	ErrCMSNotFound  = errors.New("ERR CMS: key does not exist")
+	ErrCMSOverflow  = errors.New("ERR CMS: INCRBY overflow")
+	ErrCMSDimension = errors.New("ERR CMS: width/depth is not equal")
+	ErrCMSTooLarge  = errors.New("ERR CMS: width*depth is too large")
+)
+
+// MaxCMSCounters предельное число счётчиков таблицы (512 МБ)
+const MaxCMSCounters = 1 << 26
+
+// CountMinSketch таблица depth×width счётчиков. Оценка частоты элемента —
+// минимум его счётчиков по строкам; она не бывает заниженной.
+type CountMinSketch struct {
+	width, depth int
+	counters     []int64
+	total        int64
+}
+
+func newCountMinSketch(width, depth int) *CountMinSketch {
+	return &CountMinSketch{width: width, depth: depth, counters: make([]int64, width*depth)}
+}
+
+// CMSDimensions вычисляет размеры таблицы по допустимой погрешности
+// (доле от общего числа событий) и вероятности её превышения. Размеры
+// сверх MaxCMSCounters ограничиваются значением, которое отвергнет CMSInit.
+func CMSDimensions(errRate, probability float64) (int, int) {
+	width := math.Min(math.Ceil(2/errRate), MaxCMSCounters+1)
+	depth := math.Min(math.Ceil(math.Log10(probability)/math.Log10(0.5)), MaxCMSCounters+1)
+	return int(width), max(int(depth), 1)
+}
+
+// cell индекс счётчика элемента в строке row
+func (c *CountMinSketch) cell(item string, row int) int {
+	return row*c.width + int(murmurHash64A([]byte(item), uint64(row))%uint64(c.width))
+}
+
+func (c *CountMinSketch) query(item string) int64 {
+	result := int64(math.MaxInt64)
+	for row := 0; row < c.depth; row++ {
+		result = min(result, c.counters[c.cell(item, row)])
+	}
+	return result
+}
+
+// getCMS возвращает таблицу или ErrCMSNotFound. Вызывается под блокировкой.
+func (s *Storage) getCMS(key string) (*CountMinSketch, error) {
+	obj, err := s.lookupType(key, TypeCMS)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, ErrCMSNotFound
+	}
+	return obj.value.(*CountMinSketch), nil
+}
+
+// CMSInit создаёт пустую таблицу заданных размеров
+func (s *Storage) CMSInit(key string, width, depth int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if width < 1 || depth < 1 || width > MaxCMSCounters/depth {
+		return ErrCMSTooLarge
+	}
+	if s.lookupWrite(key) != nil {
+		return ErrCMSExists
+	}
+	s.put(key, &object{typ: TypeCMS, value: newCountMinSketch(width, depth)})
+	return nil
+}
+
+// CMSIncrBy увеличивает счётчики элементов и возвращает их новые оценки.
+// При переполнении ничего не изменяется.
+func (s *Storage) CMSIncrBy(key string, items []string, increments []int64) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cms, err := s.getCMS(key)
+	if err != nil {
+		return nil, err
+	}
+
+	total := cms.total
+	for _, incr := range increments {
+		if total > math.MaxInt64-incr {
+			return nil, ErrCMSOverflow
+		}
+		total += incr
+	}
+	cms.total = total
+
+	result := make([]int64, len(items))
+	for i, item := range items {
+		for row := 0; row < cms.depth; row++ {
+			cms.counters[cms.cell(item, row)] += increments[i]
+		}
+		result[i] = cms.query(item)
+	}
+	return result, nil
+}
+
+// CMSQuery возвращает оценки частоты элементов
+func (s *Storage) CMSQuery(key string, items []string) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cms, err := s.getCMS(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]int64, len(items))
+	for i, item := range items {
+		result[i] = cms.query(item)
+	}
+	return result, nil
+}
+
+// CMSMerge записывает в dest взвешенную сумму таблиц sources. Все таблицы
+// должны существовать и иметь одинаковые размеры.
+func (s *Storage) CMSMerge(dest string, sources []string, weights []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, err := s.getCMS(dest)
+	if err != nil {
+		return err
+	}
+	tables := make([]*CountMinSketch, len(sources))
+	for i, key := range sources {
+		if tables[i], err = s.getCMS(key); err != nil {
+			return err
+		}
+		if tables[i].width != target.width || tables[i].depth != target.depth {
+			return ErrCMSDimension
+		}
+	}
+
+	merged := make([]int64, len(target.counters))
+	var total int64
+	for i, t := range tables {
+		for j, v := range t.counters {
+			merged[j] += v * weights[i]
+		}
+		total += t.total * weights[i]
+	}
+	target.counters, target.total = merged, total
+	return nil
+}
+
+// clone возвращает независимую копию скетча
+func (c *CountMinSketch) clone() *CountMinSketch {
+	return &CountMinSketch{width: c.width, depth: c.depth, counters: slices.Clone(c.counters), total: c.total}
+}
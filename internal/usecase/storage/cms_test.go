@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestCMSDimensions(t *testing.T) {
+	tests := []struct {
+		errRate, probability float64
+		width, depth         int
+	}{
+		{0.001, 0.01, 2000, 7},
+		{0.01, 0.5, 200, 1},
+		{0.1, 0.99, 20, 1},
+		{1e-300, 0.01, MaxCMSCounters + 1, 7},
+		{0.01, 1e-300, 200, 997},
+	}
+	for _, tt := range tests {
+		width, depth := CMSDimensions(tt.errRate, tt.probability)
+		if width != tt.width || depth != tt.depth {
+			t.Errorf("CMSDimensions(%v, %v) = %d, %d; want %d, %d", tt.errRate, tt.probability, width, depth, tt.width, tt.depth)
+		}
+	}
+}
+
+func TestCMSInit(t *testing.T) {
+	tests := []struct {
+		width, depth int
+		want         error
+	}{
+		{2000, 7, nil},
+		{MaxCMSCounters, 1, nil},
+		{MaxCMSCounters + 1, 1, ErrCMSTooLarge},
+		{math.MaxInt, 2, ErrCMSTooLarge},
+		{2, math.MaxInt, ErrCMSTooLarge},
+		{0, 5, ErrCMSTooLarge},
+	}
+	for _, tt := range tests {
+		s := newTestStorage(t)
+		if err := s.CMSInit("cms", tt.width, tt.depth); !errors.Is(err, tt.want) {
+			t.Errorf("CMSInit(%d, %d) err = %v, want %v", tt.width, tt.depth, err, tt.want)
+		}
+	}
+
+	s := newTestStorage(t)
+	s.CMSInit("cms", 10, 2)
+	if err := s.CMSInit("cms", 10, 2); !errors.Is(err, ErrCMSExists) {
+		t.Errorf("second CMSInit err = %v, want ErrCMSExists", err)
+	}
+	if _, err := s.CMSQuery("missing", []string{"a"}); !errors.Is(err, ErrCMSNotFound) {
+		t.Errorf("CMSQuery of missing key err = %v, want ErrCMSNotFound", err)
+	}
+}
+
+func TestCMSNeverUnderestimates(t *testing.T) {
+	s := newTestStorage(t)
+	s.CMSInit("cms", 50, 4)
+
+	counts := make(map[string]int64)
+	for i := range 1000 {
+		item := strconv.Itoa(i % 200)
+		incr := int64(i%7 + 1)
+		s.CMSIncrBy("cms", []string{item}, []int64{incr})
+		counts[item] += incr
+	}
+	for item, want := range counts {
+		got, _ := s.CMSQuery("cms", []string{item})
+		if got[0] < want {
+			t.Fatalf("estimate of %s = %d, below %d", item, got[0], want)
+		}
+	}
+}
+
+func TestCMSIncrByOverflow(t *testing.T) {
+	s := newTestStorage(t)
+	s.CMSInit("cms", 10, 2)
+	s.CMSIncrBy("cms", []string{"a"}, []int64{math.MaxInt64 - 1})
+
+	if _, err := s.CMSIncrBy("cms", []string{"a", "b"}, []int64{1, 1}); !errors.Is(err, ErrCMSOverflow) {
+		t.Fatalf("CMSIncrBy err = %v, want ErrCMSOverflow", err)
+	}
+	if got, _ := s.CMSQuery("cms", []string{"a", "b"}); got[0] != math.MaxInt64-1 || got[1] != 0 {
+		t.Errorf("failed CMSIncrBy changed counters: %v", got)
+	}
+}
+
+func TestCMSMerge(t *testing.T) {
+	s := newTestStorage(t)
+	for _, key := range []string{"a", "b", "dst"} {
+		s.CMSInit(key, 100, 3)
+	}
+	s.CMSInit("other", 50, 3)
+	s.CMSIncrBy("a", []string{"x"}, []int64{2})
+	s.CMSIncrBy("b", []string{"x", "y"}, []int64{5, 1})
+
+	if err := s.CMSMerge("dst", []string{"a", "b"}, []int64{3, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.CMSQuery("dst", []string{"x", "y"}); got[0] != 11 || got[1] != 1 {
+		t.Errorf("merged estimates = %v, want [11 1]", got)
+	}
+
+	tests := []struct {
+		sources []string
+		want    error
+	}{
+		{[]string{"a", "other"}, ErrCMSDimension},
+		{[]string{"a", "missing"}, ErrCMSNotFound},
+	}
+	for _, tt := range tests {
+		if err := s.CMSMerge("dst", tt.sources, []int64{1, 1}); !errors.Is(err, tt.want) {
+			t.Errorf("CMSMerge(%q) err = %v, want %v", tt.sources, err, tt.want)
+		}
+	}
+	if err := s.CMSMerge("missing", []string{"a"}, []int64{1}); !errors.Is(err, ErrCMSNotFound) {
+		t.Errorf("CMSMerge into missing key err = %v, want ErrCMSNotFound", err)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotInteger = errors.New("ERR value is not an integer or out of range")
+	ErrNotFloat   = errors.New("ERR value is not a valid float")
+	ErrOverflow   = errors.New("ERR increment or decrement would overflow")
+	ErrNaNOrInf   = errors.New("ERR increment would produce NaN or Infinity")
+)
+
+// IncrBy атомарно увеличивает целое значение строки на delta.
+// Отсутствующий ключ считается равным нулю; TTL ключа сохраняется.
+func (s *Storage) IncrBy(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, found, err := s.writeString(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var value int64
+	if found {
+		value, err = parseIntValue(current)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	value += delta
+	s.putString(key, strconv.FormatInt(value, 10))
+	return value, nil
+}
+
+// IncrByFloat атомарно увеличивает значение строки на вещественное delta
+// и возвращает новое значение в том виде, в котором оно сохранено
+func (s *Storage) IncrByFloat(key string, delta float64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, found, err := s.writeString(key)
+	if err != nil {
+		return "", err
+	}
+
+	var value float64
+	if found {
+		value, err = parseFloatValue(current)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	value += delta
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", ErrNaNOrInf
+	}
+
+	result := strconv.FormatFloat(value, 'f', -1, 64)
+	s.putString(key, result)
+	return result, nil
+}
+
+// parseIntValue разбирает хранимое целое так же строго, как Redis:
+// без знака "+", пробелов и ведущих нулей
+func parseIntValue(value string) (int64, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != value {
+		return 0, ErrNotInteger
+	}
+	return n, nil
+}
+
+// parseFloatValue разбирает хранимое число, не принимая пробелы, NaN и бесконечности
+func parseFloatValue(value string) (float64, error) {
+	if value == "" || strings.TrimSpace(value) != value {
+		return 0, ErrNotFloat
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrNotFloat
+	}
+	return f, nil
+}
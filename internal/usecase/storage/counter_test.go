@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestIncrBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		delta   int64
+		want    int64
+		wantErr error
+	}{
+		{"missing key", "", 5, 5, nil},
+		{"negative", "10", -15, -5, nil},
+		{"to max", strconv.FormatInt(math.MaxInt64-1, 10), 1, math.MaxInt64, nil},
+		{"past max", strconv.FormatInt(math.MaxInt64, 10), 1, 0, ErrOverflow},
+		{"past min", strconv.FormatInt(math.MinInt64, 10), -1, 0, ErrOverflow},
+		{"min delta", "0", math.MinInt64, math.MinInt64, nil},
+		{"min delta from negative", "-1", math.MinInt64, 0, ErrOverflow},
+		{"leading zero", "01", 1, 0, ErrNotInteger},
+		{"plus sign", "+1", 1, 0, ErrNotInteger},
+		{"space", " 1", 1, 0, ErrNotInteger},
+		{"float", "1.5", 1, 0, ErrNotInteger},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			if tt.initial != "" {
+				s.Set("k", tt.initial, 0)
+			}
+			got, err := s.IncrBy("k", tt.delta)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("IncrBy = %d, %v; want %d, %v", got, err, tt.want, tt.wantErr)
+			}
+			if err != nil {
+				if value, _, _ := s.Get("k"); value != tt.initial {
+					t.Errorf("failed IncrBy changed value to %q", value)
+				}
+			}
+		})
+	}
+}
+
+func TestIncrByFloat(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		delta   float64
+		want    string
+		wantErr error
+	}{
+		{"missing key", "", 0.5, "0.5", nil},
+		{"integer result", "1.5", 1.5, "3", nil},
+		{"exponent", "1e3", 1, "1001", nil},
+		{"overflow to inf", "1e308", 1e308, "", ErrNaNOrInf},
+		{"inf delta", "1", math.Inf(1), "", ErrNaNOrInf},
+		{"stored inf", "inf", 1, "", ErrNotFloat},
+		{"stored nan", "nan", 1, "", ErrNotFloat},
+		{"space", "1 ", 1, "", ErrNotFloat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			if tt.initial != "" {
+				s.Set("k", tt.initial, 0)
+			}
+			got, err := s.IncrByFloat("k", tt.delta)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("IncrByFloat = %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package storage
+
+import "errors"
+
+var ErrDBIndex = errors.New("ERR DB index is out of range")
+
+// DefaultDatabases число логических баз по умолчанию
+const DefaultDatabases = 16
+
+// Databases пронумерованные логические базы. Команды, затрагивающие две
+// базы, блокируют их в порядке возрастания номера.
+type Databases []*Storage
+
+func NewDatabases(n int) Databases {
+	if n <= 0 {
+		n = DefaultDatabases
+	}
+	dbs := make(Databases, n)
+	for i := range dbs {
+		dbs[i] = NewStorage()
+	}
+	return dbs
+}
+
+// Stop останавливает все базы
+func (d Databases) Stop() {
+	for _, db := range d {
+		db.Stop()
+	}
+}
+
+// valid сообщает, существует ли база с номером i
+func (d Databases) valid(i int) bool {
+	return i >= 0 && i < len(d)
+}
+
+// lockPair блокирует на запись базы a и b и возвращает функцию разблокировки
+func (d Databases) lockPair(a, b int) func() {
+	if a == b {
+		d[a].mu.Lock()
+		return d[a].mu.Unlock
+	}
+	first, second := d[min(a, b)], d[max(a, b)]
+	first.mu.Lock()
+	second.mu.Lock()
+	return func() {
+		second.mu.Unlock()
+		first.mu.Unlock()
+	}
+}
+
+// Move переносит ключ вместе с TTL из базы from в базу to. Если ключа нет
+// или в to уже есть такой ключ, Move возвращает false.
+func (d Databases) Move(key string, from, to int) (bool, error) {
+	if !d.valid(from) || !d.valid(to) {
+		return false, ErrDBIndex
+	}
+	if from == to {
+		return false, ErrSameObject
+	}
+	defer d.lockPair(from, to)()
+
+	src, dst := d[from], d[to]
+	obj := src.lookupWrite(key)
+	if obj == nil || dst.lookupWrite(key) != nil {
+		return false, nil
+	}
+
+	expTime, hasTTL := src.expiration[key]
+	src.remove(key)
+	dst.put(key, obj)
+	if hasTTL {
+		dst.setExpire(key, expTime)
+	}
+	dst.track(key, obj)
+	dst.reindex(key)
+	return true, nil
+}
+
+// Copy записывает в ключ dst базы to независимую копию ключа src базы from.
+// Без replace существующий dst не перезаписывается, и Copy возвращает false.
+func (d Databases) Copy(src string, from int, dst string, to int, replace bool) (bool, error) {
+	if !d.valid(from) || !d.valid(to) {
+		return false, ErrDBIndex
+	}
+	if src == dst && from == to {
+		return false, ErrSameObject
+	}
+	defer d.lockPair(from, to)()
+
+	return d[from].copyTo(d[to], src, dst, replace), nil
+}
+
+// Swap меняет содержимое баз a и b местами. Клиенты, работающие с базой a,
+// сразу видят данные бывшей базы b, а ожидающие чтения потоков просыпаются.
+func (d Databases) Swap(a, b int) error {
+	if !d.valid(a) || !d.valid(b) {
+		return ErrDBIndex
+	}
+	if a == b {
+		return nil
+	}
+	defer d.lockPair(a, b)()
+
+	x, y := d[a], d[b]
+	x.data, y.data = y.data, x.data
+	x.keyOrder, y.keyOrder = y.keyOrder, x.keyOrder
+	x.expiration, y.expiration = y.expiration, x.expiration
+	x.expires, y.expires = y.expires, x.expires
+	x.indexes, y.indexes = y.indexes, x.indexes
+	x.tsRetention, y.tsRetention = y.tsRetention, x.tsRetention
+	x.notifyStreams()
+	y.notifyStreams()
+	return nil
+}
+
+// FlushAll очищает все базы
+func (d Databases) FlushAll() {
+	for _, db := range d {
+		db.FlushDB()
+	}
+}
+
+// copyTo записывает в ключ dstKey базы dst копию ключа src. Вызывается под
+// блокировкой на запись обеих баз.
+func (s *Storage) copyTo(dst *Storage, src, dstKey string, replace bool) bool {
+	obj := s.lookupWrite(src)
+	if obj == nil {
+		return false
+	}
+	if dst.lookupWrite(dstKey) != nil {
+		if !replace {
+			return false
+		}
+		dst.remove(dstKey)
+	}
+
+	copied := &object{typ: obj.typ, value: cloneValue(obj)}
+	dst.put(dstKey, copied)
+	if expTime, hasTTL := s.expiration[src]; hasTTL {
+		dst.setExpire(dstKey, expTime)
+	}
+	dst.track(dstKey, copied)
+	dst.reindex(dstKey)
+	return true
+}
+
+// emptyIndexes возвращает пустые индексы с теми же определениями
+func (s *Storage) emptyIndexes() map[string]*hashIndex {
+	indexes := make(map[string]*hashIndex, len(s.indexes))
+	for name, idx := range s.indexes {
+		indexes[name], _ = newHashIndex(idx.def)
+	}
+	return indexes
+}
+
+// ExpireStats суммирует статистику удаления истёкших ключей по всем базам.
+// Доля устаревших ключей усредняется с весом числа ключей и полей с TTL.
+func (d Databases) ExpireStats() ExpireStats {
+	var total ExpireStats
+	var stale float64
+	for _, db := range d {
+		st := db.ExpireStats()
+		total.ExpiredKeys += st.ExpiredKeys
+		total.Tracked += st.Tracked
+		total.LastCycle = max(total.LastCycle, st.LastCycle)
+		total.CycleTime += st.CycleTime
+		stale += st.StalePerc * float64(st.Tracked)
+	}
+	if total.Tracked > 0 {
+		total.StalePerc = stale / float64(total.Tracked)
+	}
+	return total
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestDatabases создаёт n баз, которые останавливаются по завершении теста
+func newTestDatabases(t *testing.T, n int) Databases {
+	t.Helper()
+	dbs := NewDatabases(n)
+	t.Cleanup(dbs.Stop)
+	return dbs
+}
+
+func TestDatabasesIndexErrors(t *testing.T) {
+	dbs := newTestDatabases(t, 2)
+	if _, err := dbs.Move("k", 0, 2); !errors.Is(err, ErrDBIndex) {
+		t.Errorf("Move to db 2 err = %v, want ErrDBIndex", err)
+	}
+	if _, err := dbs.Move("k", -1, 0); !errors.Is(err, ErrDBIndex) {
+		t.Errorf("Move from db -1 err = %v, want ErrDBIndex", err)
+	}
+	if _, err := dbs.Move("k", 1, 1); !errors.Is(err, ErrSameObject) {
+		t.Errorf("Move within db err = %v, want ErrSameObject", err)
+	}
+	if _, err := dbs.Copy("k", 0, "k", 0, true); !errors.Is(err, ErrSameObject) {
+		t.Errorf("Copy onto itself err = %v, want ErrSameObject", err)
+	}
+	if err := dbs.Swap(0, 5); !errors.Is(err, ErrDBIndex) {
+		t.Errorf("Swap with db 5 err = %v, want ErrDBIndex", err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	dbs := newTestDatabases(t, 2)
+	at := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	dbs[0].Set("k", "v", 0)
+	dbs[0].Expire("k", at, ExpireAlways)
+	dbs[0].Set("taken", "a", 0)
+	dbs[1].Set("taken", "b", 0)
+
+	if ok, _ := dbs.Move("taken", 0, 1); ok {
+		t.Error("Move onto an existing key succeeded")
+	}
+	if ok, _ := dbs.Move("missing", 0, 1); ok {
+		t.Error("Move of a missing key succeeded")
+	}
+	if ok, _ := dbs.Move("k", 0, 1); !ok {
+		t.Fatal("Move failed")
+	}
+	if dbs[0].Exists("k") {
+		t.Error("moved key left in the source db")
+	}
+	if got, _ := dbs[1].ExpireTime("k"); !got.Equal(at) {
+		t.Errorf("moved key expires at %v, want %v", got, at)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	dbs := newTestDatabases(t, 2)
+	dbs[0].SAdd("set", []string{"a"})
+	dbs[0].Expire("set", time.Now().Add(time.Hour), ExpireAlways)
+	dbs[1].Set("dst", "old", 0)
+
+	if ok, _ := dbs.Copy("set", 0, "dst", 1, false); ok {
+		t.Error("Copy without REPLACE overwrote the destination")
+	}
+	if ok, _ := dbs.Copy("set", 0, "dst", 1, true); !ok {
+		t.Fatal("Copy with REPLACE failed")
+	}
+	// Копия не связана с оригиналом
+	dbs[0].SAdd("set", []string{"b"})
+	if got, _ := dbs[1].SMembers("dst"); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("copied set = %q, want [a]", got)
+	}
+	if got, _ := dbs[1].ExpireTime("dst"); got.IsZero() {
+		t.Error("copy lost the TTL")
+	}
+}
+
+func TestSwap(t *testing.T) {
+	dbs := newTestDatabases(t, 2)
+	dbs[0].Set("a", "0", time.Hour)
+	dbs[1].HSet("user:1", []string{"age"}, []string{"30"})
+	if err := dbs[1].IdxCreate(IndexDefinition{Name: "idx", Schema: []IndexField{{Name: "age", Type: IndexNumeric}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dbs.Swap(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if dbs[0].Exists("a") || !dbs[1].Exists("a") {
+		t.Error("string key not swapped")
+	}
+	if got, _ := dbs[1].ExpireTime("a"); got.IsZero() {
+		t.Error("TTL not swapped with the key")
+	}
+	// Индекс переезжает вместе с данными
+	q, _ := ParseIndexQuery("@age:[30 30]")
+	if n, _, err := dbs[0].IdxSearch("idx", q, IndexSearchOptions{Limit: 10}); err != nil || n != 1 {
+		t.Errorf("IdxSearch after Swap = %d, %v", n, err)
+	}
+
+	dbs.FlushAll()
+	if dbs[0].DBSize()+dbs[1].DBSize() != 0 {
+		t.Error("FlushAll left keys")
+	}
+}
@@ -0,0 +1,81 @@
+package storage
+
+import "time"
+
+// ExpireCondition условие установки срока жизни: NX, XX, GT или LT
+type ExpireCondition int
+
+const (
+	ExpireAlways ExpireCondition = iota
+	ExpireNX                     // только если TTL нет
+	ExpireXX                     // только если TTL уже есть
+	ExpireGT                     // только если новый срок больше текущего
+	ExpireLT                     // только если новый срок меньше текущего
+)
+
+// allows проверяет условие для текущего срока current. Отсутствие TTL
+// считается бесконечным сроком, как в Redis.
+func (c ExpireCondition) allows(current time.Time, hasTTL bool, at time.Time) bool {
+	switch c {
+	case ExpireNX:
+		return !hasTTL
+	case ExpireXX:
+		return hasTTL
+	case ExpireGT:
+		return hasTTL && at.After(current)
+	case ExpireLT:
+		return !hasTTL || at.Before(current)
+	default:
+		return true
+	}
+}
+
+// Expire устанавливает ключу любого типа момент истечения at при выполнении
+// условия cond. Момент в прошлом сразу удаляет ключ. Возвращает false, если
+// ключа нет или условие не выполнено.
+func (s *Storage) Expire(key string, at time.Time, cond ExpireCondition) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookupWrite(key) == nil {
+		return false
+	}
+	current, hasTTL := s.expiration[key]
+	if !cond.allows(current, hasTTL, at) {
+		return false
+	}
+
+	if !at.After(time.Now()) {
+		s.remove(key)
+	} else {
+		s.setExpire(key, at)
+	}
+	return true
+}
+
+// ExpireTime возвращает момент истечения ключа. found равен false, если
+// ключа нет; нулевое время означает ключ без TTL.
+func (s *Storage) ExpireTime(key string) (at time.Time, found bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.lookup(key) == nil {
+		return time.Time{}, false
+	}
+	return s.expiration[key], true
+}
+
+// Persist снимает TTL с ключа и сообщает, был ли он
+func (s *Storage) Persist(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookupWrite(key) == nil {
+		return false
+	}
+	if _, hasTTL := s.expiration[key]; !hasTTL {
+		return false
+	}
+	s.clearExpire(key)
+	return true
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpireConditions(t *testing.T) {
+	now := time.Now()
+	current, earlier, later := now.Add(time.Hour), now.Add(time.Minute), now.Add(2*time.Hour)
+
+	// Ключ без TTL считается бессрочным: GT к нему не применим, LT — всегда
+	tests := []struct {
+		name   string
+		hasTTL bool
+		cond   ExpireCondition
+		at     time.Time
+		want   bool
+	}{
+		{"always", true, ExpireAlways, earlier, true},
+		{"NX without TTL", false, ExpireNX, later, true},
+		{"NX with TTL", true, ExpireNX, later, false},
+		{"XX without TTL", false, ExpireXX, later, false},
+		{"XX with TTL", true, ExpireXX, earlier, true},
+		{"GT later", true, ExpireGT, later, true},
+		{"GT earlier", true, ExpireGT, earlier, false},
+		{"GT equal", true, ExpireGT, current, false},
+		{"GT without TTL", false, ExpireGT, later, false},
+		{"LT earlier", true, ExpireLT, earlier, true},
+		{"LT later", true, ExpireLT, later, false},
+		{"LT without TTL", false, ExpireLT, later, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			s.HSet("k", []string{"f"}, []string{"v"})
+			if tt.hasTTL {
+				s.Expire("k", current, ExpireAlways)
+			}
+
+			if got := s.Expire("k", tt.at, tt.cond); got != tt.want {
+				t.Errorf("Expire = %v, want %v", got, tt.want)
+			}
+			want := time.Time{}
+			switch {
+			case tt.want:
+				want = tt.at
+			case tt.hasTTL:
+				want = current
+			}
+			if got, _ := s.ExpireTime("k"); !got.Equal(want) {
+				t.Errorf("ExpireTime = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestExpireAndPersist(t *testing.T) {
+	s := newTestStorage(t)
+	if s.Expire("missing", time.Now().Add(time.Hour), ExpireAlways) {
+		t.Error("Expire of a missing key succeeded")
+	}
+
+	s.Set("k", "v", 0)
+	if s.Persist("k") {
+		t.Error("Persist of a key without TTL reported a change")
+	}
+	s.Expire("k", time.Now().Add(time.Hour), ExpireAlways)
+	if !s.Persist("k") {
+		t.Error("Persist failed")
+	}
+	if at, found := s.ExpireTime("k"); !found || !at.IsZero() {
+		t.Errorf("ExpireTime after Persist = %v, %v", at, found)
+	}
+
+	// Момент в прошлом удаляет ключ сразу
+	if !s.Expire("k", time.Now().Add(-time.Second), ExpireAlways) || s.Exists("k") {
+		t.Error("Expire in the past kept the key")
+	}
+	if _, found := s.ExpireTime("k"); found {
+		t.Error("ExpireTime found a deleted key")
+	}
+}
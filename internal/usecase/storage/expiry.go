@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"container/heap"
+	"math/rand/v2"
+	"time"
+)
+
+// Параметры цикла активного удаления, как в Redis: цикл запускается
+// каждые expireCycleInterval, удаляет истёкшие ключи пачками по
+// expireCycleBatch под блокировкой и продолжает, пока доля истёкших среди
+// оставшихся записей индекса не ниже expireCycleRepeatRatio и не исчерпан
+// бюджет времени expireCycleBudget. Между пачками блокировка отпускается.
+const (
+	expireCycleInterval    = 100 * time.Millisecond
+	expireCycleBudget      = 25 * time.Millisecond
+	expireCycleBatch       = 20
+	expireCycleRepeatRatio = 0.1
+
+	// expireStaleExact до стольких истёкших записей их доля считается
+	// точно, больше — оценивается по выборке из expireStaleSamples записей
+	expireStaleExact   = 1000
+	expireStaleSamples = 100
+)
+
+// expiryTarget ключ или поле хэша с TTL
+type expiryTarget struct {
+	key     string
+	field   string
+	isField bool
+}
+
+type expiryEntry struct {
+	target expiryTarget
+	at     time.Time
+	index  int
+}
+
+// expiryIndex мин-куча сроков истечения с поиском записи по ключу или полю.
+// На каждый ключ и поле приходится не больше одной записи.
+type expiryIndex struct {
+	entries  []*expiryEntry
+	byTarget map[expiryTarget]*expiryEntry
+}
+
+func newExpiryIndex() *expiryIndex {
+	return &expiryIndex{byTarget: make(map[expiryTarget]*expiryEntry)}
+}
+
+func (x *expiryIndex) Len() int           { return len(x.entries) }
+func (x *expiryIndex) Less(i, j int) bool { return x.entries[i].at.Before(x.entries[j].at) }
+func (x *expiryIndex) Swap(i, j int) {
+	x.entries[i], x.entries[j] = x.entries[j], x.entries[i]
+	x.entries[i].index = i
+	x.entries[j].index = j
+}
+func (x *expiryIndex) Push(v any) {
+	e := v.(*expiryEntry)
+	e.index = len(x.entries)
+	x.entries = append(x.entries, e)
+}
+func (x *expiryIndex) Pop() any {
+	n := len(x.entries) - 1
+	e := x.entries[n]
+	x.entries[n] = nil
+	x.entries = x.entries[:n]
+	return e
+}
+
+// set добавляет запись или переносит существующую на момент at
+func (x *expiryIndex) set(t expiryTarget, at time.Time) {
+	if e, exists := x.byTarget[t]; exists {
+		e.at = at
+		heap.Fix(x, e.index)
+		return
+	}
+	e := &expiryEntry{target: t, at: at}
+	x.byTarget[t] = e
+	heap.Push(x, e)
+}
+
+func (x *expiryIndex) remove(t expiryTarget) {
+	if e, exists := x.byTarget[t]; exists {
+		heap.Remove(x, e.index)
+		delete(x.byTarget, t)
+	}
+}
+
+// popDue извлекает самую раннюю запись, если её срок истёк к моменту now
+func (x *expiryIndex) popDue(now time.Time) (*expiryEntry, bool) {
+	if len(x.entries) == 0 || !now.After(x.entries[0].at) {
+		return nil, false
+	}
+	e := heap.Pop(x).(*expiryEntry)
+	delete(x.byTarget, e.target)
+	return e, true
+}
+
+// countDue считает записи, истёкшие к моменту now, но не больше limit.
+// Истёкшие записи образуют поддерево у корня кучи, поэтому обход стоит
+// O(limit), а не O(N).
+func (x *expiryIndex) countDue(now time.Time, limit int) int {
+	n := 0
+	stack := []int{0}
+	for len(stack) > 0 && n < limit {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= len(x.entries) || !now.After(x.entries[i].at) {
+			continue
+		}
+		n++
+		stack = append(stack, 2*i+1, 2*i+2)
+	}
+	return n
+}
+
+// staleShare возвращает долю записей, истёкших к моменту now: точно, если
+// их не больше expireStaleExact, иначе по случайной выборке
+func (x *expiryIndex) staleShare(now time.Time) float64 {
+	n := len(x.entries)
+	if n == 0 {
+		return 0
+	}
+	if due := x.countDue(now, expireStaleExact+1); due <= expireStaleExact {
+		return float64(due) / float64(n)
+	}
+	stale := 0
+	for range expireStaleSamples {
+		if now.After(x.entries[rand.IntN(n)].at) {
+			stale++
+		}
+	}
+	return float64(stale) / expireStaleSamples
+}
+
+// ExpireStats статистика удаления истёкших ключей для INFO
+type ExpireStats struct {
+	// ExpiredKeys число ключей, удалённых по истечении TTL, в том числе
+	// при обращении к ним
+	ExpiredKeys int64
+	// StalePerc доля истёкших, но ещё не удалённых ключей и полей среди
+	// имеющих TTL в конце последнего цикла, в процентах; при большом числе
+	// истёкших — оценка по выборке
+	StalePerc float64
+	// Tracked число ключей и полей с TTL
+	Tracked int
+	// LastCycle длительность последнего цикла активного удаления
+	LastCycle time.Duration
+	// CycleTime суммарное время циклов
+	CycleTime time.Duration
+}
+
+// setExpire устанавливает ключу момент истечения. Вызывается под
+// блокировкой на запись.
+func (s *Storage) setExpire(key string, at time.Time) {
+	s.expiration[key] = at
+	s.expires.set(expiryTarget{key: key}, at)
+}
+
+// clearExpire снимает TTL с ключа. Вызывается под блокировкой на запись.
+func (s *Storage) clearExpire(key string) {
+	delete(s.expiration, key)
+	s.expires.remove(expiryTarget{key: key})
+}
+
+// setFieldExpire устанавливает полю хэша key момент истечения.
+// Вызывается под блокировкой на запись.
+func (s *Storage) setFieldExpire(key string, coll *NestedCollection, field string, at time.Time) {
+	coll.expiration[field] = at
+	s.expires.set(expiryTarget{key: key, field: field, isField: true}, at)
+}
+
+// clearFieldExpire снимает TTL с поля хэша key. Вызывается под
+// блокировкой на запись.
+func (s *Storage) clearFieldExpire(key string, coll *NestedCollection, field string) {
+	if _, hasTTL := coll.expiration[field]; hasTTL {
+		delete(coll.expiration, field)
+		s.expires.remove(expiryTarget{key: key, field: field, isField: true})
+	}
+}
+
+// track заносит в индекс сроки истечения полей хэша, появившегося под
+// ключом key. Вызывается под блокировкой на запись.
+func (s *Storage) track(key string, obj *object) {
+	if coll, ok := obj.value.(*NestedCollection); ok {
+		for field, at := range coll.expiration {
+			s.expires.set(expiryTarget{key: key, field: field, isField: true}, at)
+		}
+	}
+}
+
+// untrack убирает из индекса сроки истечения полей хэша под ключом key
+func (s *Storage) untrack(key string, obj *object) {
+	if coll, ok := obj.value.(*NestedCollection); ok {
+		for field := range coll.expiration {
+			s.expires.remove(expiryTarget{key: key, field: field, isField: true})
+		}
+	}
+}
+
+// expireEntry удаляет ключ или поле записи индекса, если срок в записи
+// совпадает с текущим. Вызывается под блокировкой на запись.
+func (s *Storage) expireEntry(e *expiryEntry) {
+	t := e.target
+	if !t.isField {
+		if at, hasTTL := s.expiration[t.key]; !hasTTL || !at.Equal(e.at) {
+			return
+		}
+		s.remove(t.key)
+		s.expiredKeys++
+		return
+	}
+
+	obj, exists := s.data[t.key]
+	if !exists {
+		return
+	}
+	coll, ok := obj.value.(*NestedCollection)
+	if !ok {
+		return
+	}
+	if at, hasTTL := coll.expiration[t.field]; !hasTTL || !at.Equal(e.at) {
+		return
+	}
+	coll.del(t.field)
+	delete(coll.expiration, t.field)
+	if len(coll.fields) == 0 {
+		s.remove(t.key)
+		s.expiredKeys++
+	} else {
+		s.reindex(t.key)
+	}
+}
+
+// expireBatch удаляет до expireCycleBatch истёкших ключей и полей и
+// возвращает долю истёкших среди оставшихся записей индекса
+func (s *Storage) expireBatch() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for range expireCycleBatch {
+		e, due := s.expires.popDue(now)
+		if !due {
+			break
+		}
+		s.expireEntry(e)
+	}
+	s.staleShare = s.expires.staleShare(now)
+	return s.staleShare
+}
+
+// activeExpireCycle удаляет истёкшие ключи пачками, пока их доля среди
+// записей индекса высока и не исчерпан бюджет времени. При низкой доле
+// цикл ограничивается одной пачкой: оставшиеся истёкшие ключи удалят
+// следующие циклы, а до тех пор они невидимы для команд.
+func (s *Storage) activeExpireCycle() {
+	start := time.Now()
+	for time.Since(start) < expireCycleBudget {
+		if s.expireBatch() < expireCycleRepeatRatio {
+			break
+		}
+	}
+	s.trimTimeSeries(time.Now())
+	elapsed := time.Since(start)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastCycle = elapsed
+	s.cycleTime += elapsed
+}
+
+// ExpireStats возвращает статистику удаления истёкших ключей
+func (s *Storage) ExpireStats() ExpireStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return ExpireStats{
+		ExpiredKeys: s.expiredKeys,
+		StalePerc:   s.staleShare * 100,
+		Tracked:     s.expires.Len(),
+		LastCycle:   s.lastCycle,
+		CycleTime:   s.cycleTime,
+	}
+}
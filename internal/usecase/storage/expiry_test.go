@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpiryIndexCountDue(t *testing.T) {
+	now := time.Now()
+	x := newExpiryIndex()
+	due := 0
+	for i := range 5000 {
+		at := now.Add(time.Duration(rand.IntN(2000)-1000) * time.Millisecond)
+		if now.After(at) {
+			due++
+		}
+		x.set(expiryTarget{key: strconv.Itoa(i)}, at)
+	}
+
+	for _, limit := range []int{0, 1, 100, due, due + 1, math.MaxInt} {
+		if got, want := x.countDue(now, limit), min(due, limit); got != want {
+			t.Errorf("countDue(limit %d) = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestExpiryIndexStaleShare(t *testing.T) {
+	tests := []struct {
+		name       string
+		due, total int
+		tolerance  float64
+	}{
+		{"empty", 0, 0, 0},
+		{"exact", 500, 2000, 0},
+		{"sampled", 6000, 8000, 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			x := newExpiryIndex()
+			for i := range tt.total {
+				at := now.Add(time.Hour)
+				if i < tt.due {
+					at = now.Add(-time.Hour)
+				}
+				x.set(expiryTarget{key: strconv.Itoa(i)}, at)
+			}
+
+			want := 0.0
+			if tt.total > 0 {
+				want = float64(tt.due) / float64(tt.total)
+			}
+			if got := x.staleShare(now); math.Abs(got-want) > tt.tolerance {
+				t.Errorf("staleShare = %.3f, want %.3f", got, want)
+			}
+		})
+	}
+}
+
+func TestExpiryIndexSet(t *testing.T) {
+	now := time.Now()
+	x := newExpiryIndex()
+	k := expiryTarget{key: "k"}
+	x.set(k, now.Add(-time.Second))
+	// Перенос срока не создаёт второй записи
+	x.set(k, now.Add(time.Hour))
+	if x.Len() != 1 {
+		t.Fatalf("index has %d entries", x.Len())
+	}
+	if _, due := x.popDue(now); due {
+		t.Error("popDue returned an entry moved to the future")
+	}
+	x.remove(k)
+	if x.Len() != 0 || len(x.byTarget) != 0 {
+		t.Error("remove left the entry")
+	}
+}
+
+func TestActiveExpireCycle(t *testing.T) {
+	s := newTestStorage(t)
+	past := time.Now().Add(-time.Second)
+	for i := range 3000 {
+		key := "k" + strconv.Itoa(i)
+		s.Set(key, "v", time.Hour)
+		// Истёкший срок ставится в обход Expire, которое удалило бы ключ сразу
+		if i%3 != 0 {
+			s.mu.Lock()
+			s.setExpire(key, past)
+			s.mu.Unlock()
+		}
+	}
+	s.HSet("h", []string{"a", "b"}, []string{"1", "2"})
+	s.HExpire("h", []string{"a"}, time.Now().Add(10*time.Millisecond), ExpireAlways)
+	time.Sleep(20 * time.Millisecond)
+
+	// При высокой доле истёкших цикл продолжает пачки до бюджета времени
+	s.activeExpireCycle()
+	if n := s.ExpireStats().Tracked; n >= 3001-expireCycleBatch {
+		t.Errorf("one cycle left %d entries", n)
+	}
+	for range 100 {
+		s.activeExpireCycle()
+	}
+
+	stats := s.ExpireStats()
+	if stats.ExpiredKeys != 2000 || stats.Tracked != 1000 || stats.StalePerc != 0 {
+		t.Errorf("ExpireStats = %+v, want 2000 expired and 1000 tracked", stats)
+	}
+	if stats.CycleTime < stats.LastCycle || stats.LastCycle == 0 {
+		t.Errorf("cycle times %v and %v", stats.CycleTime, stats.LastCycle)
+	}
+	if got, _ := s.HGetAll("h"); len(got) != 1 {
+		t.Errorf("hash after field expiry = %v, want only b", got)
+	}
+	if n := s.DBSize(); n != 1001 {
+		t.Errorf("DBSize = %d, want 1001", n)
+	}
+}
+
+func TestDatabasesExpireStats(t *testing.T) {
+	dbs := newTestDatabases(t, 2)
+	for _, db := range dbs {
+		db.mu.Lock()
+	}
+	dbs[0].staleShare, dbs[1].staleShare = 0.5, 0
+	for i := range 3 {
+		dbs[0].setExpire(strconv.Itoa(i), time.Now().Add(time.Hour))
+	}
+	dbs[1].setExpire("x", time.Now().Add(time.Hour))
+	dbs[0].expiredKeys, dbs[1].expiredKeys = 4, 6
+	for _, db := range dbs {
+		db.mu.Unlock()
+	}
+
+	// Доля усредняется с весом числа записей с TTL
+	stats := dbs.ExpireStats()
+	if stats.ExpiredKeys != 10 || stats.Tracked != 4 || stats.StalePerc != 37.5 {
+		t.Errorf("ExpireStats = %+v", stats)
+	}
+}
@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Геоиндекс хранится как отсортированное множество, оценка элемента —
+// 52-битный geohash его координат, как в Redis. Поэтому точки одной ячейки
+// geohash занимают непрерывный диапазон оценок, и поиск обходит лишь
+// несколько таких диапазонов.
+const (
+	geoStep      = 26
+	geoLatMin    = -85.05112878
+	geoLatMax    = 85.05112878
+	geoLonMin    = -180.0
+	geoLonMax    = 180.0
+	earthRadiusM = 6372797.560856
+	geoAlphabet  = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+var ErrGeoMemberNotFound = errors.New("ERR could not decode requested zset member")
+
+// GeoPoint координаты точки: долгота и широта в градусах
+type GeoPoint struct {
+	Lon, Lat float64
+}
+
+// ValidGeoPoint сообщает, можно ли проиндексировать точку
+func ValidGeoPoint(p GeoPoint) bool {
+	return p.Lon >= geoLonMin && p.Lon <= geoLonMax && p.Lat >= geoLatMin && p.Lat <= geoLatMax
+}
+
+// GeoSort порядок результатов GEOSEARCH
+type GeoSort int
+
+const (
+	GeoUnsorted GeoSort = iota
+	GeoAsc
+	GeoDesc
+)
+
+// GeoQuery параметры GEOSEARCH. Центр задаётся элементом FromMember или
+// точкой Center. При Box область — прямоугольник Width×Height метров,
+// иначе круг радиуса Radius метров. Count = 0 означает отсутствие
+// ограничения; при Any поиск останавливается на первых Count найденных.
+type GeoQuery struct {
+	FromMember    string
+	HasFromMember bool
+	Center        GeoPoint
+	Radius        float64
+	Box           bool
+	Width, Height float64
+	Sort          GeoSort
+	Count         int
+	Any           bool
+}
+
+// GeoResult найденная точка: расстояние до центра в метрах и geohash
+type GeoResult struct {
+	Member string
+	Dist   float64
+	Hash   uint64
+	Point  GeoPoint
+}
+
+// interleave чередует биты: x занимает чётные позиции, y — нечётные
+func interleave(x, y uint32) uint64 {
+	spread := func(v uint32) uint64 {
+		u := uint64(v)
+		u = (u | u<<16) & 0x0000FFFF0000FFFF
+		u = (u | u<<8) & 0x00FF00FF00FF00FF
+		u = (u | u<<4) & 0x0F0F0F0F0F0F0F0F
+		u = (u | u<<2) & 0x3333333333333333
+		u = (u | u<<1) & 0x5555555555555555
+		return u
+	}
+	return spread(x) | spread(y)<<1
+}
+
+// deinterleave обратна interleave
+func deinterleave(v uint64) (uint32, uint32) {
+	squash := func(u uint64) uint32 {
+		u &= 0x5555555555555555
+		u = (u | u>>1) & 0x3333333333333333
+		u = (u | u>>2) & 0x0F0F0F0F0F0F0F0F
+		u = (u | u>>4) & 0x00FF00FF00FF00FF
+		u = (u | u>>8) & 0x0000FFFF0000FFFF
+		u = (u | u>>16) & 0x00000000FFFFFFFF
+		return uint32(u)
+	}
+	return squash(v), squash(v >> 1)
+}
+
+// geoCell возвращает номера ячейки сетки 2^step×2^step по широте и долготе
+func geoCell(p GeoPoint, latMin, latMax float64, step uint) (uint32, uint32) {
+	cells := float64(uint64(1) << step)
+	lat := (p.Lat - latMin) / (latMax - latMin) * cells
+	lon := (p.Lon - geoLonMin) / (geoLonMax - geoLonMin) * cells
+	return uint32(min(lat, cells-1)), uint32(min(lon, cells-1))
+}
+
+// geoEncode возвращает 52-битный geohash точки
+func geoEncode(p GeoPoint) uint64 {
+	lat, lon := geoCell(p, geoLatMin, geoLatMax, geoStep)
+	return interleave(lat, lon)
+}
+
+// geoDecode возвращает центр ячейки geohash
+func geoDecode(hash uint64) GeoPoint {
+	lat, lon := deinterleave(hash)
+	cells := float64(uint64(1) << geoStep)
+	latScale, lonScale := geoLatMax-geoLatMin, geoLonMax-geoLonMin
+
+	latMin := geoLatMin + float64(lat)/cells*latScale
+	latMax := geoLatMin + float64(lat+1)/cells*latScale
+	lonMin := geoLonMin + float64(lon)/cells*lonScale
+	lonMax := geoLonMin + float64(lon+1)/cells*lonScale
+
+	return GeoPoint{
+		Lon: math.Max(geoLonMin, math.Min(geoLonMax, (lonMin+lonMax)/2)),
+		Lat: math.Max(geoLatMin, math.Min(geoLatMax, (latMin+latMax)/2)),
+	}
+}
+
+// GeoScore возвращает оценку элемента геоиндекса для точки
+func GeoScore(p GeoPoint) float64 {
+	return float64(geoEncode(p))
+}
+
+// GeoHashString возвращает стандартный 11-символьный geohash точки.
+// В отличие от оценки он считается по полному диапазону широт [-90, 90].
+func GeoHashString(p GeoPoint) string {
+	lat, lon := geoCell(p, -90, 90, geoStep)
+	hash := interleave(lat, lon)
+
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		if i < 10 {
+			idx = int(hash>>(52-(i+1)*5)) & 0x1F
+		}
+		buf[i] = geoAlphabet[idx]
+	}
+	return string(buf)
+}
+
+func degRad(deg float64) float64 { return deg * math.Pi / 180 }
+func radDeg(rad float64) float64 { return rad * 180 / math.Pi }
+
+// geoLatDistance расстояние в метрах вдоль меридиана
+func geoLatDistance(lat1, lat2 float64) float64 {
+	return earthRadiusM * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+// GeoDistance расстояние в метрах между точками по формуле гаверсинусов
+func GeoDistance(a, b GeoPoint) float64 {
+	lat1, lat2 := degRad(a.Lat), degRad(b.Lat)
+	v := math.Sin((degRad(b.Lon) - degRad(a.Lon)) / 2)
+	if v == 0 {
+		return geoLatDistance(a.Lat, b.Lat)
+	}
+	u := math.Sin((lat2 - lat1) / 2)
+	h := u*u + math.Cos(lat1)*math.Cos(lat2)*v*v
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(h))
+}
+
+// contains проверяет, попадает ли точка в область запроса, и возвращает
+// расстояние до центра
+func (q *GeoQuery) contains(p GeoPoint) (float64, bool) {
+	if !q.Box {
+		dist := GeoDistance(q.Center, p)
+		return dist, dist <= q.Radius
+	}
+
+	if geoLatDistance(p.Lat, q.Center.Lat) > q.Height/2 {
+		return 0, false
+	}
+	if GeoDistance(p, GeoPoint{Lon: q.Center.Lon, Lat: p.Lat}) > q.Width/2 {
+		return 0, false
+	}
+	return GeoDistance(q.Center, p), true
+}
+
+// cellRanges возвращает диапазоны geohash, покрывающие область запроса:
+// ячейку центра и её соседей на уровне, где ячейка не меньше половины
+// области, чтобы соседи гарантированно её перекрывали
+func (q *GeoQuery) cellRanges() [][2]uint64 {
+	halfWidth, halfHeight := q.Radius, q.Radius
+	if q.Box {
+		halfWidth, halfHeight = q.Width/2, q.Height/2
+	}
+
+	latDelta := radDeg(halfHeight / earthRadiusM)
+	lonDelta := 360.0
+	if top := math.Max(math.Abs(q.Center.Lat-latDelta), math.Abs(q.Center.Lat+latDelta)); top < 90 {
+		lonDelta = radDeg(halfWidth / earthRadiusM / math.Cos(degRad(top)))
+	}
+
+	step := uint(geoStep)
+	for step > 0 {
+		cells := float64(uint64(1) << step)
+		if (geoLatMax-geoLatMin)/cells >= latDelta && (geoLonMax-geoLonMin)/cells >= lonDelta {
+			break
+		}
+		step--
+	}
+	if step == 0 {
+		return [][2]uint64{{0, 1 << (2 * geoStep)}}
+	}
+
+	lat, lon := geoCell(q.Center, geoLatMin, geoLatMax, step)
+	cells := int64(1) << step
+	shift := 2 * (geoStep - step)
+	seen := make(map[uint64]bool, 9)
+	var ranges [][2]uint64
+	for dlat := int64(-1); dlat <= 1; dlat++ {
+		y := int64(lat) + dlat
+		if y < 0 || y >= cells {
+			continue
+		}
+		for dlon := int64(-1); dlon <= 1; dlon++ {
+			x := (int64(lon) + dlon + cells) % cells
+			cell := interleave(uint32(y), uint32(x))
+			if seen[cell] {
+				continue
+			}
+			seen[cell] = true
+			ranges = append(ranges, [2]uint64{cell << shift, (cell + 1) << shift})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	return ranges
+}
+
+// geoSearch обходит подходящие диапазоны индекса и отбирает точки области
+func (z *ZSetCollection) geoSearch(q GeoQuery) []GeoResult {
+	var results []GeoResult
+	for _, r := range q.cellRanges() {
+		rng := ScoreRange{Min: float64(r[0]), Max: float64(r[1]), MaxEx: true}
+		for x := z.zsl.firstInScoreRange(rng); x != nil && rng.lteMax(x.score); x = x.level[0].forward {
+			hash := uint64(x.score)
+			p := geoDecode(hash)
+			dist, ok := q.contains(p)
+			if !ok {
+				continue
+			}
+			results = append(results, GeoResult{Member: x.member, Dist: dist, Hash: hash, Point: p})
+			if q.Any && len(results) == q.Count {
+				break
+			}
+		}
+		if q.Any && len(results) == q.Count {
+			break
+		}
+	}
+
+	switch q.Sort {
+	case GeoAsc:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Dist < results[j].Dist })
+	case GeoDesc:
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Dist > results[j].Dist })
+	}
+	if q.Count > 0 && len(results) > q.Count {
+		results = results[:q.Count]
+	}
+	return results
+}
+
+// geoQuery ищет точки в геоиндексе key. Вызывается под блокировкой.
+func (s *Storage) geoQuery(key string, q GeoQuery) ([]GeoResult, error) {
+	zset, err := s.getZSet(key)
+	if err != nil || zset == nil {
+		return nil, err
+	}
+
+	if q.HasFromMember {
+		score, exists := zset.dict[q.FromMember]
+		if !exists {
+			return nil, ErrGeoMemberNotFound
+		}
+		q.Center = geoDecode(uint64(score))
+	}
+	return zset.geoSearch(q), nil
+}
+
+// GeoPos возвращает координаты элементов; found[i] равен false для отсутствующих
+func (s *Storage) GeoPos(key string, members []string) ([]GeoPoint, []bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.getZSet(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	points := make([]GeoPoint, len(members))
+	found := make([]bool, len(members))
+	if zset == nil {
+		return points, found, nil
+	}
+	for i, member := range members {
+		if score, exists := zset.dict[member]; exists {
+			points[i], found[i] = geoDecode(uint64(score)), true
+		}
+	}
+	return points, found, nil
+}
+
+// GeoSearch возвращает элементы геоиндекса, попавшие в область запроса
+func (s *Storage) GeoSearch(key string, q GeoQuery) ([]GeoResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.geoQuery(key, q)
+}
+
+// GeoSearchStore сохраняет результат GEOSEARCH в destination как геоиндекс
+// либо, при storeDist, как множество с расстоянием в единицах unit (метрах
+// на единицу) в качестве оценки. Пустой результат удаляет destination.
+func (s *Storage) GeoSearchStore(destination, key string, q GeoQuery, storeDist bool, unit float64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results, err := s.geoQuery(key, q)
+	if err != nil {
+		return 0, err
+	}
+
+	s.remove(destination)
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	zset := newZSetCollection()
+	for _, r := range results {
+		score := float64(r.Hash)
+		if storeDist {
+			score = r.Dist / unit
+		}
+		zset.add(r.Member, score)
+	}
+	s.put(destination, &object{typ: TypeZSet, value: zset})
+	return len(results), nil
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// newTestGeo создаёт геоиндекс key из точек Redis-примеров про Сицилию
+func newTestGeo(t *testing.T, s *Storage, key string) {
+	t.Helper()
+	points := map[string]GeoPoint{
+		"Palermo": {13.361389, 38.115556},
+		"Catania": {15.087269, 37.502669},
+		"edge1":   {12.758489, 38.788135},
+		"edge2":   {17.241510, 38.788135},
+	}
+	for member, p := range points {
+		if _, _, _, err := s.ZAdd(key, ZAddOptions{}, []ZMember{{member, GeoScore(p)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGeoHashAndDistance(t *testing.T) {
+	palermo, catania := GeoPoint{13.361389, 38.115556}, GeoPoint{15.087269, 37.502669}
+	if got := GeoHashString(palermo); got != "sqc8b49rny0" {
+		t.Errorf("GeoHashString(Palermo) = %s", got)
+	}
+	if got := GeoHashString(catania); got != "sqdtr74hyu0" {
+		t.Errorf("GeoHashString(Catania) = %s", got)
+	}
+	// Расстояние считается между декодированными точками, как GEODIST
+	d := GeoDistance(geoDecode(geoEncode(palermo)), geoDecode(geoEncode(catania)))
+	if math.Abs(d-166274.1516) > 0.01 {
+		t.Errorf("distance = %.4f, want 166274.1516", d)
+	}
+	if p := geoDecode(geoEncode(palermo)); math.Abs(p.Lon-palermo.Lon) > 1e-5 || math.Abs(p.Lat-palermo.Lat) > 1e-5 {
+		t.Errorf("decoded point %v is far from %v", p, palermo)
+	}
+}
+
+func TestGeoSearch(t *testing.T) {
+	s := newTestStorage(t)
+	newTestGeo(t, s, "Sicily")
+	center := GeoPoint{15, 37}
+
+	tests := []struct {
+		name string
+		q    GeoQuery
+		want []string
+	}{
+		{"radius", GeoQuery{Center: center, Radius: 200000, Sort: GeoAsc}, []string{"Catania", "Palermo"}},
+		{"box", GeoQuery{Center: center, Box: true, Width: 400000, Height: 400000, Sort: GeoAsc}, []string{"Catania", "Palermo", "edge2", "edge1"}},
+		{"desc with count", GeoQuery{Center: center, Radius: 200000, Sort: GeoDesc, Count: 1}, []string{"Palermo"}},
+		{"from member", GeoQuery{FromMember: "Palermo", HasFromMember: true, Radius: 1, Sort: GeoAsc}, []string{"Palermo"}},
+		{"nothing near", GeoQuery{Center: GeoPoint{-100, 40}, Radius: 1000}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GeoSearch("Sicily", tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			members := make([]string, len(got))
+			for i, r := range got {
+				members[i] = r.Member
+			}
+			if !slices.Equal(members, tt.want) {
+				t.Errorf("GeoSearch = %q, want %q", members, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.GeoSearch("Sicily", GeoQuery{FromMember: "Rome", HasFromMember: true, Radius: 1}); !errors.Is(err, ErrGeoMemberNotFound) {
+		t.Errorf("missing FROMMEMBER err = %v, want ErrGeoMemberNotFound", err)
+	}
+}
+
+// TestGeoSearchMatchesBruteForce сверяет поиск по ячейкам geohash с полным
+// перебором, в том числе у полюсов и линии перемены дат
+func TestGeoSearchMatchesBruteForce(t *testing.T) {
+	z := newZSetCollection()
+	for i := range 3000 {
+		p := GeoPoint{Lon: rand.Float64()*360 - 180, Lat: rand.Float64()*170 - 85}
+		z.add(strconv.Itoa(i), GeoScore(p))
+	}
+
+	for range 200 {
+		q := GeoQuery{
+			Center: GeoPoint{Lon: rand.Float64()*360 - 180, Lat: rand.Float64()*170 - 85},
+			Radius: math.Pow(10, 3+rand.Float64()*4),
+		}
+		if rand.IntN(2) == 0 {
+			q.Box, q.Width, q.Height = true, 2*q.Radius, q.Radius
+		}
+
+		want := make(map[string]bool)
+		for member, score := range z.dict {
+			if _, ok := q.contains(geoDecode(uint64(score))); ok {
+				want[member] = true
+			}
+		}
+		got := z.geoSearch(q)
+		if len(got) != len(want) {
+			t.Fatalf("query %+v found %d points, brute force %d", q, len(got), len(want))
+		}
+		for _, r := range got {
+			if !want[r.Member] {
+				t.Fatalf("query %+v found %s outside the area", q, r.Member)
+			}
+		}
+	}
+}
+
+func TestGeoSearchStore(t *testing.T) {
+	s := newTestStorage(t)
+	newTestGeo(t, s, "Sicily")
+	q := GeoQuery{Center: GeoPoint{15, 37}, Radius: 200000}
+
+	if n, err := s.GeoSearchStore("dst", "Sicily", q, true, 1000); err != nil || n != 2 {
+		t.Fatalf("GeoSearchStore = %d, %v", n, err)
+	}
+	if score, _, _ := s.ZScore("dst", "Catania"); math.Abs(score-56.4413) > 0.001 {
+		t.Errorf("stored distance = %.4f km, want 56.4413", score)
+	}
+	if n, _ := s.GeoSearchStore("dst", "Sicily", GeoQuery{Center: GeoPoint{-100, 40}, Radius: 1}, false, 1); n != 0 || s.Exists("dst") {
+		t.Error("empty GEOSEARCHSTORE kept the destination")
+	}
+}
@@ -0,0 +1,380 @@
+package storage
+
+import (
+	"errors"
+	"maps"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	ErrHashNotFloat   = errors.New("ERR hash value is not a float")
+)
+
+// set записывает значение поля
+func (c *NestedCollection) set(field, value string) {
+	_, exists := c.fields[field]
+	c.fields[field] = value
+	if !exists {
+		c.order.add(c.fields, field)
+	}
+}
+
+// del удаляет поле без его TTL
+func (c *NestedCollection) del(field string) {
+	delete(c.fields, field)
+	c.order.remove(field)
+}
+
+// get возвращает значение поля, если оно есть и не просрочено
+func (c *NestedCollection) get(field string, now time.Time) (string, bool) {
+	value, found := c.fields[field]
+	if !found || c.fieldExpired(field, now) {
+		return "", false
+	}
+	return value, true
+}
+
+// dropExpired удаляет поле, срок жизни которого истёк, чтобы запись
+// не унаследовала его TTL. Вызывается под блокировкой на запись.
+func (c *NestedCollection) dropExpired(field string, now time.Time) {
+	if c.fieldExpired(field, now) {
+		c.del(field)
+		delete(c.expiration, field)
+	}
+}
+
+// HSet записывает поля хэша, снимая их TTL, и возвращает число новых полей
+func (s *Storage) HSet(collection string, fields, values []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	coll, err := s.hCollection(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	created := 0
+	for i, field := range fields {
+		coll.dropExpired(field, now)
+		if _, exists := coll.fields[field]; !exists {
+			created++
+		}
+		coll.set(field, values[i])
+		s.clearFieldExpire(collection, coll, field)
+	}
+	s.reindex(collection)
+	return created, nil
+}
+
+// HSetNX записывает поле, только если его ещё нет
+func (s *Storage) HSetNX(collection, field, value string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	coll, err := s.hCollection(collection)
+	if err != nil {
+		return false, err
+	}
+
+	coll.dropExpired(field, time.Now())
+	if _, exists := coll.fields[field]; exists {
+		return false, nil
+	}
+	coll.set(field, value)
+	s.reindex(collection)
+	return true, nil
+}
+
+// HMGet возвращает значения нескольких полей; found[i] равен false для отсутствующих
+func (s *Storage) HMGet(collection string, fields []string) ([]string, []bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coll, err := s.getCollection(collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make([]string, len(fields))
+	found := make([]bool, len(fields))
+	if coll == nil {
+		return values, found, nil
+	}
+
+	now := time.Now()
+	for i, field := range fields {
+		values[i], found[i] = coll.get(field, now)
+	}
+	return values, found, nil
+}
+
+// HIncrBy атомарно увеличивает целое значение поля на delta
+func (s *Storage) HIncrBy(collection, field string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	coll, err := s.hCollection(collection)
+	if err != nil {
+		return 0, err
+	}
+
+	coll.dropExpired(field, time.Now())
+	var value int64
+	if current, exists := coll.fields[field]; exists {
+		if value, err = parseIntValue(current); err != nil {
+			return 0, ErrHashNotInteger
+		}
+	}
+
+	if (delta > 0 && value > math.MaxInt64-delta) || (delta < 0 && value < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	value += delta
+	coll.set(field, strconv.FormatInt(value, 10))
+	s.reindex(collection)
+	return value, nil
+}
+
+// HIncrByFloat атомарно увеличивает значение поля на вещественное delta
+// и возвращает новое значение в том виде, в котором оно сохранено
+func (s *Storage) HIncrByFloat(collection, field string, delta float64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	coll, err := s.hCollection(collection)
+	if err != nil {
+		return "", err
+	}
+
+	coll.dropExpired(field, time.Now())
+	var value float64
+	if current, exists := coll.fields[field]; exists {
+		if value, err = parseFloatValue(current); err != nil {
+			return "", ErrHashNotFloat
+		}
+	}
+
+	value += delta
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", ErrNaNOrInf
+	}
+
+	result := strconv.FormatFloat(value, 'f', -1, 64)
+	coll.set(field, result)
+	s.reindex(collection)
+	return result, nil
+}
+
+// HStrLen возвращает длину значения поля
+func (s *Storage) HStrLen(collection, field string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coll, err := s.getCollection(collection)
+	if err != nil || coll == nil {
+		return 0, err
+	}
+
+	value, _ := coll.get(field, time.Now())
+	return len(value), nil
+}
+
+// HRandField возвращает случайные поля со значениями. При count >= 0
+// поля различны, при отрицательном могут повторяться; -count не должен
+// превышать MaxRandomCount.
+func (s *Storage) HRandField(collection string, count int) ([]string, []string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coll, err := s.getCollection(collection)
+	if err != nil || coll == nil {
+		return nil, nil, err
+	}
+
+	var fields []string
+	if len(coll.expiration) == 0 {
+		// Без TTL все поля живые, и выборка не копирует хэш
+		if count < 0 {
+			fields = randomKeys(coll.fields, -count)
+		} else {
+			fields = sampleKeys(coll.fields, min(count, len(coll.fields)))
+		}
+	} else {
+		now := time.Now()
+		live := make([]string, 0, len(coll.fields))
+		for field := range coll.fields {
+			if !coll.fieldExpired(field, now) {
+				live = append(live, field)
+			}
+		}
+		if len(live) == 0 {
+			return nil, nil, nil
+		}
+		if count < 0 {
+			fields = pickSlice(live, -count)
+		} else {
+			fields = sampleSlice(live, min(count, len(live)))
+		}
+	}
+
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = coll.fields[field]
+	}
+	return fields, values, nil
+}
+
+// HScan возвращает очередную порцию полей хэша начиная с cursor
+// и курсор следующего вызова (0 — обход завершён)
+func (s *Storage) HScan(collection string, cursor uint64, count int) ([]string, []string, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coll, err := s.getCollection(collection)
+	if err != nil || coll == nil {
+		return nil, nil, 0, err
+	}
+
+	keys, next := coll.order.scan(coll.fields, cursor, count)
+
+	now := time.Now()
+	fields := make([]string, 0, len(keys))
+	values := make([]string, 0, len(keys))
+	for _, field := range keys {
+		if value, ok := coll.get(field, now); ok {
+			fields = append(fields, field)
+			values = append(values, value)
+		}
+	}
+	return fields, values, next, nil
+}
+
+// Результаты установки и снятия TTL поля, как в ответах HEXPIRE и HPERSIST
+const (
+	FieldNotFound     = -2
+	FieldNoTTL        = -1
+	FieldNotSet       = 0
+	FieldExpireSet    = 1
+	FieldExpireDelete = 2
+)
+
+// HExpire устанавливает полям хэша момент истечения at при выполнении
+// условия cond. Момент в прошлом сразу удаляет поле. Для каждого поля
+// возвращается один из кодов FieldNotFound, FieldNotSet, FieldExpireSet,
+// FieldExpireDelete.
+func (s *Storage) HExpire(collection string, fields []string, at time.Time, cond ExpireCondition) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]int, len(fields))
+	obj, err := s.lookupWriteType(collection, TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		for i := range result {
+			result[i] = FieldNotFound
+		}
+		return result, nil
+	}
+	coll := obj.value.(*NestedCollection)
+
+	now := time.Now()
+	for i, field := range fields {
+		coll.dropExpired(field, now)
+		if _, exists := coll.fields[field]; !exists {
+			result[i] = FieldNotFound
+			continue
+		}
+
+		current, hasTTL := coll.expiration[field]
+		switch {
+		case !cond.allows(current, hasTTL, at):
+			result[i] = FieldNotSet
+		case !at.After(now):
+			coll.del(field)
+			s.clearFieldExpire(collection, coll, field)
+			result[i] = FieldExpireDelete
+		default:
+			s.setFieldExpire(collection, coll, field, at)
+			result[i] = FieldExpireSet
+		}
+	}
+
+	if len(coll.fields) == 0 {
+		s.remove(collection)
+	} else {
+		s.reindex(collection)
+	}
+	return result, nil
+}
+
+// HExpireTime возвращает моменты истечения полей. found[i] равен false для
+// отсутствующих полей, нулевое время означает поле без TTL.
+func (s *Storage) HExpireTime(collection string, fields []string) ([]time.Time, []bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coll, err := s.getCollection(collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	times := make([]time.Time, len(fields))
+	found := make([]bool, len(fields))
+	if coll == nil {
+		return times, found, nil
+	}
+
+	now := time.Now()
+	for i, field := range fields {
+		if _, found[i] = coll.get(field, now); found[i] {
+			times[i] = coll.expiration[field]
+		}
+	}
+	return times, found, nil
+}
+
+// HPersist снимает TTL с полей. Для каждого поля возвращается
+// FieldNotFound, FieldNoTTL или FieldExpireSet, если TTL был снят.
+func (s *Storage) HPersist(collection string, fields []string) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]int, len(fields))
+	coll, err := s.getCollection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i, field := range fields {
+		if coll == nil {
+			result[i] = FieldNotFound
+			continue
+		}
+		if _, live := coll.get(field, now); !live {
+			result[i] = FieldNotFound
+			continue
+		}
+		if _, hasTTL := coll.expiration[field]; !hasTTL {
+			result[i] = FieldNoTTL
+			continue
+		}
+		s.clearFieldExpire(collection, coll, field)
+		result[i] = FieldExpireSet
+	}
+	return result, nil
+}
+
+// clone возвращает независимую копию хэша вместе со сроками жизни полей
+func (c *NestedCollection) clone() *NestedCollection {
+	copied := &NestedCollection{fields: maps.Clone(c.fields), expiration: maps.Clone(c.expiration)}
+	copied.order.reset(copied.fields)
+	return copied
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestHash создаёт хэш key из n полей "0".."n-1" со значениями "v0".."v(n-1)"
+func newTestHash(t *testing.T, s *Storage, key string, n int) {
+	t.Helper()
+	fields := make([]string, n)
+	values := make([]string, n)
+	for i := range fields {
+		fields[i] = strconv.Itoa(i)
+		values[i] = "v" + fields[i]
+	}
+	if _, err := s.HSet(key, fields, values); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHRandField(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		count    int
+		want     int
+		distinct bool
+	}{
+		{"zero", 10, 0, 0, true},
+		{"some", 10, 3, 3, true},
+		{"more than hash", 10, 50, 10, true},
+		{"most of large hash", 1000, 900, 900, true},
+		{"repeats", 3, -20, 20, false},
+		{"repeats from large hash", 1000, -20, 20, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			newTestHash(t, s, "h", tt.size)
+
+			fields, values, err := s.HRandField("h", tt.count)
+			if err != nil || len(fields) != tt.want || len(values) != tt.want {
+				t.Fatalf("HRandField = %d fields, %d values, %v; want %d", len(fields), len(values), err, tt.want)
+			}
+			if tt.distinct && !distinct(fields) {
+				t.Errorf("fields repeat: %q", fields)
+			}
+			for i, field := range fields {
+				if values[i] != "v"+field {
+					t.Errorf("value of %s = %q", field, values[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHIncrBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		delta   int64
+		want    int64
+		wantErr error
+	}{
+		{"missing field", "", 3, 3, nil},
+		{"add", "10", -4, 6, nil},
+		{"past max", strconv.FormatInt(math.MaxInt64, 10), 1, 0, ErrOverflow},
+		{"past min", strconv.FormatInt(math.MinInt64, 10), -1, 0, ErrOverflow},
+		{"not integer", "1.5", 1, 0, ErrHashNotInteger},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			s.HSet("h", []string{"other"}, []string{"x"})
+			if tt.initial != "" {
+				s.HSet("h", []string{"f"}, []string{tt.initial})
+			}
+			got, err := s.HIncrBy("h", "f", tt.delta)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("HIncrBy = %d, %v; want %d, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	s := newTestStorage(t)
+	if _, err := s.HIncrByFloat("h", "f", math.Inf(1)); !errors.Is(err, ErrNaNOrInf) {
+		t.Errorf("HIncrByFloat(inf) err = %v, want ErrNaNOrInf", err)
+	}
+	s.HSet("h", []string{"f"}, []string{"abc"})
+	if _, err := s.HIncrByFloat("h", "f", 1); !errors.Is(err, ErrHashNotFloat) {
+		t.Errorf("HIncrByFloat on text err = %v, want ErrHashNotFloat", err)
+	}
+}
+
+func TestHSetNXAndHMGet(t *testing.T) {
+	s := newTestStorage(t)
+	if ok, _ := s.HSetNX("h", "f", "1"); !ok {
+		t.Error("HSetNX of a new field failed")
+	}
+	if ok, _ := s.HSetNX("h", "f", "2"); ok {
+		t.Error("HSetNX overwrote a field")
+	}
+	values, found, err := s.HMGet("h", []string{"f", "missing"})
+	if err != nil || values[0] != "1" || !found[0] || found[1] {
+		t.Errorf("HMGet = %q, %v, %v", values, found, err)
+	}
+	if n, _ := s.HStrLen("h", "f"); n != 1 {
+		t.Errorf("HStrLen = %d", n)
+	}
+}
+
+func TestHExpireConditions(t *testing.T) {
+	now := time.Now()
+	soon, later := now.Add(time.Hour), now.Add(2*time.Hour)
+
+	tests := []struct {
+		name    string
+		current time.Time
+		at      time.Time
+		cond    ExpireCondition
+		want    int
+	}{
+		{"set", time.Time{}, soon, ExpireAlways, FieldExpireSet},
+		{"NX without TTL", time.Time{}, soon, ExpireNX, FieldExpireSet},
+		{"NX with TTL", soon, later, ExpireNX, FieldNotSet},
+		{"XX without TTL", time.Time{}, soon, ExpireXX, FieldNotSet},
+		{"XX with TTL", soon, later, ExpireXX, FieldExpireSet},
+		{"GT without TTL", time.Time{}, soon, ExpireGT, FieldNotSet},
+		{"GT later", soon, later, ExpireGT, FieldExpireSet},
+		{"GT earlier", later, soon, ExpireGT, FieldNotSet},
+		{"LT without TTL", time.Time{}, soon, ExpireLT, FieldExpireSet},
+		{"LT later", soon, later, ExpireLT, FieldNotSet},
+		{"past deletes", time.Time{}, now.Add(-time.Second), ExpireAlways, FieldExpireDelete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			s.HSet("h", []string{"f", "other"}, []string{"1", "2"})
+			if !tt.current.IsZero() {
+				s.HExpire("h", []string{"f"}, tt.current, ExpireAlways)
+			}
+
+			got, err := s.HExpire("h", []string{"f", "missing"}, tt.at, tt.cond)
+			if err != nil || !slices.Equal(got, []int{tt.want, FieldNotFound}) {
+				t.Fatalf("HExpire = %v, %v; want [%d %d]", got, err, tt.want, FieldNotFound)
+			}
+			times, found, _ := s.HExpireTime("h", []string{"f"})
+			switch tt.want {
+			case FieldExpireDelete:
+				if found[0] {
+					t.Error("field with past expiration exists")
+				}
+			case FieldExpireSet:
+				if !times[0].Equal(tt.at) {
+					t.Errorf("expiration = %v, want %v", times[0], tt.at)
+				}
+			default:
+				if !times[0].Equal(tt.current) {
+					t.Errorf("expiration changed to %v", times[0])
+				}
+			}
+		})
+	}
+}
+
+func TestHashFieldExpiration(t *testing.T) {
+	s := newTestStorage(t)
+	s.HSet("h", []string{"a", "b"}, []string{"1", "2"})
+	s.HExpire("h", []string{"a"}, time.Now().Add(20*time.Millisecond), ExpireAlways)
+
+	if got, _ := s.HPersist("h", []string{"b", "missing"}); !slices.Equal(got, []int{FieldNoTTL, FieldNotFound}) {
+		t.Errorf("HPersist = %v", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// Просроченное поле не видно чтению и случайной выборке
+	if _, found, _ := s.HGet("h", "a"); found {
+		t.Error("expired field is visible")
+	}
+	for range 20 {
+		if fields, _, _ := s.HRandField("h", -5); slices.Contains(fields, "a") {
+			t.Fatal("HRandField returned an expired field")
+		}
+	}
+	// Запись поверх просроченного поля не наследует его TTL
+	if n, _ := s.HSet("h", []string{"a"}, []string{"new"}); n != 1 {
+		t.Errorf("HSet over expired field created %d fields, want 1", n)
+	}
+	if times, _, _ := s.HExpireTime("h", []string{"a"}); !times[0].IsZero() {
+		t.Errorf("rewritten field kept TTL %v", times[0])
+	}
+
+	// Истечение последнего поля удаляет хэш
+	s.HExpire("h", []string{"a", "b"}, time.Now().Add(-time.Second), ExpireAlways)
+	if s.Exists("h") {
+		t.Error("hash without fields still exists")
+	}
+}
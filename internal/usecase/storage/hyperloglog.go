@@ -0,0 +1,449 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// HyperLogLog хранится строкой в формате Redis: заголовок "HYLL", байт
+// кодировки, кэш мощности и регистры в разреженном (sparse) или плотном
+// (dense) представлении. Поэтому GET/SET и AOF работают с ним как со строкой.
+const (
+	hllP          = 14
+	hllQ          = 64 - hllP
+	hllRegisters  = 1 << hllP
+	hllBits       = 6
+	hllRegMax     = 1<<hllBits - 1
+	hllHeaderSize = 16
+	hllDenseSize  = hllHeaderSize + (hllRegisters*hllBits+7)/8
+	hllDense      = 0
+	hllSparse     = 1
+
+	// hllSparseMaxBytes предел размера разреженного представления, как hll-sparse-max-bytes
+	hllSparseMaxBytes = 3000
+	// hllSparseValMax наибольшее значение регистра, которое кодирует опкод VAL
+	hllSparseValMax = 32
+	hllAlphaInf     = 0.721347520444481703680
+	hllHashSeed     = 0xadc83b19
+)
+
+var (
+	ErrNotHLL     = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	ErrCorruptHLL = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// murmurHash64A — хэш-функция, которой Redis распределяет элементы по регистрам
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(key))*m
+	blocks := len(key) / 8
+	for i := 0; i < blocks; i++ {
+		k := binary.LittleEndian.Uint64(key[i*8:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+
+	tail := key[blocks*8:]
+	for i := len(tail) - 1; i >= 0; i-- {
+		h ^= uint64(tail[i]) << (8 * i)
+	}
+	if len(tail) > 0 {
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// hllPatLen возвращает номер регистра элемента и длину серии нулей + 1
+func hllPatLen(element string) (int, uint8) {
+	hash := murmurHash64A([]byte(element), hllHashSeed)
+	index := int(hash & (hllRegisters - 1))
+	hash >>= hllP
+	hash |= 1 << hllQ
+
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+// newHLL создаёт пустой HyperLogLog в разреженном представлении
+func newHLL() []byte {
+	var regs [hllRegisters]uint8
+	data, _ := encodeHLL(&regs, hllSparse)
+	return data
+}
+
+// validHLL проверяет заголовок строки
+func validHLL(data []byte) bool {
+	if len(data) < hllHeaderSize || string(data[:4]) != "HYLL" {
+		return false
+	}
+	switch data[4] {
+	case hllDense:
+		return len(data) == hllDenseSize
+	case hllSparse:
+		return true
+	default:
+		return false
+	}
+}
+
+// denseRegister читает 6-битный регистр плотного представления
+func denseRegister(regs []byte, i int) uint8 {
+	pos := i * hllBits / 8
+	shift := uint(i * hllBits & 7)
+	v := regs[pos] >> shift
+	if pos+1 < len(regs) {
+		v |= regs[pos+1] << (8 - shift)
+	}
+	return v & hllRegMax
+}
+
+// setDenseRegister записывает 6-битный регистр плотного представления
+func setDenseRegister(regs []byte, i int, value uint8) {
+	pos := i * hllBits / 8
+	shift := uint(i * hllBits & 7)
+	regs[pos] &^= hllRegMax << shift
+	regs[pos] |= value << shift
+	if pos+1 < len(regs) {
+		regs[pos+1] &^= hllRegMax >> (8 - shift)
+		regs[pos+1] |= value >> (8 - shift)
+	}
+}
+
+// decodeHLL распаковывает регистры из любого представления
+func decodeHLL(data []byte, regs *[hllRegisters]uint8) error {
+	body := data[hllHeaderSize:]
+	if data[4] == hllDense {
+		for i := range regs {
+			regs[i] = denseRegister(body, i)
+		}
+		return nil
+	}
+
+	idx := 0
+	for i := 0; i < len(body); {
+		op := body[i]
+		switch op & 0xC0 {
+		case 0x00: // ZERO: серия из 1..64 нулевых регистров
+			idx += int(op&0x3F) + 1
+			i++
+		case 0x40: // XZERO: серия из 1..16384 нулевых регистров
+			if i+1 >= len(body) {
+				return ErrCorruptHLL
+			}
+			idx += (int(op&0x3F)<<8 | int(body[i+1])) + 1
+			i += 2
+		default: // VAL: 1..4 регистра со значением 1..32
+			value := (op>>2)&0x1F + 1
+			run := int(op&0x3) + 1
+			if idx+run > hllRegisters {
+				return ErrCorruptHLL
+			}
+			for j := 0; j < run; j++ {
+				regs[idx+j] = value
+			}
+			idx += run
+			i++
+		}
+		if idx > hllRegisters {
+			return ErrCorruptHLL
+		}
+	}
+	if idx != hllRegisters {
+		return ErrCorruptHLL
+	}
+	return nil
+}
+
+// encodeHLL упаковывает регистры. Разреженное представление используется,
+// пока значения помещаются в опкод VAL и размер не превышает предела;
+// иначе возвращается плотное. Кэш мощности помечается недействительным.
+func encodeHLL(regs *[hllRegisters]uint8, encoding byte) ([]byte, byte) {
+	if encoding == hllSparse {
+		if data, ok := encodeSparse(regs); ok {
+			return data, hllSparse
+		}
+	}
+
+	data := make([]byte, hllDenseSize)
+	copy(data, "HYLL")
+	data[4] = hllDense
+	invalidateHLLCache(data)
+	body := data[hllHeaderSize:]
+	for i, value := range regs {
+		if value != 0 {
+			setDenseRegister(body, i, value)
+		}
+	}
+	return data, hllDense
+}
+
+func encodeSparse(regs *[hllRegisters]uint8) ([]byte, bool) {
+	data := make([]byte, hllHeaderSize, 64)
+	copy(data, "HYLL")
+	data[4] = hllSparse
+	invalidateHLLCache(data)
+
+	for i := 0; i < hllRegisters; {
+		value := regs[i]
+		run := 1
+		for i+run < hllRegisters && regs[i+run] == value {
+			run++
+		}
+		i += run
+
+		if value > hllSparseValMax {
+			return nil, false
+		}
+		for run > 0 {
+			switch {
+			case value != 0:
+				n := min(run, 4)
+				data = append(data, 0x80|(value-1)<<2|byte(n-1))
+				run -= n
+			case run > 64:
+				n := min(run, hllRegisters)
+				data = append(data, 0x40|byte((n-1)>>8), byte(n-1))
+				run -= n
+			default:
+				data = append(data, byte(run-1))
+				run = 0
+			}
+		}
+		if len(data) > hllSparseMaxBytes {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+func invalidateHLLCache(data []byte) {
+	data[15] |= 0x80
+}
+
+// cachedHLLCount возвращает кэшированную мощность, если кэш действителен
+func cachedHLLCount(data []byte) (int64, bool) {
+	if data[15]&0x80 != 0 {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(data[8:16])), true
+}
+
+// hllSigma и hllTau — поправки оценщика Ertl, который использует Redis
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+// hllCount оценивает мощность по регистрам
+func hllCount(regs *[hllRegisters]uint8) int64 {
+	var histogram [64]int
+	for _, value := range regs {
+		histogram[value]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	return int64(math.Round(hllAlphaInf * m * m / z))
+}
+
+// readHLL возвращает HyperLogLog по ключу или nil. Вызывается под блокировкой.
+func (s *Storage) readHLL(key string) ([]byte, error) {
+	data, err := s.readBits(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	if !validHLL(data) {
+		return nil, ErrNotHLL
+	}
+	return data, nil
+}
+
+// writeHLL возвращает изменяемый HyperLogLog по ключу, создавая пустой,
+// если ключа нет. Вызывается под блокировкой на запись.
+func (s *Storage) writeHLL(key string) (*object, []byte, bool, error) {
+	obj, err := s.lookupWriteType(key, TypeString)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if obj == nil {
+		obj = &object{typ: TypeString, value: newHLL()}
+		s.put(key, obj)
+		return obj, obj.value.([]byte), true, nil
+	}
+
+	data, _ := s.writeBits(key, 0)
+	if !validHLL(data) {
+		return nil, nil, false, ErrNotHLL
+	}
+	return obj, data, false, nil
+}
+
+// PFAdd добавляет элементы в HyperLogLog и сообщает, изменились ли регистры
+// или был создан ключ
+func (s *Storage) PFAdd(key string, elements []string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, data, created, err := s.writeHLL(key)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	if data[4] == hllDense {
+		// Плотное представление изменяется на месте
+		body := data[hllHeaderSize:]
+		for _, element := range elements {
+			index, count := hllPatLen(element)
+			if count > denseRegister(body, index) {
+				setDenseRegister(body, index, count)
+				changed = true
+			}
+		}
+		if changed {
+			invalidateHLLCache(data)
+		}
+		return changed || created, nil
+	}
+
+	var regs [hllRegisters]uint8
+	if err := decodeHLL(data, &regs); err != nil {
+		return false, err
+	}
+	for _, element := range elements {
+		index, count := hllPatLen(element)
+		if count > regs[index] {
+			regs[index] = count
+			changed = true
+		}
+	}
+	if changed {
+		obj.value, _ = encodeHLL(&regs, hllSparse)
+	}
+	return changed || created, nil
+}
+
+// PFCount возвращает оценку мощности HyperLogLog или, для нескольких ключей,
+// их объединения. Оценка одного ключа кэшируется в его заголовке.
+func (s *Storage) PFCount(keys []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(keys) > 1 {
+		var union [hllRegisters]uint8
+		if err := s.mergeHLL(keys, &union); err != nil {
+			return 0, err
+		}
+		return hllCount(&union), nil
+	}
+
+	data, err := s.readHLL(keys[0])
+	if err != nil || data == nil {
+		return 0, err
+	}
+	if count, ok := cachedHLLCount(data); ok {
+		return count, nil
+	}
+
+	var regs [hllRegisters]uint8
+	if err := decodeHLL(data, &regs); err != nil {
+		return 0, err
+	}
+	count := hllCount(&regs)
+	data, _ = s.writeBits(keys[0], 0)
+	binary.LittleEndian.PutUint64(data[8:16], uint64(count))
+	return count, nil
+}
+
+// mergeHLL объединяет регистры нескольких HyperLogLog, беря максимум.
+// Вызывается под блокировкой.
+func (s *Storage) mergeHLL(keys []string, union *[hllRegisters]uint8) error {
+	var regs [hllRegisters]uint8
+	for _, key := range keys {
+		data, err := s.readHLL(key)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		if err := decodeHLL(data, &regs); err != nil {
+			return err
+		}
+		for i, value := range regs {
+			union[i] = max(union[i], value)
+		}
+	}
+	return nil
+}
+
+// PFMerge объединяет HyperLogLog источников с dest и сохраняет результат
+// в dest в плотном представлении; TTL dest сохраняется
+func (s *Storage) PFMerge(dest string, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var union [hllRegisters]uint8
+	if err := s.mergeHLL(keys, &union); err != nil {
+		return err
+	}
+
+	obj, data, _, err := s.writeHLL(dest)
+	if err != nil {
+		return err
+	}
+	var regs [hllRegisters]uint8
+	if err := decodeHLL(data, &regs); err != nil {
+		return err
+	}
+	for i, value := range regs {
+		union[i] = max(union[i], value)
+	}
+	obj.value, _ = encodeHLL(&union, hllDense)
+	return nil
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"testing"
+)
+
+func TestHLLEncodingRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		fill     func(regs *[hllRegisters]uint8)
+		encoding byte
+		want     byte
+	}{
+		{"empty sparse", func(*[hllRegisters]uint8) {}, hllSparse, hllSparse},
+		{"few values sparse", func(r *[hllRegisters]uint8) { r[0], r[100], r[hllRegisters-1] = 1, 32, 5 }, hllSparse, hllSparse},
+		{"value above VAL", func(r *[hllRegisters]uint8) { r[7] = 33 }, hllSparse, hllDense},
+		{"too many runs", func(r *[hllRegisters]uint8) {
+			for i := 0; i < hllRegisters; i += 2 {
+				r[i] = uint8(i%7 + 1)
+			}
+		}, hllSparse, hllDense},
+		{"dense requested", func(r *[hllRegisters]uint8) { r[1] = hllRegMax }, hllDense, hllDense},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var regs, decoded [hllRegisters]uint8
+			tt.fill(&regs)
+
+			data, encoding := encodeHLL(&regs, tt.encoding)
+			if encoding != tt.want || data[4] != tt.want {
+				t.Fatalf("encoding = %d, want %d", encoding, tt.want)
+			}
+			if !validHLL(data) {
+				t.Fatal("encoded HLL is not valid")
+			}
+			if err := decodeHLL(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded != regs {
+				t.Error("decoded registers differ")
+			}
+		})
+	}
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	s := newTestStorage(t)
+	for _, n := range []int{10, 1000, 100000} {
+		key := "hll" + strconv.Itoa(n)
+		elements := make([]string, n)
+		for i := range elements {
+			elements[i] = strconv.Itoa(i)
+		}
+		if _, err := s.PFAdd(key, elements); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.PFCount([]string{key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Стандартная ошибка HyperLogLog с 16384 регистрами около 0.81%
+		if diff := math.Abs(float64(got-int64(n))) / float64(n); diff > 0.03 {
+			t.Errorf("PFCount of %d elements = %d", n, got)
+		}
+	}
+}
+
+func TestPFAddChanged(t *testing.T) {
+	s := newTestStorage(t)
+	if changed, _ := s.PFAdd("h", nil); !changed {
+		t.Error("PFAdd creating a key reported no change")
+	}
+	if changed, _ := s.PFAdd("h", []string{"a"}); !changed {
+		t.Error("PFAdd of a new element reported no change")
+	}
+	if changed, _ := s.PFAdd("h", []string{"a"}); changed {
+		t.Error("PFAdd of a seen element reported a change")
+	}
+	if n, _ := s.PFCount([]string{"h"}); n != 1 {
+		t.Errorf("PFCount = %d, want 1", n)
+	}
+}
+
+func TestPFMerge(t *testing.T) {
+	s := newTestStorage(t)
+	for i := range 3000 {
+		s.PFAdd("a", []string{"x" + strconv.Itoa(i)})
+		s.PFAdd("b", []string{"y" + strconv.Itoa(i)})
+		s.PFAdd("b", []string{"x" + strconv.Itoa(i)})
+	}
+	union, _ := s.PFCount([]string{"a", "b"})
+
+	if err := s.PFMerge("dst", []string{"a", "b", "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.PFCount([]string{"dst"}); n != union {
+		t.Errorf("merged count = %d, union count = %d", n, union)
+	}
+	if diff := math.Abs(float64(union-6000)) / 6000; diff > 0.03 {
+		t.Errorf("union count = %d, want about 6000", union)
+	}
+	data, _, _ := s.Get("dst")
+	if data[4] != hllDense {
+		t.Error("PFMERGE result is not dense")
+	}
+}
+
+func TestHLLInvalidValues(t *testing.T) {
+	s := newTestStorage(t)
+	s.Set("plain", "hello", 0)
+
+	var regs [hllRegisters]uint8
+	for range 100 {
+		regs[rand.IntN(hllRegisters)] = 3
+	}
+	sparse, _ := encodeHLL(&regs, hllSparse)
+	// Отрезанный последний опкод и лишние регистры портят разреженное представление
+	s.Set("truncated", string(sparse[:len(sparse)-1]), 0)
+	s.Set("overlong", string(sparse)+"\x80", 0)
+
+	tests := []struct {
+		key  string
+		want error
+	}{
+		{"plain", ErrNotHLL},
+		{"truncated", ErrCorruptHLL},
+		{"overlong", ErrCorruptHLL},
+	}
+	for _, tt := range tests {
+		if _, err := s.PFCount([]string{tt.key}); !errors.Is(err, tt.want) {
+			t.Errorf("PFCount(%s) err = %v, want %v", tt.key, err, tt.want)
+		}
+		if _, err := s.PFAdd(tt.key, []string{"a"}); !errors.Is(err, tt.want) {
+			t.Errorf("PFAdd(%s) err = %v, want %v", tt.key, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrIndexExists   = errors.New("ERR Index already exists")
+	ErrIndexNotFound = errors.New("ERR Unknown Index name")
+)
+
+// IndexFieldType тип индексируемого поля хэша
+type IndexFieldType int
+
+const (
+	IndexNumeric IndexFieldType = iota
+	IndexTag
+	IndexText
+)
+
+// IndexField поле схемы индекса. Weight — вес поля TEXT в оценке BM25.
+type IndexField struct {
+	Name   string
+	Type   IndexFieldType
+	Weight float64
+}
+
+// IndexDefinition описание индекса: хэши с ключами, начинающимися с одного
+// из префиксов, индексируются по полям схемы. Без префиксов индексируются
+// все хэши.
+type IndexDefinition struct {
+	Name     string
+	Prefixes []string
+	Schema   []IndexField
+}
+
+// IndexSearchOptions сортировка, окно выдачи и подсветка IDX.SEARCH.
+// Без SortBy документы упорядочены по убыванию оценки BM25, при равной
+// оценке — по ключу. Highlight обрамляет найденные слова в полях
+// HighlightFields (по умолчанию во всех полях TEXT).
+type IndexSearchOptions struct {
+	SortBy          string
+	Descending      bool
+	Offset          int
+	Limit           int
+	Highlight       bool
+	HighlightFields []string
+	HighlightOpen   string
+	HighlightClose  string
+}
+
+// IndexDocument найденный хэш: ключ, оценка и пары поле-значение
+// по возрастанию поля
+type IndexDocument struct {
+	Key    string
+	Score  float64
+	Fields []string
+}
+
+// indexedDoc значения документа, под которыми он сейчас лежит в индексе
+type indexedDoc struct {
+	numeric map[string]float64
+	tags    map[string][]string
+	words   []string
+	stems   []string
+	length  int
+}
+
+// hashIndex числовые поля хранятся в skiplist с ключом документа в качестве
+// элемента, теги — в множествах ключей по значению тега, слова полей
+// TEXT — в обратных индексах по слову и по его основе
+type hashIndex struct {
+	def     IndexDefinition
+	fields  map[string]IndexFieldType
+	weights map[string]float64
+	text    []string
+	numeric map[string]*skiplist
+	tags    map[string]map[string]map[string]struct{}
+	words   map[string]map[string]struct{}
+	stems   map[string]map[string]struct{}
+	docs    map[string]*indexedDoc
+
+	// textLength суммарное число слов в полях TEXT всех документов
+	textLength int
+}
+
+func newHashIndex(def IndexDefinition) (*hashIndex, error) {
+	idx := &hashIndex{
+		def:     def,
+		fields:  make(map[string]IndexFieldType),
+		weights: make(map[string]float64),
+		numeric: make(map[string]*skiplist),
+		tags:    make(map[string]map[string]map[string]struct{}),
+		words:   make(map[string]map[string]struct{}),
+		stems:   make(map[string]map[string]struct{}),
+		docs:    make(map[string]*indexedDoc),
+	}
+	for _, f := range def.Schema {
+		if _, exists := idx.fields[f.Name]; exists {
+			return nil, fmt.Errorf("ERR Duplicate field in schema - %s", f.Name)
+		}
+		idx.fields[f.Name] = f.Type
+		switch f.Type {
+		case IndexNumeric:
+			idx.numeric[f.Name] = newSkiplist()
+		case IndexTag:
+			idx.tags[f.Name] = make(map[string]map[string]struct{})
+		case IndexText:
+			idx.text = append(idx.text, f.Name)
+			idx.weights[f.Name] = f.Weight
+		}
+	}
+	return idx, nil
+}
+
+func (idx *hashIndex) covers(key string) bool {
+	if len(idx.def.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range idx.def.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitTags разбивает значение поля TAG по запятым; теги не зависят от регистра
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// addPosting добавляет ключ в множество по слову или основе
+func addPosting(postings map[string]map[string]struct{}, term, key string) {
+	keys := postings[term]
+	if keys == nil {
+		keys = make(map[string]struct{})
+		postings[term] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+func deletePosting(postings map[string]map[string]struct{}, term, key string) {
+	delete(postings[term], key)
+	if len(postings[term]) == 0 {
+		delete(postings, term)
+	}
+}
+
+// parseIndexNumber разбирает значение числового поля. NaN считается
+// нечисловым: он не упорядочен и нарушил бы порядок skiplist.
+func parseIndexNumber(value string) (float64, bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	return n, err == nil && !math.IsNaN(n)
+}
+
+func (idx *hashIndex) add(key string, coll *NestedCollection, now time.Time) {
+	doc := &indexedDoc{numeric: make(map[string]float64), tags: make(map[string][]string)}
+	words := make(map[string]bool)
+	stems := make(map[string]bool)
+	for name, typ := range idx.fields {
+		value, found := coll.get(name, now)
+		if !found {
+			continue
+		}
+		switch typ {
+		case IndexNumeric:
+			// Нечисловое значение не попадает в индекс
+			if n, ok := parseIndexNumber(value); ok {
+				doc.numeric[name] = n
+				idx.numeric[name].insert(n, key)
+			}
+		case IndexTag:
+			tags := splitTags(value)
+			doc.tags[name] = tags
+			for _, tag := range tags {
+				addPosting(idx.tags[name], tag, key)
+			}
+		case IndexText:
+			tokens := analyze(value)
+			doc.length += len(tokens)
+			for _, t := range tokens {
+				words[t.term], stems[t.stem] = true, true
+			}
+		}
+	}
+
+	for word := range words {
+		doc.words = append(doc.words, word)
+		addPosting(idx.words, word, key)
+	}
+	for stem := range stems {
+		doc.stems = append(doc.stems, stem)
+		addPosting(idx.stems, stem, key)
+	}
+	idx.textLength += doc.length
+	idx.docs[key] = doc
+}
+
+func (idx *hashIndex) delete(key string) {
+	doc, exists := idx.docs[key]
+	if !exists {
+		return
+	}
+	for name, n := range doc.numeric {
+		idx.numeric[name].delete(n, key)
+	}
+	for name, tags := range doc.tags {
+		for _, tag := range tags {
+			deletePosting(idx.tags[name], tag, key)
+		}
+	}
+	for _, word := range doc.words {
+		deletePosting(idx.words, word, key)
+	}
+	for _, stem := range doc.stems {
+		deletePosting(idx.stems, stem, key)
+	}
+	idx.textLength -= doc.length
+	delete(idx.docs, key)
+}
+
+// reindex обновляет ключ во всех индексах по текущему содержимому хэша.
+// Вызывается под блокировкой на запись после каждого изменения хэша
+// и удаления ключа.
+func (s *Storage) reindex(key string) {
+	if len(s.indexes) == 0 {
+		return
+	}
+
+	var coll *NestedCollection
+	if obj := s.lookup(key); obj != nil && obj.typ == TypeHash {
+		coll = obj.value.(*NestedCollection)
+	}
+	now := time.Now()
+	for _, idx := range s.indexes {
+		if !idx.covers(key) {
+			continue
+		}
+		idx.delete(key)
+		if coll != nil && len(coll.fields) > 0 {
+			idx.add(key, coll, now)
+		}
+	}
+}
+
+// IdxCreate создаёт индекс и сразу индексирует подходящие хэши
+func (s *Storage) IdxCreate(def IndexDefinition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.indexes[def.Name]; exists {
+		return ErrIndexExists
+	}
+	idx, err := newHashIndex(def)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for key := range s.data {
+		if obj := s.lookup(key); obj != nil && obj.typ == TypeHash && idx.covers(key) {
+			idx.add(key, obj.value.(*NestedCollection), now)
+		}
+	}
+	s.indexes[def.Name] = idx
+	return nil
+}
+
+// IdxDrop удаляет индекс; хэши остаются
+func (s *Storage) IdxDrop(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.indexes[name]; !exists {
+		return ErrIndexNotFound
+	}
+	delete(s.indexes, name)
+	return nil
+}
+
+// IdxSearch возвращает общее число подходящих хэшей и окно выдачи.
+// Индекс даёт кандидатов, а условие проверяется по текущим значениям
+// полей, поэтому поля с истёкшим TTL в выдачу не попадают.
+func (s *Storage) IdxSearch(name string, q *IndexQuery, opts IndexSearchOptions) (int, []IndexDocument, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx, exists := s.indexes[name]
+	if !exists {
+		return 0, nil, ErrIndexNotFound
+	}
+	if err := q.root.check(idx); err != nil {
+		return 0, nil, err
+	}
+	sortType, sortable := idx.fields[opts.SortBy]
+	if opts.SortBy != "" && !sortable {
+		return 0, nil, fmt.Errorf("ERR Property `%s` not loaded nor in schema", opts.SortBy)
+	}
+
+	for _, field := range opts.HighlightFields {
+		if err := checkField(idx, field, IndexText); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	terms := textTerms(q.root)
+	df := make([]int, len(terms))
+	for i, term := range terms {
+		df[i] = len(term.candidates(idx))
+	}
+
+	now := time.Now()
+	var found []*indexDoc
+	for key := range q.root.candidates(idx) {
+		coll, err := s.getCollection(key)
+		if err != nil || coll == nil {
+			continue
+		}
+		doc := &indexDoc{key: key, coll: coll, now: now, idx: idx}
+		if q.root.match(doc) {
+			doc.score = bm25(doc, terms, df)
+			found = append(found, doc)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].score != found[j].score {
+			return found[i].score > found[j].score
+		}
+		return found[i].key < found[j].key
+	})
+	if opts.SortBy != "" {
+		sortDocs(found, opts.SortBy, sortType, opts.Descending)
+	}
+
+	highlighted := opts.HighlightFields
+	if len(highlighted) == 0 {
+		highlighted = idx.text
+	}
+
+	total := len(found)
+	start := min(opts.Offset, total)
+	end := min(start+opts.Limit, total)
+	docs := make([]IndexDocument, 0, end-start)
+	for _, d := range found[start:end] {
+		fields := make([]string, 0, len(d.coll.fields))
+		for field := range d.coll.fields {
+			if _, found := d.value(field); found {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+
+		doc := IndexDocument{Key: d.key, Score: d.score, Fields: make([]string, 0, 2*len(fields))}
+		for _, field := range fields {
+			value := d.coll.fields[field]
+			if opts.Highlight && len(terms) > 0 && slices.Contains(highlighted, field) {
+				value = highlight(value, terms, opts.HighlightOpen, opts.HighlightClose)
+			}
+			doc.Fields = append(doc.Fields, field, value)
+		}
+		docs = append(docs, doc)
+	}
+	return total, docs, nil
+}
+
+// indexDoc найденный хэш с разобранными по требованию полями TEXT
+type indexDoc struct {
+	key      string
+	coll     *NestedCollection
+	now      time.Time
+	idx      *hashIndex
+	score    float64
+	analyzed map[string][]textToken
+}
+
+func (d *indexDoc) value(field string) (string, bool) {
+	return d.coll.get(field, d.now)
+}
+
+// tokens возвращает слова поля, разбирая его один раз
+func (d *indexDoc) tokens(field string) []textToken {
+	if tokens, cached := d.analyzed[field]; cached {
+		return tokens
+	}
+	if d.analyzed == nil {
+		d.analyzed = make(map[string][]textToken)
+	}
+	value, _ := d.value(field)
+	tokens := analyze(value)
+	d.analyzed[field] = tokens
+	return tokens
+}
+
+// sortDocs упорядочивает документы по значению поля, сохраняя прежний
+// порядок при равенстве. Документы без значения поля идут последними.
+func sortDocs(docs []*indexDoc, field string, typ IndexFieldType, desc bool) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		a, okA := docs[i].value(field)
+		b, okB := docs[j].value(field)
+		if !okA || !okB {
+			return okA && !okB
+		}
+
+		var less, greater bool
+		if typ == IndexNumeric {
+			x, okX := parseIndexNumber(a)
+			y, okY := parseIndexNumber(b)
+			if !okX || !okY {
+				return okX && !okY
+			}
+			less, greater = x < y, x > y
+		} else {
+			less, greater = a < b, a > b
+		}
+		if desc {
+			return greater
+		}
+		return less
+	})
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestIndex создаёт индекс idx над хэшами user:* с полями age и city
+func newTestIndex(t *testing.T, s *Storage) {
+	t.Helper()
+	err := s.IdxCreate(IndexDefinition{
+		Name:     "idx",
+		Prefixes: []string{"user:"},
+		Schema:   []IndexField{{Name: "age", Type: IndexNumeric}, {Name: "city", Type: IndexTag}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// searchKeys возвращает ключи всех документов, подходящих под запрос
+func searchKeys(t *testing.T, s *Storage, raw string, opts IndexSearchOptions) []string {
+	t.Helper()
+	q, err := ParseIndexQuery(raw)
+	if err != nil {
+		t.Fatalf("ParseIndexQuery(%q): %v", raw, err)
+	}
+	if opts.Limit == 0 {
+		opts.Limit = 100
+	}
+	_, docs, err := s.IdxSearch("idx", q, opts)
+	if err != nil {
+		t.Fatalf("IdxSearch(%q): %v", raw, err)
+	}
+	keys := make([]string, len(docs))
+	for i, d := range docs {
+		keys[i] = d.Key
+	}
+	return keys
+}
+
+func TestIdxSearchNumericAndTag(t *testing.T) {
+	s := newTestStorage(t)
+	s.HSet("user:1", []string{"age", "city"}, []string{"20", "Moscow"})
+	s.HSet("user:2", []string{"age", "city"}, []string{"30", "Paris,London"})
+	s.HSet("user:3", []string{"age", "city"}, []string{"old", "paris"})
+	s.HSet("other:1", []string{"age", "city"}, []string{"25", "Moscow"})
+	// Индекс подхватывает хэши, созданные до него
+	newTestIndex(t, s)
+	s.HSet("user:4", []string{"age"}, []string{"40"})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"*", []string{"user:1", "user:2", "user:3", "user:4"}},
+		{"@age:[20 30]", []string{"user:1", "user:2"}},
+		{"@age:[(20 +inf]", []string{"user:2", "user:4"}},
+		{"@age:[-inf (30]", []string{"user:1"}},
+		{"@city:{PARIS}", []string{"user:2", "user:3"}},
+		{"@city:{moscow | london}", []string{"user:1", "user:2"}},
+		{"@city:{paris} @age:[0 100]", []string{"user:2"}},
+		{"@city:{moscow} | @age:[35 50]", []string{"user:1", "user:4"}},
+		{"(@city:{moscow} | @city:{london}) @age:[25 +inf]", []string{"user:2"}},
+	}
+	for _, tt := range tests {
+		got := searchKeys(t, s, tt.query, IndexSearchOptions{})
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("IdxSearch(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestIdxFollowsHashChanges(t *testing.T) {
+	s := newTestStorage(t)
+	newTestIndex(t, s)
+	s.HSet("user:1", []string{"age"}, []string{"20"})
+
+	s.HSet("user:1", []string{"age"}, []string{"50"})
+	if got := searchKeys(t, s, "@age:[20 20]", IndexSearchOptions{}); len(got) != 0 {
+		t.Errorf("old value still indexed: %q", got)
+	}
+	if got := searchKeys(t, s, "@age:[50 50]", IndexSearchOptions{}); !slices.Equal(got, []string{"user:1"}) {
+		t.Errorf("new value not indexed: %q", got)
+	}
+
+	s.Rename("user:1", "user:2", false)
+	if got := searchKeys(t, s, "*", IndexSearchOptions{}); !slices.Equal(got, []string{"user:2"}) {
+		t.Errorf("after RENAME indexed keys = %q", got)
+	}
+
+	// Поле с истёкшим TTL в выдачу не попадает
+	s.HExpire("user:2", []string{"age"}, time.Now().Add(-time.Second), ExpireAlways)
+	if got := searchKeys(t, s, "@age:[-inf +inf]", IndexSearchOptions{}); len(got) != 0 {
+		t.Errorf("expired field matched: %q", got)
+	}
+
+	s.HSet("user:3", []string{"age"}, []string{"1"})
+	s.Delete("user:3")
+	if got := searchKeys(t, s, "*", IndexSearchOptions{}); slices.Contains(got, "user:3") {
+		t.Error("deleted hash still indexed")
+	}
+}
+
+// TestIdxNaN проверяет, что NaN не попадает в числовой индекс и не
+// нарушает порядок остальных значений
+func TestIdxNaN(t *testing.T) {
+	s := newTestStorage(t)
+	newTestIndex(t, s)
+	s.HSet("user:1", []string{"age"}, []string{"nan"})
+	s.HSet("user:1", []string{"age"}, []string{"5"})
+	s.HSet("user:2", []string{"age"}, []string{"7"})
+	if got := searchKeys(t, s, "@age:[-inf +inf]", IndexSearchOptions{}); !slices.Equal(got, []string{"user:1", "user:2"}) {
+		t.Errorf("@age:[-inf +inf] = %q, want user:1 and user:2", got)
+	}
+
+	// Документ с NaN при сортировке идёт последним, как без значения
+	s.HSet("user:0", []string{"age"}, []string{"NaN"})
+	got := searchKeys(t, s, "*", IndexSearchOptions{SortBy: "age"})
+	if want := []string{"user:1", "user:2", "user:0"}; !slices.Equal(got, want) {
+		t.Errorf("sorted by age = %q, want %q", got, want)
+	}
+}
+
+func TestIdxSearchSortAndLimit(t *testing.T) {
+	s := newTestStorage(t)
+	newTestIndex(t, s)
+	s.HSet("user:a", []string{"age"}, []string{"3"})
+	s.HSet("user:b", []string{"age"}, []string{"1"})
+	s.HSet("user:c", []string{"age"}, []string{"2"})
+	s.HSet("user:d", []string{"city"}, []string{"x"})
+
+	tests := []struct {
+		name string
+		opts IndexSearchOptions
+		want []string
+	}{
+		{"by key", IndexSearchOptions{}, []string{"user:a", "user:b", "user:c", "user:d"}},
+		{"by age", IndexSearchOptions{SortBy: "age"}, []string{"user:b", "user:c", "user:a", "user:d"}},
+		{"by age desc", IndexSearchOptions{SortBy: "age", Descending: true}, []string{"user:a", "user:c", "user:b", "user:d"}},
+		{"window", IndexSearchOptions{SortBy: "age", Offset: 1, Limit: 2}, []string{"user:c", "user:a"}},
+		{"offset past end", IndexSearchOptions{Offset: 10, Limit: 2}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := searchKeys(t, s, "*", tt.opts); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: IdxSearch = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIdxErrors(t *testing.T) {
+	s := newTestStorage(t)
+	newTestIndex(t, s)
+	if err := s.IdxCreate(IndexDefinition{Name: "idx"}); !errors.Is(err, ErrIndexExists) {
+		t.Errorf("second IdxCreate err = %v, want ErrIndexExists", err)
+	}
+	if err := s.IdxCreate(IndexDefinition{Name: "dup", Schema: []IndexField{{Name: "a"}, {Name: "a", Type: IndexTag}}}); err == nil {
+		t.Error("IdxCreate with a duplicate field succeeded")
+	}
+
+	for _, raw := range []string{"", "(", "@age:[1]", "@age:[a b]", "@city:{ | }", "@x", "* )"} {
+		if _, err := ParseIndexQuery(raw); !errors.Is(err, ErrIndexQuerySyntax) {
+			t.Errorf("ParseIndexQuery(%q) err = %v, want ErrIndexQuerySyntax", raw, err)
+		}
+	}
+
+	q, _ := ParseIndexQuery("@city:[1 2]")
+	if _, _, err := s.IdxSearch("idx", q, IndexSearchOptions{Limit: 10}); err == nil {
+		t.Error("numeric condition on a TAG field accepted")
+	}
+	q, _ = ParseIndexQuery("*")
+	if _, _, err := s.IdxSearch("idx", q, IndexSearchOptions{SortBy: "name", Limit: 10}); err == nil {
+		t.Error("SORTBY unknown field accepted")
+	}
+	if err := s.IdxDrop("idx"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.IdxSearch("idx", q, IndexSearchOptions{}); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("IdxSearch after IdxDrop err = %v, want ErrIndexNotFound", err)
+	}
+}
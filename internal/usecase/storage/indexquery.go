@@ -0,0 +1,408 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var ErrIndexQuerySyntax = errors.New("ERR Syntax error in query")
+
+// IndexQuery разобранный запрос IDX.SEARCH. Поддерживаются условия
+// @field:[min max] для числовых полей (с "(" для исключающей границы
+// и -inf/+inf), @field:{a | b} для тегов, * для всех документов, скобки,
+// пробел как AND и | как OR. AND связывает сильнее OR. Слова, префиксы
+// "pre*" и фразы в кавычках ищутся во всех полях TEXT, а после @field:
+// — только в указанном поле; @field:(...) ограничивает полем всю группу.
+type IndexQuery struct {
+	Raw  string
+	root queryNode
+}
+
+// queryNode узел дерева запроса
+type queryNode interface {
+	// check проверяет, что поля условия есть в схеме и имеют нужный тип
+	check(idx *hashIndex) error
+	// candidates возвращает ключи документов, которые могут подходить
+	candidates(idx *hashIndex) map[string]struct{}
+	// match проверяет условие по текущим значениям полей хэша
+	match(doc *indexDoc) bool
+}
+
+type queryAll struct{}
+
+// queryNone запрос только из стоп-слов: ему не подходит ни один документ
+type queryNone struct{}
+
+type queryNumeric struct {
+	field string
+	r     ScoreRange
+}
+
+type queryTag struct {
+	field  string
+	values []string
+}
+
+type queryAnd []queryNode
+
+type queryOr []queryNode
+
+func checkField(idx *hashIndex, field string, typ IndexFieldType) error {
+	if actual, exists := idx.fields[field]; !exists || actual != typ {
+		return fmt.Errorf("ERR Unknown field `%s`", field)
+	}
+	return nil
+}
+
+func (queryAll) check(*hashIndex) error { return nil }
+
+func (queryAll) candidates(idx *hashIndex) map[string]struct{} {
+	result := make(map[string]struct{}, len(idx.docs))
+	for key := range idx.docs {
+		result[key] = struct{}{}
+	}
+	return result
+}
+
+func (queryAll) match(*indexDoc) bool { return true }
+
+func (queryNone) check(*hashIndex) error { return nil }
+
+func (queryNone) candidates(*hashIndex) map[string]struct{} { return nil }
+
+func (queryNone) match(*indexDoc) bool { return false }
+
+func (q queryNumeric) check(idx *hashIndex) error { return checkField(idx, q.field, IndexNumeric) }
+
+func (q queryNumeric) candidates(idx *hashIndex) map[string]struct{} {
+	result := make(map[string]struct{})
+	for x := idx.numeric[q.field].firstInScoreRange(q.r); x != nil && q.r.lteMax(x.score); x = x.level[0].forward {
+		result[x.member] = struct{}{}
+	}
+	return result
+}
+
+func (q queryNumeric) match(doc *indexDoc) bool {
+	value, found := doc.value(q.field)
+	if !found {
+		return false
+	}
+	n, ok := parseIndexNumber(value)
+	return ok && q.r.gteMin(n) && q.r.lteMax(n)
+}
+
+func (q queryTag) check(idx *hashIndex) error { return checkField(idx, q.field, IndexTag) }
+
+func (q queryTag) candidates(idx *hashIndex) map[string]struct{} {
+	result := make(map[string]struct{})
+	for _, tag := range q.values {
+		for key := range idx.tags[q.field][tag] {
+			result[key] = struct{}{}
+		}
+	}
+	return result
+}
+
+func (q queryTag) match(doc *indexDoc) bool {
+	value, found := doc.value(q.field)
+	if !found {
+		return false
+	}
+	for _, tag := range splitTags(value) {
+		for _, wanted := range q.values {
+			if tag == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (q queryAnd) check(idx *hashIndex) error {
+	for _, node := range q {
+		if err := node.check(idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// candidates пересекает множества, начиная с наименьшего
+func (q queryAnd) candidates(idx *hashIndex) map[string]struct{} {
+	sets := make([]map[string]struct{}, len(q))
+	smallest := 0
+	for i, node := range q {
+		sets[i] = node.candidates(idx)
+		if len(sets[i]) < len(sets[smallest]) {
+			smallest = i
+		}
+	}
+
+	result := make(map[string]struct{})
+	for key := range sets[smallest] {
+		inAll := true
+		for _, set := range sets {
+			if _, found := set[key]; !found {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			result[key] = struct{}{}
+		}
+	}
+	return result
+}
+
+func (q queryAnd) match(doc *indexDoc) bool {
+	for _, node := range q {
+		if !node.match(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q queryOr) check(idx *hashIndex) error {
+	return queryAnd(q).check(idx)
+}
+
+func (q queryOr) candidates(idx *hashIndex) map[string]struct{} {
+	result := make(map[string]struct{})
+	for _, node := range q {
+		for key := range node.candidates(idx) {
+			result[key] = struct{}{}
+		}
+	}
+	return result
+}
+
+func (q queryOr) match(doc *indexDoc) bool {
+	for _, node := range q {
+		if node.match(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIndexQuery разбирает запрос IDX.SEARCH
+func ParseIndexQuery(raw string) (*IndexQuery, error) {
+	p := &queryParser{input: raw}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpaces(); p.pos < len(p.input) {
+		return nil, ErrIndexQuerySyntax
+	}
+	return &IndexQuery{Raw: raw, root: root}, nil
+}
+
+// queryParser fields — поля, которыми ограничены текстовые условия
+// текущей группы @field:(...)
+type queryParser struct {
+	input  string
+	pos    int
+	fields []string
+}
+
+func (p *queryParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *queryParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	var nodes queryOr
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		if p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+// parseAnd разбирает последовательность условий. Стоп-слова пропускаются;
+// если кроме них ничего нет, условию не подходит ни один документ.
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes queryAnd
+	terms := 0
+	for c := p.peek(); c != 0 && c != '|' && c != ')'; c = p.peek() {
+		node, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		terms++
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+	switch {
+	case terms == 0:
+		return nil, ErrIndexQuerySyntax
+	case len(nodes) == 0:
+		return queryNone{}, nil
+	case len(nodes) == 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseTerm() (queryNode, error) {
+	switch p.peek() {
+	case '*':
+		p.pos++
+		return queryAll{}, nil
+	case '(':
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, ErrIndexQuerySyntax
+		}
+		p.pos++
+		return node, nil
+	case '@':
+		return p.parseField()
+	case '"':
+		return p.parsePhrase()
+	}
+	return p.parseWord()
+}
+
+// parseWord разбирает слово или префикс "pre*"; стоп-слово даёт nil
+func (p *queryParser) parseWord() (queryNode, error) {
+	word, end := readWord(p.input, p.pos)
+	if word == "" {
+		return nil, ErrIndexQuerySyntax
+	}
+	p.pos = end
+	word = strings.ToLower(word)
+
+	if p.pos < len(p.input) && p.input[p.pos] == '*' {
+		p.pos++
+		return newQueryText(p.fields, []string{word}, true), nil
+	}
+	if stopWords[word] {
+		return nil, nil
+	}
+	return newQueryText(p.fields, []string{word}, false), nil
+}
+
+// parsePhrase разбирает фразу в кавычках; стоп-слова в ней пропускаются
+func (p *queryParser) parsePhrase() (queryNode, error) {
+	end := strings.IndexByte(p.input[p.pos+1:], '"')
+	if end < 0 {
+		return nil, ErrIndexQuerySyntax
+	}
+	phrase := p.input[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+
+	var words []string
+	for _, t := range analyze(phrase) {
+		words = append(words, t.term)
+	}
+	if len(words) == 0 {
+		return nil, nil
+	}
+	return newQueryText(p.fields, words, false), nil
+}
+
+// parseField разбирает @field:[min max] или @field:{a | b}
+func (p *queryParser) parseField() (queryNode, error) {
+	colon := strings.IndexByte(p.input[p.pos:], ':')
+	if colon < 2 {
+		return nil, ErrIndexQuerySyntax
+	}
+	field := p.input[p.pos+1 : p.pos+colon]
+	p.pos += colon + 1
+
+	if p.pos >= len(p.input) {
+		return nil, ErrIndexQuerySyntax
+	}
+	var closing byte
+	switch p.input[p.pos] {
+	case '[':
+		closing = ']'
+	case '{':
+		closing = '}'
+	default:
+		// Текстовое условие или группа, ограниченные полем
+		outer := p.fields
+		p.fields = []string{field}
+		defer func() { p.fields = outer }()
+		return p.parseTerm()
+	}
+	end := strings.IndexByte(p.input[p.pos:], closing)
+	if end < 0 {
+		return nil, ErrIndexQuerySyntax
+	}
+	body := p.input[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+
+	if closing == '}' {
+		q := queryTag{field: field}
+		for _, tag := range strings.Split(body, "|") {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				q.values = append(q.values, tag)
+			}
+		}
+		if len(q.values) == 0 {
+			return nil, ErrIndexQuerySyntax
+		}
+		return q, nil
+	}
+
+	bounds := strings.Fields(strings.ReplaceAll(body, ",", " "))
+	if len(bounds) != 2 {
+		return nil, ErrIndexQuerySyntax
+	}
+	q := queryNumeric{field: field}
+	var err error
+	if q.r.Min, q.r.MinEx, err = parseQueryBound(bounds[0]); err != nil {
+		return nil, err
+	}
+	if q.r.Max, q.r.MaxEx, err = parseQueryBound(bounds[1]); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func parseQueryBound(raw string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(raw, "(")
+	raw = strings.TrimPrefix(raw, "(")
+	switch strings.ToLower(raw) {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "inf", "+inf":
+		return math.Inf(1), exclusive, nil
+	}
+	n, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(n) {
+		return 0, false, ErrIndexQuerySyntax
+	}
+	return n, exclusive, nil
+}
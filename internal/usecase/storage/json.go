@@ -0,0 +1,650 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Документ JSON хранится деревом значений: nil, bool, string, int64,
+// float64, *jsonArray и *jsonObject. Целые и дробные числа различаются,
+// как в JSON.TYPE, а объекты сохраняют порядок ключей.
+
+var (
+	ErrJSONSyntax     = errors.New("ERR invalid JSON value")
+	ErrJSONNoKey      = errors.New("ERR could not perform this operation on a key that doesn't exist")
+	ErrJSONRootCreate = errors.New("ERR new objects must be created at the root")
+	ErrJSONNotNumber  = errors.New("ERR value is not a JSON number")
+)
+
+// jsonObject объект JSON с сохранением порядка ключей
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]any)}
+}
+
+func (o *jsonObject) set(key string, value any) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) del(key string) bool {
+	if _, exists := o.values[key]; !exists {
+		return false
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// jsonArray массив JSON; указатель позволяет изменять его на месте
+type jsonArray struct {
+	items []any
+}
+
+// ParseJSON разбирает текст JSON в дерево значений
+func ParseJSON(text string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+
+	value, err := parseJSONValue(dec)
+	if err != nil {
+		return nil, ErrJSONSyntax
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, ErrJSONSyntax
+	}
+	return value, nil
+}
+
+func parseJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := newJSONObject()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := parseJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(keyTok.(string), value)
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			arr := &jsonArray{items: []any{}}
+			for dec.More() {
+				value, err := parseJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr.items = append(arr.items, value)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		return nil, ErrJSONSyntax
+	case json.Number:
+		return jsonNumber(string(t))
+	default:
+		// nil, bool или string
+		return t, nil
+	}
+}
+
+// jsonNumber возвращает int64 для целых чисел, помещающихся в него, иначе float64
+func jsonNumber(text string) (any, error) {
+	if !strings.ContainsAny(text, ".eE") {
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n, nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return nil, ErrJSONSyntax
+	}
+	return f, nil
+}
+
+// jsonTypeName возвращает имя типа значения, как JSON.TYPE
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case *jsonArray:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonClone копирует значение вместе с вложенными массивами и объектами
+func jsonClone(v any) any {
+	switch t := v.(type) {
+	case *jsonArray:
+		items := make([]any, len(t.items))
+		for i, item := range t.items {
+			items[i] = jsonClone(item)
+		}
+		return &jsonArray{items: items}
+	case *jsonObject:
+		obj := &jsonObject{keys: append([]string(nil), t.keys...), values: make(map[string]any, len(t.values))}
+		for k, item := range t.values {
+			obj.values[k] = jsonClone(item)
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+// JSONFormat оформление вывода JSON.GET; пустые строки дают компактный вывод
+type JSONFormat struct {
+	Indent, Newline, Space string
+}
+
+// JSONString сериализует значение
+func (f JSONFormat) JSONString(v any) string {
+	var b strings.Builder
+	f.write(&b, v, 0)
+	return b.String()
+}
+
+func (f JSONFormat) write(b *strings.Builder, v any, depth int) {
+	switch t := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(t))
+	case int64:
+		b.WriteString(strconv.FormatInt(t, 10))
+	case float64:
+		b.WriteString(formatJSONFloat(t))
+	case string:
+		writeJSONString(b, t)
+	case *jsonArray:
+		if len(t.items) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteByte('[')
+		for i, item := range t.items {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			f.newline(b, depth+1)
+			f.write(b, item, depth+1)
+		}
+		f.newline(b, depth)
+		b.WriteByte(']')
+	case *jsonObject:
+		if len(t.keys) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteByte('{')
+		for i, key := range t.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			f.newline(b, depth+1)
+			writeJSONString(b, key)
+			b.WriteByte(':')
+			b.WriteString(f.Space)
+			f.write(b, t.values[key], depth+1)
+		}
+		f.newline(b, depth)
+		b.WriteByte('}')
+	}
+}
+
+func (f JSONFormat) newline(b *strings.Builder, depth int) {
+	b.WriteString(f.Newline)
+	for i := 0; i < depth; i++ {
+		b.WriteString(f.Indent)
+	}
+}
+
+// formatJSONFloat печатает дробное число так, чтобы при разборе оно
+// осталось дробным: 3.0, а не 3
+func formatJSONFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	s = strings.Replace(s, "e+", "e", 1)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func writeJSONString(b *strings.Builder, s string) {
+	const hex = "0123456789abcdef"
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20:
+			b.WriteString(`\u00`)
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xF])
+		case c < utf8.RuneSelf:
+			b.WriteByte(c)
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteRune(r)
+			i += size
+			continue
+		}
+		i++
+	}
+	b.WriteByte('"')
+}
+
+// setMatch записывает значение на место совпадения пути
+func setMatch(obj *object, m jsonMatch, value any) {
+	switch p := m.parent.(type) {
+	case nil:
+		obj.value = value
+	case *jsonObject:
+		p.values[m.key] = value
+	case *jsonArray:
+		p.items[m.index] = value
+	}
+}
+
+// JSONSet записывает значение по пути. Отсутствующий ключ создаётся только
+// по корневому пути; отсутствующий ключ объекта создаётся, если путь
+// заканчивается именем, а родитель существует. Возвращает false, если
+// условие cond не выполнено или записывать некуда.
+func (s *Storage) JSONSet(key string, path *JSONPath, value any, cond SetCondition) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, err := s.lookupWriteType(key, TypeJSON)
+	if err != nil {
+		return false, err
+	}
+	if obj == nil {
+		if !path.IsRoot() {
+			return false, ErrJSONRootCreate
+		}
+		if cond == SetIfExists {
+			return false, nil
+		}
+		s.put(key, &object{typ: TypeJSON, value: value})
+		return true, nil
+	}
+
+	if matches := path.eval(obj.value); len(matches) > 0 {
+		if cond == SetIfNotExists {
+			return false, nil
+		}
+		for i, m := range matches {
+			if i > 0 {
+				value = jsonClone(value)
+			}
+			setMatch(obj, m, value)
+		}
+		return true, nil
+	}
+	if cond == SetIfExists {
+		return false, nil
+	}
+
+	parent, names, ok := path.parentPath()
+	created := false
+	if ok {
+		for _, m := range parent.eval(obj.value) {
+			o, isObject := m.value.(*jsonObject)
+			if !isObject {
+				continue
+			}
+			for _, name := range names {
+				if created {
+					value = jsonClone(value)
+				}
+				o.set(name, value)
+				created = true
+			}
+		}
+	}
+	if !created && path.Legacy {
+		return false, path.MissingError()
+	}
+	return created, nil
+}
+
+// JSONGet сериализует документ или значения по путям. Для путей JSONPath
+// возвращается массив совпадений, для нескольких путей — объект, ключи
+// которого — сами пути. Второе значение равно false, если ключа нет.
+func (s *Storage) JSONGet(key string, paths []*JSONPath, f JSONFormat) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, err := s.lookupType(key, TypeJSON)
+	if err != nil || obj == nil {
+		return "", false, err
+	}
+	if len(paths) == 0 {
+		return f.JSONString(obj.value), true, nil
+	}
+
+	legacy := true
+	for _, p := range paths {
+		legacy = legacy && p.Legacy
+	}
+
+	results := make([]any, len(paths))
+	for i, p := range paths {
+		matches := p.eval(obj.value)
+		if legacy {
+			if len(matches) == 0 {
+				return "", true, p.MissingError()
+			}
+			results[i] = matches[0].value
+			continue
+		}
+		arr := &jsonArray{items: make([]any, len(matches))}
+		for j, m := range matches {
+			arr.items[j] = m.value
+		}
+		results[i] = arr
+	}
+
+	if len(paths) == 1 {
+		return f.JSONString(results[0]), true, nil
+	}
+	out := newJSONObject()
+	for i, p := range paths {
+		out.set(p.Raw, results[i])
+	}
+	return f.JSONString(out), true, nil
+}
+
+// JSONMGet возвращает значения по пути из нескольких документов;
+// found[i] равен false для отсутствующих ключей, ключей другого типа
+// и путей старого синтаксиса без совпадений
+func (s *Storage) JSONMGet(keys []string, path *JSONPath) ([]string, []bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make([]string, len(keys))
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		obj, err := s.lookupType(key, TypeJSON)
+		if err != nil || obj == nil {
+			continue
+		}
+
+		matches := path.eval(obj.value)
+		if path.Legacy {
+			if len(matches) > 0 {
+				values[i], found[i] = JSONFormat{}.JSONString(matches[0].value), true
+			}
+			continue
+		}
+		arr := &jsonArray{items: make([]any, len(matches))}
+		for j, m := range matches {
+			arr.items[j] = m.value
+		}
+		values[i], found[i] = JSONFormat{}.JSONString(arr), true
+	}
+	return values, found
+}
+
+// JSONDel удаляет значения по пути и возвращает их число. Удаление корня удаляет ключ.
+func (s *Storage) JSONDel(key string, path *JSONPath) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, err := s.lookupWriteType(key, TypeJSON)
+	if err != nil || obj == nil {
+		return 0, err
+	}
+	if path.IsRoot() {
+		s.remove(key)
+		return 1, nil
+	}
+
+	// Элементы массивов удаляются с конца, чтобы не сдвигать индексы остальных
+	matches := path.eval(obj.value)
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].index > matches[j].index })
+
+	type place struct {
+		parent any
+		key    string
+		index  int
+	}
+	seen := make(map[place]bool, len(matches))
+	deleted := 0
+	for _, m := range matches {
+		p := place{m.parent, m.key, m.index}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+
+		switch parent := m.parent.(type) {
+		case *jsonObject:
+			if parent.del(m.key) {
+				deleted++
+			}
+		case *jsonArray:
+			parent.items = append(parent.items[:m.index], parent.items[m.index+1:]...)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// errJSONType сообщает jsonApply, что значение по пути не того типа
+var errJSONType = errors.New("wrong JSON type")
+
+// jsonApply вызывает fn для значений по пути и собирает результаты. fn
+// возвращает новое значение и результат либо errJSONType, если значение не
+// того типа: его результат равен nil, а для пути старого синтаксиса это
+// ошибка. Любая другая ошибка fn отменяет операцию: новые значения
+// записываются, только когда обработаны все совпадения. Путь старого
+// синтаксиса обрабатывает только первое совпадение.
+func (s *Storage) jsonApply(key string, path *JSONPath, write bool, expected string, fn func(v any) (any, any, error)) ([]any, error) {
+	var obj *object
+	var err error
+	if write {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		obj, err = s.lookupWriteType(key, TypeJSON)
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		obj, err = s.lookupType(key, TypeJSON)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, ErrJSONNoKey
+	}
+
+	matches := path.eval(obj.value)
+	if path.Legacy {
+		if len(matches) == 0 {
+			return nil, path.MissingError()
+		}
+		matches = matches[:1]
+	}
+
+	results := make([]any, len(matches))
+	values := make([]any, len(matches))
+	for i, m := range matches {
+		value, result, err := fn(m.value)
+		switch {
+		case err == errJSONType && path.Legacy:
+			return nil, fmt.Errorf("ERR wrong type of path value - expected %s but found %s", expected, jsonTypeName(m.value))
+		case err == errJSONType:
+			value = m.value
+		case err != nil:
+			return nil, err
+		}
+		values[i], results[i] = value, result
+	}
+	if write {
+		for i, m := range matches {
+			setMatch(obj, m, values[i])
+		}
+	}
+	return results, nil
+}
+
+// JSONType возвращает имена типов значений по пути
+func (s *Storage) JSONType(key string, path *JSONPath) ([]any, error) {
+	return s.jsonApply(key, path, false, "", func(v any) (any, any, error) {
+		return v, jsonTypeName(v), nil
+	})
+}
+
+// JSONNumIncrBy увеличивает числа по пути на delta (int64 или float64).
+// Сумма целых остаётся целой, если не переполняется.
+func (s *Storage) JSONNumIncrBy(key string, path *JSONPath, delta any) ([]any, error) {
+	return s.jsonApply(key, path, true, "a number", func(v any) (any, any, error) {
+		var sum any
+		switch n := v.(type) {
+		case int64:
+			if d, ok := delta.(int64); ok && !((d > 0 && n > math.MaxInt64-d) || (d < 0 && n < math.MinInt64-d)) {
+				sum = n + d
+			} else {
+				sum = float64(n) + toFloat(delta)
+			}
+		case float64:
+			sum = n + toFloat(delta)
+		default:
+			return v, nil, errJSONType
+		}
+		if f, ok := sum.(float64); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return nil, nil, ErrNaNOrInf
+		}
+		return sum, sum, nil
+	})
+}
+
+func toFloat(n any) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
+// JSONStrAppend дописывает suffix к строкам по пути и возвращает их новые длины
+func (s *Storage) JSONStrAppend(key string, path *JSONPath, suffix string) ([]any, error) {
+	return s.jsonApply(key, path, true, "string", func(v any) (any, any, error) {
+		str, ok := v.(string)
+		if !ok {
+			return v, nil, errJSONType
+		}
+		str += suffix
+		return str, int64(len(str)), nil
+	})
+}
+
+// JSONArrAppend добавляет значения в конец массивов по пути и возвращает их новые длины
+func (s *Storage) JSONArrAppend(key string, path *JSONPath, values []any) ([]any, error) {
+	return s.jsonApply(key, path, true, "array", func(v any) (any, any, error) {
+		arr, ok := v.(*jsonArray)
+		if !ok {
+			return v, nil, errJSONType
+		}
+		for _, value := range values {
+			arr.items = append(arr.items, jsonClone(value))
+		}
+		return arr, int64(len(arr.items)), nil
+	})
+}
+
+// JSONArrPop извлекает элемент массивов по индексу (отрицательный отсчитывается
+// с конца, выход за границы прижимается к ним) и возвращает его сериализованным.
+// Для пустого массива результат равен nil.
+func (s *Storage) JSONArrPop(key string, path *JSONPath, index int) ([]any, error) {
+	return s.jsonApply(key, path, true, "array", func(v any) (any, any, error) {
+		arr, ok := v.(*jsonArray)
+		if !ok {
+			return v, nil, errJSONType
+		}
+		n := len(arr.items)
+		if n == 0 {
+			return arr, nil, nil
+		}
+		i := index
+		if i < 0 {
+			i += n
+		}
+		i = max(0, min(i, n-1))
+
+		popped := arr.items[i]
+		arr.items = append(arr.items[:i], arr.items[i+1:]...)
+		return arr, JSONFormat{}.JSONString(popped), nil
+	})
+}
+
+// JSONArrLen возвращает длины массивов по пути
+func (s *Storage) JSONArrLen(key string, path *JSONPath) ([]any, error) {
+	return s.jsonApply(key, path, false, "array", func(v any) (any, any, error) {
+		arr, ok := v.(*jsonArray)
+		if !ok {
+			return v, nil, errJSONType
+		}
+		return v, int64(len(arr.items)), nil
+	})
+}
+
+// JSONObjKeys возвращает ключи объектов по пути
+func (s *Storage) JSONObjKeys(key string, path *JSONPath) ([]any, error) {
+	return s.jsonApply(key, path, false, "object", func(v any) (any, any, error) {
+		obj, ok := v.(*jsonObject)
+		if !ok {
+			return v, nil, errJSONType
+		}
+		return v, append([]string(nil), obj.keys...), nil
+	})
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+// mustPath разбирает путь JSONPath или прерывает тест
+func mustPath(t *testing.T, raw string) *JSONPath {
+	t.Helper()
+	p, err := ParseJSONPath(raw)
+	if err != nil {
+		t.Fatalf("ParseJSONPath(%q): %v", raw, err)
+	}
+	return p
+}
+
+// newTestJSON записывает документ text в key
+func newTestJSON(t *testing.T, s *Storage, key, text string) {
+	t.Helper()
+	doc, err := ParseJSON(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.JSONSet(key, mustPath(t, "$"), doc, SetAlways); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"b":1,"a":2}`, `{"b":1,"a":2}`},
+		{`{"a":1,"a":2}`, `{"a":2}`},
+		{` [1, 2.5, -0.0, 1e3, true, null] `, `[1,2.5,-0.0,1000.0,true,null]`},
+		{`9223372036854775807`, `9223372036854775807`},
+		{`9223372036854775808`, `9.223372036854776e18`},
+		{`"a\"b\\c\n\u0001é"`, `"a\"b\\c\n\u0001é"`},
+		{`{}`, `{}`},
+		{`[]`, `[]`},
+	}
+	for _, tt := range tests {
+		v, err := ParseJSON(tt.in)
+		if err != nil {
+			t.Errorf("ParseJSON(%s): %v", tt.in, err)
+			continue
+		}
+		if got := (JSONFormat{}).JSONString(v); got != tt.want {
+			t.Errorf("ParseJSON(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{``, `{`, `[1,]`, `{"a"}`, `1 2`, `1e999`, `nul`} {
+		if _, err := ParseJSON(in); !errors.Is(err, ErrJSONSyntax) {
+			t.Errorf("ParseJSON(%q) err = %v, want ErrJSONSyntax", in, err)
+		}
+	}
+}
+
+func TestJSONPathEval(t *testing.T) {
+	s := newTestStorage(t)
+	newTestJSON(t, s, "doc", `{"a":{"x":1,"arr":[1,2,3,4,5]},"b":{"x":2},"x":0}`)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$", `[{"a":{"x":1,"arr":[1,2,3,4,5]},"b":{"x":2},"x":0}]`},
+		{"$.a.x", `[1]`},
+		{"$['a']['x']", `[1]`},
+		{"$.*.x", `[1,2]`},
+		{"$..x", `[0,1,2]`},
+		{"$.a.arr[-1]", `[5]`},
+		{"$.a.arr[0,2]", `[1,3]`},
+		{"$.a.arr[1:3]", `[2,3]`},
+		{"$.a.arr[::2]", `[1,3,5]`},
+		{"$.a.arr[10]", `[]`},
+		{"$.missing", `[]`},
+		{".a.x", `1`},
+		{"a.arr[1]", `2`},
+	}
+	for _, tt := range tests {
+		got, _, err := s.JSONGet("doc", []*JSONPath{mustPath(t, tt.path)}, JSONFormat{})
+		if err != nil || got != tt.want {
+			t.Errorf("JSONGet(%s) = %s, %v; want %s", tt.path, got, err, tt.want)
+		}
+	}
+
+	if _, _, err := s.JSONGet("doc", []*JSONPath{mustPath(t, ".missing")}, JSONFormat{}); err == nil {
+		t.Error("legacy path without match succeeded")
+	}
+	for _, raw := range []string{"$.", "$[", "$[1", "$x", "$['a'"} {
+		if _, err := ParseJSONPath(raw); !errors.Is(err, ErrJSONPathSyntax) {
+			t.Errorf("ParseJSONPath(%q) err = %v, want ErrJSONPathSyntax", raw, err)
+		}
+	}
+}
+
+func TestJSONSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		value   string
+		cond    SetCondition
+		want    bool
+		wantErr bool
+		wantDoc string
+	}{
+		{"replace", "$.a", `5`, SetAlways, true, false, `{"a":5,"o":{}}`},
+		{"new key", "$.o.n", `"v"`, SetAlways, true, false, `{"a":1,"o":{"n":"v"}}`},
+		{"NX on existing", "$.a", `5`, SetIfNotExists, false, false, `{"a":1,"o":{}}`},
+		{"XX on missing", "$.b", `5`, SetIfExists, false, false, `{"a":1,"o":{}}`},
+		{"missing parent", "$.x.y", `5`, SetAlways, false, false, `{"a":1,"o":{}}`},
+		{"legacy missing parent", ".x.y", `5`, SetAlways, false, true, `{"a":1,"o":{}}`},
+		{"every match gets a copy", "$.*", `[]`, SetAlways, true, false, `{"a":[],"o":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			newTestJSON(t, s, "doc", `{"a":1,"o":{}}`)
+			value, _ := ParseJSON(tt.value)
+
+			ok, err := s.JSONSet("doc", mustPath(t, tt.path), value, tt.cond)
+			if ok != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("JSONSet = %v, %v; want %v", ok, err, tt.want)
+			}
+			if got, _, _ := s.JSONGet("doc", nil, JSONFormat{}); got != tt.wantDoc {
+				t.Errorf("document = %s, want %s", got, tt.wantDoc)
+			}
+		})
+	}
+
+	// Копии не связаны между собой
+	s := newTestStorage(t)
+	newTestJSON(t, s, "doc", `{"a":1,"b":2}`)
+	s.JSONSet("doc", mustPath(t, "$.*"), &jsonArray{items: []any{}}, SetAlways)
+	s.JSONArrAppend("doc", mustPath(t, "$.a"), []any{int64(1)})
+	if got, _, _ := s.JSONGet("doc", nil, JSONFormat{}); got != `{"a":[1],"b":[]}` {
+		t.Errorf("shared value across matches: %s", got)
+	}
+
+	if _, err := s.JSONSet("new", mustPath(t, "$.a"), int64(1), SetAlways); !errors.Is(err, ErrJSONRootCreate) {
+		t.Errorf("JSONSet of missing key at $.a err = %v, want ErrJSONRootCreate", err)
+	}
+}
+
+func TestJSONDel(t *testing.T) {
+	s := newTestStorage(t)
+	newTestJSON(t, s, "doc", `{"arr":[0,1,2,3,4],"o":{"a":1,"b":2}}`)
+
+	// Индексы удаляются с конца, повторяющиеся совпадения — один раз
+	if n, _ := s.JSONDel("doc", mustPath(t, "$.arr[0,2,-1,0]")); n != 3 {
+		t.Errorf("JSONDel of indices = %d, want 3", n)
+	}
+	if n, _ := s.JSONDel("doc", mustPath(t, "$.o.*")); n != 2 {
+		t.Errorf("JSONDel of wildcard = %d, want 2", n)
+	}
+	if got, _, _ := s.JSONGet("doc", nil, JSONFormat{}); got != `{"arr":[1,3],"o":{}}` {
+		t.Errorf("document = %s", got)
+	}
+	if n, _ := s.JSONDel("doc", mustPath(t, "$")); n != 1 || s.Exists("doc") {
+		t.Error("deleting the root kept the key")
+	}
+}
+
+func TestJSONNumIncrBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		delta   any
+		want    string
+		wantErr error
+	}{
+		{"integer", `{"i":1,"f":1.5,"s":"x"}`, int64(2), `{"i":3,"f":3.5,"s":"x"}`, nil},
+		{"float", `{"i":1,"f":1.5,"s":"x"}`, 0.5, `{"i":1.5,"f":2.0,"s":"x"}`, nil},
+		{"integer overflow becomes float", `{"i":9223372036854775807,"s":"x"}`, int64(1), `{"i":9.223372036854776e18,"s":"x"}`, nil},
+		// Переполнение одного значения оставляет документ неизменным
+		{"infinity", `{"i":1,"f":1.7e308,"s":"x"}`, 1.7e308, `{"i":1,"f":1.7e308,"s":"x"}`, ErrNaNOrInf},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			newTestJSON(t, s, "doc", tt.doc)
+
+			results, err := s.JSONNumIncrBy("doc", mustPath(t, "$.*"), tt.delta)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("JSONNumIncrBy err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && results[len(results)-1] != nil {
+				t.Errorf("result for string = %v, want nil", results[len(results)-1])
+			}
+			if got, _, _ := s.JSONGet("doc", nil, JSONFormat{}); got != tt.want {
+				t.Errorf("document = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	s := newTestStorage(t)
+	newTestJSON(t, s, "doc", `{"s":"x"}`)
+	if _, err := s.JSONNumIncrBy("doc", mustPath(t, ".s"), int64(1)); err == nil {
+		t.Error("legacy path to a string accepted NUMINCRBY")
+	}
+}
+
+func TestJSONArrPop(t *testing.T) {
+	tests := []struct {
+		index int
+		want  any
+		left  string
+	}{
+		{-1, "3", `[1,2]`},
+		{0, "1", `[2,3]`},
+		{100, "3", `[1,2]`},
+		{-100, "1", `[2,3]`},
+	}
+	for _, tt := range tests {
+		s := newTestStorage(t)
+		newTestJSON(t, s, "doc", `{"a":[1,2,3],"e":[]}`)
+
+		results, err := s.JSONArrPop("doc", mustPath(t, "$.a"), tt.index)
+		if err != nil || len(results) != 1 || results[0] != tt.want {
+			t.Errorf("JSONArrPop(%d) = %v, %v; want %v", tt.index, results, err, tt.want)
+		}
+		if got, _, _ := s.JSONGet("doc", []*JSONPath{mustPath(t, ".a")}, JSONFormat{}); got != tt.left {
+			t.Errorf("array after JSONArrPop(%d) = %s, want %s", tt.index, got, tt.left)
+		}
+		if results, _ := s.JSONArrPop("doc", mustPath(t, "$.e"), 0); results[0] != nil {
+			t.Errorf("JSONArrPop of empty array = %v", results)
+		}
+	}
+
+	s := newTestStorage(t)
+	if _, err := s.JSONArrPop("missing", mustPath(t, "$"), 0); !errors.Is(err, ErrJSONNoKey) {
+		t.Errorf("JSONArrPop of missing key err = %v, want ErrJSONNoKey", err)
+	}
+}
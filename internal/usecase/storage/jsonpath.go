@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrJSONPathSyntax = errors.New("ERR invalid JSONPath")
+
+// JSONPath разобранный путь. Поддерживается подмножество JSONPath:
+// $, .name, ['name'], [n] с отрицательными индексами, объединения [a,b],
+// срезы [start:end:step], * и рекурсивный спуск "..". Пути без "$"
+// считаются путями старого синтаксиса (".a.b", "a[0]"): они адресуют
+// одно значение, а его отсутствие — ошибка.
+type JSONPath struct {
+	Raw      string
+	Legacy   bool
+	segments []jsonSegment
+}
+
+type jsonSegmentKind int
+
+const (
+	segChild jsonSegmentKind = iota
+	segIndex
+	segSlice
+	segWildcard
+)
+
+// jsonSegment шаг пути. При recursive шаг применяется к узлу и всем его потомкам.
+type jsonSegment struct {
+	kind      jsonSegmentKind
+	names     []string
+	indices   []int
+	start     *int
+	end       *int
+	step      int
+	recursive bool
+}
+
+// IsRoot сообщает, что путь адресует корень документа
+func (p *JSONPath) IsRoot() bool {
+	return len(p.segments) == 0
+}
+
+// MissingError ошибка для пути старого синтаксиса, не указывающего ни на одно значение
+func (p *JSONPath) MissingError() error {
+	return fmt.Errorf("ERR Path '%s' does not exist", p.Raw)
+}
+
+// ParseJSONPath разбирает путь JSONPath или путь старого синтаксиса
+func ParseJSONPath(raw string) (*JSONPath, error) {
+	path := &JSONPath{Raw: raw}
+	expr := raw
+	switch {
+	case strings.HasPrefix(raw, "$"):
+		expr = raw[1:]
+	case raw == ".":
+		path.Legacy, expr = true, ""
+	case strings.HasPrefix(raw, "."), strings.HasPrefix(raw, "["):
+		path.Legacy = true
+	default:
+		path.Legacy, expr = true, "."+raw
+	}
+
+	for len(expr) > 0 {
+		var seg jsonSegment
+		var err error
+		switch {
+		case strings.HasPrefix(expr, ".."):
+			expr = expr[2:]
+			if strings.HasPrefix(expr, "[") {
+				seg, expr, err = parseBracket(expr)
+			} else {
+				seg, expr, err = parseDotName(expr)
+			}
+			seg.recursive = true
+		case expr[0] == '.':
+			seg, expr, err = parseDotName(expr[1:])
+		case expr[0] == '[':
+			seg, expr, err = parseBracket(expr)
+		default:
+			err = ErrJSONPathSyntax
+		}
+		if err != nil {
+			return nil, err
+		}
+		path.segments = append(path.segments, seg)
+	}
+	return path, nil
+}
+
+func parseDotName(expr string) (jsonSegment, string, error) {
+	end := strings.IndexAny(expr, ".[")
+	if end < 0 {
+		end = len(expr)
+	}
+	name := expr[:end]
+	switch name {
+	case "":
+		return jsonSegment{}, "", ErrJSONPathSyntax
+	case "*":
+		return jsonSegment{kind: segWildcard}, expr[end:], nil
+	}
+	return jsonSegment{kind: segChild, names: []string{name}}, expr[end:], nil
+}
+
+// parseBracket разбирает [*], ['a','b'], [1,-1] и [start:end:step]
+func parseBracket(expr string) (jsonSegment, string, error) {
+	var seg jsonSegment
+	i := 1
+	skipSpaces := func() {
+		for i < len(expr) && expr[i] == ' ' {
+			i++
+		}
+	}
+
+	skipSpaces()
+	if i < len(expr) && expr[i] == '*' {
+		i++
+		skipSpaces()
+		if i >= len(expr) || expr[i] != ']' {
+			return seg, "", ErrJSONPathSyntax
+		}
+		return jsonSegment{kind: segWildcard}, expr[i+1:], nil
+	}
+
+	if i < len(expr) && (expr[i] == '\'' || expr[i] == '"') {
+		seg.kind = segChild
+		for {
+			skipSpaces()
+			if i >= len(expr) || (expr[i] != '\'' && expr[i] != '"') {
+				return seg, "", ErrJSONPathSyntax
+			}
+			quote := expr[i]
+			var name strings.Builder
+			for i++; i < len(expr) && expr[i] != quote; i++ {
+				if expr[i] == '\\' && i+1 < len(expr) {
+					i++
+				}
+				name.WriteByte(expr[i])
+			}
+			if i >= len(expr) {
+				return seg, "", ErrJSONPathSyntax
+			}
+			seg.names = append(seg.names, name.String())
+			i++
+			skipSpaces()
+			if i < len(expr) && expr[i] == ',' {
+				i++
+				continue
+			}
+			if i < len(expr) && expr[i] == ']' {
+				return seg, expr[i+1:], nil
+			}
+			return seg, "", ErrJSONPathSyntax
+		}
+	}
+
+	end := strings.IndexByte(expr, ']')
+	if end < 0 {
+		return seg, "", ErrJSONPathSyntax
+	}
+	body, rest := expr[1:end], expr[end+1:]
+
+	if strings.Contains(body, ":") {
+		parts := strings.Split(body, ":")
+		if len(parts) > 3 {
+			return seg, "", ErrJSONPathSyntax
+		}
+		seg.kind, seg.step = segSlice, 1
+		bounds := []**int{&seg.start, &seg.end}
+		for j, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return seg, "", ErrJSONPathSyntax
+			}
+			if j == 2 {
+				if n <= 0 {
+					return seg, "", ErrJSONPathSyntax
+				}
+				seg.step = n
+			} else {
+				*bounds[j] = &n
+			}
+		}
+		return seg, rest, nil
+	}
+
+	seg.kind = segIndex
+	for _, part := range strings.Split(body, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return seg, "", ErrJSONPathSyntax
+		}
+		seg.indices = append(seg.indices, n)
+	}
+	return seg, rest, nil
+}
+
+// jsonMatch найденное значение и его место в родителе: ключ объекта
+// или индекс массива. У корня родителя нет.
+type jsonMatch struct {
+	value  any
+	parent any
+	key    string
+	index  int
+}
+
+// eval возвращает значения документа root, на которые указывает путь
+func (p *JSONPath) eval(root any) []jsonMatch {
+	matches := []jsonMatch{{value: root}}
+	for _, seg := range p.segments {
+		var next []jsonMatch
+		for _, m := range matches {
+			if seg.recursive {
+				for _, d := range descendants(m) {
+					next = seg.apply(d, next)
+				}
+			} else {
+				next = seg.apply(m, next)
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// descendants возвращает значение и всех его потомков в прямом порядке обхода
+func descendants(m jsonMatch) []jsonMatch {
+	result := []jsonMatch{m}
+	for _, child := range children(m.value) {
+		result = append(result, descendants(child)...)
+	}
+	return result
+}
+
+func children(v any) []jsonMatch {
+	switch t := v.(type) {
+	case *jsonArray:
+		result := make([]jsonMatch, len(t.items))
+		for i, item := range t.items {
+			result[i] = jsonMatch{value: item, parent: t, index: i}
+		}
+		return result
+	case *jsonObject:
+		result := make([]jsonMatch, len(t.keys))
+		for i, key := range t.keys {
+			result[i] = jsonMatch{value: t.values[key], parent: t, key: key}
+		}
+		return result
+	}
+	return nil
+}
+
+func (seg jsonSegment) apply(m jsonMatch, out []jsonMatch) []jsonMatch {
+	switch seg.kind {
+	case segWildcard:
+		return append(out, children(m.value)...)
+	case segChild:
+		if obj, ok := m.value.(*jsonObject); ok {
+			for _, name := range seg.names {
+				if value, exists := obj.values[name]; exists {
+					out = append(out, jsonMatch{value: value, parent: obj, key: name})
+				}
+			}
+		}
+	case segIndex:
+		if arr, ok := m.value.(*jsonArray); ok {
+			for _, i := range seg.indices {
+				if i < 0 {
+					i += len(arr.items)
+				}
+				if i >= 0 && i < len(arr.items) {
+					out = append(out, jsonMatch{value: arr.items[i], parent: arr, index: i})
+				}
+			}
+		}
+	case segSlice:
+		if arr, ok := m.value.(*jsonArray); ok {
+			n := len(arr.items)
+			start, end := 0, n
+			if seg.start != nil {
+				start = *seg.start
+			}
+			if seg.end != nil {
+				end = *seg.end
+			}
+			if start < 0 {
+				start = max(start+n, 0)
+			}
+			if end < 0 {
+				end += n
+			}
+			for i := start; i < min(end, n); i += seg.step {
+				out = append(out, jsonMatch{value: arr.items[i], parent: arr, index: i})
+			}
+		}
+	}
+	return out
+}
+
+// parentPath возвращает путь без последнего шага и имена ключей этого шага,
+// если он адресует ключи объекта: по нему JSON.SET создаёт новые ключи
+func (p *JSONPath) parentPath() (*JSONPath, []string, bool) {
+	if len(p.segments) == 0 {
+		return nil, nil, false
+	}
+	last := p.segments[len(p.segments)-1]
+	if last.kind != segChild || last.recursive {
+		return nil, nil, false
+	}
+	parent := &JSONPath{Raw: p.Raw, Legacy: p.Legacy, segments: p.segments[:len(p.segments)-1]}
+	return parent, last.names, true
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"keyvalue/internal/usecase/glob"
+	"sort"
+)
+
+var ErrSameObject = errors.New("ERR source and destination objects are the same")
+
+// cloneValue возвращает независимую копию значения объекта
+func cloneValue(obj *object) any {
+	switch v := obj.value.(type) {
+	case []byte:
+		return bytes.Clone(v)
+	case *NestedCollection:
+		return v.clone()
+	case *ListCollection:
+		return v.clone()
+	case *SetCollection:
+		return v.clone()
+	case *ZSetCollection:
+		return v.clone()
+	case *StreamCollection:
+		return v.clone()
+	case *BloomFilter:
+		return v.clone()
+	case *CuckooFilter:
+		return v.clone()
+	case *CountMinSketch:
+		return v.clone()
+	case *TopK:
+		return v.clone()
+	case *TimeSeries:
+		return v.clone()
+	case *VectorSet:
+		return v.clone()
+	}
+	if obj.typ == TypeJSON {
+		return jsonClone(obj.value)
+	}
+	return obj.value
+}
+
+// Keys возвращает ключи, подходящие под шаблон, по возрастанию
+func (s *Storage) Keys(pattern string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key := range s.data {
+		if !s.expired(key) && glob.Match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DBSize возвращает число живых ключей
+func (s *Storage) DBSize() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for key := range s.data {
+		if !s.expired(key) {
+			n++
+		}
+	}
+	return n
+}
+
+// randomKeyTries столько раз RandomKey выбирает ключ через randomKey,
+// прежде чем перебрать все ключи в поисках живого
+const randomKeyTries = 100
+
+// RandomKey возвращает случайный живой ключ. Истёкшие ключи пропускаются
+// повторным выбором, как в Redis; если почти все ключи истекли и выбор
+// не удался, живой ключ ищется перебором.
+func (s *Storage) RandomKey() (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.data) == 0 {
+		return "", false
+	}
+	for range randomKeyTries {
+		if key := randomKey(s.data); !s.expired(key) {
+			return key, true
+		}
+	}
+	for key := range s.data {
+		if !s.expired(key) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// Rename переносит значение и TTL ключа src в dst. При nx ключ не
+// переименовывается, если dst уже существует, и Rename возвращает false.
+func (s *Storage) Rename(src, dst string, nx bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj := s.lookupWrite(src)
+	if obj == nil {
+		return false, ErrNoSuchKey
+	}
+	if src == dst {
+		return !nx, nil
+	}
+	if nx && s.lookupWrite(dst) != nil {
+		return false, nil
+	}
+
+	expTime, hasTTL := s.expiration[src]
+	s.remove(dst)
+	s.untrack(src, obj)
+	delete(s.data, src)
+	s.keyOrder.remove(src)
+	s.clearExpire(src)
+	s.put(dst, obj)
+	if hasTTL {
+		s.setExpire(dst, expTime)
+	}
+	s.track(dst, obj)
+
+	if ts, ok := obj.value.(*TimeSeries); ok {
+		s.renameTimeSeries(ts, src, dst)
+	}
+	s.reindex(src)
+	s.reindex(dst)
+	return true, nil
+}
+
+// renameTimeSeries обновляет ссылки правил компактизации на
+// переименованный ряд. Вызывается под блокировкой на запись.
+func (s *Storage) renameTimeSeries(ts *TimeSeries, src, dst string) {
+	for _, rule := range ts.rules {
+		if target, err := s.getTimeSeries(rule.dest); err == nil {
+			target.source = dst
+		}
+	}
+	if ts.source == "" {
+		return
+	}
+	if source, err := s.getTimeSeries(ts.source); err == nil {
+		for _, rule := range source.rules {
+			if rule.dest == src {
+				rule.dest = dst
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRename(t *testing.T) {
+	at := time.Now().Add(time.Hour)
+	tests := []struct {
+		name    string
+		src     string
+		dst     string
+		nx      bool
+		want    bool
+		wantErr error
+		// wantTTL наличие TTL у dst после переименования
+		wantTTL bool
+	}{
+		{"keeps source TTL", "ttl", "new", false, true, nil, true},
+		{"drops destination TTL", "plain", "ttl", false, true, nil, false},
+		{"NX onto existing", "plain", "ttl", true, false, nil, true},
+		{"NX onto missing", "plain", "new", true, true, nil, false},
+		{"same key", "ttl", "ttl", false, true, nil, true},
+		{"same key NX", "ttl", "ttl", true, false, nil, true},
+		{"missing source", "missing", "new", false, false, ErrNoSuchKey, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			s.Set("plain", "p", 0)
+			s.Set("ttl", "t", 0)
+			s.Expire("ttl", at, ExpireAlways)
+
+			ok, err := s.Rename(tt.src, tt.dst, tt.nx)
+			if ok != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rename = %v, %v; want %v, %v", ok, err, tt.want, tt.wantErr)
+			}
+			if expireAt, _ := s.ExpireTime(tt.dst); !expireAt.IsZero() != tt.wantTTL {
+				t.Errorf("destination expires at %v, want TTL = %v", expireAt, tt.wantTTL)
+			}
+			if ok && tt.src != tt.dst && s.Exists(tt.src) {
+				t.Error("source still exists")
+			}
+		})
+	}
+}
+
+func TestRenameTimeSeriesRule(t *testing.T) {
+	s := newTestStorage(t)
+	s.TSCreate("src", TSOptions{})
+	s.TSCreate("dst", TSOptions{})
+	s.TSCreateRule("src", "dst", AggSum, 10, 0)
+
+	// Правило следует за переименованием обоих концов
+	s.Rename("src", "src2", false)
+	s.Rename("dst", "dst2", false)
+	addSamples(t, s, "src2", 1, 15)
+
+	got, _ := s.TSRange("dst2", TSRangeQuery{To: math.MaxInt64})
+	if !slices.Equal(got, []TSSample{{0, 1}}) {
+		t.Errorf("compacted samples = %v", got)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	s := newTestStorage(t)
+	for _, key := range []string{"user:1", "user:2", "user:10", "order:1", "expired"} {
+		s.Set(key, "v", 0)
+	}
+	s.Expire("expired", time.Now().Add(-time.Second), ExpireAlways)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*", []string{"order:1", "user:1", "user:10", "user:2"}},
+		{"user:?", []string{"user:1", "user:2"}},
+		{"user:[12]*", []string{"user:1", "user:10", "user:2"}},
+		{"exp*", nil},
+	}
+	for _, tt := range tests {
+		if got := s.Keys(tt.pattern); !slices.Equal(got, tt.want) {
+			t.Errorf("Keys(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+	if n := s.DBSize(); n != 4 {
+		t.Errorf("DBSize = %d, want 4", n)
+	}
+}
+
+func TestRandomKey(t *testing.T) {
+	s := newTestStorage(t)
+	if _, ok := s.RandomKey(); ok {
+		t.Error("RandomKey of empty storage succeeded")
+	}
+
+	s.Set("gone", "v", 0)
+	s.Expire("gone", time.Now().Add(-time.Second), ExpireAlways)
+	if key, ok := s.RandomKey(); ok {
+		t.Errorf("RandomKey returned expired key %s", key)
+	}
+
+	s.Set("a", "v", 0)
+	s.Set("b", "v", 0)
+	seen := make(map[string]bool)
+	for range 200 {
+		key, _ := s.RandomKey()
+		seen[key] = true
+	}
+	if !seen["a"] || !seen["b"] || len(seen) != 2 {
+		t.Errorf("RandomKey returned %v", seen)
+	}
+}
+
+func TestRandomKeySampling(t *testing.T) {
+	s := newTestStorage(t)
+	past := time.Now().Add(-time.Second)
+	s.mu.Lock()
+	for i := range 1000 {
+		key := "k" + strconv.Itoa(i)
+		s.put(key, &object{typ: TypeString, value: "v"})
+		// Истёкший срок ставится в обход Expire, которое удалило бы ключ сразу
+		if i%10 != 0 {
+			s.setExpire(key, past)
+		}
+	}
+	s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for range 20000 {
+		key, ok := s.RandomKey()
+		if !ok || !s.Exists(key) {
+			t.Fatalf("RandomKey = %q, %v; want a live key", key, ok)
+		}
+		seen[key] = true
+	}
+	if len(seen) != 100 {
+		t.Errorf("RandomKey returned %d of 100 live keys", len(seen))
+	}
+
+	// Единственный живой ключ находится, даже если выбор его не задевает
+	s.mu.Lock()
+	for i := range 1000 {
+		s.setExpire("k"+strconv.Itoa(i), past)
+	}
+	s.put("live", &object{typ: TypeString, value: "v"})
+	s.mu.Unlock()
+	if key, ok := s.RandomKey(); !ok || key != "live" {
+		t.Errorf("RandomKey = %q, %v; want live", key, ok)
+	}
+}
@@ -0,0 +1,417 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	ErrNoSuchKey       = errors.New("ERR no such key")
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+)
+
+// ListEnd сторона списка, с которой работает команда
+type ListEnd int
+
+const (
+	ListLeft ListEnd = iota
+	ListRight
+)
+
+// ListCollection список строк на кольцевом буфере: вставка и удаление
+// с обоих концов и доступ по индексу выполняются за O(1)
+type ListCollection struct {
+	buf  []string
+	head int
+	size int
+}
+
+func newListCollection() *ListCollection {
+	return &ListCollection{buf: make([]string, 8)}
+}
+
+func (l *ListCollection) Len() int {
+	return l.size
+}
+
+func (l *ListCollection) grow() {
+	buf := make([]string, len(l.buf)*2)
+	for i := 0; i < l.size; i++ {
+		buf[i] = l.at(i)
+	}
+	l.buf = buf
+	l.head = 0
+}
+
+func (l *ListCollection) pos(i int) int {
+	return (l.head + i) % len(l.buf)
+}
+
+func (l *ListCollection) at(i int) string {
+	return l.buf[l.pos(i)]
+}
+
+func (l *ListCollection) set(i int, value string) {
+	l.buf[l.pos(i)] = value
+}
+
+func (l *ListCollection) push(end ListEnd, value string) {
+	if l.size == len(l.buf) {
+		l.grow()
+	}
+	if end == ListLeft {
+		l.head = (l.head - 1 + len(l.buf)) % len(l.buf)
+		l.buf[l.head] = value
+	} else {
+		l.buf[l.pos(l.size)] = value
+	}
+	l.size++
+}
+
+func (l *ListCollection) pop(end ListEnd) string {
+	var i int
+	if end == ListLeft {
+		i = l.head
+		l.head = (l.head + 1) % len(l.buf)
+	} else {
+		i = l.pos(l.size - 1)
+	}
+	value := l.buf[i]
+	l.buf[i] = ""
+	l.size--
+	return value
+}
+
+// items возвращает копию элементов в диапазоне [start, stop)
+func (l *ListCollection) items(start, stop int) []string {
+	result := make([]string, 0, stop-start)
+	for i := start; i < stop; i++ {
+		result = append(result, l.at(i))
+	}
+	return result
+}
+
+// reset заменяет содержимое списка
+func (l *ListCollection) reset(items []string) {
+	capacity := 8
+	for capacity < len(items) {
+		capacity *= 2
+	}
+	l.buf = make([]string, capacity)
+	copy(l.buf, items)
+	l.head = 0
+	l.size = len(items)
+}
+
+// normalizeRange приводит индексы start/stop в стиле Redis (включительно,
+// отрицательные от конца) к полуинтервалу [from, to) в пределах длины
+func normalizeRange(start, stop, length int) (int, int) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+// getList возвращает существующий список или nil. Вызывается под блокировкой.
+func (s *Storage) getList(key string) (*ListCollection, error) {
+	obj, err := s.lookupType(key, TypeList)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return obj.value.(*ListCollection), nil
+}
+
+// writeList возвращает список для изменения; create создаёт отсутствующий.
+// Вызывается под блокировкой на запись.
+func (s *Storage) writeList(key string, create bool) (*ListCollection, error) {
+	obj, err := s.lookupWriteType(key, TypeList)
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil {
+		return obj.value.(*ListCollection), nil
+	}
+	if !create {
+		return nil, nil
+	}
+
+	list := newListCollection()
+	s.put(key, &object{typ: TypeList, value: list})
+	return list, nil
+}
+
+// dropEmptyList удаляет ключ, если в списке не осталось элементов
+func (s *Storage) dropEmptyList(key string, list *ListCollection) {
+	if list.Len() == 0 {
+		s.remove(key)
+	}
+}
+
+// Push добавляет элементы в начало или конец списка и возвращает его длину.
+// При onlyExisting список не создаётся (LPUSHX/RPUSHX).
+func (s *Storage) Push(key string, end ListEnd, values []string, onlyExisting bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.writeList(key, !onlyExisting)
+	if err != nil || list == nil {
+		return 0, err
+	}
+
+	for _, value := range values {
+		list.push(end, value)
+	}
+	return list.Len(), nil
+}
+
+// Pop снимает до count элементов с указанного конца списка
+func (s *Storage) Pop(key string, end ListEnd, count int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.writeList(key, false)
+	if err != nil || list == nil {
+		return nil, err
+	}
+
+	if count > list.Len() {
+		count = list.Len()
+	}
+	result := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		result = append(result, list.pop(end))
+	}
+
+	s.dropEmptyList(key, list)
+	return result, nil
+}
+
+func (s *Storage) LLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.getList(key)
+	if err != nil || list == nil {
+		return 0, err
+	}
+	return list.Len(), nil
+}
+
+func (s *Storage) LRange(key string, start, stop int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.getList(key)
+	if err != nil || list == nil {
+		return nil, err
+	}
+
+	from, to := normalizeRange(start, stop, list.Len())
+	return list.items(from, to), nil
+}
+
+func (s *Storage) LIndex(key string, index int) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.getList(key)
+	if err != nil || list == nil {
+		return "", false, err
+	}
+
+	if index < 0 {
+		index += list.Len()
+	}
+	if index < 0 || index >= list.Len() {
+		return "", false, nil
+	}
+	return list.at(index), true, nil
+}
+
+func (s *Storage) LSet(key string, index int, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.writeList(key, false)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		return ErrNoSuchKey
+	}
+
+	if index < 0 {
+		index += list.Len()
+	}
+	if index < 0 || index >= list.Len() {
+		return ErrIndexOutOfRange
+	}
+	list.set(index, value)
+	return nil
+}
+
+// LInsert вставляет value до или после первого вхождения pivot.
+// Возвращает новую длину, -1 если pivot не найден и 0 если ключа нет.
+func (s *Storage) LInsert(key string, before bool, pivot, value string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.writeList(key, false)
+	if err != nil || list == nil {
+		return 0, err
+	}
+
+	items := list.items(0, list.Len())
+	for i, item := range items {
+		if item != pivot {
+			continue
+		}
+		if !before {
+			i++
+		}
+		items = append(items[:i], append([]string{value}, items[i:]...)...)
+		list.reset(items)
+		return list.Len(), nil
+	}
+	return -1, nil
+}
+
+// LRem удаляет count вхождений value: с начала при count > 0,
+// с конца при count < 0 и все при count == 0
+func (s *Storage) LRem(key string, count int, value string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.writeList(key, false)
+	if err != nil || list == nil {
+		return 0, err
+	}
+
+	items := list.items(0, list.Len())
+	keep := make([]bool, len(items))
+	removed := 0
+	// Модуль в uint: -count переполняется при count == math.MinInt
+	limit := uint(count)
+	if count < 0 {
+		limit = -limit
+	}
+
+	for i := range items {
+		idx := i
+		if count < 0 {
+			idx = len(items) - 1 - i
+		}
+		if items[idx] == value && (limit == 0 || uint(removed) < limit) {
+			removed++
+			continue
+		}
+		keep[idx] = true
+	}
+
+	if removed > 0 {
+		result := make([]string, 0, len(items)-removed)
+		for i, item := range items {
+			if keep[i] {
+				result = append(result, item)
+			}
+		}
+		list.reset(result)
+		s.dropEmptyList(key, list)
+	}
+	return removed, nil
+}
+
+func (s *Storage) LTrim(key string, start, stop int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.writeList(key, false)
+	if err != nil || list == nil {
+		return err
+	}
+
+	from, to := normalizeRange(start, stop, list.Len())
+	list.reset(list.items(from, to))
+	s.dropEmptyList(key, list)
+	return nil
+}
+
+// LPos ищет позиции элемента. rank задаёт, с какого по счёту совпадения
+// начинать (отрицательный — поиск с конца), count — сколько позиций вернуть
+// (0 — все), maxlen — сколько элементов просмотреть (0 — весь список).
+func (s *Storage) LPos(key, element string, rank, count, maxlen int) ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, err := s.getList(key)
+	if err != nil || list == nil {
+		return nil, err
+	}
+
+	var result []int
+	skip := rank - 1
+	if rank < 0 {
+		skip = -rank - 1
+	}
+	length := list.Len()
+	for i := 0; i < length; i++ {
+		if maxlen > 0 && i >= maxlen {
+			break
+		}
+		idx := i
+		if rank < 0 {
+			idx = length - 1 - i
+		}
+		if list.at(idx) != element {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		result = append(result, idx)
+		if count > 0 && len(result) == count {
+			break
+		}
+	}
+	return result, nil
+}
+
+// LMove атомарно переносит элемент с одного конца source на конец destination
+func (s *Storage) LMove(source, destination string, from, to ListEnd) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := s.writeList(source, false)
+	if err != nil || src == nil {
+		return "", false, err
+	}
+	// Тип приёмника проверяется до изменения источника
+	if _, err := s.lookupWriteType(destination, TypeList); err != nil {
+		return "", false, err
+	}
+
+	value := src.pop(from)
+	s.dropEmptyList(source, src)
+
+	dst, _ := s.writeList(destination, true)
+	dst.push(to, value)
+	return value, true, nil
+}
+
+// clone возвращает независимую копию списка
+func (l *ListCollection) clone() *ListCollection {
+	return &ListCollection{buf: slices.Clone(l.buf), head: l.head, size: l.size}
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// newTestList создаёт список key с элементами items
+func newTestList(t *testing.T, s *Storage, key string, items ...string) {
+	t.Helper()
+	if _, err := s.Push(key, ListRight, items, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLRange(t *testing.T) {
+	s := newTestStorage(t)
+	newTestList(t, s, "l", "a", "b", "c", "d")
+
+	tests := []struct {
+		start, stop int
+		want        []string
+	}{
+		{0, -1, []string{"a", "b", "c", "d"}},
+		{1, 2, []string{"b", "c"}},
+		{-2, -1, []string{"c", "d"}},
+		{-100, 100, []string{"a", "b", "c", "d"}},
+		{math.MinInt, math.MaxInt, []string{"a", "b", "c", "d"}},
+		{3, 1, nil},
+		{4, 10, nil},
+		{math.MaxInt, math.MaxInt, nil},
+		{math.MinInt, math.MinInt, nil},
+	}
+	for _, tt := range tests {
+		got, err := s.LRange("l", tt.start, tt.stop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tt.want) || !slices.Equal(got, tt.want) {
+			t.Errorf("LRange(%d, %d) = %q, want %q", tt.start, tt.stop, got, tt.want)
+		}
+	}
+}
+
+func TestLIndexAndLSet(t *testing.T) {
+	s := newTestStorage(t)
+	newTestList(t, s, "l", "a", "b", "c")
+
+	tests := []struct {
+		index int
+		want  string
+		found bool
+	}{
+		{0, "a", true},
+		{-1, "c", true},
+		{2, "c", true},
+		{3, "", false},
+		{-4, "", false},
+		{math.MinInt, "", false},
+		{math.MaxInt, "", false},
+	}
+	for _, tt := range tests {
+		got, found, err := s.LIndex("l", tt.index)
+		if err != nil || got != tt.want || found != tt.found {
+			t.Errorf("LIndex(%d) = %q, %v, %v; want %q, %v", tt.index, got, found, err, tt.want, tt.found)
+		}
+	}
+
+	if err := s.LSet("l", -1, "z"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LSet("l", math.MinInt, "z"); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("LSet(MinInt) err = %v, want ErrIndexOutOfRange", err)
+	}
+	if err := s.LSet("missing", 0, "z"); !errors.Is(err, ErrNoSuchKey) {
+		t.Errorf("LSet on missing key err = %v, want ErrNoSuchKey", err)
+	}
+	if got, _ := s.LRange("l", 0, -1); !slices.Equal(got, []string{"a", "b", "z"}) {
+		t.Errorf("list = %q after LSet", got)
+	}
+}
+
+func TestLRem(t *testing.T) {
+	tests := []struct {
+		count   int
+		removed int
+		want    []string
+	}{
+		{0, 3, []string{"b", "c"}},
+		{2, 2, []string{"b", "x", "c"}},
+		{-2, 2, []string{"x", "b", "c"}},
+		{10, 3, []string{"b", "c"}},
+		{math.MinInt, 3, []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		s := newTestStorage(t)
+		newTestList(t, s, "l", "x", "b", "x", "x", "c")
+
+		removed, err := s.LRem("l", tt.count, "x")
+		if err != nil || removed != tt.removed {
+			t.Errorf("LRem(%d) = %d, %v; want %d", tt.count, removed, err, tt.removed)
+		}
+		if got, _ := s.LRange("l", 0, -1); !slices.Equal(got, tt.want) {
+			t.Errorf("LRem(%d) left %q, want %q", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestEmptiedListIsRemoved(t *testing.T) {
+	tests := []struct {
+		name  string
+		empty func(s *Storage) error
+	}{
+		{"Pop", func(s *Storage) error { _, err := s.Pop("l", ListLeft, 10); return err }},
+		{"LTrim", func(s *Storage) error { return s.LTrim("l", 5, 10) }},
+		{"LRem", func(s *Storage) error { _, err := s.LRem("l", 0, "a"); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			newTestList(t, s, "l", "a", "a")
+			if err := tt.empty(s); err != nil {
+				t.Fatal(err)
+			}
+			if s.Exists("l") {
+				t.Error("empty list still exists")
+			}
+		})
+	}
+}
+
+func TestPushWrapsRingBuffer(t *testing.T) {
+	s := newTestStorage(t)
+	var want []string
+	for i := range 100 {
+		v := string(rune('a' + i%26))
+		if i%2 == 0 {
+			s.Push("l", ListLeft, []string{v}, false)
+			want = append([]string{v}, want...)
+		} else {
+			s.Push("l", ListRight, []string{v}, false)
+			want = append(want, v)
+		}
+	}
+	if got, _ := s.LRange("l", 0, -1); !slices.Equal(got, want) {
+		t.Errorf("LRange = %q, want %q", got, want)
+	}
+	if n, _ := s.Push("missing", ListLeft, []string{"a"}, true); n != 0 || s.Exists("missing") {
+		t.Error("Push with onlyExisting created a list")
+	}
+}
+
+func TestLPos(t *testing.T) {
+	s := newTestStorage(t)
+	newTestList(t, s, "l", "a", "b", "a", "c", "a")
+
+	tests := []struct {
+		rank, count, maxlen int
+		want                []int
+	}{
+		{1, 1, 0, []int{0}},
+		{2, 1, 0, []int{2}},
+		{-1, 1, 0, []int{4}},
+		{1, 0, 0, []int{0, 2, 4}},
+		{-1, 0, 0, []int{4, 2, 0}},
+		{1, 0, 3, []int{0, 2}},
+		{4, 1, 0, nil},
+	}
+	for _, tt := range tests {
+		got, err := s.LPos("l", "a", tt.rank, tt.count, tt.maxlen)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("LPos(rank %d, count %d, maxlen %d) = %v, %v; want %v",
+				tt.rank, tt.count, tt.maxlen, got, err, tt.want)
+		}
+	}
+}
+
+func TestLMove(t *testing.T) {
+	s := newTestStorage(t)
+	newTestList(t, s, "src", "a", "b", "c")
+
+	// Перенос в тот же список вращает его
+	if v, ok, err := s.LMove("src", "src", ListLeft, ListRight); err != nil || !ok || v != "a" {
+		t.Fatalf("LMove = %q, %v, %v", v, ok, err)
+	}
+	if got, _ := s.LRange("src", 0, -1); !slices.Equal(got, []string{"b", "c", "a"}) {
+		t.Errorf("rotated list = %q", got)
+	}
+
+	s.Set("str", "v", 0)
+	if _, _, err := s.LMove("src", "str", ListLeft, ListRight); !errors.Is(err, ErrWrongType) {
+		t.Errorf("LMove to string err = %v, want ErrWrongType", err)
+	}
+	if n, _ := s.LLen("src"); n != 3 {
+		t.Errorf("source changed by failed LMove: len %d", n)
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+)
+
+// MaxRandomCount наибольшее число элементов с повторами, которое можно
+// запросить у SRANDMEMBER и HRANDFIELD отрицательным count
+const MaxRandomCount = 1 << 24
+
+const (
+	// sampleListLimit до такого размера выборка делается по списку всех
+	// ключей: он короток, а выбор строго равновероятен
+	sampleListLimit = 128
+	// sampleFairWindow из стольких подряд идущих при обходе ключей
+	// randomKey выбирает один, сглаживая неравномерность начала обхода
+	sampleFairWindow = 16
+)
+
+// randomKey возвращает случайный ключ непустого m за O(1). Обход map в Go
+// начинается со случайной позиции, но ключи после пустых ячеек выпадают
+// чаще, поэтому, как dictGetFairRandomKey в Redis, ключ выбирается среди
+// нескольких первых. Выбор и так не строго равновероятен: отдельные ключи
+// выпадают в разы чаще других, как и в Redis.
+func randomKey[V any](m map[string]V) string {
+	var window [sampleFairWindow]string
+	n := 0
+	for key := range m {
+		window[n] = key
+		n++
+		if n == len(window) {
+			break
+		}
+	}
+	return window[rand.IntN(n)]
+}
+
+// sampleKeys возвращает count различных случайных ключей m, count <= len(m).
+// Малая доля большого m набирается повторными randomKey без копирования
+// всех ключей.
+func sampleKeys[V any](m map[string]V, count int) []string {
+	if len(m) <= sampleListLimit || count*3 >= len(m) {
+		return sampleSlice(slices.Collect(maps.Keys(m)), count)
+	}
+
+	picked := make(map[string]struct{}, count)
+	keys := make([]string, 0, count)
+	for len(keys) < count {
+		key := randomKey(m)
+		if _, dup := picked[key]; !dup {
+			picked[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// randomKeys возвращает count случайных ключей непустого m с повторами
+func randomKeys[V any](m map[string]V, count int) []string {
+	if len(m) <= sampleListLimit || count >= len(m) {
+		return pickSlice(slices.Collect(maps.Keys(m)), count)
+	}
+	keys := make([]string, count)
+	for i := range keys {
+		keys[i] = randomKey(m)
+	}
+	return keys
+}
+
+// sampleSlice частично перемешивает items и возвращает count первых
+// элементов, count <= len(items)
+func sampleSlice(items []string, count int) []string {
+	for i := range count {
+		j := i + rand.IntN(len(items)-i)
+		items[i], items[j] = items[j], items[i]
+	}
+	return items[:count]
+}
+
+// pickSlice возвращает count случайных элементов непустого items с повторами
+func pickSlice(items []string, count int) []string {
+	picked := make([]string, count)
+	for i := range picked {
+		picked[i] = items[rand.IntN(len(items))]
+	}
+	return picked
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"strconv"
+	"testing"
+)
+
+// newSampleMap возвращает map из n ключей "0".."n-1"
+func newSampleMap(n int) map[string]struct{} {
+	m := make(map[string]struct{}, n)
+	for i := range n {
+		m[strconv.Itoa(i)] = struct{}{}
+	}
+	return m
+}
+
+func TestSampleKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		size, count int
+	}{
+		{"single", 1, 1},
+		{"whole small map", 10, 10},
+		{"part of small map", 100, 7},
+		{"large share of large map", 1000, 400},
+		{"small share of large map", 10000, 50},
+		{"none", 1000, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newSampleMap(tt.size)
+			keys := sampleKeys(m, tt.count)
+			if len(keys) != tt.count {
+				t.Fatalf("got %d keys, want %d", len(keys), tt.count)
+			}
+			seen := make(map[string]bool, len(keys))
+			for _, key := range keys {
+				if _, ok := m[key]; !ok || seen[key] {
+					t.Fatalf("key %q is unknown or repeated", key)
+				}
+				seen[key] = true
+			}
+		})
+	}
+}
+
+func TestRandomKeys(t *testing.T) {
+	for _, size := range []int{1, 10, 1000} {
+		m := newSampleMap(size)
+		for _, count := range []int{1, 5, 2 * size} {
+			keys := randomKeys(m, count)
+			if len(keys) != count {
+				t.Fatalf("size %d: got %d keys, want %d", size, len(keys), count)
+			}
+			for _, key := range keys {
+				if _, ok := m[key]; !ok {
+					t.Fatalf("size %d: unknown key %q", size, key)
+				}
+			}
+		}
+	}
+}
+
+// TestRandomKeyReachesAll проверяет, что выбор среди первых ключей обхода
+// не оставляет ключей, которые не выпадают никогда
+func TestRandomKeyReachesAll(t *testing.T) {
+	m := newSampleMap(200)
+	seen := make(map[string]bool, len(m))
+	for range 200000 {
+		seen[randomKey(m)] = true
+	}
+	if len(seen) != len(m) {
+		t.Errorf("randomKey returned %d of %d keys", len(seen), len(m))
+	}
+}
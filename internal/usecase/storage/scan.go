@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// scanHash положение элемента в порядке обхода SCAN. Хэш не зависит от
+// состояния таблицы, поэтому курсор остаётся корректным при росте и
+// сжатии коллекции.
+func scanHash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// scanMap выбирает не менее count ключей с хэшем не меньше cursor в порядке
+// возрастания хэша и возвращает курсор следующего вызова (0 — обход
+// завершён). Ключи с одинаковым хэшем всегда попадают в один ответ, поэтому
+// любой ключ, существовавший всё время обхода, будет возвращён.
+//
+// Каждый вызов хэширует и сортирует всю коллекцию, то есть стоит
+// O(N log N), поэтому так обходятся только небольшие коллекции (см. scanOrder).
+func scanMap[V any](m map[string]V, cursor uint64, count int) ([]string, uint64) {
+	type hashed struct {
+		hash uint64
+		key  string
+	}
+
+	candidates := make([]hashed, 0, len(m))
+	for key := range m {
+		if h := scanHash(key); h >= cursor {
+			candidates = append(candidates, hashed{h, key})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].hash < candidates[j].hash
+	})
+
+	n := min(max(count, 1), len(candidates))
+	for n > 0 && n < len(candidates) && candidates[n].hash == candidates[n-1].hash {
+		n++
+	}
+
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = candidates[i].key
+	}
+
+	if n == len(candidates) || candidates[n-1].hash == ^uint64(0) {
+		return keys, 0
+	}
+	return keys, candidates[n-1].hash + 1
+}
+
+// scanMinBits число старших битов хэша, по которым раскладываются ключи
+// пустого индекса
+const scanMinBits = 4
+
+// scanEmptyVisits во столько раз больше count пустых корзин просматривает
+// один вызов scan, прежде чем вернуть пустой ответ, как в Redis
+const scanEmptyVisits = 10
+
+type scanEntry struct {
+	hash uint64
+	key  string
+}
+
+// scanIndex раскладывает ключи по корзинам по старшим битам scanHash, так
+// что корзины идут в порядке возрастания хэша. Число корзин следует за
+// числом ключей, а курсор — это сам хэш, поэтому изменение числа корзин
+// между вызовами SCAN не теряет и не повторяет ключи.
+type scanIndex struct {
+	buckets [][]scanEntry
+	bits    uint
+	size    int
+}
+
+func newScanIndex() *scanIndex {
+	return &scanIndex{buckets: make([][]scanEntry, 1<<scanMinBits), bits: scanMinBits}
+}
+
+func (x *scanIndex) bucket(hash uint64) int {
+	return int(hash >> (64 - x.bits))
+}
+
+// add добавляет ключ, которого ещё нет в индексе
+func (x *scanIndex) add(key string) {
+	e := scanEntry{scanHash(key), key}
+	b := x.bucket(e.hash)
+	x.buckets[b] = append(x.buckets[b], e)
+	x.size++
+	if x.size > 2*len(x.buckets) {
+		x.resize(x.bits + 1)
+	}
+}
+
+func (x *scanIndex) remove(key string) {
+	b := x.bucket(scanHash(key))
+	bucket := x.buckets[b]
+	for i, e := range bucket {
+		if e.key == key {
+			last := len(bucket) - 1
+			bucket[i] = bucket[last]
+			bucket[last] = scanEntry{}
+			x.buckets[b] = bucket[:last]
+			x.size--
+			break
+		}
+	}
+	if x.bits > scanMinBits && x.size < len(x.buckets)/8 {
+		x.resize(x.bits - 1)
+	}
+}
+
+// resize перекладывает ключи в 1<<bits корзин
+func (x *scanIndex) resize(bits uint) {
+	old := x.buckets
+	x.buckets = make([][]scanEntry, 1<<bits)
+	x.bits = bits
+	for _, bucket := range old {
+		for _, e := range bucket {
+			b := x.bucket(e.hash)
+			x.buckets[b] = append(x.buckets[b], e)
+		}
+	}
+}
+
+// scan возвращает ключи с хэшем не меньше cursor из корзин, начиная с
+// корзины курсора, пока их не наберётся count или не встретится слишком
+// много пустых корзин, и курсор следующего вызова (0 — обход завершён).
+// Корзина всегда возвращается целиком, поэтому вызов стоит O(count), а не
+// O(N).
+func (x *scanIndex) scan(cursor uint64, count int) ([]string, uint64) {
+	count = max(count, 1)
+	var keys []string
+	empty := 0
+	b := x.bucket(cursor)
+	for ; b < len(x.buckets) && len(keys) < count && empty < scanEmptyVisits*count; b++ {
+		if len(x.buckets[b]) == 0 {
+			empty++
+		}
+		for _, e := range x.buckets[b] {
+			if e.hash >= cursor {
+				keys = append(keys, e.key)
+			}
+		}
+	}
+	if b == len(x.buckets) {
+		return keys, 0
+	}
+	return keys, uint64(b) << (64 - x.bits)
+}
+
+// collectionScanLimit до такого размера элементы множества, хэша или
+// сортированного множества обходятся через scanMap, больше — по scanIndex
+const collectionScanLimit = 128
+
+// scanOrder порядок обхода элементов коллекции m в SSCAN, HSCAN и ZSCAN.
+// Пока коллекция мала, индекса нет и обход сортирует все хэши; при росте
+// сверх collectionScanLimit строится scanIndex и дальше поддерживается при
+// каждом изменении, как индекс пространства ключей. Курсоры обоих способов —
+// это хэш, с которого продолжать, так что смена способа посреди обхода не
+// теряет элементов.
+type scanOrder[V any] struct {
+	index *scanIndex
+}
+
+// add учитывает ключ, только что добавленный в m
+func (o *scanOrder[V]) add(m map[string]V, key string) {
+	switch {
+	case o.index != nil:
+		o.index.add(key)
+	case len(m) > collectionScanLimit:
+		o.reset(m)
+	}
+}
+
+// remove учитывает ключ, удалённый из m
+func (o *scanOrder[V]) remove(key string) {
+	if o.index != nil {
+		o.index.remove(key)
+	}
+}
+
+// reset строит порядок заново по всем ключам m
+func (o *scanOrder[V]) reset(m map[string]V) {
+	o.index = nil
+	if len(m) <= collectionScanLimit {
+		return
+	}
+	o.index = newScanIndex()
+	for key := range m {
+		o.index.add(key)
+	}
+}
+
+// scan возвращает очередную порцию ключей m и курсор следующего вызова
+func (o *scanOrder[V]) scan(m map[string]V, cursor uint64, count int) ([]string, uint64) {
+	if o.index == nil {
+		return scanMap(m, cursor, count)
+	}
+	return o.index.scan(cursor, count)
+}
+
+// Scan возвращает очередную порцию ключей начиная с cursor и курсор
+// следующего вызова. Непустой typ оставляет только ключи этого типа
+// в том виде, в котором его отдаёт TYPE.
+func (s *Storage) Scan(cursor uint64, count int, typ string) ([]string, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys, next := s.keyOrder.scan(cursor, count)
+
+	result := keys[:0]
+	for _, key := range keys {
+		obj := s.lookup(key)
+		if obj != nil && (typ == "" || strings.EqualFold(obj.typ.String(), typ)) {
+			result = append(result, key)
+		}
+	}
+	return result, next
+}
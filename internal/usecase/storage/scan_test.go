@@ -0,0 +1,253 @@
+package storage
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestScanMap(t *testing.T) {
+	m := make(map[string]int)
+	for i := range 100 {
+		m[strconv.Itoa(i)] = i
+	}
+
+	for _, count := range []int{0, 1, 7, 1000} {
+		seen := make(map[string]int)
+		cursor := uint64(0)
+		for {
+			var keys []string
+			keys, cursor = scanMap(m, cursor, count)
+			for _, key := range keys {
+				seen[key]++
+			}
+			if cursor == 0 {
+				break
+			}
+		}
+		if len(seen) != len(m) {
+			t.Errorf("count %d: scanned %d of %d keys", count, len(seen), len(m))
+		}
+		for key, n := range seen {
+			if n != 1 {
+				t.Errorf("count %d: key %s returned %d times", count, key, n)
+			}
+		}
+	}
+}
+
+// TestScanIndexResize проверяет, что ключи, существовавшие весь обход,
+// возвращаются ровно один раз, пока индекс растёт и сжимается
+func TestScanIndexResize(t *testing.T) {
+	tests := []struct {
+		name          string
+		initial       int
+		stableEvery   int
+		added         int
+		removePerCall int
+		wantShrink    bool
+	}{
+		{"growing", 100, 2, 20, 0, false},
+		{"shrinking", 5000, 10, 0, 200, true},
+		{"churn", 1000, 2, 50, 50, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := newScanIndex()
+			var stable, transient []string
+			for i := range tt.initial {
+				key := "k" + strconv.Itoa(i)
+				x.add(key)
+				if i%tt.stableEvery == 0 {
+					stable = append(stable, key)
+				} else {
+					transient = append(transient, key)
+				}
+			}
+
+			bits := x.bits
+			seen := make(map[string]int)
+			next := 0
+			cursor := uint64(0)
+			for {
+				var keys []string
+				keys, cursor = x.scan(cursor, 10)
+				for _, key := range keys {
+					seen[key]++
+				}
+				if cursor == 0 {
+					break
+				}
+				for range tt.added {
+					x.add("new" + strconv.Itoa(next))
+					next++
+				}
+				for range min(tt.removePerCall, len(transient)) {
+					x.remove(transient[len(transient)-1])
+					transient = transient[:len(transient)-1]
+				}
+			}
+
+			if tt.wantShrink && x.bits >= bits {
+				t.Errorf("index kept %d bits after removals", x.bits)
+			}
+			for _, key := range stable {
+				if seen[key] != 1 {
+					t.Fatalf("stable key %s returned %d times", key, seen[key])
+				}
+			}
+			for key, n := range seen {
+				if n > 1 {
+					t.Fatalf("key %s returned %d times", key, n)
+				}
+			}
+		})
+	}
+
+	x := newScanIndex()
+	for i := range 10000 {
+		x.add(strconv.Itoa(i))
+	}
+	for i := range 10000 {
+		x.remove(strconv.Itoa(i))
+	}
+	if x.size != 0 || x.bits != scanMinBits {
+		t.Errorf("empty index has size %d and %d bits", x.size, x.bits)
+	}
+}
+
+func TestScanType(t *testing.T) {
+	s := newTestStorage(t)
+	for i := range 50 {
+		s.Set("s"+strconv.Itoa(i), "v", 0)
+		s.SAdd("set"+strconv.Itoa(i), []string{"a"})
+	}
+
+	var got []string
+	cursor := uint64(0)
+	for {
+		var keys []string
+		keys, cursor = s.Scan(cursor, 5, "SET")
+		got = append(got, keys...)
+		if cursor == 0 {
+			break
+		}
+	}
+	slices.Sort(got)
+	if len(got) != 50 || got[0] != "set0" {
+		t.Errorf("SCAN TYPE set returned %d keys: %q", len(got), got)
+	}
+}
+
+// TestCollectionScan проверяет обход элементов множества, хэша и
+// сортированного множества, когда коллекция посреди обхода вырастает
+// сверх collectionScanLimit и начинает обходиться по scanIndex
+func TestCollectionScan(t *testing.T) {
+	tests := []struct {
+		name  string
+		add   func(s *Storage, members []string)
+		del   func(s *Storage, member string)
+		scan  func(s *Storage, cursor uint64) ([]string, uint64)
+		index func(s *Storage) *scanIndex
+	}{
+		{
+			name: "set",
+			add:  func(s *Storage, members []string) { s.SAdd("k", members) },
+			del:  func(s *Storage, member string) { s.SRem("k", []string{member}) },
+			scan: func(s *Storage, cursor uint64) ([]string, uint64) {
+				members, next, _ := s.SScan("k", cursor, 10)
+				return members, next
+			},
+			index: func(s *Storage) *scanIndex {
+				set, _ := s.getSet("k")
+				return set.order.index
+			},
+		},
+		{
+			name: "hash",
+			add:  func(s *Storage, members []string) { s.HSet("k", members, members) },
+			del:  func(s *Storage, member string) { s.HDelete("k", member) },
+			scan: func(s *Storage, cursor uint64) ([]string, uint64) {
+				fields, _, next, _ := s.HScan("k", cursor, 10)
+				return fields, next
+			},
+			index: func(s *Storage) *scanIndex {
+				coll, _ := s.getCollection("k")
+				return coll.order.index
+			},
+		},
+		{
+			name: "zset",
+			add: func(s *Storage, members []string) {
+				var zm []ZMember
+				for _, m := range members {
+					zm = append(zm, ZMember{Member: m})
+				}
+				s.ZAdd("k", ZAddOptions{}, zm)
+			},
+			del: func(s *Storage, member string) { s.ZRem("k", []string{member}) },
+			scan: func(s *Storage, cursor uint64) ([]string, uint64) {
+				members, next, _ := s.ZScan("k", cursor, 10)
+				var result []string
+				for _, m := range members {
+					result = append(result, m.Member)
+				}
+				return result, next
+			},
+			index: func(s *Storage) *scanIndex {
+				zset, _ := s.getZSet("k")
+				return zset.order.index
+			},
+		},
+	}
+
+	members := func(prefix string, n int) []string {
+		result := make([]string, n)
+		for i := range result {
+			result[i] = prefix + strconv.Itoa(i)
+		}
+		return result
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			stable := members("s", 100)
+			tt.add(s, stable)
+			tt.add(s, []string{"gone"})
+			if tt.index(s) != nil {
+				t.Fatal("small collection has a scan index")
+			}
+
+			seen := make(map[string]int)
+			cursor := uint64(0)
+			for i := 0; ; i++ {
+				var keys []string
+				keys, cursor = tt.scan(s, cursor)
+				for _, key := range keys {
+					seen[key]++
+				}
+				if cursor == 0 {
+					break
+				}
+				if i == 0 {
+					tt.add(s, members("new", 1000))
+					tt.del(s, "gone")
+				}
+			}
+
+			if x := tt.index(s); x == nil || x.size != 1100 {
+				t.Fatal("large collection has no scan index of all 1100 members")
+			}
+			for _, key := range stable {
+				if seen[key] != 1 {
+					t.Fatalf("stable member %s returned %d times", key, seen[key])
+				}
+			}
+			for key, n := range seen {
+				if n > 1 {
+					t.Fatalf("member %s returned %d times", key, n)
+				}
+			}
+		})
+	}
+}
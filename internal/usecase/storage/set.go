@@ -0,0 +1,393 @@
+package storage
+
+import (
+	"maps"
+	"sort"
+)
+
+// SetOp операция над несколькими множествами
+type SetOp int
+
+const (
+	SetInter SetOp = iota
+	SetUnion
+	SetDiff
+)
+
+// SetCollection неупорядоченное множество уникальных строк
+type SetCollection struct {
+	members map[string]struct{}
+	order   scanOrder[struct{}]
+}
+
+func newSetCollection() *SetCollection {
+	return &SetCollection{members: make(map[string]struct{})}
+}
+
+func (c *SetCollection) Len() int {
+	return len(c.members)
+}
+
+// add добавляет элемент и сообщает, что его ещё не было
+func (c *SetCollection) add(member string) bool {
+	if _, exists := c.members[member]; exists {
+		return false
+	}
+	c.members[member] = struct{}{}
+	c.order.add(c.members, member)
+	return true
+}
+
+// remove удаляет элемент и сообщает, что он был
+func (c *SetCollection) remove(member string) bool {
+	if _, exists := c.members[member]; !exists {
+		return false
+	}
+	delete(c.members, member)
+	c.order.remove(member)
+	return true
+}
+
+func (c *SetCollection) list() []string {
+	result := make([]string, 0, len(c.members))
+	for member := range c.members {
+		result = append(result, member)
+	}
+	return result
+}
+
+// getSet возвращает существующее множество или nil. Вызывается под блокировкой.
+func (s *Storage) getSet(key string) (*SetCollection, error) {
+	obj, err := s.lookupType(key, TypeSet)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return obj.value.(*SetCollection), nil
+}
+
+// writeSet возвращает множество для изменения; create создаёт отсутствующее.
+// Вызывается под блокировкой на запись.
+func (s *Storage) writeSet(key string, create bool) (*SetCollection, error) {
+	obj, err := s.lookupWriteType(key, TypeSet)
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil {
+		return obj.value.(*SetCollection), nil
+	}
+	if !create {
+		return nil, nil
+	}
+
+	set := newSetCollection()
+	s.put(key, &object{typ: TypeSet, value: set})
+	return set, nil
+}
+
+// dropEmptySet удаляет ключ, если во множестве не осталось элементов
+func (s *Storage) dropEmptySet(key string, set *SetCollection) {
+	if set.Len() == 0 {
+		s.remove(key)
+	}
+}
+
+// SAdd добавляет элементы и возвращает число новых
+func (s *Storage) SAdd(key string, members []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.writeSet(key, true)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, member := range members {
+		if set.add(member) {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// SRem удаляет элементы и возвращает число удалённых
+func (s *Storage) SRem(key string, members []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.writeSet(key, false)
+	if err != nil || set == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if set.remove(member) {
+			removed++
+		}
+	}
+
+	s.dropEmptySet(key, set)
+	return removed, nil
+}
+
+func (s *Storage) SMembers(key string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getSet(key)
+	if err != nil || set == nil {
+		return nil, err
+	}
+	return set.list(), nil
+}
+
+// SScan возвращает очередную порцию элементов множества начиная с cursor
+// и курсор следующего вызова (0 — обход завершён)
+func (s *Storage) SScan(key string, cursor uint64, count int) ([]string, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getSet(key)
+	if err != nil || set == nil {
+		return nil, 0, err
+	}
+	members, next := set.order.scan(set.members, cursor, count)
+	return members, next, nil
+}
+
+// SIsMember проверяет принадлежность каждого из элементов множеству
+func (s *Storage) SIsMember(key string, members []string) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getSet(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]bool, len(members))
+	if set == nil {
+		return result, nil
+	}
+	for i, member := range members {
+		_, result[i] = set.members[member]
+	}
+	return result, nil
+}
+
+func (s *Storage) SCard(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getSet(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+	return set.Len(), nil
+}
+
+// SPop удаляет и возвращает до count случайных элементов
+func (s *Storage) SPop(key string, count int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set, err := s.writeSet(key, false)
+	if err != nil || set == nil {
+		return nil, err
+	}
+
+	result := sampleKeys(set.members, min(count, set.Len()))
+	for _, member := range result {
+		set.remove(member)
+	}
+
+	s.dropEmptySet(key, set)
+	return result, nil
+}
+
+// SRandMember возвращает случайные элементы без удаления. При count >= 0
+// элементы различны, при отрицательном могут повторяться; -count не
+// должен превышать MaxRandomCount.
+func (s *Storage) SRandMember(key string, count int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getSet(key)
+	if err != nil || set == nil {
+		return nil, err
+	}
+
+	if count < 0 {
+		return randomKeys(set.members, -count), nil
+	}
+	return sampleKeys(set.members, min(count, set.Len())), nil
+}
+
+// SMove атомарно переносит элемент из source в destination
+func (s *Storage) SMove(source, destination, member string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	src, err := s.writeSet(source, false)
+	if err != nil {
+		return false, err
+	}
+	if _, err := s.lookupWriteType(destination, TypeSet); err != nil {
+		return false, err
+	}
+	if src == nil {
+		return false, nil
+	}
+	if !src.remove(member) {
+		return false, nil
+	}
+	s.dropEmptySet(source, src)
+
+	dst, _ := s.writeSet(destination, true)
+	dst.add(member)
+	return true, nil
+}
+
+// combineSets вычисляет пересечение, объединение или разность множеств.
+// Вызывается под блокировкой.
+func (s *Storage) combineSets(op SetOp, keys []string) (map[string]struct{}, error) {
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]struct{})
+	switch op {
+	case SetUnion:
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			for member := range set.members {
+				result[member] = struct{}{}
+			}
+		}
+	case SetInter:
+		intersect(sets, func(member string) bool {
+			result[member] = struct{}{}
+			return true
+		})
+	case SetDiff:
+		if sets[0] == nil {
+			return result, nil
+		}
+		for member := range sets[0].members {
+			result[member] = struct{}{}
+		}
+		for _, set := range sets[1:] {
+			if set == nil {
+				continue
+			}
+			for member := range set.members {
+				delete(result, member)
+			}
+		}
+	}
+	return result, nil
+}
+
+// getSets возвращает множества по ключам; на месте отсутствующих — nil.
+// Вызывается под блокировкой.
+func (s *Storage) getSets(keys []string) ([]*SetCollection, error) {
+	sets := make([]*SetCollection, len(keys))
+	for i, key := range keys {
+		set, err := s.getSet(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+// intersect передаёт yield элементы пересечения sets, пока yield
+// возвращает true. Отсутствующее множество делает пересечение пустым.
+func intersect(sets []*SetCollection, yield func(member string) bool) {
+	for _, set := range sets {
+		if set == nil {
+			return
+		}
+	}
+	// Перебираем самое маленькое множество
+	sorted := append([]*SetCollection(nil), sets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Len() < sorted[j].Len() })
+members:
+	for member := range sorted[0].members {
+		for _, set := range sorted[1:] {
+			if _, ok := set.members[member]; !ok {
+				continue members
+			}
+		}
+		if !yield(member) {
+			return
+		}
+	}
+}
+
+// SCombine возвращает результат операции над множествами
+func (s *Storage) SCombine(op SetOp, keys []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members, err := s.combineSets(op, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(members))
+	for member := range members {
+		result = append(result, member)
+	}
+	return result, nil
+}
+
+// SCombineStore сохраняет результат операции в destination и возвращает его размер.
+// Пустой результат удаляет destination.
+func (s *Storage) SCombineStore(op SetOp, destination string, keys []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members, err := s.combineSets(op, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	s.remove(destination)
+	if len(members) > 0 {
+		set := &SetCollection{members: members}
+		set.order.reset(members)
+		s.put(destination, &object{typ: TypeSet, value: set})
+	}
+	return len(members), nil
+}
+
+// SInterCard возвращает размер пересечения, прекращая подсчёт по достижении limit (0 — без ограничения)
+func (s *Storage) SInterCard(keys []string, limit int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets, err := s.getSets(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	intersect(sets, func(string) bool {
+		n++
+		return limit == 0 || n < limit
+	})
+	return n, nil
+}
+
+// clone возвращает независимую копию множества
+func (c *SetCollection) clone() *SetCollection {
+	copied := &SetCollection{members: maps.Clone(c.members)}
+	copied.order.reset(copied.members)
+	return copied
+}
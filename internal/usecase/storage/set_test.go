@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// newTestSet создаёт множество key из n элементов "0".."n-1"
+func newTestSet(t *testing.T, s *Storage, key string, n int) {
+	t.Helper()
+	members := make([]string, n)
+	for i := range members {
+		members[i] = strconv.Itoa(i)
+	}
+	if _, err := s.SAdd(key, members); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// distinct сообщает, что элементы items попарно различны
+func distinct(items []string) bool {
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if seen[item] {
+			return false
+		}
+		seen[item] = true
+	}
+	return true
+}
+
+func TestSRandMember(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		count    int
+		want     int
+		distinct bool
+	}{
+		{"zero", 10, 0, 0, true},
+		{"small set", 10, 3, 3, true},
+		{"whole small set", 10, 10, 10, true},
+		{"more than small set", 10, 50, 10, true},
+		{"few of large set", 1000, 5, 5, true},
+		{"most of large set", 1000, 900, 900, true},
+		{"repeats from small set", 3, -20, 20, false},
+		{"repeats from large set", 1000, -20, 20, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			newTestSet(t, s, "s", tt.size)
+
+			got, err := s.SRandMember("s", tt.count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want {
+				t.Fatalf("len = %d, want %d", len(got), tt.want)
+			}
+			if tt.distinct && !distinct(got) {
+				t.Errorf("members repeat: %q", got)
+			}
+			for _, m := range got {
+				if ok, _ := s.SIsMember("s", []string{m}); !ok[0] {
+					t.Errorf("%q is not a member", m)
+				}
+			}
+			if n, _ := s.SCard("s"); n != tt.size {
+				t.Errorf("SRANDMEMBER changed the set: %d members", n)
+			}
+		})
+	}
+
+	s := newTestStorage(t)
+	if got, err := s.SRandMember("missing", -5); err != nil || got != nil {
+		t.Errorf("SRandMember(missing) = %q, %v", got, err)
+	}
+}
+
+func TestSPop(t *testing.T) {
+	tests := []struct {
+		size, count, want int
+	}{
+		{10, 1, 1},
+		{10, 4, 4},
+		{1000, 10, 10},
+		{1000, 999, 999},
+		{10, 100, 10},
+	}
+	for _, tt := range tests {
+		s := newTestStorage(t)
+		newTestSet(t, s, "s", tt.size)
+
+		popped, err := s.SPop("s", tt.count)
+		if err != nil || len(popped) != tt.want || !distinct(popped) {
+			t.Fatalf("SPop(%d) of %d = %d members, %v", tt.count, tt.size, len(popped), err)
+		}
+		left, _ := s.SCard("s")
+		if left != tt.size-tt.want {
+			t.Errorf("%d members left, want %d", left, tt.size-tt.want)
+		}
+		if found, _ := s.SIsMember("s", popped); slices.Contains(found, true) {
+			t.Error("popped member is still in the set")
+		}
+		if left == 0 && s.Exists("s") {
+			t.Error("empty set still exists")
+		}
+	}
+}
+
+// TestRandomKeySpread проверяет, что randomKey выбирает все ключи большого
+// множества с частотами одного порядка. Строгой равновероятности, как и в
+// Redis, нет.
+func TestRandomKeySpread(t *testing.T) {
+	m := make(map[string]struct{})
+	for i := range 1000 {
+		m[strconv.Itoa(i)] = struct{}{}
+	}
+	seen := make(map[string]int)
+	for range 100000 {
+		seen[randomKey(m)]++
+	}
+	if len(seen) != len(m) {
+		t.Fatalf("%d of %d keys drawn", len(seen), len(m))
+	}
+	for key, n := range seen {
+		if n < 25 || n > 400 {
+			t.Errorf("key %s drawn %d times, expected about 100", key, n)
+		}
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	s := newTestStorage(t)
+	s.SAdd("a", []string{"1", "2", "3", "4"})
+	s.SAdd("b", []string{"3", "4", "5"})
+	s.SAdd("c", []string{"4", "6"})
+
+	tests := []struct {
+		op   SetOp
+		keys []string
+		want []string
+	}{
+		{SetInter, []string{"a", "b"}, []string{"3", "4"}},
+		{SetInter, []string{"a", "b", "c"}, []string{"4"}},
+		{SetInter, []string{"a", "missing"}, nil},
+		{SetUnion, []string{"a", "missing", "c"}, []string{"1", "2", "3", "4", "6"}},
+		{SetDiff, []string{"a", "b"}, []string{"1", "2"}},
+		{SetDiff, []string{"missing", "a"}, nil},
+	}
+	for _, tt := range tests {
+		got, err := s.SCombine(tt.op, tt.keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+		if len(got) != len(tt.want) || !slices.Equal(got, tt.want) {
+			t.Errorf("SCombine(%d, %q) = %q, want %q", tt.op, tt.keys, got, tt.want)
+		}
+	}
+
+	s.Set("str", "v", 0)
+	if _, err := s.SCombine(SetUnion, []string{"a", "str"}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("SCombine with string err = %v, want ErrWrongType", err)
+	}
+	if n, err := s.SCombineStore(SetInter, "a", []string{"b", "missing"}); err != nil || n != 0 || s.Exists("a") {
+		t.Errorf("empty SINTERSTORE = %d, %v; destination exists: %v", n, err, s.Exists("a"))
+	}
+}
+
+func TestSInterCard(t *testing.T) {
+	s := newTestStorage(t)
+	newTestSet(t, s, "a", 100)
+	newTestSet(t, s, "b", 50)
+
+	tests := []struct {
+		keys  []string
+		limit int
+		want  int
+	}{
+		{[]string{"a", "b"}, 0, 50},
+		{[]string{"a", "b"}, 10, 10},
+		{[]string{"a", "b"}, 1, 1},
+		{[]string{"a", "b"}, 1000, 50},
+		{[]string{"a", "missing"}, 0, 0},
+	}
+	for _, tt := range tests {
+		if got, err := s.SInterCard(tt.keys, tt.limit); err != nil || got != tt.want {
+			t.Errorf("SInterCard(%q, %d) = %d, %v; want %d", tt.keys, tt.limit, got, err, tt.want)
+		}
+	}
+}
+
+func TestIntersectStopsAtLimit(t *testing.T) {
+	a, b := newSetCollection(), newSetCollection()
+	for i := range 100 {
+		a.members[strconv.Itoa(i)] = struct{}{}
+		b.members[strconv.Itoa(i)] = struct{}{}
+	}
+	visited := 0
+	intersect([]*SetCollection{a, b}, func(string) bool {
+		visited++
+		return visited < 3
+	})
+	if visited != 3 {
+		t.Errorf("intersect visited %d members after yield returned false, want 3", visited)
+	}
+}
+
+func TestSMove(t *testing.T) {
+	s := newTestStorage(t)
+	s.SAdd("src", []string{"a"})
+	s.Set("str", "v", 0)
+
+	if _, err := s.SMove("src", "str", "a"); !errors.Is(err, ErrWrongType) {
+		t.Errorf("SMove to string err = %v, want ErrWrongType", err)
+	}
+	if moved, err := s.SMove("src", "dst", "missing"); err != nil || moved {
+		t.Errorf("SMove of missing member = %v, %v", moved, err)
+	}
+	if moved, err := s.SMove("src", "dst", "a"); err != nil || !moved {
+		t.Fatalf("SMove = %v, %v", moved, err)
+	}
+	if s.Exists("src") {
+		t.Error("emptied source still exists")
+	}
+	if got, _ := s.SMembers("dst"); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("destination = %q", got)
+	}
+}
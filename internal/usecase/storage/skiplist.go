@@ -0,0 +1,283 @@
+package storage
+
+import "math/rand"
+
+const (
+	skiplistMaxLevel = 32
+	skiplistP        = 0.25
+)
+
+// ScoreRange диапазон оценок; границы могут быть исключающими
+type ScoreRange struct {
+	Min, Max     float64
+	MinEx, MaxEx bool
+}
+
+func (r ScoreRange) gteMin(score float64) bool {
+	if r.MinEx {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) lteMax(score float64) bool {
+	if r.MaxEx {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+// LexBound граница лексикографического диапазона. Inf = -1 соответствует
+// "-" (меньше любой строки), Inf = 1 — "+" (больше любой строки).
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// LexRange лексикографический диапазон элементов
+type LexRange struct {
+	Min, Max LexBound
+}
+
+func (r LexRange) gteMin(member string) bool {
+	switch {
+	case r.Min.Inf < 0:
+		return true
+	case r.Min.Inf > 0:
+		return false
+	case r.Min.Exclusive:
+		return member > r.Min.Value
+	default:
+		return member >= r.Min.Value
+	}
+}
+
+func (r LexRange) lteMax(member string) bool {
+	switch {
+	case r.Max.Inf > 0:
+		return true
+	case r.Max.Inf < 0:
+		return false
+	case r.Max.Exclusive:
+		return member < r.Max.Value
+	default:
+		return member <= r.Max.Value
+	}
+}
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+// skiplist упорядочивает элементы по (score, member) и хранит на каждом
+// уровне длину перехода, что позволяет вычислять ранг за O(log n)
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skiplistNode{level: make([]skiplistLevel, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// before сообщает, стоит ли узел строго раньше пары (score, member)
+func (n *skiplistNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+func (zsl *skiplist) insert(score float64, member string) *skiplistNode {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i < zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+	return x
+}
+
+func (zsl *skiplist) deleteNode(x *skiplistNode, update []*skiplistNode) {
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+func (zsl *skiplist) delete(score float64, member string) bool {
+	update := make([]*skiplistNode, skiplistMaxLevel)
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x != nil && x.score == score && x.member == member {
+		zsl.deleteNode(x, update)
+		return true
+	}
+	return false
+}
+
+// rank возвращает позицию элемента, начиная с 1, или 0 если его нет
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.before(score, member) || (x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank возвращает узел с позицией rank (начиная с 1)
+func (zsl *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+func (zsl *skiplist) firstInScoreRange(r ScoreRange) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !r.lteMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInScoreRange(r ScoreRange) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	if x == zsl.header || !r.gteMin(x.score) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) firstInLexRange(r LexRange) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !r.lteMax(x.member) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInLexRange(r LexRange) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.member) {
+			x = x.level[i].forward
+		}
+	}
+	if x == zsl.header || !r.gteMin(x.member) {
+		return nil
+	}
+	return x
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+type scoredMember struct {
+	score  float64
+	member string
+}
+
+func compareScored(a, b scoredMember) int {
+	if c := cmp.Compare(a.score, b.score); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.member, b.member)
+}
+
+// TestSkiplistMatchesSortedSlice сверяет ранги и поиск по диапазонам с
+// отсортированным срезом после случайных вставок и удалений
+func TestSkiplistMatchesSortedSlice(t *testing.T) {
+	zsl := newSkiplist()
+	var want []scoredMember
+	for range 5000 {
+		if len(want) > 0 && rand.IntN(3) == 0 {
+			i := rand.IntN(len(want))
+			if !zsl.delete(want[i].score, want[i].member) {
+				t.Fatalf("delete of %v failed", want[i])
+			}
+			want = slices.Delete(want, i, i+1)
+			continue
+		}
+		// Небольшой разброс оценок даёт много равных, упорядоченных по элементу
+		e := scoredMember{float64(rand.IntN(50)), strconv.Itoa(rand.Int())}
+		zsl.insert(e.score, e.member)
+		i, _ := slices.BinarySearchFunc(want, e, compareScored)
+		want = slices.Insert(want, i, e)
+	}
+
+	if zsl.length != len(want) {
+		t.Fatalf("length = %d, want %d", zsl.length, len(want))
+	}
+	for i, e := range want {
+		if r := zsl.rank(e.score, e.member); r != i+1 {
+			t.Fatalf("rank(%v) = %d, want %d", e, r, i+1)
+		}
+		if n := zsl.byRank(i + 1); n.member != e.member {
+			t.Fatalf("byRank(%d) = %s, want %s", i+1, n.member, e.member)
+		}
+	}
+	if zsl.byRank(len(want)+1) != nil || zsl.rank(0, "missing") != 0 || zsl.delete(0, "missing") {
+		t.Error("lookup of a missing element succeeded")
+	}
+
+	// Обратные ссылки и хвост согласованы с прямым порядком
+	i := len(want) - 1
+	for x := zsl.tail; x != nil; x = x.backward {
+		if x.member != want[i].member {
+			t.Fatalf("backward walk at %d = %s, want %s", i, x.member, want[i].member)
+		}
+		i--
+	}
+	if i != -1 {
+		t.Errorf("backward walk stopped %d elements early", i+1)
+	}
+}
+
+func TestSkiplistScoreRange(t *testing.T) {
+	zsl := newSkiplist()
+	for i, score := range []float64{1, 2, 2, 3, 5} {
+		zsl.insert(score, strconv.Itoa(i))
+	}
+
+	tests := []struct {
+		r           ScoreRange
+		first, last string
+	}{
+		{ScoreRange{Min: 2, Max: 3}, "1", "3"},
+		{ScoreRange{Min: 2, Max: 3, MinEx: true}, "3", "3"},
+		{ScoreRange{Min: 2, Max: 3, MaxEx: true}, "1", "2"},
+		{ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, "0", "4"},
+		{ScoreRange{Min: 3.5, Max: 4}, "", ""},
+		{ScoreRange{Min: 3, Max: 2}, "", ""},
+		{ScoreRange{Min: 2, Max: 2, MinEx: true}, "", ""},
+		{ScoreRange{Min: 6, Max: 7}, "", ""},
+	}
+	for _, tt := range tests {
+		first, last := "", ""
+		if n := zsl.firstInScoreRange(tt.r); n != nil {
+			first = n.member
+		}
+		if n := zsl.lastInScoreRange(tt.r); n != nil {
+			last = n.member
+		}
+		if first != tt.first || last != tt.last {
+			t.Errorf("range %+v = %q..%q, want %q..%q", tt.r, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestSkiplistLexRange(t *testing.T) {
+	zsl := newSkiplist()
+	for _, member := range []string{"a", "b", "c", "d"} {
+		zsl.insert(0, member)
+	}
+
+	minusInf, plusInf := LexBound{Inf: -1}, LexBound{Inf: 1}
+	tests := []struct {
+		r           LexRange
+		first, last string
+	}{
+		{LexRange{minusInf, plusInf}, "a", "d"},
+		{LexRange{LexBound{Value: "b"}, LexBound{Value: "c"}}, "b", "c"},
+		{LexRange{LexBound{Value: "b", Exclusive: true}, plusInf}, "c", "d"},
+		{LexRange{minusInf, LexBound{Value: "c", Exclusive: true}}, "a", "b"},
+		{LexRange{LexBound{Value: "bb"}, LexBound{Value: "bz"}}, "", ""},
+		{LexRange{plusInf, minusInf}, "", ""},
+		{LexRange{LexBound{Value: "c"}, LexBound{Value: "b"}}, "", ""},
+	}
+	for _, tt := range tests {
+		first, last := "", ""
+		if n := zsl.firstInLexRange(tt.r); n != nil {
+			first = n.member
+		}
+		if n := zsl.lastInLexRange(tt.r); n != nil {
+			last = n.member
+		}
+		if first != tt.first || last != tt.last {
+			t.Errorf("range %+v = %q..%q, want %q..%q", tt.r, first, last, tt.first, tt.last)
+		}
+	}
+}
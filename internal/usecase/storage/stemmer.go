@@ -0,0 +1,259 @@
+package storage
+
+// porter состояние стеммера Портера: слово b[0..k], j — конец основы
+// после успешного ends
+type porter struct {
+	b    []byte
+	k, j int
+}
+
+// Stem приводит английское слово в нижнем регистре к основе по алгоритму
+// Портера. Слова с символами вне a-z возвращаются без изменений.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	p := &porter{b: []byte(word), k: len(word) - 1}
+	p.step1ab()
+	if p.k > 0 {
+		p.step1c()
+		p.step2()
+		p.step3()
+		p.step4()
+		p.step5()
+	}
+	return string(p.b[:p.k+1])
+}
+
+func (p *porter) cons(i int) bool {
+	switch p.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !p.cons(i-1)
+	}
+	return true
+}
+
+// m число последовательностей «гласные-согласные» в основе b[0..j]
+func (p *porter) m() int {
+	n, i := 0, 0
+	for ; ; i++ {
+		if i > p.j {
+			return n
+		}
+		if !p.cons(i) {
+			break
+		}
+	}
+	for i++; ; i++ {
+		for ; ; i++ {
+			if i > p.j {
+				return n
+			}
+			if p.cons(i) {
+				break
+			}
+		}
+		n++
+		for i++; ; i++ {
+			if i > p.j {
+				return n
+			}
+			if !p.cons(i) {
+				break
+			}
+		}
+	}
+}
+
+func (p *porter) vowelInStem() bool {
+	for i := 0; i <= p.j; i++ {
+		if !p.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *porter) doublec(j int) bool {
+	return j >= 1 && p.b[j] == p.b[j-1] && p.cons(j)
+}
+
+// cvc сообщает, что b[i-2..i] — согласная, гласная, согласная,
+// причём последняя не w, x или y
+func (p *porter) cvc(i int) bool {
+	if i < 2 || !p.cons(i) || p.cons(i-1) || !p.cons(i-2) {
+		return false
+	}
+	switch p.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (p *porter) ends(s string) bool {
+	if len(s) > p.k+1 || string(p.b[p.k-len(s)+1:p.k+1]) != s {
+		return false
+	}
+	p.j = p.k - len(s)
+	return true
+}
+
+func (p *porter) setto(s string) {
+	p.b = append(p.b[:p.j+1], s...)
+	p.k = p.j + len(s)
+}
+
+func (p *porter) replace(s string) {
+	if p.m() > 0 {
+		p.setto(s)
+	}
+}
+
+// replaceFirst заменяет первое подходящее окончание, если основа
+// достаточно длинная
+func (p *porter) replaceFirst(pairs ...string) {
+	for i := 0; i < len(pairs); i += 2 {
+		if p.ends(pairs[i]) {
+			p.replace(pairs[i+1])
+			return
+		}
+	}
+}
+
+// step1ab убирает множественное число и окончания -ed, -ing
+func (p *porter) step1ab() {
+	if p.b[p.k] == 's' {
+		switch {
+		case p.ends("sses"):
+			p.k -= 2
+		case p.ends("ies"):
+			p.setto("i")
+		case p.b[p.k-1] != 's':
+			p.k--
+		}
+	}
+	if p.ends("eed") {
+		if p.m() > 0 {
+			p.k--
+		}
+		return
+	}
+	if (p.ends("ed") || p.ends("ing")) && p.vowelInStem() {
+		p.k = p.j
+		switch {
+		case p.ends("at"):
+			p.setto("ate")
+		case p.ends("bl"):
+			p.setto("ble")
+		case p.ends("iz"):
+			p.setto("ize")
+		case p.doublec(p.k):
+			p.k--
+			switch p.b[p.k] {
+			case 'l', 's', 'z':
+				p.k++
+			}
+		case p.m() == 1 && p.cvc(p.k):
+			p.setto("e")
+		}
+	}
+}
+
+// step1c заменяет конечную y на i, если в основе есть гласная
+func (p *porter) step1c() {
+	if p.ends("y") && p.vowelInStem() {
+		p.b[p.k] = 'i'
+	}
+}
+
+// step2 сводит двойные суффиксы к одинарным
+func (p *porter) step2() {
+	switch p.b[p.k-1] {
+	case 'a':
+		p.replaceFirst("ational", "ate", "tional", "tion")
+	case 'c':
+		p.replaceFirst("enci", "ence", "anci", "ance")
+	case 'e':
+		p.replaceFirst("izer", "ize")
+	case 'l':
+		p.replaceFirst("bli", "ble", "alli", "al", "entli", "ent", "eli", "e", "ousli", "ous")
+	case 'o':
+		p.replaceFirst("ization", "ize", "ation", "ate", "ator", "ate")
+	case 's':
+		p.replaceFirst("alism", "al", "iveness", "ive", "fulness", "ful", "ousness", "ous")
+	case 't':
+		p.replaceFirst("aliti", "al", "iviti", "ive", "biliti", "ble")
+	case 'g':
+		p.replaceFirst("logi", "log")
+	}
+}
+
+// step3 обрабатывает -ic-, -full, -ness и подобные
+func (p *porter) step3() {
+	switch p.b[p.k] {
+	case 'e':
+		p.replaceFirst("icate", "ic", "ative", "", "alize", "al")
+	case 'i':
+		p.replaceFirst("iciti", "ic")
+	case 'l':
+		p.replaceFirst("ical", "ic", "ful", "")
+	case 's':
+		p.replaceFirst("ness", "")
+	}
+}
+
+// step4 убирает -ant, -ence и прочие суффиксы при m() > 1
+func (p *porter) step4() {
+	var found bool
+	switch p.b[p.k-1] {
+	case 'a':
+		found = p.ends("al")
+	case 'c':
+		found = p.ends("ance") || p.ends("ence")
+	case 'e':
+		found = p.ends("er")
+	case 'i':
+		found = p.ends("ic")
+	case 'l':
+		found = p.ends("able") || p.ends("ible")
+	case 'n':
+		found = p.ends("ant") || p.ends("ement") || p.ends("ment") || p.ends("ent")
+	case 'o':
+		found = p.ends("ion") && p.j >= 0 && (p.b[p.j] == 's' || p.b[p.j] == 't') || p.ends("ou")
+	case 's':
+		found = p.ends("ism")
+	case 't':
+		found = p.ends("ate") || p.ends("iti")
+	case 'u':
+		found = p.ends("ous")
+	case 'v':
+		found = p.ends("ive")
+	case 'z':
+		found = p.ends("ize")
+	}
+	if found && p.m() > 1 {
+		p.k = p.j
+	}
+}
+
+// step5 убирает конечную -e и сдваивает -ll при m() > 1
+func (p *porter) step5() {
+	p.j = p.k
+	if p.b[p.k] == 'e' {
+		if a := p.m(); a > 1 || a == 1 && !p.cvc(p.k-1) {
+			p.k--
+		}
+	}
+	if p.b[p.k] == 'l' && p.doublec(p.k) && p.m() > 1 {
+		p.k--
+	}
+}
@@ -0,0 +1,43 @@
+package storage
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	// Пары из словаря, опубликованного вместе с алгоритмом Портера
+	tests := []struct {
+		word, want string
+	}{
+		{"caresses", "caress"},
+		{"ponies", "poni"},
+		{"cats", "cat"},
+		{"agreed", "agre"},
+		{"plastered", "plaster"},
+		{"motoring", "motor"},
+		{"sing", "sing"},
+		{"sized", "size"},
+		{"hopping", "hop"},
+		{"falling", "fall"},
+		{"filing", "file"},
+		{"happy", "happi"},
+		{"sky", "sky"},
+		{"relational", "relat"},
+		{"conditional", "condit"},
+		{"digitizer", "digit"},
+		{"generalization", "gener"},
+		{"electrical", "electr"},
+		{"hopeful", "hope"},
+		{"goodness", "good"},
+		{"adjustable", "adjust"},
+		{"controll", "control"},
+		{"running", "run"},
+		// Короткие слова и слова не из a-z не меняются
+		{"is", "is"},
+		{"résumés", "résumés"},
+		{"v2s", "v2s"},
+	}
+	for _, tt := range tests {
+		if got := Stem(tt.word); got != tt.want {
+			t.Errorf("Stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
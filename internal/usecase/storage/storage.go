@@ -6,26 +6,115 @@ import (
 	"time"
 )
 
+var (
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+)
+
+// ObjectType тип значения, хранящегося по ключу
+type ObjectType int
+
+const (
+	TypeNone ObjectType = iota
+	TypeString
+	TypeHash
+	TypeList
+	TypeSet
+	TypeZSet
+	TypeStream
+	TypeJSON
+	TypeBloom
+	TypeCuckoo
+	TypeCMS
+	TypeTopK
+	TypeTimeSeries
+	TypeVectorSet
+)
+
+// String возвращает имя типа в том виде, в котором его отдаёт команда TYPE
+func (t ObjectType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeHash:
+		return "hash"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeZSet:
+		return "zset"
+	case TypeStream:
+		return "stream"
+	case TypeJSON:
+		return "ReJSON-RL"
+	case TypeBloom:
+		return "MBbloom--"
+	case TypeCuckoo:
+		return "MBbloomCF"
+	case TypeCMS:
+		return "CMSk-TYPE"
+	case TypeTopK:
+		return "TopK-TYPE"
+	case TypeTimeSeries:
+		return "TSDB-TYPE"
+	case TypeVectorSet:
+		return "vectorset"
+	default:
+		return "none"
+	}
+}
+
+// object значение в общем пространстве ключей
+type object struct {
+	typ   ObjectType
+	value any
+}
+
 type Storage struct {
-	data         map[string]string
-	expiration   map[string]time.Time
-	hCollections map[string]*NestedCollection
-	mu           sync.RWMutex
-	stopCleaner  chan struct{}
+	data        map[string]*object
+	expiration  map[string]time.Time
+	mu          sync.RWMutex
+	stopCleaner chan struct{}
+
+	// keyOrder ключи data в порядке обхода SCAN
+	keyOrder *scanIndex
+
+	// expires индекс сроков истечения ключей и полей хэшей для активного
+	// удаления; expiredKeys, staleShare, lastCycle и cycleTime — его статистика
+	expires     *expiryIndex
+	expiredKeys int64
+	staleShare  float64
+	lastCycle   time.Duration
+	cycleTime   time.Duration
+
+	// indexes вторичные индексы хэшей по имени
+	indexes map[string]*hashIndex
+
+	// tsRetention ключи временных рядов с глубиной хранения, которые цикл
+	// активного удаления обходит, удаляя старые отсчёты. Ключи, под которыми
+	// такого ряда больше нет, убираются при обходе.
+	tsRetention map[string]struct{}
+
+	// streamSignal закрывается при каждом XADD, пробуждая блокирующие чтения
+	streamSignal chan struct{}
 }
 
 type NestedCollection struct {
-	mu         sync.RWMutex
 	fields     map[string]string
 	expiration map[string]time.Time
+	order      scanOrder[string]
 }
 
 func NewStorage() *Storage {
 	store := &Storage{
-		data:         make(map[string]string),
+		data:         make(map[string]*object),
 		expiration:   make(map[string]time.Time),
-		hCollections: make(map[string]*NestedCollection),
+		keyOrder:     newScanIndex(),
+		expires:      newExpiryIndex(),
+		indexes:      make(map[string]*hashIndex),
+		tsRetention:  make(map[string]struct{}),
 		stopCleaner:  make(chan struct{}),
+		streamSignal: make(chan struct{}),
 	}
 
 	go store.startBackgroundCleaner()
@@ -37,43 +126,101 @@ func (s *Storage) Stop() {
 	close(s.stopCleaner)
 }
 
+// Done возвращает канал, закрываемый при остановке хранилища
+func (s *Storage) Done() <-chan struct{} {
+	return s.stopCleaner
+}
+
+// startBackgroundCleaner запускает цикл активного удаления истёкших ключей
+// и отсчётов временных рядов старше глубины хранения.
+// Кроме цикла истёкший ключ удаляет только запись (lookupWrite) перед
+// изменением. Чтение (lookup) выполняется под блокировкой на чтение и лишь
+// скрывает истёкший ключ, не освобождая память.
 func (s *Storage) startBackgroundCleaner() {
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(expireCycleInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			s.cleanExpired()
+			s.activeExpireCycle()
 		case <-s.stopCleaner:
 			return
 		}
 	}
 }
 
-func (s *Storage) cleanExpired() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// expired сообщает, истёк ли срок жизни ключа. Вызывается под блокировкой.
+func (s *Storage) expired(key string) bool {
+	expTime, exists := s.expiration[key]
+	return exists && time.Now().After(expTime)
+}
 
-	now := time.Now()
+// lookup возвращает живой объект по ключу или nil. Вызывается под блокировкой.
+func (s *Storage) lookup(key string) *object {
+	obj, found := s.data[key]
+	if !found || s.expired(key) {
+		return nil
+	}
+	return obj
+}
 
-	// Очистка основных ключей
-	for key, expTime := range s.expiration {
-		if now.After(expTime) {
-			delete(s.data, key)
-			delete(s.expiration, key)
-		}
+// lookupType возвращает объект, если он имеет ожидаемый тип,
+// и ErrWrongType, если по ключу лежит значение другого типа.
+func (s *Storage) lookupType(key string, typ ObjectType) (*object, error) {
+	obj := s.lookup(key)
+	if obj == nil {
+		return nil, nil
+	}
+	if obj.typ != typ {
+		return nil, ErrWrongType
 	}
+	return obj, nil
+}
 
-	// Очистка вложенных коллекций
-	for _, coll := range s.hCollections {
-		for field, expTime := range coll.expiration {
-			if now.After(expTime) {
-				delete(coll.fields, field)
-				delete(coll.expiration, field)
-			}
-		}
+// lookupWrite работает как lookup, но сразу удаляет просроченный ключ,
+// чтобы запись не унаследовала его TTL. Вызывается под блокировкой на запись.
+func (s *Storage) lookupWrite(key string) *object {
+	if s.expired(key) {
+		s.remove(key)
+		s.expiredKeys++
+		return nil
 	}
+	return s.data[key]
+}
+
+// lookupWriteType — lookupWrite с проверкой типа
+func (s *Storage) lookupWriteType(key string, typ ObjectType) (*object, error) {
+	obj := s.lookupWrite(key)
+	if obj == nil {
+		return nil, nil
+	}
+	if obj.typ != typ {
+		return nil, ErrWrongType
+	}
+	return obj, nil
+}
+
+// put записывает объект под ключ. Вызывается под блокировкой на запись.
+func (s *Storage) put(key string, obj *object) {
+	if _, exists := s.data[key]; !exists {
+		s.keyOrder.add(key)
+	}
+	s.data[key] = obj
+	if ts, ok := obj.value.(*TimeSeries); ok && ts.retention > 0 {
+		s.tsRetention[key] = struct{}{}
+	}
+}
+
+// remove удаляет ключ вместе с его TTL. Вызывается под блокировкой на запись.
+func (s *Storage) remove(key string) {
+	if obj, exists := s.data[key]; exists {
+		s.untrack(key, obj)
+		s.keyOrder.remove(key)
+	}
+	delete(s.data, key)
+	s.clearExpire(key)
+	s.reindex(key)
 }
 
 // Set сохраняет значение с опциональным TTL
@@ -81,91 +228,97 @@ func (s *Storage) Set(key string, value string, ttl time.Duration) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data[key] = value
+	s.put(key, &object{typ: TypeString, value: value})
 	if ttl > 0 {
-		s.expiration[key] = time.Now().Add(ttl)
+		s.setExpire(key, time.Now().Add(ttl))
 	} else {
-		delete(s.expiration, key)
+		s.clearExpire(key)
 	}
 }
 
 // Get возвращает значение по ключу
-func (s *Storage) Get(key string) (string, bool) {
+func (s *Storage) Get(key string) (string, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if expTime, exists := s.expiration[key]; exists && time.Now().After(expTime) {
-		return "", false
-	}
-
-	value, found := s.data[key]
-	return value, found
+	return s.getString(key)
 }
 
-// Delete удаляет ключ из хранилища
+// Delete удаляет ключ любого типа из хранилища
 func (s *Storage) Delete(key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, found := s.data[key]; !found {
+	if s.lookupWrite(key) == nil {
 		return errors.New("key not found")
 	}
 
-	delete(s.data, key)
-	delete(s.expiration, key)
+	s.remove(key)
 	return nil
 }
 
-// HCollection создает или возвращает вложенную коллекцию
-func (s *Storage) HCollection(name string) *NestedCollection {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Type возвращает тип значения по ключу
+func (s *Storage) Type(key string) ObjectType {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	if coll, exists := s.hCollections[name]; exists {
-		return coll
+	obj := s.lookup(key)
+	if obj == nil {
+		return TypeNone
+	}
+	return obj.typ
+}
+
+// hCollection возвращает хэш по ключу, при необходимости создавая его.
+// Вызывается под блокировкой на запись.
+func (s *Storage) hCollection(name string) (*NestedCollection, error) {
+	obj, err := s.lookupWriteType(name, TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil {
+		return obj.value.(*NestedCollection), nil
 	}
 
 	coll := &NestedCollection{
 		fields:     make(map[string]string),
 		expiration: make(map[string]time.Time),
 	}
-	s.hCollections[name] = coll
-	return coll
+	s.put(name, &object{typ: TypeHash, value: coll})
+	return coll, nil
 }
 
-// HSet устанавливает значение в вложенной коллекции
-func (s *Storage) HSet(collection, field string, value string, ttl time.Duration) {
-	coll := s.HCollection(collection)
-	coll.mu.Lock()
-	defer coll.mu.Unlock()
-
-	coll.fields[field] = value
-	if ttl > 0 {
-		coll.expiration[field] = time.Now().Add(ttl)
-	} else {
-		delete(coll.expiration, field)
+// getCollection возвращает существующий хэш или nil. Вызывается под блокировкой.
+func (s *Storage) getCollection(name string) (*NestedCollection, error) {
+	obj, err := s.lookupType(name, TypeHash)
+	if err != nil || obj == nil {
+		return nil, err
 	}
+	return obj.value.(*NestedCollection), nil
+}
+
+// fieldExpired сообщает, истёк ли срок жизни поля хэша
+func (c *NestedCollection) fieldExpired(field string, now time.Time) bool {
+	expTime, hasTTL := c.expiration[field]
+	return hasTTL && now.After(expTime)
 }
 
 // HGet получает значение из вложенной коллекции
-func (s *Storage) HGet(collection, field string) (string, bool) {
+func (s *Storage) HGet(collection, field string) (string, bool, error) {
 	s.mu.RLock()
-	coll, exists := s.hCollections[collection]
-	s.mu.RUnlock()
+	defer s.mu.RUnlock()
 
-	if !exists {
-		return "", false
+	coll, err := s.getCollection(collection)
+	if err != nil || coll == nil {
+		return "", false, err
 	}
 
-	coll.mu.RLock()
-	defer coll.mu.RUnlock()
-
-	if expTime, exists := coll.expiration[field]; exists && time.Now().After(expTime) {
-		return "", false
+	if coll.fieldExpired(field, time.Now()) {
+		return "", false, nil
 	}
 
 	value, found := coll.fields[field]
-	return value, found
+	return value, found, nil
 }
 
 // HDelete удаляет поле из вложенной коллекции
@@ -173,17 +326,26 @@ func (s *Storage) HDelete(collection, field string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	coll, exists := s.hCollections[collection]
-	if !exists {
+	obj, err := s.lookupWriteType(collection, TypeHash)
+	if err != nil {
+		return err
+	}
+	if obj == nil {
 		return errors.New("collection not found")
 	}
+	coll := obj.value.(*NestedCollection)
 
-	if _, found := coll.fields[field]; !found {
+	if _, found := coll.fields[field]; !found || coll.fieldExpired(field, time.Now()) {
 		return errors.New("field not found")
 	}
 
-	delete(coll.fields, field)
-	delete(coll.expiration, field)
+	coll.del(field)
+	s.clearFieldExpire(collection, coll, field)
+	if len(coll.fields) == 0 {
+		s.remove(collection)
+	} else {
+		s.reindex(collection)
+	}
 	return nil
 }
 
@@ -192,142 +354,94 @@ func (s *Storage) HDeleteAll(collection string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.hCollections[collection]; !exists {
+	obj, err := s.lookupWriteType(collection, TypeHash)
+	if err != nil {
+		return err
+	}
+	if obj == nil {
 		return errors.New("collection not found")
 	}
 
-	delete(s.hCollections, collection)
+	s.remove(collection)
 	return nil
 }
 
-func (s *Storage) HGetAll(collection string) map[string]string {
+func (s *Storage) HGetAll(collection string) (map[string]string, error) {
 	s.mu.RLock()
-	coll, exists := s.hCollections[collection]
-	s.mu.RUnlock()
+	defer s.mu.RUnlock()
 
-	if !exists {
-		return nil
+	coll, err := s.getCollection(collection)
+	if err != nil || coll == nil {
+		return nil, err
 	}
 
-	coll.mu.RLock()
-	defer coll.mu.RUnlock()
-
 	now := time.Now()
 	result := make(map[string]string)
 
 	for field, value := range coll.fields {
-		if expTime, hasTTL := coll.expiration[field]; hasTTL && now.After(expTime) {
+		if coll.fieldExpired(field, now) {
 			continue
 		}
 		result[field] = value
 	}
 
-	return result
+	return result, nil
 }
 
-func (s *Storage) HExists(collection, field string) bool {
+func (s *Storage) HExists(collection, field string) (bool, error) {
 	s.mu.RLock()
-	coll, exists := s.hCollections[collection]
-	s.mu.RUnlock()
-
-	if !exists {
-		return false
-	}
-
-	coll.mu.RLock()
-	defer coll.mu.RUnlock()
+	defer s.mu.RUnlock()
 
-	_, found := coll.fields[field]
-	if !found {
-		return false
+	coll, err := s.getCollection(collection)
+	if err != nil || coll == nil {
+		return false, err
 	}
 
-	if expTime, hasTTL := coll.expiration[field]; hasTTL && time.Now().After(expTime) {
-		return false
+	if _, found := coll.fields[field]; !found {
+		return false, nil
 	}
 
-	return true
+	return !coll.fieldExpired(field, time.Now()), nil
 }
 
-func (s *Storage) HLen(collection string) int {
+func (s *Storage) HLen(collection string) (int, error) {
 	s.mu.RLock()
-	coll, exists := s.hCollections[collection]
-	s.mu.RUnlock()
+	defer s.mu.RUnlock()
 
-	if !exists {
-		return 0
+	coll, err := s.getCollection(collection)
+	if err != nil || coll == nil {
+		return 0, err
 	}
 
-	coll.mu.RLock()
-	defer coll.mu.RUnlock()
-
 	now := time.Now()
 	count := 0
 	for field := range coll.fields {
-		if expTime, hasTTL := coll.expiration[field]; hasTTL && now.After(expTime) {
+		if coll.fieldExpired(field, now) {
 			continue
 		}
 		count++
 	}
 
-	return count
+	return count, nil
 }
 
+// FlushDB удаляет все ключи. Индексы остаются, но становятся пустыми.
 func (s *Storage) FlushDB() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.data = make(map[string]string)
+	s.data = make(map[string]*object)
 	s.expiration = make(map[string]time.Time)
-	s.hCollections = make(map[string]*NestedCollection)
+	s.keyOrder = newScanIndex()
+	s.expires = newExpiryIndex()
+	s.indexes = s.emptyIndexes()
+	s.tsRetention = make(map[string]struct{})
 }
 
-// Exists проверяет существование ключа
+// Exists проверяет существование ключа любого типа
 func (s *Storage) Exists(key string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	if expTime, exists := s.expiration[key]; exists && time.Now().After(expTime) {
-		return false
-	}
-
-	_, found := s.data[key]
-	return found
-}
-
-// TTL возвращает оставшееся время жизни ключа
-func (s *Storage) TTL(key string) (time.Duration, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	expTime, exists := s.expiration[key]
-	if !exists {
-		return 0, errors.New("key has no TTL")
-	}
-
-	remaining := time.Until(expTime)
-	if remaining <= 0 {
-		return 0, errors.New("key expired")
-	}
-
-	return remaining, nil
-}
-
-func (s *Storage) SetTTL(key string, ttl time.Duration) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	_, found := s.data[key]
-	if !found {
-		return errors.New("key not found")
-	}
-
-	if ttl > 0 {
-		s.expiration[key] = time.Now().Add(ttl)
-	} else {
-		// Если ttl == 0 или < 0, удаляем TTL (бессрочный ключ)
-		delete(s.expiration, key)
-	}
-
-	return nil
+	return s.lookup(key) != nil
 }
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+)
+
+// newTestStorage создаёт хранилище, которое останавливается по завершении теста
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	s := NewStorage()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func TestTypeOfEachValue(t *testing.T) {
+	s := newTestStorage(t)
+	s.Set("str", "v", 0)
+	if _, err := s.HSet("hash", []string{"f"}, []string{"v"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Push("list", ListRight, []string{"a"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SAdd("set", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"str", "string"},
+		{"hash", "hash"},
+		{"list", "list"},
+		{"set", "set"},
+		{"missing", "none"},
+	}
+	for _, tt := range tests {
+		if got := s.Type(tt.key).String(); got != tt.want {
+			t.Errorf("Type(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestWrongType(t *testing.T) {
+	s := newTestStorage(t)
+	s.Set("str", "v", 0)
+	if _, err := s.SAdd("set", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"GET on set", func() error { _, _, err := s.Get("set"); return err }},
+		{"HSET on string", func() error { _, err := s.HSet("str", []string{"f"}, []string{"v"}); return err }},
+		{"LPUSH on string", func() error { _, err := s.Push("str", ListLeft, []string{"a"}, false); return err }},
+		{"SADD on string", func() error { _, err := s.SAdd("str", []string{"a"}); return err }},
+		{"INCR on set", func() error { _, err := s.IncrBy("set", 1); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, ErrWrongType) {
+				t.Errorf("err = %v, want ErrWrongType", err)
+			}
+		})
+	}
+}
+
+func TestSetReplacesValueOfAnyType(t *testing.T) {
+	s := newTestStorage(t)
+	if _, err := s.SAdd("k", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Set("k", "v", 0)
+
+	got, found, err := s.Get("k")
+	if err != nil || !found || got != "v" {
+		t.Fatalf("Get = %q, %v, %v; want v", got, found, err)
+	}
+	if err := s.Delete("k"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Exists("k") {
+		t.Error("key exists after Delete")
+	}
+	if err := s.Delete("k"); err == nil {
+		t.Error("Delete of missing key succeeded")
+	}
+}
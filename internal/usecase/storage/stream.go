@@ -0,0 +1,1017 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStreamIDTooSmall  = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamIDZero      = errors.New("ERR The ID specified in XADD must be greater than 0-0")
+	ErrStreamExhausted   = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+	ErrInvalidStreamID   = errors.New("ERR Invalid stream ID specified as stream command argument")
+	ErrNoGroup           = errors.New("NOGROUP No such key or consumer group")
+	ErrBusyGroup         = errors.New("BUSYGROUP Consumer Group name already exists")
+	ErrStreamKeyRequired = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+)
+
+// StreamID идентификатор записи потока: миллисекунды и порядковый номер
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// MaxStreamID наибольший возможный идентификатор
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Less сообщает, что id строго меньше other
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+
+func (id StreamID) IsZero() bool {
+	return id.Ms == 0 && id.Seq == 0
+}
+
+// Next возвращает следующий возможный идентификатор
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	default:
+		return id, false
+	}
+}
+
+// Prev возвращает предыдущий возможный идентификатор
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+// ParseStreamID разбирает идентификатор вида "ms-seq" или "ms";
+// во втором случае порядковый номер равен defaultSeq
+func ParseStreamID(s string, defaultSeq uint64) (StreamID, error) {
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	if !hasSeq {
+		return StreamID{Ms: ms, Seq: defaultSeq}, nil
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, ErrInvalidStreamID
+	}
+	return StreamID{Ms: ms, Seq: seq}, nil
+}
+
+// StreamEntry запись потока. Fields содержит чередующиеся поля и значения;
+// nil означает, что запись удалена из потока, но осталась в PEL группы.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// StreamResult записи одного потока в ответе XREAD/XREADGROUP
+type StreamResult struct {
+	Key     string
+	Entries []StreamEntry
+}
+
+// XAddID идентификатор для XADD: явный, полностью автоматический ("*")
+// или с автоматическим порядковым номером ("ms-*")
+type XAddID struct {
+	ID      StreamID
+	Auto    bool
+	AutoSeq bool
+}
+
+// TrimStrategy способ обрезки потока
+type TrimStrategy int
+
+const (
+	TrimNone TrimStrategy = iota
+	TrimMaxLen
+	TrimMinID
+)
+
+// StreamTrim параметры обрезки XADD/XTRIM. Limit > 0 ограничивает число
+// удаляемых за раз записей.
+type StreamTrim struct {
+	Strategy TrimStrategy
+	MaxLen   int
+	MinID    StreamID
+	Limit    int
+}
+
+// PendingEntry запись, доставленная потребителю, но ещё не подтверждённая
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	DeliveryTime  time.Time
+	DeliveryCount int
+}
+
+type streamConsumer struct {
+	name     string
+	seenTime time.Time
+	pending  map[StreamID]*PendingEntry
+}
+
+type consumerGroup struct {
+	lastID    StreamID
+	pel       map[StreamID]*PendingEntry
+	consumers map[string]*streamConsumer
+}
+
+// StreamCollection поток записей, упорядоченных по идентификатору,
+// с группами потребителей
+type StreamCollection struct {
+	entries      []StreamEntry
+	lastID       StreamID
+	maxDeletedID StreamID
+	entriesAdded uint64
+	groups       map[string]*consumerGroup
+}
+
+func newStreamCollection() *StreamCollection {
+	return &StreamCollection{groups: make(map[string]*consumerGroup)}
+}
+
+func (st *StreamCollection) Len() int {
+	return len(st.entries)
+}
+
+// search возвращает индекс первой записи с идентификатором >= id
+func (st *StreamCollection) search(id StreamID) int {
+	return sort.Search(len(st.entries), func(i int) bool {
+		return !st.entries[i].ID.Less(id)
+	})
+}
+
+func (st *StreamCollection) find(id StreamID) (StreamEntry, bool) {
+	i := st.search(id)
+	if i < len(st.entries) && st.entries[i].ID == id {
+		return st.entries[i], true
+	}
+	return StreamEntry{}, false
+}
+
+// nextID вычисляет идентификатор новой записи
+func (st *StreamCollection) nextID(id XAddID) (StreamID, error) {
+	switch {
+	case id.Auto:
+		ms := uint64(time.Now().UnixMilli())
+		if ms > st.lastID.Ms {
+			return StreamID{Ms: ms}, nil
+		}
+		next, ok := st.lastID.Next()
+		if !ok {
+			return StreamID{}, ErrStreamExhausted
+		}
+		return next, nil
+	case id.AutoSeq:
+		if id.ID.Ms < st.lastID.Ms {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		if id.ID.Ms > st.lastID.Ms {
+			if id.ID.Ms == 0 {
+				return StreamID{Seq: 1}, nil
+			}
+			return StreamID{Ms: id.ID.Ms}, nil
+		}
+		if st.lastID.Seq == math.MaxUint64 {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return StreamID{Ms: id.ID.Ms, Seq: st.lastID.Seq + 1}, nil
+	default:
+		if id.ID.IsZero() {
+			return StreamID{}, ErrStreamIDZero
+		}
+		if !st.lastID.Less(id.ID) {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return id.ID, nil
+	}
+}
+
+// trim удаляет старые записи и возвращает их число
+func (st *StreamCollection) trim(t StreamTrim) int {
+	n := 0
+	switch t.Strategy {
+	case TrimMaxLen:
+		if len(st.entries) > t.MaxLen {
+			n = len(st.entries) - t.MaxLen
+		}
+	case TrimMinID:
+		n = st.search(t.MinID)
+	}
+	if t.Limit > 0 && n > t.Limit {
+		n = t.Limit
+	}
+	for i := 0; i < n; i++ {
+		st.entries[i] = StreamEntry{}
+	}
+	st.entries = st.entries[n:]
+	return n
+}
+
+// rangeEntries возвращает записи в диапазоне [start, end], при rev — в обратном порядке
+func (st *StreamCollection) rangeEntries(start, end StreamID, count int, rev bool) []StreamEntry {
+	if end.Less(start) {
+		return nil
+	}
+	from := st.search(start)
+	to := sort.Search(len(st.entries), func(i int) bool {
+		return end.Less(st.entries[i].ID)
+	})
+
+	var result []StreamEntry
+	if rev {
+		for i := to - 1; i >= from && (count <= 0 || len(result) < count); i-- {
+			result = append(result, st.entries[i])
+		}
+	} else {
+		for i := from; i < to && (count <= 0 || len(result) < count); i++ {
+			result = append(result, st.entries[i])
+		}
+	}
+	return result
+}
+
+// after возвращает до count записей с идентификатором строго больше id
+func (st *StreamCollection) after(id StreamID, count int) []StreamEntry {
+	next, ok := id.Next()
+	if !ok {
+		return nil
+	}
+	return st.rangeEntries(next, MaxStreamID, count, false)
+}
+
+func (g *consumerGroup) consumer(name string, now time.Time) *streamConsumer {
+	c, exists := g.consumers[name]
+	if !exists {
+		c = &streamConsumer{name: name, pending: make(map[StreamID]*PendingEntry)}
+		g.consumers[name] = c
+	}
+	c.seenTime = now
+	return c
+}
+
+// assign закрепляет запись PEL за потребителем
+func (g *consumerGroup) assign(pe *PendingEntry, c *streamConsumer) {
+	if old, exists := g.consumers[pe.Consumer]; exists {
+		delete(old.pending, pe.ID)
+	}
+	pe.Consumer = c.name
+	c.pending[pe.ID] = pe
+	g.pel[pe.ID] = pe
+}
+
+func (g *consumerGroup) ack(id StreamID) bool {
+	pe, exists := g.pel[id]
+	if !exists {
+		return false
+	}
+	delete(g.pel, id)
+	if c, exists := g.consumers[pe.Consumer]; exists {
+		delete(c.pending, id)
+	}
+	return true
+}
+
+// sortedPending возвращает записи PEL по возрастанию идентификатора
+func sortedPending(pel map[StreamID]*PendingEntry) []*PendingEntry {
+	result := make([]*PendingEntry, 0, len(pel))
+	for _, pe := range pel {
+		result = append(result, pe)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID.Less(result[j].ID) })
+	return result
+}
+
+// getStream возвращает существующий поток или nil. Вызывается под блокировкой.
+func (s *Storage) getStream(key string) (*StreamCollection, error) {
+	obj, err := s.lookupType(key, TypeStream)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return obj.value.(*StreamCollection), nil
+}
+
+// writeStream возвращает поток для изменения; create создаёт отсутствующий.
+// Вызывается под блокировкой на запись.
+func (s *Storage) writeStream(key string, create bool) (*StreamCollection, error) {
+	obj, err := s.lookupWriteType(key, TypeStream)
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil {
+		return obj.value.(*StreamCollection), nil
+	}
+	if !create {
+		return nil, nil
+	}
+
+	st := newStreamCollection()
+	s.put(key, &object{typ: TypeStream, value: st})
+	return st, nil
+}
+
+// writeGroup возвращает группу потребителей существующего потока
+func (s *Storage) writeGroup(key, group string) (*StreamCollection, *consumerGroup, error) {
+	st, err := s.writeStream(key, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if st == nil {
+		return nil, nil, ErrNoGroup
+	}
+	g, exists := st.groups[group]
+	if !exists {
+		return nil, nil, ErrNoGroup
+	}
+	return st, g, nil
+}
+
+// StreamSignal возвращает канал, который закроется при следующем
+// добавлении записи в любой поток. Используется блокирующим чтением.
+func (s *Storage) StreamSignal() <-chan struct{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.streamSignal
+}
+
+// notifyStreams будит ожидающих чтения потоков. Вызывается под блокировкой на запись.
+func (s *Storage) notifyStreams() {
+	close(s.streamSignal)
+	s.streamSignal = make(chan struct{})
+}
+
+// XAdd добавляет запись в поток и при необходимости обрезает его.
+// При noMkStream отсутствующий поток не создаётся и возвращается false.
+func (s *Storage) XAdd(key string, id XAddID, fields []string, noMkStream bool, trim StreamTrim) (StreamID, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.writeStream(key, false)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if st == nil && noMkStream {
+		return StreamID{}, false, nil
+	}
+
+	probe := st
+	if probe == nil {
+		probe = newStreamCollection()
+	}
+	newID, err := probe.nextID(id)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if st == nil {
+		st, _ = s.writeStream(key, true)
+	}
+
+	st.entries = append(st.entries, StreamEntry{ID: newID, Fields: fields})
+	st.lastID = newID
+	st.entriesAdded++
+	st.trim(trim)
+
+	s.notifyStreams()
+	return newID, true, nil
+}
+
+func (s *Storage) XLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, err := s.getStream(key)
+	if err != nil || st == nil {
+		return 0, err
+	}
+	return st.Len(), nil
+}
+
+// XRange возвращает записи в диапазоне [start, end]; count <= 0 — без ограничения
+func (s *Storage) XRange(key string, start, end StreamID, count int, rev bool) ([]StreamEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, err := s.getStream(key)
+	if err != nil || st == nil {
+		return nil, err
+	}
+	return st.rangeEntries(start, end, count, rev), nil
+}
+
+func (s *Storage) XDel(key string, ids []StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.writeStream(key, false)
+	if err != nil || st == nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		i := st.search(id)
+		if i >= len(st.entries) || st.entries[i].ID != id {
+			continue
+		}
+		st.entries = append(st.entries[:i], st.entries[i+1:]...)
+		if st.maxDeletedID.Less(id) {
+			st.maxDeletedID = id
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (s *Storage) XTrim(key string, trim StreamTrim) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.writeStream(key, false)
+	if err != nil || st == nil {
+		return 0, err
+	}
+	return st.trim(trim), nil
+}
+
+// XLastID возвращает последний сгенерированный идентификатор потока
+// (используется для "$" в XREAD)
+func (s *Storage) XLastID(key string) (StreamID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, err := s.getStream(key)
+	if err != nil || st == nil {
+		return StreamID{}, err
+	}
+	return st.lastID, nil
+}
+
+// XRead возвращает записи с идентификаторами больше ids[i] для каждого потока
+func (s *Storage) XRead(keys []string, ids []StreamID, count int) ([]StreamResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []StreamResult
+	for i, key := range keys {
+		st, err := s.getStream(key)
+		if err != nil {
+			return nil, err
+		}
+		if st == nil {
+			continue
+		}
+		if entries := st.after(ids[i], count); len(entries) > 0 {
+			result = append(result, StreamResult{Key: key, Entries: entries})
+		}
+	}
+	return result, nil
+}
+
+// XGroupCreate создаёт группу потребителей; при useLast группа начинает
+// с последней записи потока ("$")
+func (s *Storage) XGroupCreate(key, group string, id StreamID, useLast, mkStream bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.writeStream(key, mkStream)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		return ErrStreamKeyRequired
+	}
+	if _, exists := st.groups[group]; exists {
+		return ErrBusyGroup
+	}
+
+	if useLast {
+		id = st.lastID
+	}
+	st.groups[group] = &consumerGroup{
+		lastID:    id,
+		pel:       make(map[StreamID]*PendingEntry),
+		consumers: make(map[string]*streamConsumer),
+	}
+	return nil
+}
+
+func (s *Storage) XGroupSetID(key, group string, id StreamID, useLast bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, g, err := s.writeGroup(key, group)
+	if err != nil {
+		return err
+	}
+	if useLast {
+		id = st.lastID
+	}
+	g.lastID = id
+	return nil
+}
+
+func (s *Storage) XGroupDestroy(key, group string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.writeStream(key, false)
+	if err != nil {
+		return false, err
+	}
+	if st == nil {
+		return false, ErrStreamKeyRequired
+	}
+	if _, exists := st.groups[group]; !exists {
+		return false, nil
+	}
+	delete(st.groups, group)
+	return true, nil
+}
+
+func (s *Storage) XGroupCreateConsumer(key, group, consumer string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.writeGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := g.consumers[consumer]; exists {
+		return false, nil
+	}
+	g.consumer(consumer, time.Now())
+	return true, nil
+}
+
+// XGroupDelConsumer удаляет потребителя вместе с его записями PEL
+// и возвращает их число
+func (s *Storage) XGroupDelConsumer(key, group, consumer string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.writeGroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+	c, exists := g.consumers[consumer]
+	if !exists {
+		return 0, nil
+	}
+
+	pending := len(c.pending)
+	for id := range c.pending {
+		delete(g.pel, id)
+	}
+	delete(g.consumers, consumer)
+	return pending, nil
+}
+
+// XReadGroup читает записи от имени потребителя группы. Идентификатор ">"
+// (newOnly[i]) выдаёт новые записи и сдвигает last-delivered-id группы;
+// иной идентификатор повторно выдаёт записи из PEL потребителя.
+func (s *Storage) XReadGroup(group, consumer string, keys []string, ids []StreamID, newOnly []bool, count int, noAck bool) ([]StreamResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups := make([]*consumerGroup, len(keys))
+	streams := make([]*StreamCollection, len(keys))
+	for i, key := range keys {
+		st, g, err := s.writeGroup(key, group)
+		if err == ErrNoGroup {
+			return nil, errors.New("NOGROUP No such key '" + key + "' or consumer group '" + group + "' in XREADGROUP with GROUP option")
+		}
+		if err != nil {
+			return nil, err
+		}
+		streams[i], groups[i] = st, g
+	}
+
+	now := time.Now()
+	var result []StreamResult
+	for i, key := range keys {
+		st, g := streams[i], groups[i]
+		c := g.consumer(consumer, now)
+
+		if !newOnly[i] {
+			var entries []StreamEntry
+			for _, pe := range sortedPending(c.pending) {
+				if !ids[i].Less(pe.ID) {
+					continue
+				}
+				if count > 0 && len(entries) >= count {
+					break
+				}
+				entry, found := st.find(pe.ID)
+				if !found {
+					entry = StreamEntry{ID: pe.ID}
+				}
+				pe.DeliveryTime = now
+				pe.DeliveryCount++
+				entries = append(entries, entry)
+			}
+			result = append(result, StreamResult{Key: key, Entries: entries})
+			continue
+		}
+
+		entries := st.after(g.lastID, count)
+		if len(entries) == 0 {
+			continue
+		}
+		g.lastID = entries[len(entries)-1].ID
+		if !noAck {
+			for _, entry := range entries {
+				pe, exists := g.pel[entry.ID]
+				if !exists {
+					pe = &PendingEntry{ID: entry.ID}
+				}
+				pe.DeliveryTime = now
+				pe.DeliveryCount = 1
+				g.assign(pe, c)
+			}
+		}
+		result = append(result, StreamResult{Key: key, Entries: entries})
+	}
+	return result, nil
+}
+
+func (s *Storage) XAck(key, group string, ids []StreamID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.writeGroup(key, group)
+	if err == ErrNoGroup {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	acked := 0
+	for _, id := range ids {
+		if g.ack(id) {
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+// PendingSummary сводка XPENDING без диапазона
+type PendingSummary struct {
+	Count     int
+	Min, Max  StreamID
+	Consumers map[string]int
+}
+
+func (s *Storage) XPendingSummary(key, group string) (PendingSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.writeGroup(key, group)
+	if err != nil {
+		return PendingSummary{}, err
+	}
+
+	summary := PendingSummary{Count: len(g.pel), Consumers: make(map[string]int)}
+	for i, pe := range sortedPending(g.pel) {
+		if i == 0 {
+			summary.Min = pe.ID
+		}
+		summary.Max = pe.ID
+		summary.Consumers[pe.Consumer]++
+	}
+	return summary, nil
+}
+
+// XPending возвращает записи PEL в диапазоне [start, end] с простоем
+// не меньше minIdle; consumer != "" ограничивает выборку одним потребителем
+func (s *Storage) XPending(key, group string, minIdle time.Duration, start, end StreamID, count int, consumer string) ([]PendingEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.writeGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	pel := g.pel
+	if consumer != "" {
+		c, exists := g.consumers[consumer]
+		if !exists {
+			return nil, nil
+		}
+		pel = c.pending
+	}
+
+	now := time.Now()
+	var result []PendingEntry
+	for _, pe := range sortedPending(pel) {
+		if count > 0 && len(result) >= count {
+			break
+		}
+		if pe.ID.Less(start) || end.Less(pe.ID) || now.Sub(pe.DeliveryTime) < minIdle {
+			continue
+		}
+		result = append(result, *pe)
+	}
+	return result, nil
+}
+
+// XPendingEntries возвращает текущее состояние записей PEL и last-delivered-id
+// группы; используется для детерминированной записи команд в AOF
+func (s *Storage) XPendingEntries(key, group string, ids []StreamID) ([]PendingEntry, StreamID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, g, err := s.writeGroup(key, group)
+	if err != nil {
+		return nil, StreamID{}, err
+	}
+
+	var result []PendingEntry
+	for _, id := range ids {
+		if pe, exists := g.pel[id]; exists {
+			result = append(result, *pe)
+		}
+	}
+	return result, g.lastID, nil
+}
+
+// XClaimOptions необязательные параметры XCLAIM
+type XClaimOptions struct {
+	Idle       *time.Duration
+	Time       *time.Time
+	RetryCount *int
+	Force      bool
+	JustID     bool
+	LastID     *StreamID
+}
+
+// XClaim передаёт потребителю записи PEL, простаивающие не меньше minIdle
+func (s *Storage) XClaim(key, group, consumer string, minIdle time.Duration, ids []StreamID, opts XClaimOptions) ([]StreamEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, g, err := s.writeGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if opts.LastID != nil && g.lastID.Less(*opts.LastID) {
+		g.lastID = *opts.LastID
+	}
+
+	deliveryTime := now
+	switch {
+	case opts.Time != nil:
+		deliveryTime = *opts.Time
+	case opts.Idle != nil:
+		deliveryTime = now.Add(-*opts.Idle)
+	}
+
+	c := g.consumer(consumer, now)
+	var result []StreamEntry
+	for _, id := range ids {
+		entry, found := st.find(id)
+		pe, pending := g.pel[id]
+		if !pending {
+			if !opts.Force || !found {
+				continue
+			}
+			pe = &PendingEntry{ID: id}
+		} else if minIdle > 0 && now.Sub(pe.DeliveryTime) < minIdle {
+			continue
+		}
+		if !found {
+			// Запись удалена из потока: убираем её из PEL
+			g.ack(id)
+			continue
+		}
+
+		pe.DeliveryTime = deliveryTime
+		if opts.RetryCount != nil {
+			pe.DeliveryCount = *opts.RetryCount
+		} else if !opts.JustID {
+			pe.DeliveryCount++
+		}
+		g.assign(pe, c)
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// XAutoClaim передаёт потребителю до count простаивающих записей PEL,
+// начиная с start. Возвращает курсор для следующего вызова, переданные
+// записи и идентификаторы записей, удалённых из потока.
+func (s *Storage) XAutoClaim(key, group, consumer string, minIdle time.Duration, start StreamID, count int, justID bool) (StreamID, []StreamEntry, []StreamID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, g, err := s.writeGroup(key, group)
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+
+	now := time.Now()
+	c := g.consumer(consumer, now)
+	attempts := count * 10
+
+	var claimed []StreamEntry
+	var deleted []StreamID
+	next := StreamID{}
+	for _, pe := range sortedPending(g.pel) {
+		if pe.ID.Less(start) {
+			continue
+		}
+		if attempts == 0 || len(claimed) >= count {
+			next = pe.ID
+			break
+		}
+		attempts--
+
+		if now.Sub(pe.DeliveryTime) < minIdle {
+			continue
+		}
+		entry, found := st.find(pe.ID)
+		if !found {
+			g.ack(pe.ID)
+			deleted = append(deleted, pe.ID)
+			continue
+		}
+
+		pe.DeliveryTime = now
+		if !justID {
+			pe.DeliveryCount++
+		}
+		g.assign(pe, c)
+		claimed = append(claimed, entry)
+	}
+	return next, claimed, deleted, nil
+}
+
+// StreamInfo сведения XINFO STREAM
+type StreamInfo struct {
+	Length       int
+	Groups       int
+	LastID       StreamID
+	MaxDeletedID StreamID
+	EntriesAdded uint64
+	First, Last  *StreamEntry
+}
+
+// GroupInfo сведения XINFO GROUPS
+type GroupInfo struct {
+	Name      string
+	Consumers int
+	Pending   int
+	LastID    StreamID
+}
+
+// ConsumerInfo сведения XINFO CONSUMERS
+type ConsumerInfo struct {
+	Name    string
+	Pending int
+	Idle    time.Duration
+}
+
+func (s *Storage) XInfoStream(key string) (StreamInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, err := s.getStream(key)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+	if st == nil {
+		return StreamInfo{}, ErrNoSuchKey
+	}
+
+	info := StreamInfo{
+		Length:       st.Len(),
+		Groups:       len(st.groups),
+		LastID:       st.lastID,
+		MaxDeletedID: st.maxDeletedID,
+		EntriesAdded: st.entriesAdded,
+	}
+	if st.Len() > 0 {
+		first, last := st.entries[0], st.entries[st.Len()-1]
+		info.First, info.Last = &first, &last
+	}
+	return info, nil
+}
+
+func (s *Storage) XInfoGroups(key string) ([]GroupInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, err := s.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, ErrNoSuchKey
+	}
+
+	result := make([]GroupInfo, 0, len(st.groups))
+	for name, g := range st.groups {
+		result = append(result, GroupInfo{
+			Name:      name,
+			Consumers: len(g.consumers),
+			Pending:   len(g.pel),
+			LastID:    g.lastID,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (s *Storage) XInfoConsumers(key, group string) ([]ConsumerInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	st, err := s.getStream(key)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, ErrNoSuchKey
+	}
+	g, exists := st.groups[group]
+	if !exists {
+		return nil, ErrNoGroup
+	}
+
+	now := time.Now()
+	result := make([]ConsumerInfo, 0, len(g.consumers))
+	for name, c := range g.consumers {
+		result = append(result, ConsumerInfo{
+			Name:    name,
+			Pending: len(c.pending),
+			Idle:    now.Sub(c.seenTime),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// clone возвращает независимую копию потока. Записи PEL копируются,
+// а потребители копии ссылаются на записи PEL своей группы.
+func (st *StreamCollection) clone() *StreamCollection {
+	copied := &StreamCollection{
+		entries:      make([]StreamEntry, len(st.entries)),
+		lastID:       st.lastID,
+		maxDeletedID: st.maxDeletedID,
+		entriesAdded: st.entriesAdded,
+		groups:       make(map[string]*consumerGroup, len(st.groups)),
+	}
+	for i, entry := range st.entries {
+		copied.entries[i] = StreamEntry{ID: entry.ID, Fields: slices.Clone(entry.Fields)}
+	}
+
+	for name, g := range st.groups {
+		group := &consumerGroup{
+			lastID:    g.lastID,
+			pel:       make(map[StreamID]*PendingEntry, len(g.pel)),
+			consumers: make(map[string]*streamConsumer, len(g.consumers)),
+		}
+		for id, pe := range g.pel {
+			entry := *pe
+			group.pel[id] = &entry
+		}
+		for consumerName, c := range g.consumers {
+			consumer := &streamConsumer{name: c.name, seenTime: c.seenTime, pending: make(map[StreamID]*PendingEntry, len(c.pending))}
+			for id := range c.pending {
+				consumer.pending[id] = group.pel[id]
+			}
+			group.consumers[consumerName] = consumer
+		}
+		copied.groups[name] = group
+	}
+	return copied
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// entryIDs возвращает идентификаторы записей
+func entryIDs(entries []StreamEntry) []StreamID {
+	result := make([]StreamID, len(entries))
+	for i, e := range entries {
+		result[i] = e.ID
+	}
+	return result
+}
+
+func TestParseStreamID(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    StreamID
+		wantErr bool
+	}{
+		{"1-2", StreamID{1, 2}, false},
+		{"5", StreamID{5, 7}, false},
+		{"18446744073709551615-18446744073709551615", MaxStreamID, false},
+		{"18446744073709551616", StreamID{}, true},
+		{"1-", StreamID{}, true},
+		{"-1", StreamID{}, true},
+		{"a-b", StreamID{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStreamID(tt.in, 7)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseStreamID(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestStreamIDNextPrev(t *testing.T) {
+	if next, ok := (StreamID{1, math.MaxUint64}).Next(); !ok || next != (StreamID{2, 0}) {
+		t.Errorf("Next carries into ms: %v, %v", next, ok)
+	}
+	if _, ok := MaxStreamID.Next(); ok {
+		t.Error("MaxStreamID has a next ID")
+	}
+	if prev, ok := (StreamID{2, 0}).Prev(); !ok || prev != (StreamID{1, math.MaxUint64}) {
+		t.Errorf("Prev borrows from ms: %v, %v", prev, ok)
+	}
+	if _, ok := (StreamID{}).Prev(); ok {
+		t.Error("0-0 has a previous ID")
+	}
+}
+
+func TestXAddIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		last    StreamID
+		id      XAddID
+		want    StreamID
+		wantErr error
+	}{
+		{"explicit", StreamID{1, 1}, XAddID{ID: StreamID{2, 0}}, StreamID{2, 0}, nil},
+		{"explicit equal", StreamID{1, 1}, XAddID{ID: StreamID{1, 1}}, StreamID{}, ErrStreamIDTooSmall},
+		{"explicit smaller", StreamID{2, 0}, XAddID{ID: StreamID{1, 5}}, StreamID{}, ErrStreamIDTooSmall},
+		{"explicit zero", StreamID{}, XAddID{}, StreamID{}, ErrStreamIDZero},
+		{"auto seq new ms", StreamID{1, 5}, XAddID{ID: StreamID{Ms: 3}, AutoSeq: true}, StreamID{3, 0}, nil},
+		{"auto seq same ms", StreamID{3, 5}, XAddID{ID: StreamID{Ms: 3}, AutoSeq: true}, StreamID{3, 6}, nil},
+		{"auto seq zero ms", StreamID{}, XAddID{AutoSeq: true}, StreamID{0, 1}, nil},
+		{"auto seq smaller ms", StreamID{3, 0}, XAddID{ID: StreamID{Ms: 2}, AutoSeq: true}, StreamID{}, ErrStreamIDTooSmall},
+		{"auto seq exhausted", StreamID{3, math.MaxUint64}, XAddID{ID: StreamID{Ms: 3}, AutoSeq: true}, StreamID{}, ErrStreamIDTooSmall},
+		{"auto after future id", StreamID{math.MaxUint64 - 1, math.MaxUint64}, XAddID{Auto: true}, StreamID{Ms: math.MaxUint64}, nil},
+		{"auto exhausted", MaxStreamID, XAddID{Auto: true}, StreamID{}, ErrStreamExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newStreamCollection()
+			st.lastID = tt.last
+			got, err := st.nextID(tt.id)
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("nextID = %v, %v; want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestXAddFailureKeepsKeyAbsent(t *testing.T) {
+	s := newTestStorage(t)
+	if _, _, err := s.XAdd("st", XAddID{}, []string{"f", "v"}, false, StreamTrim{}); !errors.Is(err, ErrStreamIDZero) {
+		t.Fatalf("XAdd 0-0 err = %v", err)
+	}
+	if s.Exists("st") {
+		t.Error("failed XADD created the stream")
+	}
+	if _, added, err := s.XAdd("st", XAddID{Auto: true}, []string{"f", "v"}, true, StreamTrim{}); err != nil || added {
+		t.Errorf("XAdd NOMKSTREAM = %v, %v", added, err)
+	}
+	s.Set("str", "v", 0)
+	if _, _, err := s.XAdd("str", XAddID{Auto: true}, []string{"f", "v"}, false, StreamTrim{}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("XAdd to string err = %v, want ErrWrongType", err)
+	}
+}
+
+// newTestStream создаёт поток key с записями 1-0..n-0
+func newTestStream(t *testing.T, s *Storage, key string, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		if _, _, err := s.XAdd(key, XAddID{ID: StreamID{Ms: uint64(i)}}, []string{"f", "v"}, false, StreamTrim{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestXRange(t *testing.T) {
+	s := newTestStorage(t)
+	newTestStream(t, s, "st", 5)
+
+	tests := []struct {
+		name       string
+		start, end StreamID
+		count      int
+		rev        bool
+		want       []StreamID
+	}{
+		{"all", StreamID{}, MaxStreamID, 0, false, []StreamID{{1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}}},
+		{"inner", StreamID{2, 0}, StreamID{3, 0}, 0, false, []StreamID{{2, 0}, {3, 0}}},
+		{"count", StreamID{}, MaxStreamID, 2, false, []StreamID{{1, 0}, {2, 0}}},
+		{"reversed", StreamID{}, MaxStreamID, 2, true, []StreamID{{5, 0}, {4, 0}}},
+		{"between entries", StreamID{2, 1}, StreamID{2, 9}, 0, false, nil},
+		{"end before start", StreamID{4, 0}, StreamID{2, 0}, 0, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.XRange("st", tt.start, tt.end, tt.count, tt.rev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g := entryIDs(got); !slices.Equal(g, tt.want) {
+				t.Errorf("XRange = %v, want %v", g, tt.want)
+			}
+		})
+	}
+}
+
+func TestXDelAndTrim(t *testing.T) {
+	s := newTestStorage(t)
+	newTestStream(t, s, "st", 10)
+
+	if n, _ := s.XDel("st", []StreamID{{2, 0}, {2, 0}, {99, 0}}); n != 1 {
+		t.Errorf("XDel = %d, want 1", n)
+	}
+
+	tests := []struct {
+		trim    StreamTrim
+		trimmed int
+		firstID StreamID
+		wantLen int
+	}{
+		{StreamTrim{Strategy: TrimMaxLen, MaxLen: 100}, 0, StreamID{1, 0}, 9},
+		{StreamTrim{Strategy: TrimMaxLen, MaxLen: 5, Limit: 2}, 2, StreamID{4, 0}, 7},
+		{StreamTrim{Strategy: TrimMinID, MinID: StreamID{6, 0}}, 2, StreamID{6, 0}, 5},
+		{StreamTrim{Strategy: TrimMaxLen, MaxLen: 0}, 5, StreamID{}, 0},
+	}
+	for _, tt := range tests {
+		n, err := s.XTrim("st", tt.trim)
+		if err != nil || n != tt.trimmed {
+			t.Errorf("XTrim(%+v) = %d, %v; want %d", tt.trim, n, err, tt.trimmed)
+		}
+		got, _ := s.XRange("st", StreamID{}, MaxStreamID, 0, false)
+		if len(got) != tt.wantLen || (len(got) > 0 && got[0].ID != tt.firstID) {
+			t.Errorf("after XTrim(%+v) stream = %v", tt.trim, entryIDs(got))
+		}
+	}
+
+	// Пустой поток сохраняется вместе с последним идентификатором
+	if !s.Exists("st") {
+		t.Error("trimmed stream was removed")
+	}
+	if _, _, err := s.XAdd("st", XAddID{ID: StreamID{5, 0}}, []string{"f", "v"}, false, StreamTrim{}); !errors.Is(err, ErrStreamIDTooSmall) {
+		t.Errorf("XAdd below last ID err = %v, want ErrStreamIDTooSmall", err)
+	}
+}
+
+func TestConsumerGroupFlow(t *testing.T) {
+	s := newTestStorage(t)
+	newTestStream(t, s, "st", 3)
+
+	if err := s.XGroupCreate("st", "g", StreamID{}, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.XGroupCreate("st", "g", StreamID{}, false, false); !errors.Is(err, ErrBusyGroup) {
+		t.Errorf("duplicate group err = %v, want ErrBusyGroup", err)
+	}
+	if err := s.XGroupCreate("missing", "g", StreamID{}, false, false); !errors.Is(err, ErrStreamKeyRequired) {
+		t.Errorf("group on missing key err = %v, want ErrStreamKeyRequired", err)
+	}
+
+	read := func(consumer string, id StreamID, newOnly bool) []StreamID {
+		t.Helper()
+		res, err := s.XReadGroup("g", consumer, []string{"st"}, []StreamID{id}, []bool{newOnly}, 2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) == 0 {
+			return nil
+		}
+		return entryIDs(res[0].Entries)
+	}
+
+	if got := read("alice", StreamID{}, true); !slices.Equal(got, []StreamID{{1, 0}, {2, 0}}) {
+		t.Errorf("first read = %v", got)
+	}
+	if got := read("bob", StreamID{}, true); !slices.Equal(got, []StreamID{{3, 0}}) {
+		t.Errorf("second read = %v", got)
+	}
+	if got := read("bob", StreamID{}, true); got != nil {
+		t.Errorf("read past end = %v", got)
+	}
+	// История потребителя возвращает его неподтверждённые записи
+	if got := read("alice", StreamID{}, false); !slices.Equal(got, []StreamID{{1, 0}, {2, 0}}) {
+		t.Errorf("alice history = %v", got)
+	}
+
+	if n, _ := s.XAck("st", "g", []StreamID{{1, 0}, {1, 0}, {9, 0}}); n != 1 {
+		t.Errorf("XAck = %d, want 1", n)
+	}
+	summary, err := s.XPendingSummary("st", "g")
+	if err != nil || summary.Count != 2 || summary.Consumers["alice"] != 1 || summary.Consumers["bob"] != 1 {
+		t.Errorf("XPendingSummary = %+v, %v", summary, err)
+	}
+
+	// Запись, удалённая из потока, уходит из PEL при XCLAIM
+	s.XDel("st", []StreamID{{2, 0}})
+	claimed, err := s.XClaim("st", "g", "bob", 0, []StreamID{{2, 0}, {3, 0}}, XClaimOptions{})
+	if err != nil || !slices.Equal(entryIDs(claimed), []StreamID{{3, 0}}) {
+		t.Errorf("XClaim = %v, %v", entryIDs(claimed), err)
+	}
+	pending, _ := s.XPending("st", "g", 0, StreamID{}, MaxStreamID, 10, "")
+	if len(pending) != 1 || pending[0].ID != (StreamID{3, 0}) || pending[0].Consumer != "bob" || pending[0].DeliveryCount != 2 {
+		t.Errorf("XPending = %+v", pending)
+	}
+
+	// FORCE JUSTID добавляет запись в PEL без увеличения счётчика доставок
+	claimed, _ = s.XClaim("st", "g", "carol", 0, []StreamID{{1, 0}}, XClaimOptions{Force: true, JustID: true})
+	if len(claimed) != 1 {
+		t.Errorf("XClaim FORCE = %v", entryIDs(claimed))
+	}
+	if pending, _ := s.XPending("st", "g", 0, StreamID{}, MaxStreamID, 10, "carol"); len(pending) != 1 || pending[0].DeliveryCount != 0 {
+		t.Errorf("forced pending entry = %+v", pending)
+	}
+
+	if _, err := s.XReadGroup("nope", "c", []string{"st"}, []StreamID{{}}, []bool{true}, 0, false); err == nil {
+		t.Error("XReadGroup of missing group succeeded")
+	}
+}
@@ -0,0 +1,354 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxStringLength предельная длина строкового значения, как proto-max-bulk-len в Redis
+const MaxStringLength = 512 * 1024 * 1024
+
+var (
+	ErrStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	ErrLCSTooLarge   = errors.New("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+)
+
+// SetCondition условие, при котором SET записывает значение
+type SetCondition int
+
+const (
+	SetAlways      SetCondition = iota
+	SetIfNotExists              // NX
+	SetIfExists                 // XX
+)
+
+// SetOptions параметры команды SET
+type SetOptions struct {
+	Condition SetCondition
+	// Get — вернуть прежнее значение ключа
+	Get bool
+	// KeepTTL — сохранить TTL существующего ключа
+	KeepTTL bool
+	// ExpireAt момент истечения; нулевое значение — ключ без TTL
+	ExpireAt time.Time
+}
+
+// SetResult результат SET: прежнее значение и признак записи
+type SetResult struct {
+	Old     string
+	HadOld  bool
+	Applied bool
+}
+
+// setExpireAt устанавливает абсолютный срок жизни ключа. Нулевое время
+// снимает TTL, момент в прошлом сразу удаляет ключ. Вызывается под блокировкой на запись.
+func (s *Storage) setExpireAt(key string, at time.Time) {
+	switch {
+	case at.IsZero():
+		s.clearExpire(key)
+	case !at.After(time.Now()):
+		s.remove(key)
+	default:
+		s.setExpire(key, at)
+	}
+}
+
+// stringValue возвращает значение строкового объекта. Битовые карты хранятся
+// изменяемым срезом байт, чтобы SETBIT не копировал всю строку.
+func stringValue(obj *object) string {
+	if bits, ok := obj.value.([]byte); ok {
+		return string(bits)
+	}
+	return obj.value.(string)
+}
+
+// getString возвращает строку по ключу. Вызывается под блокировкой.
+func (s *Storage) getString(key string) (string, bool, error) {
+	obj, err := s.lookupType(key, TypeString)
+	if err != nil || obj == nil {
+		return "", false, err
+	}
+	return stringValue(obj), true, nil
+}
+
+// writeString возвращает строку для изменения. Вызывается под блокировкой на запись.
+func (s *Storage) writeString(key string) (string, bool, error) {
+	obj, err := s.lookupWriteType(key, TypeString)
+	if err != nil || obj == nil {
+		return "", false, err
+	}
+	return stringValue(obj), true, nil
+}
+
+// putString сохраняет строку, не трогая TTL ключа. Вызывается под блокировкой на запись.
+func (s *Storage) putString(key, value string) {
+	s.put(key, &object{typ: TypeString, value: value})
+}
+
+// SetWithOptions атомарно проверяет условие NX/XX и записывает значение
+func (s *Storage) SetWithOptions(key, value string, opts SetOptions) (SetResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result SetResult
+	obj := s.lookupWrite(key)
+	if obj != nil && opts.Get {
+		if obj.typ != TypeString {
+			return result, ErrWrongType
+		}
+		result.Old, result.HadOld = stringValue(obj), true
+	}
+
+	switch {
+	case opts.Condition == SetIfNotExists && obj != nil:
+		return result, nil
+	case opts.Condition == SetIfExists && obj == nil:
+		return result, nil
+	}
+
+	s.putString(key, value)
+	if !opts.KeepTTL {
+		s.setExpireAt(key, opts.ExpireAt)
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// MGet возвращает значения нескольких ключей; для отсутствующих
+// и нестроковых ключей found равен false
+func (s *Storage) MGet(keys []string) ([]string, []bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make([]string, len(keys))
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i], _ = s.getString(key)
+	}
+	return values, found
+}
+
+// MSet атомарно записывает пары ключ-значение, снимая их TTL.
+// При onlyNew ничего не записывает, если хотя бы один ключ существует.
+func (s *Storage) MSet(keys, values []string, onlyNew bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if onlyNew {
+		for _, key := range keys {
+			if s.lookupWrite(key) != nil {
+				return false
+			}
+		}
+	}
+
+	for i, key := range keys {
+		s.putString(key, values[i])
+		s.clearExpire(key)
+	}
+	return true
+}
+
+// GetDel возвращает значение строки и удаляет ключ
+func (s *Storage) GetDel(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, found, err := s.writeString(key)
+	if err != nil || !found {
+		return "", false, err
+	}
+	s.remove(key)
+	return value, true, nil
+}
+
+// GetEx возвращает значение строки и меняет её TTL: устанавливает expireAt
+// или, при persist, снимает его. Без обоих параметров TTL не меняется.
+func (s *Storage) GetEx(key string, expireAt time.Time, persist bool) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, found, err := s.writeString(key)
+	if err != nil || !found {
+		return "", false, err
+	}
+
+	if persist || !expireAt.IsZero() {
+		s.setExpireAt(key, expireAt)
+	}
+	return value, true, nil
+}
+
+// Append дописывает value в конец строки и возвращает новую длину
+func (s *Storage) Append(key, value string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, _, err := s.writeString(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(current)+len(value) > MaxStringLength {
+		return 0, ErrStringTooLong
+	}
+
+	current += value
+	s.putString(key, current)
+	return len(current), nil
+}
+
+// StrLen возвращает длину строки
+func (s *Storage) StrLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, _, err := s.getString(key)
+	return len(value), err
+}
+
+// GetRange возвращает подстроку между индексами start и end включительно;
+// отрицательные индексы отсчитываются от конца строки
+func (s *Storage) GetRange(key string, start, end int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, _, err := s.getString(key)
+	if err != nil {
+		return "", err
+	}
+	from, to := normalizeRange(start, end, len(value))
+	return value[from:to], nil
+}
+
+// SetRange перезаписывает строку начиная с offset, дополняя её нулевыми
+// байтами при необходимости, и возвращает новую длину
+func (s *Storage) SetRange(key string, offset int, value string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, _, err := s.writeString(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) == 0 {
+		return len(current), nil
+	}
+	// Сравнение без сложения: offset+len(value) может переполниться
+	if offset < 0 || offset > MaxStringLength-len(value) {
+		return 0, ErrStringTooLong
+	}
+
+	buf := []byte(current)
+	if need := offset + len(value); need > len(buf) {
+		buf = append(buf, make([]byte, need-len(buf))...)
+	}
+	copy(buf[offset:], value)
+
+	s.putString(key, string(buf))
+	return len(buf), nil
+}
+
+// LCSMatch совпадающий фрагмент двух строк: границы включительно
+type LCSMatch struct {
+	A   [2]int
+	B   [2]int
+	Len int
+}
+
+// LCSResult наибольшая общая подпоследовательность и её фрагменты,
+// перечисленные от конца строк к началу
+type LCSResult struct {
+	Sequence string
+	Matches  []LCSMatch
+}
+
+// LCS находит наибольшую общую подпоследовательность строк по двум ключам.
+// Фрагменты короче minMatchLen в Matches не попадают.
+func (s *Storage) LCS(key1, key2 string, minMatchLen int) (LCSResult, error) {
+	s.mu.RLock()
+	a, _, err1 := s.getString(key1)
+	b, _, err2 := s.getString(key2)
+	s.mu.RUnlock()
+
+	if err1 != nil {
+		return LCSResult{}, err1
+	}
+	if err2 != nil {
+		return LCSResult{}, err2
+	}
+	if (len(a)+1)*(len(b)+1) > MaxStringLength/4 {
+		return LCSResult{}, ErrLCSTooLarge
+	}
+	return lcs(a, b, minMatchLen), nil
+}
+
+// lcs вычисляет наибольшую общую подпоследовательность динамическим
+// программированием и восстанавливает её проходом от конца таблицы
+func lcs(a, b string, minMatchLen int) LCSResult {
+	width := len(b) + 1
+	table := make([]uint32, (len(a)+1)*width)
+	at := func(i, j int) uint32 { return table[i*width+j] }
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				table[i*width+j] = at(i-1, j-1) + 1
+			case at(i-1, j) > at(i, j-1):
+				table[i*width+j] = at(i-1, j)
+			default:
+				table[i*width+j] = at(i, j-1)
+			}
+		}
+	}
+
+	var result LCSResult
+	seq := make([]byte, at(len(a), len(b)))
+	idx := len(seq)
+
+	// Текущий фрагмент; inRange == false, пока фрагмент не начат
+	var aStart, aEnd, bStart, bEnd int
+	inRange := false
+
+	i, j := len(a), len(b)
+	for i > 0 && j > 0 {
+		emit := false
+		if a[i-1] == b[j-1] {
+			idx--
+			seq[idx] = a[i-1]
+			if !inRange {
+				aStart, aEnd, bStart, bEnd = i-1, i-1, j-1, j-1
+				inRange = true
+			} else {
+				aStart--
+				bStart--
+			}
+			if aStart == 0 || bStart == 0 {
+				emit = true
+			}
+			i--
+			j--
+		} else {
+			if at(i-1, j) > at(i, j-1) {
+				i--
+			} else {
+				j--
+			}
+			emit = inRange
+		}
+
+		if emit {
+			if length := aEnd - aStart + 1; length >= minMatchLen {
+				result.Matches = append(result.Matches, LCSMatch{
+					A:   [2]int{aStart, aEnd},
+					B:   [2]int{bStart, bEnd},
+					Len: length,
+				})
+			}
+			inRange = false
+		}
+	}
+
+	result.Sequence = string(seq)
+	return result
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetWithOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        SetOptions
+		exists      bool
+		wantApplied bool
+		wantValue   string
+	}{
+		{"plain", SetOptions{}, true, true, "new"},
+		{"NX on existing", SetOptions{Condition: SetIfNotExists}, true, false, "old"},
+		{"NX on missing", SetOptions{Condition: SetIfNotExists}, false, true, "new"},
+		{"XX on existing", SetOptions{Condition: SetIfExists}, true, true, "new"},
+		{"XX on missing", SetOptions{Condition: SetIfExists}, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			if tt.exists {
+				s.Set("k", "old", 0)
+			}
+			res, err := s.SetWithOptions("k", "new", tt.opts)
+			if err != nil || res.Applied != tt.wantApplied {
+				t.Errorf("SetWithOptions = %+v, %v; want applied %v", res, err, tt.wantApplied)
+			}
+			if got, _, _ := s.Get("k"); got != tt.wantValue {
+				t.Errorf("value = %q, want %q", got, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestSetGetAndTTL(t *testing.T) {
+	s := newTestStorage(t)
+	s.SetWithOptions("k", "v1", SetOptions{ExpireAt: time.Now().Add(time.Hour)})
+
+	// KEEPTTL сохраняет срок жизни, обычный SET его снимает
+	res, err := s.SetWithOptions("k", "v2", SetOptions{Get: true, KeepTTL: true})
+	if err != nil || !res.HadOld || res.Old != "v1" {
+		t.Errorf("SET GET = %+v, %v", res, err)
+	}
+	if at, _ := s.ExpireTime("k"); at.IsZero() {
+		t.Error("KEEPTTL dropped the expiration")
+	}
+	s.SetWithOptions("k", "v3", SetOptions{})
+	if at, found := s.ExpireTime("k"); !found || !at.IsZero() {
+		t.Errorf("ExpireTime after plain SET = %v, %v; want no TTL", at, found)
+	}
+
+	// Срок в прошлом сразу удаляет ключ
+	s.SetWithOptions("k", "v4", SetOptions{ExpireAt: time.Now().Add(-time.Second)})
+	if s.Exists("k") {
+		t.Error("key with past expiration exists")
+	}
+
+	s.SAdd("set", []string{"a"})
+	if _, err := s.SetWithOptions("set", "v", SetOptions{Get: true}); !errors.Is(err, ErrWrongType) {
+		t.Errorf("SET GET on set err = %v, want ErrWrongType", err)
+	}
+}
+
+func TestGetRange(t *testing.T) {
+	s := newTestStorage(t)
+	s.Set("k", "Hello", 0)
+
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{0, -1, "Hello"},
+		{1, 3, "ell"},
+		{-3, -1, "llo"},
+		{3, 1, ""},
+		{5, 10, ""},
+		{math.MinInt, math.MaxInt, "Hello"},
+		{math.MaxInt, math.MaxInt, ""},
+	}
+	for _, tt := range tests {
+		if got, err := s.GetRange("k", tt.start, tt.end); err != nil || got != tt.want {
+			t.Errorf("GetRange(%d, %d) = %q, %v; want %q", tt.start, tt.end, got, err, tt.want)
+		}
+	}
+}
+
+func TestSetRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		offset  int
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"overwrite", "Hello World", 6, "Redis", "Hello Redis", nil},
+		{"pad with zeros", "ab", 4, "c", "ab\x00\x00c", nil},
+		{"missing key", "", 2, "x", "\x00\x00x", nil},
+		{"empty value", "ab", 100, "", "ab", nil},
+		{"past limit", "", MaxStringLength, "x", "", ErrStringTooLong},
+		{"overflowing offset", "", math.MaxInt, "x", "", ErrStringTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			if tt.initial != "" {
+				s.Set("k", tt.initial, 0)
+			}
+			n, err := s.SetRange("k", tt.offset, tt.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetRange err = %v, want %v", err, tt.wantErr)
+			}
+			got, _, _ := s.Get("k")
+			if got != tt.want || (err == nil && n != len(tt.want)) {
+				t.Errorf("SetRange = %d, value %q; want %q", n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendAndMSet(t *testing.T) {
+	s := newTestStorage(t)
+	if n, _ := s.Append("k", "ab"); n != 2 {
+		t.Errorf("Append to missing key = %d", n)
+	}
+	if n, _ := s.Append("k", "cd"); n != 4 {
+		t.Errorf("Append = %d", n)
+	}
+
+	if s.MSet([]string{"new", "k"}, []string{"1", "2"}, true) {
+		t.Error("MSETNX with an existing key succeeded")
+	}
+	if s.Exists("new") {
+		t.Error("failed MSETNX wrote a key")
+	}
+	if !s.MSet([]string{"new", "k"}, []string{"1", "2"}, false) {
+		t.Error("MSET failed")
+	}
+	values, found := s.MGet([]string{"new", "k", "missing"})
+	if values[0] != "1" || values[1] != "2" || !found[0] || found[2] {
+		t.Errorf("MGet = %q, %v", values, found)
+	}
+}
+
+func TestLCS(t *testing.T) {
+	s := newTestStorage(t)
+	s.Set("a", "ohmytext", 0)
+	s.Set("b", "mynewtext", 0)
+
+	res, err := s.LCS("a", "b", 0)
+	if err != nil || res.Sequence != "mytext" {
+		t.Fatalf("LCS = %q, %v; want mytext", res.Sequence, err)
+	}
+	if len(res.Matches) != 2 || res.Matches[0].Len != 4 || res.Matches[1].Len != 2 {
+		t.Errorf("matches = %+v", res.Matches)
+	}
+	if res, _ := s.LCS("a", "b", 3); len(res.Matches) != 1 {
+		t.Errorf("MINMATCHLEN 3 matches = %+v", res.Matches)
+	}
+	if res, err := s.LCS("a", "missing", 0); err != nil || res.Sequence != "" {
+		t.Errorf("LCS with missing key = %+v, %v", res, err)
+	}
+
+	s.Set("big", strings.Repeat("x", 20000), 0)
+	if _, err := s.LCS("big", "big", 0); !errors.Is(err, ErrLCSTooLarge) {
+		t.Errorf("huge LCS err = %v, want ErrLCSTooLarge", err)
+	}
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Параметры BM25
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// stopWords английские стоп-слова, которые не индексируются и
+// выбрасываются из запросов
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true, "to": true,
+	"was": true, "will": true, "with": true,
+}
+
+// textToken слово текста: в нижнем регистре, его основа и байтовые
+// границы в исходной строке
+type textToken struct {
+	term       string
+	stem       string
+	start, end int
+}
+
+// analyze разбивает текст на слова из букв и цифр, приводит их к нижнему
+// регистру и отбрасывает стоп-слова. Позиция слова — его индекс в результате.
+func analyze(text string) []textToken {
+	var tokens []textToken
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		term := strings.ToLower(text[start:end])
+		if !stopWords[term] {
+			tokens = append(tokens, textToken{term: term, stem: Stem(term), start: start, end: end})
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			flush(i)
+		}
+	}
+	flush(len(text))
+	return tokens
+}
+
+// queryText текстовое условие: слово (совпадает по основе), префикс
+// или фраза из подряд идущих слов. Пустой fields — все поля TEXT.
+type queryText struct {
+	fields []string
+	words  []string
+	stems  []string
+	prefix bool
+}
+
+func newQueryText(fields, words []string, prefix bool) *queryText {
+	q := &queryText{fields: fields, words: words, prefix: prefix}
+	for _, w := range words {
+		q.stems = append(q.stems, Stem(w))
+	}
+	return q
+}
+
+func (q *queryText) check(idx *hashIndex) error {
+	for _, field := range q.fields {
+		if err := checkField(idx, field, IndexText); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *queryText) candidates(idx *hashIndex) map[string]struct{} {
+	result := make(map[string]struct{})
+	if q.prefix {
+		for word, keys := range idx.words {
+			if strings.HasPrefix(word, q.words[0]) {
+				for key := range keys {
+					result[key] = struct{}{}
+				}
+			}
+		}
+		return result
+	}
+
+	for key := range idx.stems[q.stems[0]] {
+		inAll := true
+		for _, stem := range q.stems[1:] {
+			if _, found := idx.stems[stem][key]; !found {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			result[key] = struct{}{}
+		}
+	}
+	return result
+}
+
+// tokenMatches сообщает, подходит ли слово текста i-му слову условия
+func (q *queryText) tokenMatches(t textToken, i int) bool {
+	if q.prefix {
+		return strings.HasPrefix(t.term, q.words[0])
+	}
+	return t.term == q.words[i] || t.stem == q.stems[i]
+}
+
+// occurrences число вхождений условия в поле
+func (q *queryText) occurrences(tokens []textToken) int {
+	n := 0
+	for p := 0; p+len(q.words) <= len(tokens); p++ {
+		matched := true
+		for i := range q.words {
+			if !q.tokenMatches(tokens[p+i], i) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			n++
+		}
+	}
+	return n
+}
+
+func (q *queryText) searchFields(doc *indexDoc) []string {
+	if len(q.fields) > 0 {
+		return q.fields
+	}
+	return doc.idx.text
+}
+
+// frequency взвешенная частота: сумма вхождений по полям с весами полей
+func (q *queryText) frequency(doc *indexDoc) float64 {
+	var tf float64
+	for _, field := range q.searchFields(doc) {
+		tf += doc.idx.weights[field] * float64(q.occurrences(doc.tokens(field)))
+	}
+	return tf
+}
+
+func (q *queryText) match(doc *indexDoc) bool {
+	for _, field := range q.searchFields(doc) {
+		if q.occurrences(doc.tokens(field)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// textTerms собирает текстовые условия запроса
+func textTerms(node queryNode) []*queryText {
+	switch n := node.(type) {
+	case *queryText:
+		return []*queryText{n}
+	case queryAnd:
+		var result []*queryText
+		for _, child := range n {
+			result = append(result, textTerms(child)...)
+		}
+		return result
+	case queryOr:
+		return textTerms(queryAnd(n))
+	}
+	return nil
+}
+
+// bm25 оценка документа по текстовым условиям запроса. df — число
+// документов, в которых встречается каждое условие.
+func bm25(doc *indexDoc, terms []*queryText, df []int) float64 {
+	idx := doc.idx
+	n := float64(len(idx.docs))
+	if n == 0 || idx.textLength == 0 {
+		return 0
+	}
+	avgLength := float64(idx.textLength) / n
+
+	length := 0
+	for _, field := range idx.text {
+		length += len(doc.tokens(field))
+	}
+
+	var score float64
+	for i, term := range terms {
+		tf := term.frequency(doc)
+		if tf == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-float64(df[i])+0.5)/(float64(df[i])+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(length)/avgLength))
+	}
+	return score
+}
+
+// highlight обрамляет слова value, подходящие под условия, тегами open и close
+func highlight(value string, terms []*queryText, open, close string) string {
+	var b strings.Builder
+	last := 0
+	for _, t := range analyze(value) {
+		hit := false
+		for _, term := range terms {
+			for i := range term.words {
+				hit = hit || term.tokenMatches(t, i)
+			}
+		}
+		if hit {
+			b.WriteString(value[last:t.start])
+			b.WriteString(open)
+			b.WriteString(value[t.start:t.end])
+			b.WriteString(close)
+			last = t.end
+		}
+	}
+	b.WriteString(value[last:])
+	return b.String()
+}
+
+// isWordRune сообщает, может ли символ входить в слово запроса
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// readWord читает слово запроса с позиции pos
+func readWord(s string, pos int) (string, int) {
+	end := pos
+	for end < len(s) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if !isWordRune(r) {
+			break
+		}
+		end += size
+	}
+	return s[pos:end], end
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+)
+
+// newTestTextIndex создаёт индекс idx с полями TEXT title (вес 5) и body
+func newTestTextIndex(t *testing.T, s *Storage, docs map[string][2]string) {
+	t.Helper()
+	err := s.IdxCreate(IndexDefinition{
+		Name:   "idx",
+		Schema: []IndexField{{Name: "title", Type: IndexText, Weight: 5}, {Name: "body", Type: IndexText, Weight: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, doc := range docs {
+		s.HSet(key, []string{"title", "body"}, []string{doc[0], doc[1]})
+	}
+}
+
+func TestTextSearch(t *testing.T) {
+	s := newTestStorage(t)
+	newTestTextIndex(t, s, map[string][2]string{
+		"a": {"Running shoes", "The quick brown fox"},
+		"b": {"Walking", "A quick fox, brown; it runs"},
+		"c": {"Programming in Go", "Программы на Go"},
+	})
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"runs", []string{"a", "b"}},
+		{"RUN", []string{"a", "b"}},
+		{`"quick brown"`, []string{"a"}},
+		// Стоп-слова не занимают позиций ни в тексте, ни во фразе
+		{`"the quick brown"`, []string{"a"}},
+		{`"brown runs"`, []string{"b"}},
+		{"quick brown", []string{"a", "b"}},
+		{"prog*", []string{"c"}},
+		{"программы", []string{"c"}},
+		{"@title:run", []string{"a"}},
+		{"@body:(fox | go)", []string{"a", "b", "c"}},
+		{"@title:walking fox", []string{"b"}},
+		{"the", []string{}},
+		{"missing", []string{}},
+	}
+	for _, tt := range tests {
+		got := searchKeys(t, s, tt.query, IndexSearchOptions{})
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("IdxSearch(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestTextBM25Ranking(t *testing.T) {
+	tests := []struct {
+		name string
+		docs map[string][2]string
+		want []string
+	}{
+		{"term frequency", map[string][2]string{
+			"once":  {"", "apple pear plum"},
+			"twice": {"", "apple apple plum"},
+		}, []string{"twice", "once"}},
+		{"shorter document", map[string][2]string{
+			"long":  {"", "apple pear plum cherry grape melon"},
+			"short": {"", "apple pear"},
+		}, []string{"short", "long"}},
+		{"field weight", map[string][2]string{
+			"body":  {"pear", "apple"},
+			"title": {"apple", "pear"},
+		}, []string{"title", "body"}},
+		{"equal score by key", map[string][2]string{
+			"y": {"", "apple"},
+			"x": {"", "apple"},
+		}, []string{"x", "y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			newTestTextIndex(t, s, tt.docs)
+			if got := searchKeys(t, s, "apple", IndexSearchOptions{}); !slices.Equal(got, tt.want) {
+				t.Errorf("IdxSearch = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextHighlight(t *testing.T) {
+	s := newTestStorage(t)
+	newTestTextIndex(t, s, map[string][2]string{"a": {"Foxes", "The Fox runs; FOXES ran"}})
+
+	q, _ := ParseIndexQuery("fox")
+	tests := []struct {
+		opts IndexSearchOptions
+		want []string
+	}{
+		{IndexSearchOptions{Limit: 1}, []string{"body", "The Fox runs; FOXES ran", "title", "Foxes"}},
+		{IndexSearchOptions{Limit: 1, Highlight: true, HighlightOpen: "<b>", HighlightClose: "</b>"},
+			[]string{"body", "The <b>Fox</b> runs; <b>FOXES</b> ran", "title", "<b>Foxes</b>"}},
+		{IndexSearchOptions{Limit: 1, Highlight: true, HighlightFields: []string{"title"}, HighlightOpen: "[", HighlightClose: "]"},
+			[]string{"body", "The Fox runs; FOXES ran", "title", "[Foxes]"}},
+	}
+	for _, tt := range tests {
+		_, docs, err := s.IdxSearch("idx", q, tt.opts)
+		if err != nil || len(docs) != 1 || !slices.Equal(docs[0].Fields, tt.want) {
+			t.Errorf("IdxSearch(%+v) = %v, %v; want %q", tt.opts, docs, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+// tsRetentionBatch столько рядов обрезает за один цикл активного удаления
+const tsRetentionBatch = 20
+
+var (
+	ErrTSExists        = errors.New("ERR TSDB: key already exists")
+	ErrTSNotFound      = errors.New("ERR TSDB: the key does not exist")
+	ErrTSTooOld        = errors.New("ERR TSDB: Timestamp is older than retention")
+	ErrTSDuplicate     = errors.New("ERR TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+	ErrTSSameRuleKeys  = errors.New("ERR TSDB: the source key and destination key should be different")
+	ErrTSRuleExists    = errors.New("ERR TSDB: the destination key already has a src rule")
+	ErrTSRuleDestChain = errors.New("ERR TSDB: the destination key already has a dst rule")
+)
+
+// DuplicatePolicy поведение при добавлении отсчёта с уже занятой меткой времени
+type DuplicatePolicy int
+
+const (
+	// DuplicateUnset означает политику ряда; для ряда — BLOCK
+	DuplicateUnset DuplicatePolicy = iota
+	DuplicateBlock
+	DuplicateFirst
+	DuplicateLast
+	DuplicateMin
+	DuplicateMax
+	DuplicateSum
+)
+
+// Aggregation функция агрегации отсчётов в корзине
+type Aggregation int
+
+const (
+	AggNone Aggregation = iota
+	AggAvg
+	AggSum
+	AggMin
+	AggMax
+	AggCount
+	AggFirst
+	AggLast
+)
+
+// TSLabel метка временного ряда
+type TSLabel struct {
+	Name, Value string
+}
+
+// TSSample отсчёт: метка времени в миллисекундах и значение
+type TSSample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// TSOptions параметры ряда. Retention — глубина хранения в миллисекундах
+// относительно последнего отсчёта, а в фоновом цикле — относительно
+// текущего времени; 0 — без ограничения.
+type TSOptions struct {
+	Retention int64
+	Duplicate DuplicatePolicy
+	Labels    []TSLabel
+}
+
+// TSRangeQuery выборка TS.RANGE: отсчёты [From, To], при Aggregation —
+// агрегаты по корзинам длиной Bucket миллисекунд. Count = 0 — без ограничения.
+type TSRangeQuery struct {
+	From, To    int64
+	Count       int
+	Aggregation Aggregation
+	Bucket      int64
+	Reverse     bool
+}
+
+// TSFilter условие TS.MRANGE на метку: при Equal значение метки входит
+// в Values, иначе не входит. Пустой Values означает отсутствие метки.
+type TSFilter struct {
+	Label  string
+	Values []string
+	Equal  bool
+}
+
+// TSSeriesRange результат TS.MRANGE для одного ряда
+type TSSeriesRange struct {
+	Key     string
+	Labels  []TSLabel
+	Samples []TSSample
+}
+
+// tsRule правило компактизации: агрегаты корзин исходного ряда пишутся в dest
+type tsRule struct {
+	dest        string
+	aggregation Aggregation
+	bucket      int64
+	align       int64
+
+	// Открытая корзина и накопленные в ней отсчёты
+	open    bool
+	start   int64
+	samples []TSSample
+}
+
+// TimeSeries отсчёты упорядочены по времени
+type TimeSeries struct {
+	samples   []TSSample
+	retention int64
+	duplicate DuplicatePolicy
+	labels    []TSLabel
+	rules     []*tsRule
+	source    string
+}
+
+func newTimeSeries(opts TSOptions) *TimeSeries {
+	duplicate := opts.Duplicate
+	if duplicate == DuplicateUnset {
+		duplicate = DuplicateBlock
+	}
+	return &TimeSeries{retention: opts.Retention, duplicate: duplicate, labels: opts.Labels}
+}
+
+func (ts *TimeSeries) last() (int64, bool) {
+	if len(ts.samples) == 0 {
+		return 0, false
+	}
+	return ts.samples[len(ts.samples)-1].Timestamp, true
+}
+
+// minTimestamp наименьшая метка времени, которую ещё хранит ряд
+func (ts *TimeSeries) minTimestamp() int64 {
+	last, ok := ts.last()
+	if ts.retention == 0 || !ok {
+		return math.MinInt64
+	}
+	return last - ts.retention
+}
+
+// trim удаляет отсчёты старше глубины хранения
+func (ts *TimeSeries) trim() {
+	ts.trimBefore(ts.minTimestamp())
+}
+
+// trimBefore удаляет отсчёты с меткой времени меньше lo
+func (ts *TimeSeries) trimBefore(lo int64) {
+	i := sort.Search(len(ts.samples), func(i int) bool { return ts.samples[i].Timestamp >= lo })
+	if i > 0 {
+		ts.samples = append(ts.samples[:0], ts.samples[i:]...)
+	}
+}
+
+// upsert добавляет отсчёт или, если метка времени занята, применяет политику
+func (ts *TimeSeries) upsert(sample TSSample, policy DuplicatePolicy) error {
+	if sample.Timestamp < ts.minTimestamp() {
+		return ErrTSTooOld
+	}
+	if policy == DuplicateUnset {
+		policy = ts.duplicate
+	}
+
+	i := sort.Search(len(ts.samples), func(i int) bool { return ts.samples[i].Timestamp >= sample.Timestamp })
+	if i == len(ts.samples) || ts.samples[i].Timestamp != sample.Timestamp {
+		ts.samples = append(ts.samples, TSSample{})
+		copy(ts.samples[i+1:], ts.samples[i:])
+		ts.samples[i] = sample
+		return nil
+	}
+
+	current := &ts.samples[i].Value
+	switch policy {
+	case DuplicateBlock:
+		return ErrTSDuplicate
+	case DuplicateLast:
+		*current = sample.Value
+	case DuplicateMin:
+		*current = math.Min(*current, sample.Value)
+	case DuplicateMax:
+		*current = math.Max(*current, sample.Value)
+	case DuplicateSum:
+		*current += sample.Value
+	}
+	return nil
+}
+
+// aggregate вычисляет агрегат непустого набора отсчётов
+func aggregateSamples(agg Aggregation, samples []TSSample) float64 {
+	switch agg {
+	case AggCount:
+		return float64(len(samples))
+	case AggFirst:
+		return samples[0].Value
+	case AggLast:
+		return samples[len(samples)-1].Value
+	}
+
+	result := samples[0].Value
+	for _, s := range samples[1:] {
+		switch agg {
+		case AggMin:
+			result = math.Min(result, s.Value)
+		case AggMax:
+			result = math.Max(result, s.Value)
+		default:
+			result += s.Value
+		}
+	}
+	if agg == AggAvg {
+		result /= float64(len(samples))
+	}
+	return result
+}
+
+// bucketStart начало корзины, в которую попадает метка времени
+func bucketStart(timestamp, bucket, align int64) int64 {
+	offset := (timestamp - align) % bucket
+	if offset < 0 {
+		offset += bucket
+	}
+	return timestamp - offset
+}
+
+// query возвращает отсчёты или агрегаты по запросу
+func (ts *TimeSeries) query(q TSRangeQuery) []TSSample {
+	from := max(q.From, ts.minTimestamp())
+	lo := sort.Search(len(ts.samples), func(i int) bool { return ts.samples[i].Timestamp >= from })
+	hi := sort.Search(len(ts.samples), func(i int) bool { return ts.samples[i].Timestamp > q.To })
+	if lo >= hi {
+		return []TSSample{}
+	}
+	samples := ts.samples[lo:hi]
+
+	var result []TSSample
+	if q.Aggregation == AggNone {
+		result = append(result, samples...)
+	} else {
+		for i := 0; i < len(samples); {
+			start := bucketStart(samples[i].Timestamp, q.Bucket, 0)
+			j := i
+			// Разность вместо start+q.Bucket: сумма переполняется у MaxInt64
+			for j < len(samples) && samples[j].Timestamp-start < q.Bucket {
+				j++
+			}
+			result = append(result, TSSample{Timestamp: start, Value: aggregateSamples(q.Aggregation, samples[i:j])})
+			i = j
+		}
+	}
+
+	if q.Reverse {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	if q.Count > 0 && len(result) > q.Count {
+		result = result[:q.Count]
+	}
+	return result
+}
+
+// getTimeSeries возвращает ряд или ErrTSNotFound. Вызывается под блокировкой.
+func (s *Storage) getTimeSeries(key string) (*TimeSeries, error) {
+	obj, err := s.lookupType(key, TypeTimeSeries)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, ErrTSNotFound
+	}
+	return obj.value.(*TimeSeries), nil
+}
+
+// trimTimeSeries удаляет из не более чем tsRetentionBatch рядов с глубиной
+// хранения отсчёты старше now на эту глубину. Так ряд, в который перестали
+// писать, не хранит старые отсчёты вечно; при записи ряд обрезается
+// относительно последнего отсчёта в tsAdd. Ряды выбираются в случайном
+// порядке обхода map, как ключи в активном удалении Redis.
+func (s *Storage) trimTimeSeries(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	visited := 0
+	for key := range s.tsRetention {
+		if visited == tsRetentionBatch {
+			break
+		}
+		visited++
+
+		var ts *TimeSeries
+		if obj, exists := s.data[key]; exists {
+			ts, _ = obj.value.(*TimeSeries)
+		}
+		if ts == nil || ts.retention == 0 {
+			delete(s.tsRetention, key)
+			continue
+		}
+		ts.trimBefore(now.UnixMilli() - ts.retention)
+	}
+}
+
+// tsAdd добавляет отсчёт в ряд и передаёт закрытые корзины в ряды
+// компактизации. Вызывается под блокировкой на запись.
+func (s *Storage) tsAdd(ts *TimeSeries, sample TSSample, policy DuplicatePolicy) error {
+	last, hasLast := ts.last()
+	if err := ts.upsert(sample, policy); err != nil {
+		return err
+	}
+	ts.trim()
+
+	// Опоздавшие отсчёты в уже закрытые корзины не пересчитываются
+	if hasLast && sample.Timestamp < last {
+		return nil
+	}
+	for _, rule := range ts.rules {
+		start := bucketStart(sample.Timestamp, rule.bucket, rule.align)
+		if rule.open && start > rule.start {
+			if dest, err := s.getTimeSeries(rule.dest); err == nil {
+				closed := TSSample{Timestamp: rule.start, Value: aggregateSamples(rule.aggregation, rule.samples)}
+				s.tsAdd(dest, closed, DuplicateLast)
+			}
+			rule.open = false
+		}
+		if !rule.open {
+			rule.open, rule.start, rule.samples = true, start, rule.samples[:0]
+		}
+		if n := len(rule.samples); n > 0 && rule.samples[n-1].Timestamp == sample.Timestamp {
+			rule.samples[n-1] = ts.samples[len(ts.samples)-1]
+		} else {
+			rule.samples = append(rule.samples, sample)
+		}
+	}
+	return nil
+}
+
+// TSCreate создаёт пустой ряд
+func (s *Storage) TSCreate(key string, opts TSOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookupWrite(key) != nil {
+		return ErrTSExists
+	}
+	s.put(key, &object{typ: TypeTimeSeries, value: newTimeSeries(opts)})
+	return nil
+}
+
+// TSAdd добавляет отсчёт, создавая ряд с параметрами opts при его
+// отсутствии. policy переопределяет политику дубликатов ряда.
+func (s *Storage) TSAdd(key string, sample TSSample, opts TSOptions, policy DuplicatePolicy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, err := s.lookupWriteType(key, TypeTimeSeries)
+	if err != nil {
+		return err
+	}
+	if obj == nil {
+		obj = &object{typ: TypeTimeSeries, value: newTimeSeries(opts)}
+		s.put(key, obj)
+	}
+	return s.tsAdd(obj.value.(*TimeSeries), sample, policy)
+}
+
+// TSMAdd добавляет отсчёты в существующие ряды; ошибка одного отсчёта
+// не мешает остальным
+func (s *Storage) TSMAdd(keys []string, samples []TSSample) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		obj, err := s.lookupWriteType(key, TypeTimeSeries)
+		switch {
+		case err != nil:
+			errs[i] = err
+		case obj == nil:
+			errs[i] = ErrTSNotFound
+		default:
+			errs[i] = s.tsAdd(obj.value.(*TimeSeries), samples[i], DuplicateUnset)
+		}
+	}
+	return errs
+}
+
+// TSRange возвращает отсчёты ряда по запросу
+func (s *Storage) TSRange(key string, q TSRangeQuery) ([]TSSample, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ts, err := s.getTimeSeries(key)
+	if err != nil {
+		return nil, err
+	}
+	return ts.query(q), nil
+}
+
+// matches проверяет метки ряда по всем условиям
+func (ts *TimeSeries) matches(filters []TSFilter) bool {
+	for _, f := range filters {
+		value, found := "", false
+		for _, l := range ts.labels {
+			if l.Name == f.Label {
+				value, found = l.Value, true
+				break
+			}
+		}
+
+		in := false
+		if len(f.Values) == 0 {
+			in = !found
+		} else if found {
+			for _, v := range f.Values {
+				in = in || v == value
+			}
+		}
+		if in != f.Equal {
+			return false
+		}
+	}
+	return true
+}
+
+// TSMRange выполняет запрос над всеми рядами, метки которых подходят
+// под условия. Результаты упорядочены по ключу.
+func (s *Storage) TSMRange(filters []TSFilter, q TSRangeQuery) []TSSeriesRange {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []TSSeriesRange
+	for key := range s.data {
+		obj := s.lookup(key)
+		if obj == nil || obj.typ != TypeTimeSeries {
+			continue
+		}
+		ts := obj.value.(*TimeSeries)
+		if !ts.matches(filters) {
+			continue
+		}
+		result = append(result, TSSeriesRange{
+			Key:     key,
+			Labels:  append([]TSLabel(nil), ts.labels...),
+			Samples: ts.query(q),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// TSCreateRule добавляет правило компактизации src в dest. Каждый ряд
+// может быть приёмником только одного правила и не может быть одновременно
+// источником и приёмником.
+func (s *Storage) TSCreateRule(src, dest string, agg Aggregation, bucket, align int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if src == dest {
+		return ErrTSSameRuleKeys
+	}
+	source, err := s.getTimeSeries(src)
+	if err != nil {
+		return err
+	}
+	target, err := s.getTimeSeries(dest)
+	if err != nil {
+		return err
+	}
+	if target.source != "" {
+		return ErrTSRuleExists
+	}
+	if source.source != "" || len(target.rules) > 0 {
+		return ErrTSRuleDestChain
+	}
+
+	source.rules = append(source.rules, &tsRule{dest: dest, aggregation: agg, bucket: bucket, align: align})
+	target.source = src
+	return nil
+}
+
+// clone возвращает копию отсчётов, параметров и меток ряда. Правила
+// компактизации и связь с исходным рядом не копируются.
+func (ts *TimeSeries) clone() *TimeSeries {
+	return &TimeSeries{
+		samples:   slices.Clone(ts.samples),
+		retention: ts.retention,
+		duplicate: ts.duplicate,
+		labels:    slices.Clone(ts.labels),
+	}
+}
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// addSamples добавляет отсчёты value = timestamp в ряд key
+func addSamples(t *testing.T, s *Storage, key string, timestamps ...int64) {
+	t.Helper()
+	for _, ts := range timestamps {
+		if err := s.TSAdd(key, TSSample{Timestamp: ts, Value: float64(ts)}, TSOptions{}, DuplicateUnset); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTSDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		policy  DuplicatePolicy
+		want    float64
+		wantErr error
+	}{
+		{DuplicateBlock, 10, ErrTSDuplicate},
+		{DuplicateFirst, 10, nil},
+		{DuplicateLast, 3, nil},
+		{DuplicateMin, 3, nil},
+		{DuplicateMax, 10, nil},
+		{DuplicateSum, 13, nil},
+	}
+	for _, tt := range tests {
+		s := newTestStorage(t)
+		s.TSCreate("ts", TSOptions{Duplicate: tt.policy})
+		s.TSAdd("ts", TSSample{Timestamp: 1, Value: 10}, TSOptions{}, DuplicateUnset)
+
+		err := s.TSAdd("ts", TSSample{Timestamp: 1, Value: 3}, TSOptions{}, DuplicateUnset)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("policy %d: err = %v, want %v", tt.policy, err, tt.wantErr)
+		}
+		got, _ := s.TSRange("ts", TSRangeQuery{From: 0, To: math.MaxInt64})
+		if len(got) != 1 || got[0].Value != tt.want {
+			t.Errorf("policy %d: samples = %v, want value %v", tt.policy, got, tt.want)
+		}
+	}
+
+	// ON_DUPLICATE переопределяет политику ряда
+	s := newTestStorage(t)
+	addSamples(t, s, "ts", 1)
+	if err := s.TSAdd("ts", TSSample{Timestamp: 1, Value: 5}, TSOptions{}, DuplicateSum); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.TSRange("ts", TSRangeQuery{To: math.MaxInt64}); got[0].Value != 6 {
+		t.Errorf("ON_DUPLICATE SUM value = %v, want 6", got[0].Value)
+	}
+}
+
+func TestTSRetention(t *testing.T) {
+	s := newTestStorage(t)
+	// Отсчёты в будущем, чтобы фоновый цикл не обрезал ряд по текущему времени
+	base := time.Now().Add(time.Hour).UnixMilli()
+	s.TSCreate("ts", TSOptions{Retention: 100})
+	addSamples(t, s, "ts", base+10, base+50, base+200)
+
+	if err := s.TSAdd("ts", TSSample{Timestamp: base + 99, Value: 1}, TSOptions{}, DuplicateUnset); !errors.Is(err, ErrTSTooOld) {
+		t.Errorf("TSAdd older than retention err = %v, want ErrTSTooOld", err)
+	}
+	got, _ := s.TSRange("ts", TSRangeQuery{To: math.MaxInt64})
+	if !slices.Equal(got, []TSSample{{base + 200, float64(base + 200)}}) {
+		t.Errorf("samples after trim = %v", got)
+	}
+}
+
+// TestTSTrimInactive проверяет, что цикл активного удаления обрезает ряды,
+// в которые больше не пишут, относительно переданного времени
+func TestTSTrimInactive(t *testing.T) {
+	s := newTestStorage(t)
+	base := time.Now().Add(time.Hour).UnixMilli()
+	s.TSCreate("ts", TSOptions{Retention: 100})
+	s.TSCreate("moved", TSOptions{Retention: 100})
+	s.TSCreate("plain", TSOptions{})
+	s.TSCreate("gone", TSOptions{Retention: 100})
+	for _, key := range []string{"ts", "moved", "plain"} {
+		addSamples(t, s, key, base+10, base+50, base+90)
+	}
+	s.Rename("moved", "renamed", false)
+	s.Set("gone", "v", 0)
+
+	s.trimTimeSeries(time.UnixMilli(base + 155))
+	tests := []struct {
+		key  string
+		want []int64
+	}{
+		{"ts", []int64{base + 90}},
+		{"renamed", []int64{base + 90}},
+		{"plain", []int64{base + 10, base + 50, base + 90}},
+	}
+	for _, tt := range tests {
+		samples, _ := s.TSRange(tt.key, TSRangeQuery{To: math.MaxInt64})
+		var got []int64
+		for _, sample := range samples {
+			got = append(got, sample.Timestamp)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: timestamps after trim = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	// Ключи без ряда с глубиной хранения больше не обходятся
+	s.mu.RLock()
+	tracked := len(s.tsRetention)
+	s.mu.RUnlock()
+	if tracked != 2 {
+		t.Errorf("%d series tracked, want ts and renamed", tracked)
+	}
+	s.trimTimeSeries(time.UnixMilli(base + 1000))
+	if samples, _ := s.TSRange("ts", TSRangeQuery{To: math.MaxInt64}); len(samples) != 0 {
+		t.Errorf("samples older than retention = %v", samples)
+	}
+}
+
+func TestTSRange(t *testing.T) {
+	s := newTestStorage(t)
+	addSamples(t, s, "ts", 1, 5, 10, 11, 19, 20, 35)
+
+	tests := []struct {
+		name string
+		q    TSRangeQuery
+		want []TSSample
+	}{
+		{"window", TSRangeQuery{From: 5, To: 11}, []TSSample{{5, 5}, {10, 10}, {11, 11}}},
+		{"count", TSRangeQuery{To: math.MaxInt64, Count: 2}, []TSSample{{1, 1}, {5, 5}}},
+		{"reverse count", TSRangeQuery{To: math.MaxInt64, Count: 2, Reverse: true}, []TSSample{{35, 35}, {20, 20}}},
+		{"empty", TSRangeQuery{From: 36, To: 100}, []TSSample{}},
+		{"sum", TSRangeQuery{To: math.MaxInt64, Aggregation: AggSum, Bucket: 10}, []TSSample{{0, 6}, {10, 40}, {20, 20}, {30, 35}}},
+		{"avg", TSRangeQuery{From: 10, To: 19, Aggregation: AggAvg, Bucket: 10}, []TSSample{{10, 40.0 / 3}}},
+		{"count agg", TSRangeQuery{To: math.MaxInt64, Aggregation: AggCount, Bucket: 1000}, []TSSample{{0, 7}}},
+		{"min", TSRangeQuery{To: math.MaxInt64, Aggregation: AggMin, Bucket: 20}, []TSSample{{0, 1}, {20, 20}}},
+		{"last reversed", TSRangeQuery{To: math.MaxInt64, Aggregation: AggLast, Bucket: 20, Reverse: true}, []TSSample{{20, 35}, {0, 19}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.TSRange("ts", tt.q)
+			if err != nil || !slices.Equal(got, tt.want) {
+				t.Errorf("TSRange = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTSRangeNearMaxTimestamp(t *testing.T) {
+	s := newTestStorage(t)
+	addSamples(t, s, "ts", math.MaxInt64-1, math.MaxInt64)
+
+	got, err := s.TSRange("ts", TSRangeQuery{To: math.MaxInt64, Aggregation: AggCount, Bucket: 10})
+	if err != nil || len(got) != 1 || got[0].Value != 2 {
+		t.Errorf("TSRange = %v, %v; want one bucket of 2 samples", got, err)
+	}
+}
+
+func TestTSCompactionRule(t *testing.T) {
+	s := newTestStorage(t)
+	s.TSCreate("src", TSOptions{})
+	s.TSCreate("dst", TSOptions{})
+	if err := s.TSCreateRule("src", "dst", AggSum, 10, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	addSamples(t, s, "src", 1, 2, 12, 25)
+	// Опоздавший отсчёт в закрытую корзину её не пересчитывает
+	addSamples(t, s, "src", 3)
+
+	got, _ := s.TSRange("dst", TSRangeQuery{To: math.MaxInt64})
+	if !slices.Equal(got, []TSSample{{0, 3}, {10, 12}}) {
+		t.Errorf("compacted samples = %v", got)
+	}
+
+	tests := []struct {
+		src, dst string
+		want     error
+	}{
+		{"src", "src", ErrTSSameRuleKeys},
+		{"other", "dst", ErrTSRuleExists},
+		{"dst", "other", ErrTSRuleDestChain},
+		{"src", "missing", ErrTSNotFound},
+	}
+	s.TSCreate("other", TSOptions{})
+	for _, tt := range tests {
+		if err := s.TSCreateRule(tt.src, tt.dst, AggSum, 10, 0); !errors.Is(err, tt.want) {
+			t.Errorf("TSCreateRule(%s, %s) err = %v, want %v", tt.src, tt.dst, err, tt.want)
+		}
+	}
+}
+
+func TestTSMRangeFilters(t *testing.T) {
+	s := newTestStorage(t)
+	s.TSCreate("a", TSOptions{Labels: []TSLabel{{"area", "north"}, {"kind", "temp"}}})
+	s.TSCreate("b", TSOptions{Labels: []TSLabel{{"area", "south"}, {"kind", "temp"}}})
+	s.TSCreate("c", TSOptions{Labels: []TSLabel{{"kind", "hum"}}})
+
+	tests := []struct {
+		filters []TSFilter
+		want    []string
+	}{
+		{[]TSFilter{{Label: "kind", Values: []string{"temp"}, Equal: true}}, []string{"a", "b"}},
+		{[]TSFilter{{Label: "area", Values: []string{"north"}, Equal: false}}, []string{"b", "c"}},
+		{[]TSFilter{{Label: "area", Equal: true}}, []string{"c"}},
+		{[]TSFilter{{Label: "area", Equal: false}}, []string{"a", "b"}},
+		{[]TSFilter{{Label: "area", Values: []string{"north", "south"}, Equal: true}, {Label: "kind", Values: []string{"temp"}, Equal: true}}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		var keys []string
+		for _, r := range s.TSMRange(tt.filters, TSRangeQuery{To: math.MaxInt64}) {
+			keys = append(keys, r.Key)
+		}
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("TSMRange(%+v) = %q, want %q", tt.filters, keys, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+)
+
+var (
+	ErrTopKExists   = errors.New("ERR TOPK: key already exists")
+	ErrTopKNotFound = errors.New("ERR TOPK: key does not exist")
+)
+
+// TopKOptions параметры TOPK.RESERVE
+type TopKOptions struct {
+	K, Width, Depth int
+	Decay           float64
+}
+
+// DefaultTopKOptions размеры таблицы и коэффициент затухания по умолчанию
+var DefaultTopKOptions = TopKOptions{Width: 8, Depth: 7, Decay: 0.9}
+
+// topkFingerprintSeed затравка хэша отпечатков, как в RedisBloom
+const topkFingerprintSeed = 1919
+
+type topkBucket struct {
+	fp    uint64
+	count int64
+}
+
+// TopKItem элемент списка самых частых
+type TopKItem struct {
+	Item  string
+	Count int64
+}
+
+// TopK поиск самых частых элементов алгоритмом HeavyKeeper: таблица
+// depth×width корзин с отпечатками и счётчиками, чужие счётчики затухают
+// с вероятностью decay^count, а k лучших хранятся в мин-куче. Случайность
+// затухания берётся из собственного генератора, чтобы повтор команд из
+// AOF давал то же состояние.
+type TopK struct {
+	k, width, depth int
+	decay           float64
+	buckets         []topkBucket
+	heap            []TopKItem
+	rng             uint64
+}
+
+func newTopK(opts TopKOptions) *TopK {
+	return &TopK{
+		k:       opts.K,
+		width:   opts.Width,
+		depth:   opts.Depth,
+		decay:   opts.Decay,
+		buckets: make([]topkBucket, opts.Width*opts.Depth),
+		rng:     0x9E3779B97F4A7C15,
+	}
+}
+
+// random возвращает псевдослучайное число из [0, 1) (xorshift64)
+func (t *TopK) random() float64 {
+	t.rng ^= t.rng << 13
+	t.rng ^= t.rng >> 7
+	t.rng ^= t.rng << 17
+	return float64(t.rng>>11) / (1 << 53)
+}
+
+func (t *TopK) heapIndex(item string) int {
+	for i, h := range t.heap {
+		if h.Item == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// fix восстанавливает свойство мин-кучи после изменения счётчика i
+func (t *TopK) fix(i int) {
+	for {
+		smallest := i
+		for _, c := range []int{2*i + 1, 2*i + 2} {
+			if c < len(t.heap) && t.heap[c].Count < t.heap[smallest].Count {
+				smallest = c
+			}
+		}
+		if smallest == i {
+			break
+		}
+		t.heap[i], t.heap[smallest] = t.heap[smallest], t.heap[i]
+		i = smallest
+	}
+	for i > 0 && t.heap[(i-1)/2].Count > t.heap[i].Count {
+		t.heap[i], t.heap[(i-1)/2] = t.heap[(i-1)/2], t.heap[i]
+		i = (i - 1) / 2
+	}
+}
+
+// add учитывает incr появлений элемента и возвращает вытесненный из
+// списка лучших элемент
+func (t *TopK) add(item string, incr int64) (string, bool) {
+	fp := murmurHash64A([]byte(item), topkFingerprintSeed)
+
+	var maxCount int64
+	for row := 0; row < t.depth; row++ {
+		b := &t.buckets[row*t.width+int(murmurHash64A([]byte(item), uint64(row))%uint64(t.width))]
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, incr
+		case b.fp == fp:
+			b.count += incr
+		default:
+			for rest := incr; rest > 0; rest-- {
+				if t.random() < math.Pow(t.decay, float64(b.count)) {
+					b.count--
+					if b.count == 0 {
+						b.fp, b.count = fp, rest
+						break
+					}
+				}
+			}
+		}
+		if b.fp == fp {
+			maxCount = max(maxCount, b.count)
+		}
+	}
+
+	if i := t.heapIndex(item); i >= 0 {
+		t.heap[i].Count = max(t.heap[i].Count, maxCount)
+		t.fix(i)
+		return "", false
+	}
+	if len(t.heap) < t.k {
+		if maxCount > 0 {
+			t.heap = append(t.heap, TopKItem{Item: item, Count: maxCount})
+			t.fix(len(t.heap) - 1)
+		}
+		return "", false
+	}
+	if maxCount <= t.heap[0].Count {
+		return "", false
+	}
+
+	expelled := t.heap[0].Item
+	t.heap[0] = TopKItem{Item: item, Count: maxCount}
+	t.fix(0)
+	return expelled, true
+}
+
+// getTopK возвращает структуру или ErrTopKNotFound. Вызывается под блокировкой.
+func (s *Storage) getTopK(key string) (*TopK, error) {
+	obj, err := s.lookupType(key, TypeTopK)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, ErrTopKNotFound
+	}
+	return obj.value.(*TopK), nil
+}
+
+// TopKReserve создаёт пустую структуру для k самых частых элементов
+func (s *Storage) TopKReserve(key string, opts TopKOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookupWrite(key) != nil {
+		return ErrTopKExists
+	}
+	s.put(key, &object{typ: TypeTopK, value: newTopK(opts)})
+	return nil
+}
+
+// TopKIncrBy учитывает появления элементов. Для каждого возвращается
+// вытесненный им из списка лучших элемент; expelled[i] равен false, если
+// вытеснения не было.
+func (s *Storage) TopKIncrBy(key string, items []string, increments []int64) ([]string, []bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	topk, err := s.getTopK(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dropped := make([]string, len(items))
+	expelled := make([]bool, len(items))
+	for i, item := range items {
+		dropped[i], expelled[i] = topk.add(item, increments[i])
+	}
+	return dropped, expelled, nil
+}
+
+// TopKQuery сообщает, входят ли элементы в список самых частых
+func (s *Storage) TopKQuery(key string, items []string) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	topk, err := s.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]bool, len(items))
+	for i, item := range items {
+		found[i] = topk.heapIndex(item) >= 0
+	}
+	return found, nil
+}
+
+// TopKList возвращает самые частые элементы по убыванию оценки
+func (s *Storage) TopKList(key string) ([]TopKItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	topk, err := s.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+
+	items := append([]TopKItem(nil), topk.heap...)
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Item < items[j].Item
+	})
+	return items, nil
+}
+
+// clone возвращает независимую копию вместе с состоянием генератора,
+// чтобы копия вела себя так же, как оригинал
+func (t *TopK) clone() *TopK {
+	copied := *t
+	copied.buckets = slices.Clone(t.buckets)
+	copied.heap = slices.Clone(t.heap)
+	return &copied
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestTopKFindsHeavyHitters(t *testing.T) {
+	s := newTestStorage(t)
+	s.TopKReserve("tk", TopKOptions{K: 3, Width: 50, Depth: 5, Decay: 0.9})
+
+	// Элементы "heavy0".."heavy2" встречаются гораздо чаще остальных
+	for i := range 3000 {
+		item := "light" + strconv.Itoa(i%500)
+		if i%3 == 0 {
+			item = "heavy" + strconv.Itoa(i%9/3)
+		}
+		if _, _, err := s.TopKIncrBy("tk", []string{item}, []int64{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, _ := s.TopKList("tk")
+	names := make([]string, len(list))
+	for i, item := range list {
+		names[i] = item.Item
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"heavy0", "heavy1", "heavy2"}) {
+		t.Errorf("TopKList = %v", list)
+	}
+	for i := 1; i < len(list); i++ {
+		if list[i].Count > list[i-1].Count {
+			t.Errorf("TopKList is not sorted: %v", list)
+		}
+	}
+	if found, _ := s.TopKQuery("tk", []string{"heavy0", "light1"}); !found[0] || found[1] {
+		t.Errorf("TopKQuery = %v", found)
+	}
+}
+
+// TestTopKDeterministic проверяет, что одинаковые команды дают одинаковое
+// состояние: на этом основан повтор TOPK.ADD из AOF
+func TestTopKDeterministic(t *testing.T) {
+	a, b := newTestStorage(t), newTestStorage(t)
+	for _, s := range []*Storage{a, b} {
+		s.TopKReserve("tk", TopKOptions{K: 5, Width: 8, Depth: 3, Decay: 0.9})
+	}
+	for i := range 2000 {
+		item := strconv.Itoa(i * 7919 % 97)
+		da, ea, _ := a.TopKIncrBy("tk", []string{item}, []int64{int64(i%3 + 1)})
+		db, eb, _ := b.TopKIncrBy("tk", []string{item}, []int64{int64(i%3 + 1)})
+		if da[0] != db[0] || ea[0] != eb[0] {
+			t.Fatalf("step %d: expelled %q/%v and %q/%v", i, da[0], ea[0], db[0], eb[0])
+		}
+	}
+	la, _ := a.TopKList("tk")
+	lb, _ := b.TopKList("tk")
+	if !slices.Equal(la, lb) {
+		t.Errorf("lists differ: %v and %v", la, lb)
+	}
+}
+
+func TestTopKErrors(t *testing.T) {
+	s := newTestStorage(t)
+	if _, _, err := s.TopKIncrBy("missing", []string{"a"}, []int64{1}); !errors.Is(err, ErrTopKNotFound) {
+		t.Errorf("TopKIncrBy of missing key err = %v, want ErrTopKNotFound", err)
+	}
+	s.TopKReserve("tk", DefaultTopKOptions)
+	if err := s.TopKReserve("tk", DefaultTopKOptions); !errors.Is(err, ErrTopKExists) {
+		t.Errorf("second TopKReserve err = %v, want ErrTopKExists", err)
+	}
+}
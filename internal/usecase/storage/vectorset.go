@@ -0,0 +1,531 @@
+package storage
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+var (
+	ErrVectorNotFound   = errors.New("ERR element not found in set")
+	ErrVectorZero       = errors.New("ERR zero vector can't be used with COSINE metric")
+	ErrVectorSetMissing = errors.New("ERR key does not exist")
+)
+
+// flatSearchLimit размер набора, до которого поиск идёт полным перебором
+const flatSearchLimit = 1000
+
+// VectorMetric мера близости векторов набора
+type VectorMetric int
+
+const (
+	MetricCosine VectorMetric = iota
+	MetricL2
+	MetricIP
+)
+
+func (m VectorMetric) String() string {
+	switch m {
+	case MetricL2:
+		return "l2"
+	case MetricIP:
+		return "ip"
+	default:
+		return "cosine"
+	}
+}
+
+// VectorOptions параметры, с которыми создаётся набор: мера, число связей
+// узла M и ширина поиска при построении графа EF
+type VectorOptions struct {
+	Metric VectorMetric
+	M      int
+	EF     int
+}
+
+var DefaultVectorOptions = VectorOptions{Metric: MetricCosine, M: 16, EF: 200}
+
+// VectorQuery запрос VSIM: по вектору или по элементу набора
+type VectorQuery struct {
+	Vector  []float32
+	Element string
+	Count   int
+	EF      int
+}
+
+// VectorMatch найденный элемент. Score для COSINE — сходство от 0 до 1,
+// для IP — скалярное произведение, для L2 — евклидово расстояние.
+type VectorMatch struct {
+	Element string
+	Score   float64
+}
+
+// VectorInfo ответ VINFO
+type VectorInfo struct {
+	Dim      int
+	Size     int
+	Metric   VectorMetric
+	M        int
+	MaxLevel int
+}
+
+type vectorNode struct {
+	element string
+	vector  []float32
+	links   [][]*vectorNode
+}
+
+// VectorSet набор векторов с графом HNSW. Связи в графе двусторонние:
+// так удаление узла затрагивает только его соседей.
+type VectorSet struct {
+	dim    int
+	opts   VectorOptions
+	nodes  map[string]*vectorNode
+	entry  *vectorNode
+	levels float64
+	rng    uint64
+}
+
+func newVectorSet(dim int, opts VectorOptions) *VectorSet {
+	return &VectorSet{
+		dim:    dim,
+		opts:   opts,
+		nodes:  make(map[string]*vectorNode),
+		levels: 1 / math.Log(float64(opts.M)),
+		rng:    0x9E3779B97F4A7C15,
+	}
+}
+
+// randomLevel выбирает уровень узла. Генератор детерминирован,
+// чтобы граф после загрузки AOF совпадал с исходным.
+func (v *VectorSet) randomLevel() int {
+	v.rng ^= v.rng << 13
+	v.rng ^= v.rng >> 7
+	v.rng ^= v.rng << 17
+	u := (float64(v.rng>>11) + 1) / (1 << 53)
+	return int(-math.Log(u) * v.levels)
+}
+
+func (v *VectorSet) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * v.opts.M
+	}
+	return v.opts.M
+}
+
+// distance чем меньше, тем ближе. Для COSINE векторы хранятся нормированными.
+func (v *VectorSet) distance(a, b []float32) float64 {
+	var sum float64
+	switch v.opts.Metric {
+	case MetricL2:
+		for i := range a {
+			d := float64(a[i]) - float64(b[i])
+			sum += d * d
+		}
+		return sum
+	default:
+		for i := range a {
+			sum += float64(a[i]) * float64(b[i])
+		}
+		if v.opts.Metric == MetricIP {
+			return -sum
+		}
+		return 1 - sum
+	}
+}
+
+func (v *VectorSet) score(distance float64) float64 {
+	switch v.opts.Metric {
+	case MetricL2:
+		return math.Sqrt(distance)
+	case MetricIP:
+		return -distance
+	default:
+		return 1 - distance/2
+	}
+}
+
+// prepare проверяет размерность и нормирует вектор для COSINE
+func (v *VectorSet) prepare(vector []float32) ([]float32, error) {
+	if len(vector) != v.dim {
+		return nil, fmt.Errorf("ERR Vector dimension mismatch - got %d but set has %d", len(vector), v.dim)
+	}
+	result := append([]float32(nil), vector...)
+	if v.opts.Metric != MetricCosine {
+		return result, nil
+	}
+
+	var norm float64
+	for _, x := range result {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return nil, ErrVectorZero
+	}
+	norm = math.Sqrt(norm)
+	for i := range result {
+		result[i] = float32(float64(result[i]) / norm)
+	}
+	return result, nil
+}
+
+type candidate struct {
+	node     *vectorNode
+	distance float64
+}
+
+// candidateHeap куча кандидатов; far задаёт порядок по убыванию расстояния
+type candidateHeap struct {
+	items []candidate
+	far   bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.far {
+		return h.items[i].distance > h.items[j].distance
+	}
+	return h.items[i].distance < h.items[j].distance
+}
+func (h *candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)    { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// searchLayer ищет ef ближайших к q узлов уровня level, начиная с entries.
+// Результат упорядочен по возрастанию расстояния.
+func (v *VectorSet) searchLayer(q []float32, entries []candidate, ef, level int) []candidate {
+	visited := make(map[*vectorNode]bool, ef*4)
+	near := &candidateHeap{}
+	found := &candidateHeap{far: true}
+	for _, c := range entries {
+		visited[c.node] = true
+		heap.Push(near, c)
+		heap.Push(found, c)
+	}
+
+	for near.Len() > 0 {
+		c := heap.Pop(near).(candidate)
+		if found.Len() >= ef && c.distance > found.items[0].distance {
+			break
+		}
+		for _, next := range c.node.links[level] {
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			d := v.distance(q, next.vector)
+			if found.Len() < ef || d < found.items[0].distance {
+				heap.Push(near, candidate{next, d})
+				heap.Push(found, candidate{next, d})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	result := found.items
+	sort.Slice(result, func(i, j int) bool { return result[i].distance < result[j].distance })
+	return result
+}
+
+// descend спускается жадным поиском с верхнего уровня до уровня level
+func (v *VectorSet) descend(q []float32, level int) []candidate {
+	entries := []candidate{{v.entry, v.distance(q, v.entry.vector)}}
+	for l := len(v.entry.links) - 1; l > level; l-- {
+		entries = v.searchLayer(q, entries, 1, l)
+	}
+	return entries
+}
+
+func unlink(from, to *vectorNode, level int) {
+	links := from.links[level]
+	for i, n := range links {
+		if n == to {
+			from.links[level] = append(links[:i], links[i+1:]...)
+			return
+		}
+	}
+}
+
+func linked(from, to *vectorNode, level int) bool {
+	for _, n := range from.links[level] {
+		if n == to {
+			return true
+		}
+	}
+	return false
+}
+
+// connect связывает узлы в обе стороны. Если у соседа связей больше
+// допустимого, он теряет самую дальнюю.
+func (v *VectorSet) connect(a, b *vectorNode, level int) {
+	if a == b || linked(a, b, level) {
+		return
+	}
+	a.links[level] = append(a.links[level], b)
+	b.links[level] = append(b.links[level], a)
+	for _, n := range []*vectorNode{a, b} {
+		if len(n.links[level]) <= v.maxLinks(level) {
+			continue
+		}
+		farthest, worst := 0, -math.MaxFloat64
+		for i, other := range n.links[level] {
+			if d := v.distance(n.vector, other.vector); d > worst {
+				farthest, worst = i, d
+			}
+		}
+		dropped := n.links[level][farthest]
+		unlink(n, dropped, level)
+		unlink(dropped, n, level)
+	}
+}
+
+func (v *VectorSet) insert(element string, vector []float32) {
+	level := v.randomLevel()
+	node := &vectorNode{element: element, vector: vector, links: make([][]*vectorNode, level+1)}
+	v.nodes[element] = node
+	if v.entry == nil {
+		v.entry = node
+		return
+	}
+
+	top := len(v.entry.links) - 1
+	entries := v.descend(vector, min(level, top))
+	for l := min(level, top); l >= 0; l-- {
+		entries = v.searchLayer(vector, entries, v.opts.EF, l)
+		for _, c := range entries[:min(len(entries), v.opts.M)] {
+			v.connect(node, c.node, l)
+		}
+	}
+	if level > top {
+		v.entry = node
+	}
+}
+
+// remove удаляет узел и связывает его бывших соседей между собой,
+// чтобы граф не распадался
+func (v *VectorSet) remove(node *vectorNode) {
+	delete(v.nodes, node.element)
+	for l, neighbours := range node.links {
+		neighbours = append([]*vectorNode(nil), neighbours...)
+		for _, n := range neighbours {
+			unlink(n, node, l)
+		}
+		for _, n := range neighbours {
+			others := append([]*vectorNode(nil), neighbours...)
+			sort.Slice(others, func(i, j int) bool {
+				return v.distance(n.vector, others[i].vector) < v.distance(n.vector, others[j].vector)
+			})
+			for _, other := range others {
+				if len(n.links[l]) >= v.maxLinks(l) {
+					break
+				}
+				v.connect(n, other, l)
+			}
+		}
+	}
+
+	if v.entry != node {
+		return
+	}
+	v.entry = nil
+	for _, n := range v.nodes {
+		if v.entry == nil || len(n.links) > len(v.entry.links) ||
+			len(n.links) == len(v.entry.links) && n.element < v.entry.element {
+			v.entry = n
+		}
+	}
+}
+
+// search возвращает count ближайших к q элементов: перебором для
+// небольших наборов и по графу для больших
+func (v *VectorSet) search(q []float32, count, ef int) []VectorMatch {
+	var found []candidate
+	if len(v.nodes) <= flatSearchLimit {
+		found = make([]candidate, 0, len(v.nodes))
+		for _, n := range v.nodes {
+			found = append(found, candidate{n, v.distance(q, n.vector)})
+		}
+		sort.Slice(found, func(i, j int) bool {
+			if found[i].distance != found[j].distance {
+				return found[i].distance < found[j].distance
+			}
+			return found[i].node.element < found[j].node.element
+		})
+	} else if v.entry != nil {
+		// Ширина поиска не больше числа узлов: COUNT и EF приходят от клиента
+		found = v.searchLayer(q, v.descend(q, 0), min(max(ef, count), len(v.nodes)), 0)
+	}
+
+	found = found[:min(len(found), count)]
+	result := make([]VectorMatch, len(found))
+	for i, c := range found {
+		result[i] = VectorMatch{Element: c.node.element, Score: v.score(c.distance)}
+	}
+	return result
+}
+
+func (s *Storage) getVectorSet(key string) (*VectorSet, error) {
+	obj, err := s.lookupType(key, TypeVectorSet)
+	if obj == nil || err != nil {
+		return nil, err
+	}
+	return obj.value.(*VectorSet), nil
+}
+
+// VAdd добавляет элемент или заменяет его вектор. Набор создаётся с
+// параметрами opts и размерностью первого вектора. Возвращает true,
+// если элемент новый.
+func (s *Storage) VAdd(key, element string, vector []float32, opts VectorOptions) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, err := s.lookupWriteType(key, TypeVectorSet)
+	if err != nil {
+		return false, err
+	}
+	var set *VectorSet
+	if obj == nil {
+		set = newVectorSet(len(vector), opts)
+	} else {
+		set = obj.value.(*VectorSet)
+	}
+
+	prepared, err := set.prepare(vector)
+	if err != nil {
+		return false, err
+	}
+	if obj == nil {
+		s.put(key, &object{typ: TypeVectorSet, value: set})
+	}
+
+	old, exists := set.nodes[element]
+	if exists {
+		set.remove(old)
+	}
+	set.insert(element, prepared)
+	return !exists, nil
+}
+
+// VRem удаляет элемент; пустой набор удаляется вместе с ключом
+func (s *Storage) VRem(key, element string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, err := s.lookupWriteType(key, TypeVectorSet)
+	if obj == nil || err != nil {
+		return false, err
+	}
+	set := obj.value.(*VectorSet)
+	node, exists := set.nodes[element]
+	if !exists {
+		return false, nil
+	}
+	set.remove(node)
+	if len(set.nodes) == 0 {
+		s.remove(key)
+	}
+	return true, nil
+}
+
+// VSim ищет ближайшие к запросу элементы. При поиске по элементу
+// сам элемент входит в результат.
+func (s *Storage) VSim(key string, q VectorQuery) ([]VectorMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getVectorSet(key)
+	if set == nil || err != nil {
+		return nil, err
+	}
+
+	var vector []float32
+	if q.Vector != nil {
+		if vector, err = set.prepare(q.Vector); err != nil {
+			return nil, err
+		}
+	} else {
+		node, exists := set.nodes[q.Element]
+		if !exists {
+			return nil, ErrVectorNotFound
+		}
+		vector = node.vector
+	}
+	return set.search(vector, q.Count, q.EF), nil
+}
+
+// VCard возвращает число элементов набора
+func (s *Storage) VCard(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getVectorSet(key)
+	if set == nil || err != nil {
+		return 0, err
+	}
+	return len(set.nodes), nil
+}
+
+// VDim возвращает размерность векторов набора
+func (s *Storage) VDim(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getVectorSet(key)
+	if err != nil {
+		return 0, err
+	}
+	if set == nil {
+		return 0, ErrVectorSetMissing
+	}
+	return set.dim, nil
+}
+
+// VInfo возвращает параметры набора; found = false, если ключа нет
+func (s *Storage) VInfo(key string) (VectorInfo, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, err := s.getVectorSet(key)
+	if set == nil || err != nil {
+		return VectorInfo{}, false, err
+	}
+	return VectorInfo{
+		Dim:      set.dim,
+		Size:     len(set.nodes),
+		Metric:   set.opts.Metric,
+		M:        set.opts.M,
+		MaxLevel: len(set.entry.links) - 1,
+	}, true, nil
+}
+
+// clone возвращает независимую копию набора с тем же графом HNSW
+func (v *VectorSet) clone() *VectorSet {
+	copied := *v
+	copied.nodes = make(map[string]*vectorNode, len(v.nodes))
+	for element, node := range v.nodes {
+		copied.nodes[element] = &vectorNode{element: element, vector: slices.Clone(node.vector)}
+	}
+	for element, node := range v.nodes {
+		links := make([][]*vectorNode, len(node.links))
+		for level, neighbours := range node.links {
+			links[level] = make([]*vectorNode, len(neighbours))
+			for i, n := range neighbours {
+				links[level][i] = copied.nodes[n.element]
+			}
+		}
+		copied.nodes[element].links = links
+	}
+	if v.entry != nil {
+		copied.entry = copied.nodes[v.entry.element]
+	}
+	return &copied
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// randomVector возвращает вектор размерности dim с координатами из [-1, 1)
+func randomVector(r *rand.Rand, dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = float32(r.Float64()*2 - 1)
+	}
+	return v
+}
+
+// reachable считает узлы нижнего уровня, достижимые из входной точки
+func reachable(v *VectorSet) int {
+	seen := map[*vectorNode]bool{v.entry: true}
+	queue := []*vectorNode{v.entry}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, next := range n.links[0] {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return len(seen)
+}
+
+func TestVAddErrors(t *testing.T) {
+	s := newTestStorage(t)
+	if _, err := s.VAdd("v", "zero", []float32{0, 0}, DefaultVectorOptions); !errors.Is(err, ErrVectorZero) || s.Exists("v") {
+		t.Errorf("VAdd of zero vector err = %v, key exists = %v", err, s.Exists("v"))
+	}
+
+	s.VAdd("v", "a", []float32{1, 0}, DefaultVectorOptions)
+	if _, err := s.VAdd("v", "b", []float32{1, 0, 0}, DefaultVectorOptions); err == nil {
+		t.Error("VAdd with another dimension succeeded")
+	}
+	if _, err := s.VSim("v", VectorQuery{Element: "missing", Count: 1}); !errors.Is(err, ErrVectorNotFound) {
+		t.Errorf("VSim by missing element err = %v, want ErrVectorNotFound", err)
+	}
+	if _, err := s.VDim("missing"); !errors.Is(err, ErrVectorSetMissing) {
+		t.Errorf("VDim of missing key err = %v, want ErrVectorSetMissing", err)
+	}
+
+	// Замена вектора не меняет числа элементов
+	if added, _ := s.VAdd("v", "a", []float32{0, 1}, DefaultVectorOptions); added {
+		t.Error("VAdd of existing element reported a new one")
+	}
+	if ok, _ := s.VRem("v", "a"); !ok || s.Exists("v") {
+		t.Error("VRem of the last element kept the key")
+	}
+}
+
+func TestVSimMetrics(t *testing.T) {
+	tests := []struct {
+		metric VectorMetric
+		want   []VectorMatch
+	}{
+		// Для COSINE x и far совпадают по направлению
+		{MetricCosine, []VectorMatch{{"far", 1}, {"x", 1}, {"y", 0.5}}},
+		{MetricL2, []VectorMatch{{"x", 1}, {"y", 2.23606797749979}, {"far", 8}}},
+		{MetricIP, []VectorMatch{{"far", 20}, {"x", 2}, {"y", 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric.String(), func(t *testing.T) {
+			s := newTestStorage(t)
+			opts := DefaultVectorOptions
+			opts.Metric = tt.metric
+			s.VAdd("v", "x", []float32{1, 0}, opts)
+			s.VAdd("v", "y", []float32{0, 1}, opts)
+			s.VAdd("v", "far", []float32{10, 0}, opts)
+
+			got, err := s.VSim("v", VectorQuery{Vector: []float32{2, 0}, Count: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("VSim = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestVSimGraphRecall проверяет, что поиск по графу на большом наборе
+// находит почти все точные ближайшие соседи
+func TestVSimGraphRecall(t *testing.T) {
+	s := newTestStorage(t)
+	r := rand.New(rand.NewPCG(1, 2))
+	const n, dim = 3000, 16
+	vectors := make(map[string][]float32, n)
+	for i := range n {
+		element := strconv.Itoa(i)
+		vectors[element] = randomVector(r, dim)
+		s.VAdd("v", element, vectors[element], DefaultVectorOptions)
+	}
+	set, _ := s.getVectorSet("v")
+
+	hits, total := 0, 0
+	for range 50 {
+		q := randomVector(r, dim)
+		prepared, _ := set.prepare(q)
+		exact := make([]string, 0, n)
+		for element := range vectors {
+			exact = append(exact, element)
+		}
+		slices.SortFunc(exact, func(a, b string) int {
+			da, db := set.distance(prepared, set.nodes[a].vector), set.distance(prepared, set.nodes[b].vector)
+			switch {
+			case da < db:
+				return -1
+			case da > db:
+				return 1
+			}
+			return 0
+		})
+
+		got, _ := s.VSim("v", VectorQuery{Vector: q, Count: 10, EF: 100})
+		for _, m := range got {
+			if slices.Contains(exact[:10], m.Element) {
+				hits++
+			}
+		}
+		total += 10
+	}
+	if recall := float64(hits) / float64(total); recall < 0.9 {
+		t.Errorf("recall = %.2f, want at least 0.9", recall)
+	}
+}
+
+func TestVRemKeepsGraphConnected(t *testing.T) {
+	s := newTestStorage(t)
+	r := rand.New(rand.NewPCG(3, 4))
+	for i := range 1500 {
+		s.VAdd("v", strconv.Itoa(i), randomVector(r, 8), DefaultVectorOptions)
+	}
+	for i := 0; i < 1500; i += 3 {
+		s.VRem("v", strconv.Itoa(i))
+	}
+
+	set, _ := s.getVectorSet("v")
+	if got := reachable(set); got != len(set.nodes) {
+		t.Errorf("%d of %d nodes reachable after VRem", got, len(set.nodes))
+	}
+	for _, node := range set.nodes {
+		for l, neighbours := range node.links {
+			for _, n := range neighbours {
+				if _, ok := set.nodes[n.element]; !ok || !linked(n, node, l) {
+					t.Fatalf("link %s -> %s at level %d is dangling or one-sided", node.element, n.element, l)
+				}
+			}
+		}
+	}
+}
+
+func TestVSimHugeCount(t *testing.T) {
+	s := newTestStorage(t)
+	r := rand.New(rand.NewPCG(5, 6))
+	for i := range flatSearchLimit + 10 {
+		s.VAdd("v", strconv.Itoa(i), randomVector(r, 4), DefaultVectorOptions)
+	}
+	got, err := s.VSim("v", VectorQuery{Element: "0", Count: 1 << 40, EF: 1 << 40})
+	if err != nil || len(got) != flatSearchLimit+10 || got[0].Element != "0" {
+		t.Errorf("VSim returned %d matches, %v", len(got), err)
+	}
+}
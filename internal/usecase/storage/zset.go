@@ -0,0 +1,527 @@
+package storage
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+)
+
+// ZMember элемент отсортированного множества с его оценкой
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAddOptions флаги команды ZADD
+type ZAddOptions struct {
+	NX, XX, GT, LT, CH, Incr bool
+}
+
+// ZRangeBy способ задания диапазона в ZRANGE
+type ZRangeBy int
+
+const (
+	ZRangeByRank ZRangeBy = iota
+	ZRangeByScore
+	ZRangeByLex
+)
+
+// ZRangeQuery параметры выборки диапазона. Для ZRangeByRank используются
+// Start/Stop, для ZRangeByScore — Score, для ZRangeByLex — Lex.
+// Count < 0 означает отсутствие ограничения.
+type ZRangeQuery struct {
+	By            ZRangeBy
+	Start, Stop   int
+	Score         ScoreRange
+	Lex           LexRange
+	Rev           bool
+	Offset, Count int
+}
+
+// Aggregate способ объединения оценок в ZUNIONSTORE/ZINTERSTORE
+type Aggregate int
+
+const (
+	AggregateSum Aggregate = iota
+	AggregateMin
+	AggregateMax
+)
+
+// ZSetCollection отсортированное множество: словарь даёт оценку элемента
+// за O(1), skiplist — упорядоченный обход и ранги за O(log n)
+type ZSetCollection struct {
+	dict  map[string]float64
+	zsl   *skiplist
+	order scanOrder[float64]
+}
+
+func newZSetCollection() *ZSetCollection {
+	return &ZSetCollection{
+		dict: make(map[string]float64),
+		zsl:  newSkiplist(),
+	}
+}
+
+func (z *ZSetCollection) Len() int {
+	return len(z.dict)
+}
+
+func (z *ZSetCollection) add(member string, score float64) {
+	if cur, exists := z.dict[member]; exists {
+		if cur == score {
+			return
+		}
+		z.zsl.delete(cur, member)
+		z.dict[member] = score
+	} else {
+		z.dict[member] = score
+		z.order.add(z.dict, member)
+	}
+	z.zsl.insert(score, member)
+}
+
+func (z *ZSetCollection) del(member string) bool {
+	score, exists := z.dict[member]
+	if !exists {
+		return false
+	}
+	delete(z.dict, member)
+	z.order.remove(member)
+	z.zsl.delete(score, member)
+	return true
+}
+
+// getZSet возвращает существующее отсортированное множество или nil.
+// Вызывается под блокировкой.
+func (s *Storage) getZSet(key string) (*ZSetCollection, error) {
+	obj, err := s.lookupType(key, TypeZSet)
+	if err != nil || obj == nil {
+		return nil, err
+	}
+	return obj.value.(*ZSetCollection), nil
+}
+
+// writeZSet возвращает отсортированное множество для изменения; create
+// создаёт отсутствующее. Вызывается под блокировкой на запись.
+func (s *Storage) writeZSet(key string, create bool) (*ZSetCollection, error) {
+	obj, err := s.lookupWriteType(key, TypeZSet)
+	if err != nil {
+		return nil, err
+	}
+	if obj != nil {
+		return obj.value.(*ZSetCollection), nil
+	}
+	if !create {
+		return nil, nil
+	}
+
+	zset := newZSetCollection()
+	s.put(key, &object{typ: TypeZSet, value: zset})
+	return zset, nil
+}
+
+// dropEmptyZSet удаляет ключ, если в множестве не осталось элементов
+func (s *Storage) dropEmptyZSet(key string, zset *ZSetCollection) {
+	if zset.Len() == 0 {
+		s.remove(key)
+	}
+}
+
+// ZAdd добавляет или обновляет элементы. Возвращает число добавленных
+// (с CH — добавленных и изменённых) элементов. С Incr возвращает новую
+// оценку единственного элемента и признак того, что она была применена.
+func (s *Storage) ZAdd(key string, opts ZAddOptions, members []ZMember) (int, float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.writeZSet(key, !opts.XX)
+	if err != nil || zset == nil {
+		return 0, 0, false, err
+	}
+	defer s.dropEmptyZSet(key, zset)
+
+	added, changed := 0, 0
+	var score float64
+	applied := false
+
+	for _, m := range members {
+		cur, exists := zset.dict[m.Member]
+		if !exists {
+			if opts.XX {
+				continue
+			}
+			if math.IsNaN(m.Score) {
+				return 0, 0, false, ErrScoreNaN
+			}
+			zset.add(m.Member, m.Score)
+			added++
+			score, applied = m.Score, true
+			continue
+		}
+
+		if opts.NX {
+			continue
+		}
+		newScore := m.Score
+		if opts.Incr {
+			newScore = cur + m.Score
+		}
+		if math.IsNaN(newScore) {
+			return 0, 0, false, ErrScoreNaN
+		}
+		if (opts.GT && newScore <= cur) || (opts.LT && newScore >= cur) {
+			continue
+		}
+		if newScore != cur {
+			zset.add(m.Member, newScore)
+			changed++
+		}
+		score, applied = newScore, true
+	}
+
+	if opts.CH {
+		return added + changed, score, applied, nil
+	}
+	return added, score, applied, nil
+}
+
+func (s *Storage) ZScore(key, member string) (float64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, false, err
+	}
+	score, found := zset.dict[member]
+	return score, found, nil
+}
+
+// ZScan возвращает очередную порцию элементов с оценками начиная с cursor
+// и курсор следующего вызова (0 — обход завершён)
+func (s *Storage) ZScan(key string, cursor uint64, count int) ([]ZMember, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.getZSet(key)
+	if err != nil || zset == nil {
+		return nil, 0, err
+	}
+	members, next := zset.order.scan(zset.dict, cursor, count)
+	result := make([]ZMember, len(members))
+	for i, member := range members {
+		result[i] = ZMember{Member: member, Score: zset.dict[member]}
+	}
+	return result, next, nil
+}
+
+func (s *Storage) ZCard(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+	return zset.Len(), nil
+}
+
+// ZRank возвращает позицию элемента, начиная с 0, по возрастанию
+// или (reverse) по убыванию оценок
+func (s *Storage) ZRank(key, member string, reverse bool) (int, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, false, err
+	}
+	score, found := zset.dict[member]
+	if !found {
+		return 0, false, nil
+	}
+
+	rank := zset.zsl.rank(score, member)
+	if reverse {
+		return zset.Len() - rank, true, nil
+	}
+	return rank - 1, true, nil
+}
+
+// ZRange возвращает элементы по диапазону рангов, оценок или строк
+func (s *Storage) ZRange(key string, q ZRangeQuery) ([]ZMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.getZSet(key)
+	if err != nil || zset == nil {
+		return nil, err
+	}
+	return zset.rangeNodes(q), nil
+}
+
+func (z *ZSetCollection) rangeNodes(q ZRangeQuery) []ZMember {
+	var result []ZMember
+	zsl := z.zsl
+
+	if q.By == ZRangeByRank {
+		from, to := normalizeRange(q.Start, q.Stop, zsl.length)
+		if from == to {
+			return nil
+		}
+		var x *skiplistNode
+		if q.Rev {
+			x = zsl.byRank(zsl.length - from)
+		} else {
+			x = zsl.byRank(from + 1)
+		}
+		for i := from; i < to && x != nil; i++ {
+			result = append(result, ZMember{Member: x.member, Score: x.score})
+			if q.Rev {
+				x = x.backward
+			} else {
+				x = x.level[0].forward
+			}
+		}
+		return result
+	}
+
+	var x *skiplistNode
+	inRange := func(n *skiplistNode) bool {
+		if q.By == ZRangeByScore {
+			return q.Score.gteMin(n.score) && q.Score.lteMax(n.score)
+		}
+		return q.Lex.gteMin(n.member) && q.Lex.lteMax(n.member)
+	}
+	switch {
+	case q.By == ZRangeByScore && q.Rev:
+		x = zsl.lastInScoreRange(q.Score)
+	case q.By == ZRangeByScore:
+		x = zsl.firstInScoreRange(q.Score)
+	case q.Rev:
+		x = zsl.lastInLexRange(q.Lex)
+	default:
+		x = zsl.firstInLexRange(q.Lex)
+	}
+
+	next := func(n *skiplistNode) *skiplistNode {
+		if q.Rev {
+			return n.backward
+		}
+		return n.level[0].forward
+	}
+
+	for offset := q.Offset; x != nil && offset > 0; offset-- {
+		x = next(x)
+	}
+	for x != nil && inRange(x) && (q.Count < 0 || len(result) < q.Count) {
+		result = append(result, ZMember{Member: x.member, Score: x.score})
+		x = next(x)
+	}
+	return result
+}
+
+// ZCount возвращает число элементов с оценками в диапазоне
+func (s *Storage) ZCount(key string, r ScoreRange) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zset, err := s.getZSet(key)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	first := zset.zsl.firstInScoreRange(r)
+	if first == nil {
+		return 0, nil
+	}
+	last := zset.zsl.lastInScoreRange(r)
+	return zset.zsl.rank(last.score, last.member) - zset.zsl.rank(first.score, first.member) + 1, nil
+}
+
+func (s *Storage) ZRem(key string, members []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.writeZSet(key, false)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if zset.del(member) {
+			removed++
+		}
+	}
+
+	s.dropEmptyZSet(key, zset)
+	return removed, nil
+}
+
+func (s *Storage) ZRemRangeByScore(key string, r ScoreRange) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.writeZSet(key, false)
+	if err != nil || zset == nil {
+		return 0, err
+	}
+
+	members := zset.rangeNodes(ZRangeQuery{By: ZRangeByScore, Score: r, Count: -1})
+	for _, m := range members {
+		zset.del(m.Member)
+	}
+
+	s.dropEmptyZSet(key, zset)
+	return len(members), nil
+}
+
+// ZPop удаляет и возвращает count элементов с наименьшими
+// или (max) наибольшими оценками
+func (s *Storage) ZPop(key string, count int, max bool) ([]ZMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zset, err := s.writeZSet(key, false)
+	if err != nil || zset == nil || count <= 0 {
+		return nil, err
+	}
+
+	members := zset.rangeNodes(ZRangeQuery{By: ZRangeByRank, Start: 0, Stop: count - 1, Rev: max})
+	for _, m := range members {
+		zset.del(m.Member)
+	}
+
+	s.dropEmptyZSet(key, zset)
+	return members, nil
+}
+
+// zsetSource возвращает элементы исходного ключа для ZUNIONSTORE/ZINTERSTORE.
+// Обычные множества участвуют с оценкой 1. Вызывается под блокировкой.
+func (s *Storage) zsetSource(key string) (map[string]float64, bool, error) {
+	obj := s.lookup(key)
+	if obj == nil {
+		return nil, false, nil
+	}
+
+	switch v := obj.value.(type) {
+	case *ZSetCollection:
+		return v.dict, true, nil
+	case *SetCollection:
+		result := make(map[string]float64, v.Len())
+		for member := range v.members {
+			result[member] = 1
+		}
+		return result, true, nil
+	default:
+		return nil, false, ErrWrongType
+	}
+}
+
+func aggregate(agg Aggregate, acc, score float64) float64 {
+	switch agg {
+	case AggregateMin:
+		return math.Min(acc, score)
+	case AggregateMax:
+		return math.Max(acc, score)
+	default:
+		sum := acc + score
+		// inf + -inf даёт NaN, Redis в этом случае считает сумму нулём
+		if math.IsNaN(sum) {
+			return 0
+		}
+		return sum
+	}
+}
+
+// ZCombineStore сохраняет в destination объединение (SetUnion) или
+// пересечение (SetInter) множеств с весами и возвращает его размер
+func (s *Storage) ZCombineStore(op SetOp, destination string, keys []string, weights []float64, agg Aggregate) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sources := make([]map[string]float64, len(keys))
+	present := make([]bool, len(keys))
+	for i, key := range keys {
+		src, ok, err := s.zsetSource(key)
+		if err != nil {
+			return 0, err
+		}
+		sources[i], present[i] = src, ok
+	}
+
+	weight := func(i int) float64 {
+		if weights == nil {
+			return 1
+		}
+		return weights[i]
+	}
+	weighted := func(score, w float64) float64 {
+		result := score * w
+		if math.IsNaN(result) {
+			return 0
+		}
+		return result
+	}
+
+	result := make(map[string]float64)
+	switch op {
+	case SetUnion:
+		for i, src := range sources {
+			for member, score := range src {
+				score = weighted(score, weight(i))
+				if acc, exists := result[member]; exists {
+					result[member] = aggregate(agg, acc, score)
+				} else {
+					result[member] = score
+				}
+			}
+		}
+	case SetInter:
+		for _, ok := range present {
+			if !ok {
+				sources = nil
+				break
+			}
+		}
+		if len(sources) > 0 {
+		members:
+			for member, score := range sources[0] {
+				acc := weighted(score, weight(0))
+				for i, src := range sources[1:] {
+					other, exists := src[member]
+					if !exists {
+						continue members
+					}
+					acc = aggregate(agg, acc, weighted(other, weight(i+1)))
+				}
+				result[member] = acc
+			}
+		}
+	}
+
+	s.remove(destination)
+	if len(result) > 0 {
+		zset := newZSetCollection()
+		for member, score := range result {
+			zset.add(member, score)
+		}
+		s.put(destination, &object{typ: TypeZSet, value: zset})
+	}
+	return len(result), nil
+}
+
+// clone возвращает независимую копию отсортированного множества
+func (z *ZSetCollection) clone() *ZSetCollection {
+	copied := newZSetCollection()
+	for member, score := range z.dict {
+		copied.dict[member] = score
+		copied.zsl.insert(score, member)
+	}
+	copied.order.reset(copied.dict)
+	return copied
+}
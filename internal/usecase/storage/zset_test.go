@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// zmembers возвращает имена элементов
+func zmembers(members []ZMember) []string {
+	names := make([]string, len(members))
+	for i, m := range members {
+		names[i] = m.Member
+	}
+	return names
+}
+
+func TestZAddOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      ZAddOptions
+		score     float64
+		wantN     int
+		wantScore float64
+	}{
+		{"update", ZAddOptions{}, 5, 0, 5},
+		{"CH counts changes", ZAddOptions{CH: true}, 5, 1, 5},
+		{"NX keeps score", ZAddOptions{NX: true}, 5, 0, 2},
+		{"XX updates", ZAddOptions{XX: true, CH: true}, 5, 1, 5},
+		{"GT rejects lower", ZAddOptions{GT: true, CH: true}, 1, 0, 2},
+		{"GT accepts higher", ZAddOptions{GT: true, CH: true}, 3, 1, 3},
+		{"LT rejects higher", ZAddOptions{LT: true, CH: true}, 3, 0, 2},
+		{"INCR adds", ZAddOptions{Incr: true}, 3, 0, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t)
+			s.ZAdd("z", ZAddOptions{}, []ZMember{{"a", 2}})
+
+			n, _, _, err := s.ZAdd("z", tt.opts, []ZMember{{"a", tt.score}})
+			if err != nil || n != tt.wantN {
+				t.Errorf("ZAdd = %d, %v; want %d", n, err, tt.wantN)
+			}
+			if score, _, _ := s.ZScore("z", "a"); score != tt.wantScore {
+				t.Errorf("score = %v, want %v", score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestZAddNaN(t *testing.T) {
+	s := newTestStorage(t)
+	s.ZAdd("z", ZAddOptions{}, []ZMember{{"a", math.Inf(1)}})
+
+	_, _, _, err := s.ZAdd("z", ZAddOptions{Incr: true}, []ZMember{{"a", math.Inf(-1)}})
+	if !errors.Is(err, ErrScoreNaN) {
+		t.Errorf("inf + -inf err = %v, want ErrScoreNaN", err)
+	}
+	if _, _, _, err := s.ZAdd("new", ZAddOptions{}, []ZMember{{"a", math.NaN()}}); !errors.Is(err, ErrScoreNaN) {
+		t.Errorf("NaN score err = %v, want ErrScoreNaN", err)
+	}
+	if s.Exists("new") {
+		t.Error("failed ZADD left an empty key")
+	}
+	if _, _, _, err := s.ZAdd("xx", ZAddOptions{XX: true}, []ZMember{{"a", 1}}); err != nil || s.Exists("xx") {
+		t.Errorf("ZADD XX created a key: %v", err)
+	}
+}
+
+func TestZRange(t *testing.T) {
+	s := newTestStorage(t)
+	s.ZAdd("z", ZAddOptions{}, []ZMember{{"a", 1}, {"b", 2}, {"c", 2}, {"d", 3}, {"e", math.Inf(1)}})
+
+	tests := []struct {
+		name string
+		q    ZRangeQuery
+		want []string
+	}{
+		{"all by rank", ZRangeQuery{Start: 0, Stop: -1}, []string{"a", "b", "c", "d", "e"}},
+		{"rank reversed", ZRangeQuery{Start: 0, Stop: 1, Rev: true}, []string{"e", "d"}},
+		{"rank extremes", ZRangeQuery{Start: math.MinInt, Stop: math.MaxInt}, []string{"a", "b", "c", "d", "e"}},
+		{"rank past end", ZRangeQuery{Start: 5, Stop: 10}, nil},
+		{"score", ZRangeQuery{By: ZRangeByScore, Score: ScoreRange{Min: 2, Max: 3}, Count: -1}, []string{"b", "c", "d"}},
+		{"score exclusive", ZRangeQuery{By: ZRangeByScore, Score: ScoreRange{Min: 1, Max: 3, MinEx: true, MaxEx: true}, Count: -1}, []string{"b", "c"}},
+		{"score to inf", ZRangeQuery{By: ZRangeByScore, Score: ScoreRange{Min: 3, Max: math.Inf(1)}, Count: -1}, []string{"d", "e"}},
+		{"score limit", ZRangeQuery{By: ZRangeByScore, Score: ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, Offset: 1, Count: 2}, []string{"b", "c"}},
+		{"score huge offset", ZRangeQuery{By: ZRangeByScore, Score: ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)}, Offset: math.MaxInt, Count: -1}, nil},
+		{"score reversed", ZRangeQuery{By: ZRangeByScore, Score: ScoreRange{Min: 2, Max: 3}, Rev: true, Count: -1}, []string{"d", "c", "b"}},
+		{"empty score range", ZRangeQuery{By: ZRangeByScore, Score: ScoreRange{Min: 3, Max: 2}, Count: -1}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.ZRange("z", tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if names := zmembers(got); !slices.Equal(names, tt.want) {
+				t.Errorf("ZRange = %q, want %q", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestZRangeByLex(t *testing.T) {
+	s := newTestStorage(t)
+	s.ZAdd("z", ZAddOptions{}, []ZMember{{"a", 0}, {"b", 0}, {"c", 0}, {"d", 0}})
+
+	tests := []struct {
+		lex  LexRange
+		want []string
+	}{
+		{LexRange{Min: LexBound{Inf: -1}, Max: LexBound{Inf: 1}}, []string{"a", "b", "c", "d"}},
+		{LexRange{Min: LexBound{Value: "b"}, Max: LexBound{Value: "c"}}, []string{"b", "c"}},
+		{LexRange{Min: LexBound{Value: "b", Exclusive: true}, Max: LexBound{Inf: 1}}, []string{"c", "d"}},
+		{LexRange{Min: LexBound{Inf: 1}, Max: LexBound{Inf: -1}}, nil},
+	}
+	for _, tt := range tests {
+		got, err := s.ZRange("z", ZRangeQuery{By: ZRangeByLex, Lex: tt.lex, Count: -1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := zmembers(got); !slices.Equal(names, tt.want) {
+			t.Errorf("ZRange(%+v) = %q, want %q", tt.lex, names, tt.want)
+		}
+	}
+}
+
+// TestSkiplistRanks сверяет ранги skiplist с отсортированным списком после
+// случайных вставок и удалений
+func TestSkiplistRanks(t *testing.T) {
+	s := newTestStorage(t)
+	scores := make(map[string]float64)
+	for i := range 2000 {
+		member := strconv.Itoa(rand.IntN(500))
+		if i%3 == 0 {
+			s.ZRem("z", []string{member})
+			delete(scores, member)
+			continue
+		}
+		score := float64(rand.IntN(50))
+		s.ZAdd("z", ZAddOptions{}, []ZMember{{member, score}})
+		scores[member] = score
+	}
+
+	want := make([]string, 0, len(scores))
+	for member := range scores {
+		want = append(want, member)
+	}
+	slices.SortFunc(want, func(a, b string) int {
+		if scores[a] != scores[b] {
+			if scores[a] < scores[b] {
+				return -1
+			}
+			return 1
+		}
+		if a < b {
+			return -1
+		}
+		return 1
+	})
+
+	got, _ := s.ZRange("z", ZRangeQuery{Start: 0, Stop: -1})
+	if names := zmembers(got); !slices.Equal(names, want) {
+		t.Fatalf("order differs from sorted members")
+	}
+	for i, member := range want {
+		if rank, found, _ := s.ZRank("z", member, false); !found || rank != i {
+			t.Fatalf("ZRank(%s) = %d, %v; want %d", member, rank, found, i)
+		}
+		if rank, _, _ := s.ZRank("z", member, true); rank != len(want)-1-i {
+			t.Fatalf("ZREVRANK(%s) = %d, want %d", member, rank, len(want)-1-i)
+		}
+	}
+}
+
+func TestZCountAndRemove(t *testing.T) {
+	s := newTestStorage(t)
+	s.ZAdd("z", ZAddOptions{}, []ZMember{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}})
+
+	if n, _ := s.ZCount("z", ScoreRange{Min: 2, Max: 3}); n != 2 {
+		t.Errorf("ZCount = %d, want 2", n)
+	}
+	if n, _ := s.ZCount("z", ScoreRange{Min: 5, Max: 9}); n != 0 {
+		t.Errorf("ZCount of empty range = %d", n)
+	}
+	if n, _ := s.ZRemRangeByScore("z", ScoreRange{Min: 2, Max: 3}); n != 2 {
+		t.Errorf("ZRemRangeByScore = %d, want 2", n)
+	}
+
+	popped, _ := s.ZPop("z", 1, true)
+	if names := zmembers(popped); !slices.Equal(names, []string{"d"}) {
+		t.Errorf("ZPOPMAX = %q", names)
+	}
+	if popped, _ := s.ZPop("z", math.MaxInt, false); len(popped) != 1 {
+		t.Errorf("ZPOPMIN of everything = %v", popped)
+	}
+	if s.Exists("z") {
+		t.Error("empty sorted set still exists")
+	}
+}
+
+func TestZCombineStore(t *testing.T) {
+	s := newTestStorage(t)
+	s.ZAdd("a", ZAddOptions{}, []ZMember{{"x", 1}, {"y", 2}})
+	s.ZAdd("b", ZAddOptions{}, []ZMember{{"y", 10}, {"z", math.Inf(1)}})
+	s.SAdd("set", []string{"y", "z"})
+	s.ZAdd("ninf", ZAddOptions{}, []ZMember{{"z", math.Inf(-1)}})
+
+	tests := []struct {
+		name    string
+		op      SetOp
+		keys    []string
+		weights []float64
+		agg     Aggregate
+		want    map[string]float64
+	}{
+		{"union sum", SetUnion, []string{"a", "b"}, nil, AggregateSum, map[string]float64{"x": 1, "y": 12, "z": math.Inf(1)}},
+		{"inter max", SetInter, []string{"a", "b"}, nil, AggregateMax, map[string]float64{"y": 10}},
+		{"weights", SetInter, []string{"a", "b"}, []float64{2, 0.5}, AggregateSum, map[string]float64{"y": 9}},
+		{"plain set scores 1", SetInter, []string{"a", "set"}, nil, AggregateSum, map[string]float64{"y": 3}},
+		{"inf plus -inf is 0", SetInter, []string{"b", "ninf"}, nil, AggregateSum, map[string]float64{"z": 0}},
+		{"inf times 0 is 0", SetUnion, []string{"b"}, []float64{0}, AggregateSum, map[string]float64{"y": 0, "z": 0}},
+		{"missing key", SetInter, []string{"a", "missing"}, nil, AggregateSum, map[string]float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := s.ZCombineStore(tt.op, "dst", tt.keys, tt.weights, tt.agg)
+			if err != nil || n != len(tt.want) {
+				t.Fatalf("ZCombineStore = %d, %v; want %d", n, err, len(tt.want))
+			}
+			for member, want := range tt.want {
+				if got, _, _ := s.ZScore("dst", member); got != want {
+					t.Errorf("score of %s = %v, want %v", member, got, want)
+				}
+			}
+			if len(tt.want) == 0 && s.Exists("dst") {
+				t.Error("empty result created destination")
+			}
+		})
+	}
+
+	s.Set("str", "v", 0)
+	if _, err := s.ZCombineStore(SetUnion, "dst", []string{"a", "str"}, nil, AggregateSum); !errors.Is(err, ErrWrongType) {
+		t.Errorf("string source err = %v, want ErrWrongType", err)
+	}
+}